@@ -1,44 +1,124 @@
 package main
 
 import (
+	"bank/pkg/api/model"
 	"bank/pkg/api/repositories"
 	"bank/pkg/api/service"
 	"bank/pkg/app"
+	"bank/pkg/config"
+	"context"
+	"flag"
 	"fmt"
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
-	"log"
 	"os"
+	"time"
 )
 
+var memory = flag.Bool("memory", false, "keep accounts in memory instead of using a database")
+
 func main() {
+	flag.Parse()
 	if err := run(); err != nil {
 		fmt.Fprintf(os.Stderr, "this is the startup error: %s\\n", err)
 		os.Exit(1)
 	}
 }
 
+type repos struct {
+	accounts  repositories.AccountRepository
+	scheduled repositories.ScheduledTransferRepository
+	orders    repositories.StandingOrderRepository
+	sanctions repositories.SanctionsRepository
+	webhooks  repositories.WebhookRepository
+}
+
 func run() error {
 	println("loading accountService...")
 
-	dsn := "test:test@tcp(db:3306)/bank"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	cfg, err := config.Load()
 	if err != nil {
 		return err
 	}
 
-	err = db.AutoMigrate(&repositories.AccountEntity{})
+	r, err := newRepositories(cfg.Database)
 	if err != nil {
-		log.Fatal("failed to load table")
+		return err
+	}
+	fees := model.FeeSchedule{}
+	if cfg.Fees.File != "" {
+		if fees, err = model.LoadFeeSchedule(cfg.Fees.File); err != nil {
+			return err
+		}
+	}
+	if err = service.EnsureRevenueAccount(context.Background(), r.accounts, cfg.Fees.RevenueAccountID); err != nil {
+		return err
 	}
 
-	dbRepo := repositories.NewDBRepository(db)
-	accountService := service.NewAccountService(dbRepo)
+	limits := model.Limits{}
+	if cfg.LimitsFile != "" {
+		if limits, err = model.LoadLimits(cfg.LimitsFile); err != nil {
+			return err
+		}
+	}
+
+	eventStream := service.NewEventStream(r.accounts, cfg.StreamPoll)
+	opts := []service.Option{
+		service.WithEventStream(eventStream),
+		service.WithFees(fees, cfg.Fees.RevenueAccountID),
+		service.WithLimits(limits),
+		service.WithLockStripes(cfg.Locks.Stripes, cfg.Locks.Queue),
+	}
+	if cfg.ScreeningFile != "" {
+		rules, sErr := model.LoadScreeningRules(cfg.ScreeningFile)
+		if sErr != nil {
+			return sErr
+		}
+		opts = append(opts, service.WithScreener(service.NewRulesScreener(r.accounts, rules, time.Now)))
+	}
+	var sanctionsService service.SanctionsService
+	if cfg.Sanctions.File != "" {
+		watchlist, wErr := model.LoadWatchlist(cfg.Sanctions.File)
+		if wErr != nil {
+			return wErr
+		}
+		sanctionsService = service.NewSanctionsService(r.sanctions, r.accounts, watchlist, cfg.Sanctions.Threshold, time.Now)
+		opts = append(opts, service.WithSanctions(sanctionsService))
+	}
+
+	accountService := service.NewAccountService(r.accounts, opts...)
+	scheduleService := service.NewScheduleService(r.scheduled, r.accounts, accountService, time.Now)
+	standingOrderService := service.NewStandingOrderService(r.orders, r.accounts, accountService, service.NewLogNotifier(), time.Now)
+	interestService := service.NewInterestService(r.accounts, accountService, model.DefaultProducts(), time.Now)
+	feeService := service.NewFeeService(r.accounts, accountService, fees, cfg.Fees.RevenueAccountID, time.Now)
+	holdService := service.NewHoldService(r.accounts, accountService, time.Now)
+	webhookService := service.NewWebhookService(r.webhooks, service.NewWebhookClient(cfg.Webhooks.Timeout), cfg.Webhooks.Retry, time.Now)
+	outboxRelay := service.NewOutboxRelay(r.accounts, service.NewBrokers(service.NewLogBroker(), webhookService), time.Now)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go service.RunPeriodically(ctx, "scheduled transfers", cfg.SchedulerInterval, scheduleService.ExecuteDue)
+	go service.RunPeriodically(ctx, "standing orders", cfg.SchedulerInterval, standingOrderService.ExecuteDue)
+	go service.RunPeriodically(ctx, "interest accrual", cfg.InterestInterval, interestService.Accrue)
+	go service.RunPeriodically(ctx, "maintenance fees", cfg.Fees.MaintenanceInterval, feeService.ChargeMaintenance)
+	go service.RunPeriodically(ctx, "hold expiry", cfg.HoldExpiryInterval, holdService.ExpireDue)
+	go service.RunPeriodically(ctx, "outbox relay", cfg.OutboxInterval, outboxRelay.Relay)
+	go service.RunPeriodically(ctx, "webhook deliveries", cfg.Webhooks.Interval, webhookService.Deliver)
 
 	router := gin.Default()
 
-	server := app.NewServer(router, accountService)
+	server := app.NewServer(router, accountService).
+		WithTimeouts(cfg.Timeouts).
+		WithScheduleService(scheduleService).
+		WithStandingOrderService(standingOrderService).
+		WithInterestService(interestService).
+		WithStatementService(service.NewStatementService(r.accounts, service.Bank{ID: cfg.Bank.ID, Currency: cfg.Bank.Currency}, time.Now)).
+		WithHoldService(holdService).
+		WithAuditService(service.NewAuditService(r.accounts)).
+		WithWebhookService(webhookService).
+		WithEventStream(eventStream)
+	if sanctionsService != nil {
+		server.WithSanctionsService(sanctionsService)
+	}
 	sErr := server.Run()
 	if err != nil {
 		return err
@@ -46,3 +126,44 @@ func run() error {
 
 	return sErr
 }
+
+func newRepositories(cfg config.Database) (repos, error) {
+	r, err := openRepositories(cfg)
+	if err != nil {
+		return repos{}, err
+	}
+	switch cfg.AccountStore {
+	case config.AccountStoreState:
+	case config.AccountStoreEvents:
+		r.accounts = repositories.NewEventSourcedRepository(r.accounts, cfg.SnapshotEvery, time.Now)
+	default:
+		return repos{}, fmt.Errorf("unknown account store %q", cfg.AccountStore)
+	}
+	return r, nil
+}
+
+func openRepositories(cfg config.Database) (repos, error) {
+	if *memory {
+		println("running with in-memory repository, data will not be persisted")
+		return repos{
+			accounts:  repositories.NewMemoryRepository(),
+			scheduled: repositories.NewMemoryScheduledRepository(),
+			orders:    repositories.NewMemoryStandingOrderRepository(),
+			sanctions: repositories.NewMemorySanctionsRepository(),
+			webhooks:  repositories.NewMemoryWebhookRepository(),
+		}, nil
+	}
+
+	db, err := repositories.OpenDB(cfg.Driver, cfg.DSN)
+	if err != nil {
+		return repos{}, err
+	}
+
+	return repos{
+		accounts:  repositories.NewDBRepository(db),
+		scheduled: repositories.NewDBScheduledRepository(db),
+		orders:    repositories.NewDBStandingOrderRepository(db),
+		sanctions: repositories.NewDBSanctionsRepository(db),
+		webhooks:  repositories.NewDBWebhookRepository(db),
+	}, nil
+}
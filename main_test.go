@@ -2,29 +2,27 @@ package main
 
 import (
 	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
 	"bank/pkg/api/repositories"
 	"bank/pkg/api/service"
 	"bank/pkg/app"
 	"bytes"
+	"context"
 	"encoding/json"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 )
 
 func TestCreateBankAccount(t *testing.T) {
-	db, err := setup()
-	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&repositories.AccountEntity{}))
+	repo := setup(t)
 
 	t.Run("Given a create bank account endpoint", func(t *testing.T) {
-		repo := repositories.NewDBRepository(db)
 		accountService := service.NewAccountService(repo)
 		router := gin.Default()
 		server := app.NewServer(router, accountService)
@@ -62,15 +60,12 @@ func TestCreateBankAccount(t *testing.T) {
 }
 
 func TestUpdateBankAccount(t *testing.T) {
-	db, err := setup()
-	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&repositories.AccountEntity{}))
+	repo := setup(t)
 
 	t.Run("Given an existing bank account endpoint", func(t *testing.T) {
-		existingAcc := repositories.AccountEntity{ID: uuid.New(), Name: "bill smith", Amount: 0.00}
-		require.NoError(t, db.Create(&existingAcc).Error)
+		existingAcc := model.Account{ID: uuid.New(), Name: "bill smith", Amount: 0.00}
+		require.NoError(t, repo.Create(context.Background(), &existingAcc))
 
-		repo := repositories.NewDBRepository(db)
 		accountService := service.NewAccountService(repo)
 		router := gin.Default()
 		server := app.NewServer(router, accountService)
@@ -93,19 +88,16 @@ func TestUpdateBankAccount(t *testing.T) {
 }
 
 func TestTransfer(t *testing.T) {
-	db, err := setup()
-	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&repositories.AccountEntity{}))
+	repo := setup(t)
 
 	t.Run("Given two existing accounts", func(t *testing.T) {
-		fromAccount := repositories.AccountEntity{ID: uuid.New(), Name: "billy smith", Amount: 100.00}
-		require.NoError(t, db.Create(&fromAccount).Error)
+		fromAccount := model.Account{ID: uuid.New(), Name: "billy smith", Amount: 100.00}
+		require.NoError(t, repo.Create(context.Background(), &fromAccount))
 
-		toAccount := repositories.AccountEntity{ID: uuid.New(), Name: "jhon smith", Amount: 100.00}
-		require.NoError(t, db.Create(&toAccount).Error)
+		toAccount := model.Account{ID: uuid.New(), Name: "jhon smith", Amount: 100.00}
+		require.NoError(t, repo.Create(context.Background(), &toAccount))
 
 		t.Run("And a transfer service api", func(t *testing.T) {
-			repo := repositories.NewDBRepository(db)
 			accountService := service.NewAccountService(repo)
 			router := gin.Default()
 			server := app.NewServer(router, accountService)
@@ -125,11 +117,10 @@ func TestTransfer(t *testing.T) {
 
 				t.Run("Then transaction fails", func(t *testing.T) {
 					assert.Equal(t, http.StatusInternalServerError, w.Code)
-					var fromCurrent repositories.AccountEntity
-					var toCurrent repositories.AccountEntity
-
-					require.NoError(t, db.Find(&fromCurrent, fromAccount.ID).Error)
-					require.NoError(t, db.Find(&toCurrent, toAccount.ID).Error)
+					fromCurrent, fErr := repo.Get(context.Background(), fromAccount.ID)
+					require.NoError(t, fErr)
+					toCurrent, tErr := repo.Get(context.Background(), toAccount.ID)
+					require.NoError(t, tErr)
 
 					assert.Equal(t, fromCurrent.Amount, fromAccount.Amount)
 					assert.Equal(t, toCurrent.Amount, toAccount.Amount)
@@ -151,11 +142,10 @@ func TestTransfer(t *testing.T) {
 				t.Run("Then transaction fails", func(t *testing.T) {
 					assert.Equal(t, http.StatusAccepted, w.Code)
 
-					var fromCurrent repositories.AccountEntity
-					var toCurrent repositories.AccountEntity
-
-					require.NoError(t, db.Find(&fromCurrent, fromAccount.ID).Error)
-					require.NoError(t, db.Find(&toCurrent, toAccount.ID).Error)
+					fromCurrent, fErr := repo.Get(context.Background(), fromAccount.ID)
+					require.NoError(t, fErr)
+					toCurrent, tErr := repo.Get(context.Background(), toAccount.ID)
+					require.NoError(t, tErr)
 
 					assert.Equal(t, fromCurrent.Amount, fromAccount.Amount-50.00)
 					assert.Equal(t, toCurrent.Amount, toAccount.Amount+50.00)
@@ -165,7 +155,14 @@ func TestTransfer(t *testing.T) {
 	})
 }
 
-func setup() (*gorm.DB, error) {
-	dsn := "test:test@tcp(localhost:3306)/bank"
-	return gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// setup returns the in-memory repository unless TEST_DB_DRIVER selects a
+// database (e.g. sqlite, which needs no external service)
+func setup(t *testing.T) repositories.AccountRepository {
+	driver := os.Getenv("TEST_DB_DRIVER")
+	if driver == "" {
+		return repositories.NewMemoryRepository()
+	}
+	db, err := repositories.OpenDB(driver, os.Getenv("TEST_DB_DSN"))
+	require.NoError(t, err)
+	return repositories.NewDBRepository(db)
 }
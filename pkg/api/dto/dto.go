@@ -1,16 +1,26 @@
 package dto
 
-import "github.com/google/uuid"
+import (
+	"encoding/json"
+	"github.com/google/uuid"
+	"time"
+)
 
 type CreateAccountRequest struct {
 	Name   string  `json:"name" binding:"required,min=3"`
 	Amount float64 `json:"amount" binding:"required"`
+	// Product defaults to current
+	Product string `json:"product"`
+	// CustomerID groups the accounts of the same customer
+	CustomerID string `json:"customer_id" binding:"max=64"`
 }
 
 type CreateAccountResponse struct {
-	ID     uuid.UUID
-	Name   string
-	Amount float64
+	ID         uuid.UUID
+	Name       string
+	CustomerID string `json:",omitempty"`
+	Amount     float64
+	Product    string
 }
 type UpdateAccountRequest struct {
 	Amount float64 `json:"amount" binding:"required"`
@@ -25,14 +35,391 @@ type TransferenceRequest struct {
 	From   uuid.UUID `json:"from" binding:"required"`
 	To     uuid.UUID `json:"to" binding:"required"`
 	Amount float64   `json:"amount" binding:"required"`
+	// ExecuteAt schedules the transfer instead of executing it right away
+	ExecuteAt *time.Time `json:"execute_at"`
+	// Memo is a free text reference kept with the transfer
+	Memo string `json:"memo" binding:"max=140"`
 }
 
 type GetAccountResponse struct {
-	ID     uuid.UUID
-	Name   string
-	Amount float64
+	ID         uuid.UUID
+	Name       string
+	CustomerID string `json:",omitempty"`
+	Amount     float64
+	// Held is the part of Amount reserved by active holds
+	Held float64
+	// Available is the part of Amount that can be spent
+	Available       float64
+	Product         string
+	AccruedInterest float64
 }
 
 type GetAllAccountResponse struct {
 	Accounts []GetAccountResponse
 }
+
+type ScheduledTransferResponse struct {
+	ID            uuid.UUID
+	From          uuid.UUID
+	To            uuid.UUID
+	Amount        float64
+	ExecuteAt     time.Time
+	Status        string
+	FailureReason string
+}
+
+type GetScheduledTransfersResponse struct {
+	Transfers []ScheduledTransferResponse
+}
+
+type CreateStandingOrderRequest struct {
+	From      uuid.UUID  `json:"from" binding:"required"`
+	To        uuid.UUID  `json:"to" binding:"required"`
+	Amount    float64    `json:"amount" binding:"required"`
+	Frequency string     `json:"frequency" binding:"required,oneof=daily weekly monthly last_business_day"`
+	StartAt   time.Time  `json:"start_at" binding:"required"`
+	EndAt     *time.Time `json:"end_at"`
+	Count     int        `json:"count" binding:"min=0"`
+	// OnFailure is one of retry, skip or notify. Defaults to skip.
+	OnFailure  string `json:"on_failure" binding:"omitempty,oneof=retry skip notify"`
+	MaxRetries int    `json:"max_retries" binding:"min=0"`
+}
+
+type StandingOrderResponse struct {
+	ID          uuid.UUID
+	From        uuid.UUID
+	To          uuid.UUID
+	Amount      float64
+	Frequency   string
+	StartAt     time.Time
+	EndAt       *time.Time
+	Count       int
+	OnFailure   string
+	MaxRetries  int
+	Status      string
+	Occurrences int
+	NextRunAt   *time.Time
+}
+
+type GetStandingOrdersResponse struct {
+	Orders []StandingOrderResponse
+}
+
+type StandingOrderRunResponse struct {
+	Occurrence    int
+	ScheduledFor  time.Time
+	ExecutedAt    time.Time
+	Attempt       int
+	Status        string
+	FailureReason string
+}
+
+type GetStandingOrderRunsResponse struct {
+	Runs []StandingOrderRunResponse
+}
+
+type InterestAccrualResponse struct {
+	Date    time.Time
+	Balance float64
+	Rate    float64
+	Amount  float64
+}
+
+type GetInterestResponse struct {
+	AccountID       uuid.UUID
+	Product         string
+	InterestRate    float64
+	AccruedInterest float64
+	Accruals        []InterestAccrualResponse
+}
+
+type FeeResponse struct {
+	Name   string
+	Amount float64
+}
+
+type TransferResponse struct {
+	ID     uuid.UUID
+	From   uuid.UUID
+	To     uuid.UUID
+	Amount float64
+	// Fee is the total charged to From on top of Amount
+	Fee  float64
+	Fees []FeeResponse
+	// ReversalOf links a reversal to the transfer it gives back
+	ReversalOf *uuid.UUID `json:",omitempty"`
+	// Reversed is the part of Amount already given back
+	Reversed      float64
+	Status        string
+	FailureReason string `json:",omitempty"`
+	// ScreeningReason tells which fraud rules held or denied the transfer
+	ScreeningReason string `json:",omitempty"`
+	Memo            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	// Legs of a split transfer, debits are negative
+	Legs []TransferLegResponse `json:",omitempty"`
+}
+
+type TransferLegResponse struct {
+	AccountID uuid.UUID
+	Amount    float64
+}
+
+type SplitTransferRequest struct {
+	Debits  []SplitLeg `json:"debits" binding:"required,min=1,max=100,dive"`
+	Credits []SplitLeg `json:"credits" binding:"required,min=1,max=100,dive"`
+	Memo    string     `json:"memo" binding:"max=140"`
+}
+
+type SplitLeg struct {
+	Account uuid.UUID `json:"account" binding:"required"`
+	Amount  float64   `json:"amount" binding:"required,gt=0"`
+}
+
+type GetTransfersResponse struct {
+	Transfers []TransferResponse
+}
+
+type BatchTransferRequest struct {
+	// Mode is atomic, all transfers or none, or best_effort, every transfer
+	// on its own. Defaults to atomic.
+	Mode      string             `json:"mode" binding:"omitempty,oneof=atomic best_effort"`
+	Transfers []BatchTransferLeg `json:"transfers" binding:"required,min=1,max=1000,dive"`
+}
+
+type BatchTransferLeg struct {
+	From   uuid.UUID `json:"from" binding:"required"`
+	To     uuid.UUID `json:"to" binding:"required"`
+	Amount float64   `json:"amount" binding:"required"`
+	Memo   string    `json:"memo" binding:"max=140"`
+}
+
+type BatchTransferResult struct {
+	// Index of the transfer in the request
+	Index    int
+	Transfer *TransferResponse `json:",omitempty"`
+	Error    string            `json:",omitempty"`
+}
+
+type BatchTransferResponse struct {
+	Mode      string
+	Succeeded int
+	Failed    int
+	// InReview counts the transfers held by screening, best effort mode only
+	InReview int
+	Results  []BatchTransferResult
+}
+
+type RejectTransferRequest struct {
+	Reason string `json:"reason" binding:"max=140"`
+}
+
+type ReversalRequest struct {
+	// Amount defaults to everything not yet reversed
+	Amount float64 `json:"amount" binding:"min=0"`
+	Memo   string  `json:"memo"`
+}
+
+type TransactionResponse struct {
+	ID          uuid.UUID
+	Type        string
+	Amount      float64
+	Balance     float64
+	Description string
+	CreatedAt   time.Time
+}
+
+type GetTransactionsResponse struct {
+	Transactions []TransactionResponse
+}
+
+// MoneyMovedEvent is the payload of the events telling money came in or out of
+// an account outside of transfers
+type MoneyMovedEvent struct {
+	AccountID   uuid.UUID
+	EntryID     uuid.UUID
+	Amount      float64
+	Balance     float64
+	Description string
+}
+
+type PlaceHoldRequest struct {
+	To     uuid.UUID `json:"to" binding:"required"`
+	Amount float64   `json:"amount" binding:"required"`
+	// ExpiresAt defaults to a week from now
+	ExpiresAt *time.Time `json:"expires_at"`
+	Reference string     `json:"reference"`
+}
+
+type CaptureHoldRequest struct {
+	// Amount defaults to the whole hold
+	Amount float64 `json:"amount" binding:"min=0"`
+}
+
+type HoldResponse struct {
+	ID        uuid.UUID
+	AccountID uuid.UUID
+	To        uuid.UUID
+	Amount    float64
+	Captured  float64
+	Status    string
+	Reference string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
+
+type GetHoldsResponse struct {
+	Holds []HoldResponse
+}
+
+type SanctionsMatchResponse struct {
+	EntryID string
+	List    string
+	Name    string
+	Score   float64
+	Action  string
+}
+
+type SanctionsCheckResponse struct {
+	ID        uuid.UUID
+	Subject   string
+	SubjectID uuid.UUID
+	Name      string
+	Decision  string
+	Matches   []SanctionsMatchResponse
+	CreatedAt time.Time
+}
+
+type GetSanctionsChecksResponse struct {
+	Checks []SanctionsCheckResponse
+}
+
+type AuditRecordResponse struct {
+	Sequence   int64
+	ID         uuid.UUID
+	Action     string
+	Actor      string
+	RequestID  string
+	SourceIP   string
+	EntityType string
+	EntityID   uuid.UUID
+	// Before and After hold the state of the entity, Before is empty for
+	// creations
+	Before    json.RawMessage `json:",omitempty"`
+	After     json.RawMessage `json:",omitempty"`
+	CreatedAt time.Time
+	PrevHash  string
+	Hash      string
+}
+
+type GetAuditRecordsResponse struct {
+	Records []AuditRecordResponse
+}
+
+type AuditVerificationResponse struct {
+	Valid   bool
+	Records int
+	// BrokenAt is the sequence of the first tampered record
+	BrokenAt int64  `json:",omitempty"`
+	Reason   string `json:",omitempty"`
+}
+
+type CreateWebhookRequest struct {
+	URL string `json:"url" binding:"required"`
+	// EventTypes narrow the events delivered, empty means all
+	EventTypes []string `json:"event_types"`
+	// Accounts are the accounts whose events are delivered, AllAccounts
+	// must be set instead to get the events of every account
+	Accounts    []uuid.UUID `json:"accounts"`
+	AllAccounts bool        `json:"all_accounts"`
+}
+
+type WebhookResponse struct {
+	ID  uuid.UUID
+	URL string
+	// Secret is only returned when the webhook is created
+	Secret      string `json:",omitempty"`
+	EventTypes  []string
+	Accounts    []uuid.UUID
+	AllAccounts bool
+	CreatedAt   time.Time
+}
+
+type GetWebhooksResponse struct {
+	Webhooks []WebhookResponse
+}
+
+type WebhookDeliveryResponse struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      string
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastStatusCode int
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type GetWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDeliveryResponse
+}
+
+// EventMessage is a domain event as posted to webhooks and streamed to
+// accounts, Data is the payload of the event
+type EventMessage struct {
+	ID          uuid.UUID
+	Type        string
+	AggregateID uuid.UUID
+	Accounts    []uuid.UUID
+	OccurredAt  time.Time
+	Data        json.RawMessage
+}
+
+// AccountStreamMessage is sent on the event stream of an account, either an
+// event concerning it or its balance once the events so far are sent
+type AccountStreamMessage struct {
+	// Sequence of the event, the stream resumes after it
+	Sequence int64
+	Event    *EventMessage
+	Balance  *GetAccountResponse
+}
+
+// LockStatsResponse tells how often operations waited on each other for
+// accounts since the service started
+type LockStatsResponse struct {
+	// Acquired is how many operations got their accounts
+	Acquired int64
+	// Contended is how many operations waited for another one first, whether
+	// they got their accounts or gave up
+	Contended int64
+	// Refused is how many were refused because too many already waited
+	Refused int64
+	// WaitedSeconds is the time spent waiting by the contended ones
+	WaitedSeconds float64
+}
+
+// StatementResponse sums up the transactions of an account over a period of
+// days, From and To included
+type StatementResponse struct {
+	AccountID  uuid.UUID
+	Name       string
+	CustomerID string `json:",omitempty"`
+	Product    string
+	// Currency of every amount, as an ISO 4217 code
+	Currency string
+	// BankID identifies the bank keeping the account
+	BankID         string `json:",omitempty"`
+	From           time.Time
+	To             time.Time
+	OpeningBalance float64
+	ClosingBalance float64
+	TotalCredits   float64
+	TotalDebits    float64
+	Transactions   []TransactionResponse
+	GeneratedAt    time.Time
+}
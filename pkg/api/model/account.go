@@ -3,12 +3,35 @@ package model
 import (
 	"errors"
 	"github.com/google/uuid"
+	"time"
 )
 
 type Account struct {
-	ID     uuid.UUID
-	Name   string
+	ID   uuid.UUID
+	Name string
+	// CustomerID groups the accounts of the same customer, it is optional
+	CustomerID string
+	// Amount is the ledger balance, money held is still part of it
 	Amount float64
+	// Held is the money reserved by active holds
+	Held float64
+	// Product code of the account, it decides the interest it earns
+	Product string
+	// AccruedInterest not yet credited to Amount
+	AccruedInterest float64
+	// AccruedThrough is the last day interest has been accrued for
+	AccruedThrough *time.Time
+	// MaintenanceChargedAt is the first day of the last month the
+	// maintenance fee was charged for
+	MaintenanceChargedAt *time.Time
+	// Version is the last event of the account when it is event sourced, zero
+	// otherwise. Updates of an account read at an older version are refused.
+	Version int64
+}
+
+// Available returns the money that can be spent, the balance not held
+func (a *Account) Available() float64 {
+	return a.Amount - a.Held
 }
 
 func (a *Account) AddMoney(amount float64) error {
@@ -20,9 +43,29 @@ func (a *Account) AddMoney(amount float64) error {
 }
 
 func (a *Account) Withdraw(amount float64) error {
-	if a.Amount < amount {
+	if a.Available() < amount {
 		return errors.New("not enough balance")
 	}
 	a.Amount = a.Amount - amount
 	return nil
 }
+
+// Hold reserves amount of the available balance
+func (a *Account) Hold(amount float64) error {
+	if amount <= 0 {
+		return errors.New("not valid amount")
+	}
+	if a.Available() < amount {
+		return errors.New("not enough balance")
+	}
+	a.Held = a.Held + amount
+	return nil
+}
+
+// ReleaseHold gives back amount previously held
+func (a *Account) ReleaseHold(amount float64) {
+	a.Held = a.Held - amount
+	if a.Held < 0 {
+		a.Held = 0
+	}
+}
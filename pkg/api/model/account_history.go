@@ -0,0 +1,195 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+type AccountEventType string
+
+const (
+	AccountOpened         AccountEventType = "AccountOpened"
+	AccountDetailsChanged AccountEventType = "AccountDetailsChanged"
+	BalanceChanged        AccountEventType = "BalanceChanged"
+	HeldChanged           AccountEventType = "HeldChanged"
+	InterestAccrued       AccountEventType = "InterestAccrued"
+	MaintenanceCharged    AccountEventType = "MaintenanceCharged"
+)
+
+// AccountEvent is a change in the event stream of an account. Unlike Event,
+// which tells other systems what happened, account events are the account:
+// replaying them rebuilds it.
+type AccountEvent struct {
+	AccountID uuid.UUID
+	// Version is the position of the event in the stream, the first one is 1
+	Version int64
+	Type    AccountEventType
+	// Data is the json of the values the event sets
+	Data       string
+	OccurredAt time.Time
+}
+
+// AccountSnapshot is an account as it was at Version, so rebuilding it only
+// replays the events after it
+type AccountSnapshot struct {
+	Account Account
+	Version int64
+	TakenAt time.Time
+}
+
+type accountDetails struct {
+	Name       string
+	CustomerID string
+	Product    string
+}
+
+type balanceChange struct {
+	Amount float64
+	// Delta is informative, Amount is replayed so rounding never adds up
+	Delta float64
+}
+
+type heldChange struct {
+	Held float64
+}
+
+type interestAccrual struct {
+	AccruedInterest float64
+	AccruedThrough  *time.Time
+}
+
+type maintenanceCharge struct {
+	ChargedAt *time.Time
+}
+
+// AccountAggregate is an account rebuilt from its event stream
+type AccountAggregate struct {
+	Account Account
+	// Version is the last event applied, SnapshotVersion the one of the
+	// snapshot it was rebuilt from
+	Version         int64
+	SnapshotVersion int64
+}
+
+// NewAccountAggregate rebuilds an account from its latest snapshot, nil when
+// there is none, and the events after it
+func NewAccountAggregate(snapshot *AccountSnapshot, events []*AccountEvent) (*AccountAggregate, error) {
+	a := &AccountAggregate{}
+	if snapshot != nil {
+		a.Account = snapshot.Account
+		a.Version = snapshot.Version
+		a.SnapshotVersion = snapshot.Version
+	}
+	for _, event := range events {
+		if err := a.Apply(event); err != nil {
+			return nil, err
+		}
+	}
+	a.Account.Version = a.Version
+	return a, nil
+}
+
+// Apply sets what event changed, it must be the next one of the stream
+func (a *AccountAggregate) Apply(event *AccountEvent) error {
+	if event.Version != a.Version+1 {
+		return fmt.Errorf("event %d of account %s applied at version %d", event.Version, event.AccountID, a.Version)
+	}
+
+	var err error
+	switch event.Type {
+	case AccountOpened:
+		err = json.Unmarshal([]byte(event.Data), &a.Account)
+	case AccountDetailsChanged:
+		var data accountDetails
+		if err = json.Unmarshal([]byte(event.Data), &data); err == nil {
+			a.Account.Name, a.Account.CustomerID, a.Account.Product = data.Name, data.CustomerID, data.Product
+		}
+	case BalanceChanged:
+		var data balanceChange
+		if err = json.Unmarshal([]byte(event.Data), &data); err == nil {
+			a.Account.Amount = data.Amount
+		}
+	case HeldChanged:
+		var data heldChange
+		if err = json.Unmarshal([]byte(event.Data), &data); err == nil {
+			a.Account.Held = data.Held
+		}
+	case InterestAccrued:
+		var data interestAccrual
+		if err = json.Unmarshal([]byte(event.Data), &data); err == nil {
+			a.Account.AccruedInterest, a.Account.AccruedThrough = data.AccruedInterest, data.AccruedThrough
+		}
+	case MaintenanceCharged:
+		var data maintenanceCharge
+		if err = json.Unmarshal([]byte(event.Data), &data); err == nil {
+			a.Account.MaintenanceChargedAt = data.ChargedAt
+		}
+	default:
+		err = fmt.Errorf("unknown account event %s", event.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	a.Version = event.Version
+	a.Account.Version = event.Version
+	return nil
+}
+
+// Open returns the first event of the stream of account, applied
+func (a *AccountAggregate) Open(account *Account, at time.Time) *AccountEvent {
+	opened := *account
+	opened.Version = 0
+	return a.record(account.ID, AccountOpened, opened, at)
+}
+
+// Record returns the events turning the account into updated, applied
+func (a *AccountAggregate) Record(updated *Account, at time.Time) []*AccountEvent {
+	current := a.Account
+	var events []*AccountEvent
+	if updated.Name != current.Name || updated.CustomerID != current.CustomerID || updated.Product != current.Product {
+		events = append(events, a.record(updated.ID, AccountDetailsChanged, accountDetails{Name: updated.Name, CustomerID: updated.CustomerID, Product: updated.Product}, at))
+	}
+	if updated.Amount != current.Amount {
+		events = append(events, a.record(updated.ID, BalanceChanged, balanceChange{Amount: updated.Amount, Delta: updated.Amount - current.Amount}, at))
+	}
+	if updated.Held != current.Held {
+		events = append(events, a.record(updated.ID, HeldChanged, heldChange{Held: updated.Held}, at))
+	}
+	if updated.AccruedInterest != current.AccruedInterest || !sameTime(updated.AccruedThrough, current.AccruedThrough) {
+		events = append(events, a.record(updated.ID, InterestAccrued, interestAccrual{AccruedInterest: updated.AccruedInterest, AccruedThrough: updated.AccruedThrough}, at))
+	}
+	if !sameTime(updated.MaintenanceChargedAt, current.MaintenanceChargedAt) {
+		events = append(events, a.record(updated.ID, MaintenanceCharged, maintenanceCharge{ChargedAt: updated.MaintenanceChargedAt}, at))
+	}
+	return events
+}
+
+// Snapshot returns the account as it is now
+func (a *AccountAggregate) Snapshot(at time.Time) *AccountSnapshot {
+	return &AccountSnapshot{Account: a.Account, Version: a.Version, TakenAt: at}
+}
+
+func (a *AccountAggregate) record(accountID uuid.UUID, eventType AccountEventType, data interface{}, at time.Time) *AccountEvent {
+	// the data are plain structs, they always marshal
+	content, _ := json.Marshal(data)
+	event := &AccountEvent{
+		AccountID:  accountID,
+		Version:    a.Version + 1,
+		Type:       eventType,
+		Data:       string(content),
+		OccurredAt: at,
+	}
+	// events made here always apply
+	_ = a.Apply(event)
+	return event
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
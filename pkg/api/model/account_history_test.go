@@ -0,0 +1,63 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestAccountAggregate(t *testing.T) {
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	day := time.Date(2022, 8, 31, 0, 0, 0, 0, time.UTC)
+	account := &model.Account{ID: uuid.New(), Name: "bill smith", Amount: 0.10, Product: model.ProductCurrent}
+
+	recorder := &model.AccountAggregate{}
+	history := []*model.AccountEvent{recorder.Open(account, now)}
+
+	t.Run("Given an account changed several times", func(t *testing.T) {
+		updated := *account
+		updated.Amount += 0.20
+		history = append(history, recorder.Record(&updated, now)...)
+		updated.Held = 0.10
+		updated.AccruedInterest = 0.01
+		updated.AccruedThrough = &day
+		history = append(history, recorder.Record(&updated, now)...)
+		unchanged := recorder.Record(&updated, now)
+
+		t.Run("Then only what changed is recorded", func(t *testing.T) {
+			assert.Empty(t, unchanged)
+			types := make([]model.AccountEventType, len(history))
+			for i, event := range history {
+				types[i] = event.Type
+				assert.Equal(t, int64(i+1), event.Version)
+			}
+			assert.Equal(t, []model.AccountEventType{model.AccountOpened, model.BalanceChanged, model.HeldChanged, model.InterestAccrued}, types)
+		})
+
+		t.Run("Then replaying the events rebuilds it exactly", func(t *testing.T) {
+			rebuilt, err := model.NewAccountAggregate(nil, history)
+			require.NoError(t, err)
+			assert.Equal(t, updated.Amount, rebuilt.Account.Amount)
+			assert.Equal(t, 0.10, rebuilt.Account.Held)
+			assert.True(t, day.Equal(*rebuilt.Account.AccruedThrough))
+			assert.Equal(t, int64(4), rebuilt.Account.Version)
+		})
+
+		t.Run("Then a snapshot replaces the events before it", func(t *testing.T) {
+			snapshotted, err := model.NewAccountAggregate(nil, history[:2])
+			require.NoError(t, err)
+			rebuilt, rErr := model.NewAccountAggregate(snapshotted.Snapshot(now), history[2:])
+			require.NoError(t, rErr)
+			assert.Equal(t, int64(2), rebuilt.SnapshotVersion)
+			assert.Equal(t, recorder.Account, rebuilt.Account)
+		})
+
+		t.Run("Then events out of order are refused", func(t *testing.T) {
+			_, err := model.NewAccountAggregate(nil, []*model.AccountEvent{history[0], history[2]})
+			assert.Error(t, err)
+		})
+	})
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type AuditAction string
+
+const (
+	AuditAccountCreated         AuditAction = "account.created"
+	AuditDeposit                AuditAction = "account.deposit"
+	AuditWithdrawal             AuditAction = "account.withdrawal"
+	AuditTransfer               AuditAction = "transfer.created"
+	AuditBatchTransfer          AuditAction = "transfer.batch"
+	AuditSplitTransfer          AuditAction = "transfer.split"
+	AuditReversal               AuditAction = "transfer.reversed"
+	AuditTransferApproved       AuditAction = "transfer.approved"
+	AuditTransferRejected       AuditAction = "transfer.rejected"
+	AuditScheduledCreated       AuditAction = "scheduled_transfer.created"
+	AuditScheduledCancelled     AuditAction = "scheduled_transfer.cancelled"
+	AuditStandingOrderCreated   AuditAction = "standing_order.created"
+	AuditStandingOrderCancelled AuditAction = "standing_order.cancelled"
+	AuditHoldPlaced             AuditAction = "hold.placed"
+	AuditHoldCaptured           AuditAction = "hold.captured"
+	AuditHoldReleased           AuditAction = "hold.released"
+	AuditHoldExpired            AuditAction = "hold.expired"
+	AuditInterest               AuditAction = "account.interest"
+	AuditMaintenanceFee         AuditAction = "account.maintenance_fee"
+)
+
+// SystemActor is the actor of operations started by the bank itself, such as
+// scheduled jobs
+const SystemActor = "system"
+
+// Actor tells who asked for an operation and from where
+type Actor struct {
+	ID        string
+	RequestID string
+	SourceIP  string
+}
+
+// AuditRecord is an entry of the append only audit trail. Each record is
+// chained to the previous one by hashing its content together with the hash
+// of the previous record, so changing or removing a stored record breaks
+// every hash after it. Before and After hold the state of the entity as json,
+// Before is empty for creations.
+type AuditRecord struct {
+	// Sequence orders the trail, it starts at 1 and has no gaps
+	Sequence   int64
+	ID         uuid.UUID
+	Action     AuditAction
+	Actor      string
+	RequestID  string
+	SourceIP   string
+	EntityType string
+	EntityID   uuid.UUID
+	Before     string
+	After      string
+	CreatedAt  time.Time
+	PrevHash   string
+	Hash       string
+}
+
+// Chain places the record after the one with sequence and hash prev, an empty
+// prev for the first record, and computes its hash
+func (r *AuditRecord) Chain(sequence int64, prev string) {
+	r.Sequence = sequence
+	r.PrevHash = prev
+	r.Hash = r.ComputeHash()
+}
+
+// ComputeHash returns the hash the record should have from its content.
+// CreatedAt is taken to the millisecond, the precision every database keeps.
+func (r *AuditRecord) ComputeHash() string {
+	fields := []string{
+		strconv.FormatInt(r.Sequence, 10),
+		r.ID.String(),
+		string(r.Action),
+		r.Actor,
+		r.RequestID,
+		r.SourceIP,
+		r.EntityType,
+		r.EntityID.String(),
+		r.Before,
+		r.After,
+		strconv.FormatInt(r.CreatedAt.UnixMilli(), 10),
+		r.PrevHash,
+	}
+	// lengths go first so moving content between fields changes the hash
+	var b strings.Builder
+	for _, field := range fields {
+		fmt.Fprintf(&b, "%d:%s|", len(field), field)
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// AuditVerification is the outcome of checking the hash chain of the trail
+type AuditVerification struct {
+	Valid   bool
+	Records int
+	// BrokenAt is the sequence of the first record not matching the chain
+	BrokenAt int64
+	Reason   string
+}
+
+// VerifyAuditChain checks records, the whole trail ordered by sequence, were
+// not changed, removed or reordered since they were written
+func VerifyAuditChain(records []*AuditRecord) AuditVerification {
+	prev := ""
+	for i, record := range records {
+		broken := func(reason string) AuditVerification {
+			return AuditVerification{Records: len(records), BrokenAt: record.Sequence, Reason: reason}
+		}
+		switch {
+		case record.Sequence != int64(i+1):
+			return broken("sequence gap")
+		case record.PrevHash != prev:
+			return broken("previous hash mismatch")
+		case record.Hash != record.ComputeHash():
+			return broken("content does not match its hash")
+		}
+		prev = record.Hash
+	}
+	return AuditVerification{Valid: true, Records: len(records)}
+}
@@ -0,0 +1,64 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestVerifyAuditChain(t *testing.T) {
+	trail := func() []*model.AuditRecord {
+		now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+		records := make([]*model.AuditRecord, 3)
+		prev := ""
+		for i := range records {
+			records[i] = &model.AuditRecord{
+				ID:         uuid.New(),
+				Action:     model.AuditDeposit,
+				Actor:      "teller",
+				EntityType: "account",
+				EntityID:   uuid.New(),
+				Before:     `{"Amount":10}`,
+				After:      `{"Amount":20}`,
+				CreatedAt:  now.Add(time.Duration(i) * time.Minute),
+			}
+			records[i].Chain(int64(i+1), prev)
+			prev = records[i].Hash
+		}
+		return records
+	}
+
+	t.Run("an untouched trail is valid", func(t *testing.T) {
+		assert.Equal(t, model.AuditVerification{Valid: true, Records: 3}, model.VerifyAuditChain(trail()))
+	})
+
+	t.Run("an empty trail is valid", func(t *testing.T) {
+		assert.True(t, model.VerifyAuditChain(nil).Valid)
+	})
+
+	t.Run("a changed record breaks the chain", func(t *testing.T) {
+		records := trail()
+		records[1].After = `{"Amount":2000}`
+		verification := model.VerifyAuditChain(records)
+		assert.False(t, verification.Valid)
+		assert.Equal(t, int64(2), verification.BrokenAt)
+	})
+
+	t.Run("a changed record with its hash recomputed breaks the next link", func(t *testing.T) {
+		records := trail()
+		records[1].Actor = "someone else"
+		records[1].Hash = records[1].ComputeHash()
+		verification := model.VerifyAuditChain(records)
+		assert.False(t, verification.Valid)
+		assert.Equal(t, int64(3), verification.BrokenAt)
+	})
+
+	t.Run("a removed record breaks the chain", func(t *testing.T) {
+		records := trail()
+		verification := model.VerifyAuditChain(append(records[:1], records[2:]...))
+		assert.False(t, verification.Valid)
+		assert.Equal(t, int64(3), verification.BrokenAt)
+	})
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventAccountCreated        EventType = "AccountCreated"
+	EventMoneyDeposited        EventType = "MoneyDeposited"
+	EventMoneyWithdrawn        EventType = "MoneyWithdrawn"
+	EventTransferCompleted     EventType = "TransferCompleted"
+	EventTransferFailed        EventType = "TransferFailed"
+	EventTransferHeldForReview EventType = "TransferHeldForReview"
+	EventTransferReversed      EventType = "TransferReversed"
+	EventHoldPlaced            EventType = "HoldPlaced"
+	EventHoldCaptured          EventType = "HoldCaptured"
+	EventHoldReleased          EventType = "HoldReleased"
+	EventHoldExpired           EventType = "HoldExpired"
+	EventInterestCredited      EventType = "InterestCredited"
+	EventFeeCharged            EventType = "FeeCharged"
+)
+
+var eventTypes = map[EventType]bool{
+	EventAccountCreated: true, EventMoneyDeposited: true, EventMoneyWithdrawn: true,
+	EventTransferCompleted: true, EventTransferFailed: true, EventTransferHeldForReview: true, EventTransferReversed: true,
+	EventHoldPlaced: true, EventHoldCaptured: true, EventHoldReleased: true, EventHoldExpired: true,
+	EventInterestCredited: true, EventFeeCharged: true,
+}
+
+// Valid tells if t is a known event type
+func (t EventType) Valid() bool {
+	return eventTypes[t]
+}
+
+// Event is a domain event telling something happened to an account. Events
+// are written to the outbox in the same transaction as the change they tell
+// about and published from there, so none is lost nor published for a change
+// that was rolled back.
+type Event struct {
+	// Sequence orders the outbox, it is set once the event is stored
+	Sequence int64
+	ID       uuid.UUID
+	Type     EventType
+	// AggregateID is the account, transfer or hold the event is about
+	AggregateID uuid.UUID
+	// Accounts are the accounts the event concerns, such as both sides of a
+	// transfer
+	Accounts []uuid.UUID
+	// Payload is the json of the entity the event is about
+	Payload    string
+	OccurredAt time.Time
+	// PublishedAt is nil until the event is delivered to the broker
+	PublishedAt *time.Time
+	// Attempts counts the failed deliveries, LastError tells why the last one
+	// failed
+	Attempts  int
+	LastError string
+}
@@ -0,0 +1,126 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"sort"
+)
+
+type FeeEvent string
+
+const (
+	FeeOnTransfer    FeeEvent = "transfer"
+	FeeOnMaintenance FeeEvent = "maintenance"
+)
+
+type FeeType string
+
+const (
+	FeeFlat       FeeType = "flat"
+	FeePercentage FeeType = "percentage"
+	FeeTiered     FeeType = "tiered"
+)
+
+// FeeTier applies to amounts up to UpTo, zero meaning no upper bound
+type FeeTier struct {
+	UpTo       float64 `json:"up_to"`
+	Flat       float64 `json:"flat"`
+	Percentage float64 `json:"percentage"`
+}
+
+// FeeRule describes a fee charged on an event. Product limits it to accounts
+// of that product, empty means every account. Percentages are ratios, 0.01
+// means 1%, and Min/Max bound the result when set.
+type FeeRule struct {
+	Name       string    `json:"name"`
+	Event      FeeEvent  `json:"event"`
+	Product    string    `json:"product"`
+	Type       FeeType   `json:"type"`
+	Flat       float64   `json:"flat"`
+	Percentage float64   `json:"percentage"`
+	Min        float64   `json:"min"`
+	Max        float64   `json:"max"`
+	Tiers      []FeeTier `json:"tiers"`
+}
+
+// Calculate returns the fee for amount, rounded to cents
+func (r FeeRule) Calculate(amount float64) float64 {
+	var fee float64
+	switch r.Type {
+	case FeeFlat:
+		fee = r.Flat
+	case FeePercentage:
+		fee = amount * r.Percentage
+	case FeeTiered:
+		tiers := make([]FeeTier, len(r.Tiers))
+		copy(tiers, r.Tiers)
+		sort.Slice(tiers, func(i, j int) bool {
+			// tiers without upper bound go last
+			if tiers[i].UpTo == 0 || tiers[j].UpTo == 0 {
+				return tiers[j].UpTo == 0 && tiers[i].UpTo != 0
+			}
+			return tiers[i].UpTo < tiers[j].UpTo
+		})
+		for _, tier := range tiers {
+			if tier.UpTo == 0 || amount <= tier.UpTo {
+				fee = tier.Flat + amount*tier.Percentage
+				break
+			}
+		}
+	}
+
+	if r.Min > 0 && fee < r.Min {
+		fee = r.Min
+	}
+	if r.Max > 0 && fee > r.Max {
+		fee = r.Max
+	}
+	return math.Round(fee*100) / 100
+}
+
+// Fee is the amount charged by a single rule
+type Fee struct {
+	Name   string
+	Amount float64
+}
+
+// FeeSchedule holds every fee rule the bank charges
+type FeeSchedule []FeeRule
+
+// Fees returns the fees of every rule matching the event and the product
+func (s FeeSchedule) Fees(event FeeEvent, product string, amount float64) []Fee {
+	fees := make([]Fee, 0)
+	for _, rule := range s {
+		if rule.Event != event || (rule.Product != "" && rule.Product != product) {
+			continue
+		}
+		if fee := rule.Calculate(amount); fee > 0 {
+			fees = append(fees, Fee{Name: rule.Name, Amount: fee})
+		}
+	}
+	return fees
+}
+
+// TotalFees adds up fees
+func TotalFees(fees []Fee) float64 {
+	total := 0.0
+	for _, fee := range fees {
+		total += fee.Amount
+	}
+	return math.Round(total*100) / 100
+}
+
+// LoadFeeSchedule reads a fee schedule from a json file holding a list of rules
+func LoadFeeSchedule(path string) (FeeSchedule, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var schedule FeeSchedule
+	if uErr := json.Unmarshal(content, &schedule); uErr != nil {
+		return nil, uErr
+	}
+	return schedule, nil
+}
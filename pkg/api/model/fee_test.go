@@ -0,0 +1,52 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFeeRule_Calculate(t *testing.T) {
+	tiered := model.FeeRule{
+		Type: model.FeeTiered,
+		Tiers: []model.FeeTier{
+			{UpTo: 0, Flat: 5.00},
+			{UpTo: 100, Flat: 0.50},
+			{UpTo: 1000, Flat: 1.00, Percentage: 0.001},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		rule     model.FeeRule
+		amount   float64
+		expected float64
+	}{
+		{"flat", model.FeeRule{Type: model.FeeFlat, Flat: 1.50}, 300.00, 1.50},
+		{"percentage", model.FeeRule{Type: model.FeePercentage, Percentage: 0.01}, 300.00, 3.00},
+		{"percentage below min", model.FeeRule{Type: model.FeePercentage, Percentage: 0.01, Min: 1.00}, 10.00, 1.00},
+		{"percentage above max", model.FeeRule{Type: model.FeePercentage, Percentage: 0.01, Max: 2.00}, 1000.00, 2.00},
+		{"first tier", tiered, 50.00, 0.50},
+		{"middle tier", tiered, 500.00, 1.50},
+		{"unbounded tier", tiered, 5000.00, 5.00},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.InDelta(t, c.expected, c.rule.Calculate(c.amount), 1e-9)
+		})
+	}
+}
+
+func TestFeeSchedule_Fees(t *testing.T) {
+	schedule := model.FeeSchedule{
+		{Name: "transfer fee", Event: model.FeeOnTransfer, Type: model.FeeFlat, Flat: 1.00},
+		{Name: "savings withdrawal fee", Event: model.FeeOnTransfer, Product: model.ProductSavings, Type: model.FeePercentage, Percentage: 0.01},
+		{Name: "maintenance", Event: model.FeeOnMaintenance, Type: model.FeeFlat, Flat: 3.00},
+	}
+
+	t.Run("Then only rules of the event and product apply", func(t *testing.T) {
+		assert.Equal(t, []model.Fee{{Name: "transfer fee", Amount: 1.00}}, schedule.Fees(model.FeeOnTransfer, model.ProductCurrent, 100.00))
+		assert.Equal(t, 2.00, model.TotalFees(schedule.Fees(model.FeeOnTransfer, model.ProductSavings, 100.00)))
+	})
+}
@@ -0,0 +1,31 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type HoldStatus string
+
+const (
+	HoldActive   HoldStatus = "active"
+	HoldCaptured HoldStatus = "captured"
+	HoldReleased HoldStatus = "released"
+	HoldExpired  HoldStatus = "expired"
+)
+
+// Hold reserves money of an account for a payment to To until it is
+// captured, released or it expires
+type Hold struct {
+	ID        uuid.UUID
+	AccountID uuid.UUID
+	To        uuid.UUID
+	Amount    float64
+	// Captured is the part of Amount finally paid, the rest went back
+	Captured  float64
+	Status    HoldStatus
+	Reference string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type EntryType string
+
+const (
+	EntryDeposit     EntryType = "deposit"
+	EntryWithdrawal  EntryType = "withdrawal"
+	EntryTransferIn  EntryType = "transfer_in"
+	EntryTransferOut EntryType = "transfer_out"
+	EntryFee         EntryType = "fee"
+	EntryInterest    EntryType = "interest"
+	EntryReversal    EntryType = "reversal"
+)
+
+// LedgerEntry is a movement on the balance of an account. Amount is positive
+// for credits and negative for debits.
+type LedgerEntry struct {
+	ID          uuid.UUID
+	AccountID   uuid.UUID
+	Type        EntryType
+	Amount      float64
+	Balance     float64
+	Description string
+	CreatedAt   time.Time
+}
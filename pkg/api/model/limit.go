@@ -0,0 +1,173 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+type LimitScope string
+
+const (
+	// LimitPerAccount applies to every account, or the one in Target
+	LimitPerAccount LimitScope = "account"
+	// LimitPerCustomer adds up every account of a customer
+	LimitPerCustomer LimitScope = "customer"
+	// LimitPerProduct applies to every account of a product
+	LimitPerProduct LimitScope = "product"
+)
+
+type LimitWindow string
+
+const (
+	LimitPerTransaction LimitWindow = "transaction"
+	LimitDaily          LimitWindow = "daily"
+	LimitMonthly        LimitWindow = "monthly"
+)
+
+// Windows usage is tracked for
+var Windows = []LimitWindow{LimitDaily, LimitMonthly}
+
+type LimitOperation string
+
+const (
+	LimitOnTransfer   LimitOperation = "transfer"
+	LimitOnWithdrawal LimitOperation = "withdrawal"
+)
+
+// Period returns the key of the window containing t, days and months start
+// at midnight UTC
+func (w LimitWindow) Period(t time.Time) string {
+	switch w {
+	case LimitDaily:
+		return t.UTC().Format("2006-01-02")
+	case LimitMonthly:
+		return t.UTC().Format("2006-01")
+	}
+	return ""
+}
+
+// LimitRule bounds the money that leaves an account. Target narrows it to a
+// single account id, customer id or product code depending on Scope, empty
+// means every one. Operation narrows it to transfers or withdrawals, empty
+// means both. MaxAmount and MaxCount are ignored when zero, MaxCount makes no
+// sense per transaction.
+type LimitRule struct {
+	Name      string         `json:"name"`
+	Scope     LimitScope     `json:"scope"`
+	Target    string         `json:"target"`
+	Operation LimitOperation `json:"operation"`
+	Window    LimitWindow    `json:"window"`
+	MaxAmount float64        `json:"max_amount"`
+	MaxCount  int            `json:"max_count"`
+}
+
+// Applies tells if the rule bounds op on acc
+func (r LimitRule) Applies(acc *Account, op LimitOperation) bool {
+	if r.Operation != "" && r.Operation != op {
+		return false
+	}
+	switch r.Scope {
+	case LimitPerAccount:
+		return r.Target == "" || r.Target == acc.ID.String()
+	case LimitPerCustomer:
+		return acc.CustomerID != "" && (r.Target == "" || r.Target == acc.CustomerID)
+	case LimitPerProduct:
+		return r.Target == "" || r.Target == acc.Product
+	}
+	return false
+}
+
+// Subject returns the key usage of acc is tracked under for the rule
+func (r LimitRule) Subject(acc *Account) string {
+	if r.Scope == LimitPerCustomer {
+		return CustomerSubject(acc.CustomerID)
+	}
+	return AccountSubject(acc)
+}
+
+func AccountSubject(acc *Account) string {
+	return "account:" + acc.ID.String()
+}
+
+func CustomerSubject(customerID string) string {
+	return "customer:" + customerID
+}
+
+// LimitUsage is what a subject used of an operation during a period
+type LimitUsage struct {
+	Subject   string
+	Operation LimitOperation
+	Period    string
+	Amount    float64
+	Count     int
+}
+
+// Limits holds every limit rule of the bank
+type Limits []LimitRule
+
+// UsageFunc returns the usage of subject for op during period
+type UsageFunc func(subject string, op LimitOperation, period string) (*LimitUsage, error)
+
+// Check fails with ErrLimitExceeded when moving amount out of acc now goes
+// over any rule
+func (l Limits) Check(acc *Account, op LimitOperation, amount float64, now time.Time, usage UsageFunc) error {
+	for _, rule := range l {
+		if !rule.Applies(acc, op) {
+			continue
+		}
+
+		if rule.Window == LimitPerTransaction {
+			if rule.MaxAmount > 0 && amount > rule.MaxAmount {
+				return exceeded(rule)
+			}
+			continue
+		}
+
+		ops := []LimitOperation{op}
+		if rule.Operation == "" {
+			ops = []LimitOperation{LimitOnTransfer, LimitOnWithdrawal}
+		}
+		var used float64
+		var count int
+		for _, o := range ops {
+			u, err := usage(rule.Subject(acc), o, rule.Window.Period(now))
+			if err != nil {
+				return err
+			}
+			used += u.Amount
+			count += u.Count
+		}
+
+		// tolerate float noise of amounts added up
+		if rule.MaxAmount > 0 && used+amount > rule.MaxAmount+1e-9 {
+			return exceeded(rule)
+		}
+		if rule.MaxCount > 0 && count+1 > rule.MaxCount {
+			return exceeded(rule)
+		}
+	}
+	return nil
+}
+
+func exceeded(rule LimitRule) error {
+	return fmt.Errorf("%w: %s", ErrLimitExceeded, rule.Name)
+}
+
+// LoadLimits reads limit rules from a json file holding a list of them
+func LoadLimits(path string) (Limits, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var limits Limits
+	if uErr := json.Unmarshal(content, &limits); uErr != nil {
+		return nil, uErr
+	}
+	return limits, nil
+}
@@ -0,0 +1,59 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLimits_Check(t *testing.T) {
+	now := time.Date(2022, 9, 15, 10, 0, 0, 0, time.UTC)
+	acc := &model.Account{ID: uuid.New(), CustomerID: "c-1", Product: model.ProductSavings}
+
+	// 80 transferred and 20 withdrawn today in 2 operations each
+	used := map[string]model.LimitUsage{
+		model.AccountSubject(acc) + "transfer2022-09-15":    {Amount: 80, Count: 2},
+		model.AccountSubject(acc) + "withdrawal2022-09-15":  {Amount: 20, Count: 2},
+		model.CustomerSubject("c-1") + "transfer2022-09":    {Amount: 900, Count: 9},
+		model.CustomerSubject("c-1") + "withdrawal2022-09":  {Amount: 0, Count: 0},
+		model.AccountSubject(acc) + "transfer2022-09":       {Amount: 80, Count: 2},
+		model.AccountSubject(acc) + "withdrawal2022-09":     {Amount: 20, Count: 2},
+		model.CustomerSubject("c-1") + "transfer2022-09-15": {Amount: 80, Count: 2},
+	}
+	usage := func(subject string, op model.LimitOperation, period string) (*model.LimitUsage, error) {
+		u := used[subject+string(op)+period]
+		return &u, nil
+	}
+
+	cases := []struct {
+		name     string
+		rule     model.LimitRule
+		op       model.LimitOperation
+		amount   float64
+		exceeded bool
+	}{
+		{"per transaction under", model.LimitRule{Scope: model.LimitPerAccount, Window: model.LimitPerTransaction, MaxAmount: 50}, model.LimitOnTransfer, 50, false},
+		{"per transaction over", model.LimitRule{Scope: model.LimitPerAccount, Window: model.LimitPerTransaction, MaxAmount: 50}, model.LimitOnTransfer, 51, true},
+		{"daily over for transfers only", model.LimitRule{Scope: model.LimitPerAccount, Operation: model.LimitOnTransfer, Window: model.LimitDaily, MaxAmount: 100}, model.LimitOnTransfer, 25, true},
+		{"daily adds every operation", model.LimitRule{Scope: model.LimitPerAccount, Window: model.LimitDaily, MaxAmount: 110}, model.LimitOnTransfer, 15, true},
+		{"daily count", model.LimitRule{Scope: model.LimitPerAccount, Operation: model.LimitOnWithdrawal, Window: model.LimitDaily, MaxCount: 2}, model.LimitOnWithdrawal, 1, true},
+		{"other operation not limited", model.LimitRule{Scope: model.LimitPerAccount, Operation: model.LimitOnWithdrawal, Window: model.LimitDaily, MaxCount: 2}, model.LimitOnTransfer, 1, false},
+		{"monthly per customer", model.LimitRule{Scope: model.LimitPerCustomer, Window: model.LimitMonthly, MaxAmount: 1000}, model.LimitOnTransfer, 101, true},
+		{"other customer", model.LimitRule{Scope: model.LimitPerCustomer, Target: "c-2", Window: model.LimitMonthly, MaxAmount: 1000}, model.LimitOnTransfer, 101, false},
+		{"product", model.LimitRule{Scope: model.LimitPerProduct, Target: model.ProductSavings, Window: model.LimitPerTransaction, MaxAmount: 10}, model.LimitOnTransfer, 11, true},
+		{"other product", model.LimitRule{Scope: model.LimitPerProduct, Target: model.ProductCurrent, Window: model.LimitPerTransaction, MaxAmount: 10}, model.LimitOnTransfer, 11, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := model.Limits{c.rule}.Check(acc, c.op, c.amount, now, usage)
+			if c.exceeded {
+				assert.ErrorIs(t, err, model.ErrLimitExceeded)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
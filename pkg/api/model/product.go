@@ -0,0 +1,97 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// DayCount is the convention used to turn a period into a fraction of a year
+type DayCount string
+
+const (
+	DayCountActual365 DayCount = "ACT/365"
+	DayCountActual360 DayCount = "ACT/360"
+	DayCount30360     DayCount = "30/360"
+)
+
+// YearFraction returns the part of a year between start and end
+func (d DayCount) YearFraction(start time.Time, end time.Time) float64 {
+	switch d {
+	case DayCountActual360:
+		return actualDays(start, end) / 360
+	case DayCount30360:
+		d1, d2 := start.Day(), end.Day()
+		if d1 == 31 {
+			d1 = 30
+		}
+		if d2 == 31 && d1 == 30 {
+			d2 = 30
+		}
+		days := 360*(end.Year()-start.Year()) + 30*(int(end.Month())-int(start.Month())) + d2 - d1
+		return float64(days) / 360
+	default:
+		return actualDays(start, end) / 365
+	}
+}
+
+func actualDays(start time.Time, end time.Time) float64 {
+	return end.Sub(start).Hours() / 24
+}
+
+type Capitalization string
+
+const (
+	CapitalizeMonthly   Capitalization = "monthly"
+	CapitalizeQuarterly Capitalization = "quarterly"
+	CapitalizeAnnually  Capitalization = "annually"
+)
+
+// IsPeriodEnd tells whether day closes a capitalization period
+func (c Capitalization) IsPeriodEnd(day time.Time) bool {
+	if day.AddDate(0, 0, 1).Day() != 1 {
+		return false
+	}
+	switch c {
+	case CapitalizeQuarterly:
+		return day.Month()%3 == 0
+	case CapitalizeAnnually:
+		return day.Month() == time.December
+	default:
+		return true
+	}
+}
+
+const (
+	ProductCurrent = "current"
+	ProductSavings = "savings"
+)
+
+// Product holds the conditions shared by every account of the same kind.
+// InterestRate is yearly, 0.02 means 2%.
+type Product struct {
+	Code           string
+	InterestRate   float64
+	DayCount       DayCount
+	Capitalization Capitalization
+}
+
+func DefaultProducts() map[string]Product {
+	return map[string]Product{
+		ProductCurrent: {Code: ProductCurrent},
+		ProductSavings: {
+			Code:           ProductSavings,
+			InterestRate:   0.02,
+			DayCount:       DayCountActual365,
+			Capitalization: CapitalizeMonthly,
+		},
+	}
+}
+
+// InterestAccrual is the interest earned by an account on a single day
+type InterestAccrual struct {
+	AccountID uuid.UUID
+	Date      time.Time
+	Balance   float64
+	Rate      float64
+	Amount    float64
+}
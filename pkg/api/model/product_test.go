@@ -0,0 +1,31 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDayCount_YearFraction(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, time.UTC) }
+
+	cases := []struct {
+		name     string
+		dayCount model.DayCount
+		start    time.Time
+		end      time.Time
+		expected float64
+	}{
+		{"actual/365 over a leap february", model.DayCountActual365, day(2024, 2, 1), day(2024, 3, 1), 29.0 / 365},
+		{"actual/360", model.DayCountActual360, day(2022, 1, 1), day(2022, 1, 31), 30.0 / 360},
+		{"30/360 ignores the 31st", model.DayCount30360, day(2022, 1, 31), day(2022, 2, 1), 1.0 / 360},
+		{"30/360 fills february up to 30 days", model.DayCount30360, day(2022, 2, 28), day(2022, 3, 1), 3.0 / 360},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.InDelta(t, c.expected, c.dayCount.YearFraction(c.start, c.end), 1e-12)
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type SanctionsSubject string
+
+const (
+	SanctionsAccount  SanctionsSubject = "account"
+	SanctionsTransfer SanctionsSubject = "transfer"
+)
+
+type SanctionsDecision string
+
+const (
+	SanctionsClear   SanctionsDecision = "clear"
+	SanctionsFlagged SanctionsDecision = "flagged"
+	SanctionsBlocked SanctionsDecision = "blocked"
+)
+
+// SanctionsMatch is a watchlist entry a screened name is similar to
+type SanctionsMatch struct {
+	EntryID string
+	List    string
+	// Name is the listed name or alias closest to the screened one
+	Name   string
+	Score  float64
+	Action WatchlistAction
+}
+
+// SanctionsCheck records the screening of a name against the watchlist, kept
+// for audit whatever its decision. SubjectID is the account being opened or
+// the transfer whose counterparty was screened.
+type SanctionsCheck struct {
+	ID        uuid.UUID
+	Subject   SanctionsSubject
+	SubjectID uuid.UUID
+	Name      string
+	Decision  SanctionsDecision
+	Matches   []SanctionsMatch
+	CreatedAt time.Time
+}
+
+// SanctionsDecisionOf blocks on any match of a blocking entry and flags on
+// any other match
+func SanctionsDecisionOf(matches []SanctionsMatch) SanctionsDecision {
+	decision := SanctionsClear
+	for _, match := range matches {
+		if match.Action == WatchlistBlock {
+			return SanctionsBlocked
+		}
+		decision = SanctionsFlagged
+	}
+	return decision
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type ScheduledTransferStatus string
+
+const (
+	ScheduledPending   ScheduledTransferStatus = "pending"
+	ScheduledExecuting ScheduledTransferStatus = "executing"
+	ScheduledCompleted ScheduledTransferStatus = "completed"
+	ScheduledFailed    ScheduledTransferStatus = "failed"
+	ScheduledCancelled ScheduledTransferStatus = "cancelled"
+	// ScheduledHeld is executed but held by screening, whether money moves
+	// is up to the operator reviewing the transfer
+	ScheduledHeld ScheduledTransferStatus = "held"
+)
+
+// ScheduledTransfer is a transfer to be executed once ExecuteAt is reached
+type ScheduledTransfer struct {
+	ID            uuid.UUID
+	From          uuid.UUID
+	To            uuid.UUID
+	Amount        float64
+	ExecuteAt     time.Time
+	Status        ScheduledTransferStatus
+	FailureReason string
+	CreatedAt     time.Time
+	ExecutedAt    *time.Time
+	// ClaimedAt is when it was last claimed for execution
+	ClaimedAt *time.Time
+	// Claims counts the times it was claimed, more than one means an earlier
+	// execution was abandoned
+	Claims int
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+type ScreeningDecision string
+
+const (
+	ScreeningAllow ScreeningDecision = "allow"
+	// ScreeningReview holds the transfer until an operator approves or rejects it
+	ScreeningReview ScreeningDecision = "review"
+	ScreeningDeny   ScreeningDecision = "deny"
+)
+
+// severity orders decisions, the most severe of every rule wins
+var severity = map[ScreeningDecision]int{ScreeningAllow: 0, ScreeningReview: 1, ScreeningDeny: 2}
+
+// Screening is the outcome of screening a transfer, Reasons tells which rules
+// did not allow it
+type Screening struct {
+	Decision ScreeningDecision
+	Reasons  []string
+}
+
+// Add merges the outcome of a rule
+func (s *Screening) Add(decision ScreeningDecision, reason string) {
+	if decision == ScreeningAllow {
+		return
+	}
+	if severity[decision] > severity[s.Decision] {
+		s.Decision = decision
+	}
+	s.Reasons = append(s.Reasons, reason)
+}
+
+type ScreeningRuleType string
+
+const (
+	// ScreenAmount triggers on transfers over Amount
+	ScreenAmount ScreeningRuleType = "amount"
+	// ScreenVelocity triggers when the sender already made Count transfers in
+	// the last WindowMinutes
+	ScreenVelocity ScreeningRuleType = "velocity"
+	// ScreenNewBeneficiary triggers on the first transfer to a recipient over
+	// Amount
+	ScreenNewBeneficiary ScreeningRuleType = "new_beneficiary"
+	// ScreenUnusualHours triggers on transfers made from FromHour to ToHour,
+	// wrapping around midnight when FromHour is the greater
+	ScreenUnusualHours ScreeningRuleType = "unusual_hours"
+)
+
+// ScreeningRule triggers Action, review or deny, on transfers matching it
+type ScreeningRule struct {
+	Name          string            `json:"name"`
+	Type          ScreeningRuleType `json:"type"`
+	Action        ScreeningDecision `json:"action"`
+	Amount        float64           `json:"amount"`
+	Count         int               `json:"count"`
+	WindowMinutes int               `json:"window_minutes"`
+	FromHour      int               `json:"from_hour"`
+	ToHour        int               `json:"to_hour"`
+}
+
+// Triggers tells if transfer, made at now, matches the rule. History holds
+// the previous transfers of the sender, at least those within the window of
+// the rule and one completed to the recipient if there is any.
+func (r ScreeningRule) Triggers(transfer *Transfer, history []*Transfer, now time.Time) bool {
+	switch r.Type {
+	case ScreenAmount:
+		return transfer.Amount > r.Amount
+	case ScreenVelocity:
+		since := now.Add(-time.Duration(r.WindowMinutes) * time.Minute)
+		count := 0
+		for _, previous := range history {
+			if previous.From == transfer.From && previous.Status != TransferFailed && previous.CreatedAt.After(since) {
+				count++
+			}
+		}
+		return count >= r.Count
+	case ScreenNewBeneficiary:
+		if transfer.Amount <= r.Amount {
+			return false
+		}
+		for _, previous := range history {
+			if previous.From == transfer.From && previous.To == transfer.To && previous.Status == TransferCompleted {
+				return false
+			}
+		}
+		return true
+	case ScreenUnusualHours:
+		hour := now.Hour()
+		if r.FromHour <= r.ToHour {
+			return hour >= r.FromHour && hour < r.ToHour
+		}
+		return hour >= r.FromHour || hour < r.ToHour
+	}
+	return false
+}
+
+// ScreeningRules holds every fraud rule transfers are screened with
+type ScreeningRules []ScreeningRule
+
+// Screen runs every rule on transfer
+func (rules ScreeningRules) Screen(transfer *Transfer, history []*Transfer, now time.Time) Screening {
+	screening := Screening{Decision: ScreeningAllow}
+	for _, rule := range rules {
+		if rule.Triggers(transfer, history, now) {
+			screening.Add(rule.Action, rule.Name)
+		}
+	}
+	return screening
+}
+
+// LoadScreeningRules reads fraud rules from a json file holding a list of them
+func LoadScreeningRules(path string) (ScreeningRules, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules ScreeningRules
+	if uErr := json.Unmarshal(content, &rules); uErr != nil {
+		return nil, uErr
+	}
+	for _, rule := range rules {
+		if rule.Action != ScreeningReview && rule.Action != ScreeningDeny {
+			return nil, fmt.Errorf("screening rule %q: action must be review or deny", rule.Name)
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,60 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestScreeningRules_Screen(t *testing.T) {
+	now := time.Date(2022, 9, 15, 23, 30, 0, 0, time.UTC)
+	from, known, unknown := uuid.New(), uuid.New(), uuid.New()
+
+	// two transfers to known in the last hour, one of them failed
+	history := []*model.Transfer{
+		{From: from, To: known, Amount: 10, Status: model.TransferCompleted, CreatedAt: now.Add(-50 * time.Minute)},
+		{From: from, To: known, Amount: 10, Status: model.TransferCompleted, CreatedAt: now.Add(-20 * time.Minute)},
+		{From: from, To: known, Amount: 10, Status: model.TransferFailed, CreatedAt: now.Add(-10 * time.Minute)},
+	}
+	toKnown := &model.Transfer{From: from, To: known, Amount: 100}
+	toUnknown := &model.Transfer{From: from, To: unknown, Amount: 100}
+
+	cases := []struct {
+		name     string
+		rule     model.ScreeningRule
+		transfer *model.Transfer
+		at       time.Time
+		decision model.ScreeningDecision
+	}{
+		{"amount under", model.ScreeningRule{Type: model.ScreenAmount, Action: model.ScreeningDeny, Amount: 100}, toKnown, now, model.ScreeningAllow},
+		{"amount over", model.ScreeningRule{Type: model.ScreenAmount, Action: model.ScreeningDeny, Amount: 99}, toKnown, now, model.ScreeningDeny},
+		{"velocity reached", model.ScreeningRule{Type: model.ScreenVelocity, Action: model.ScreeningReview, Count: 2, WindowMinutes: 60}, toKnown, now, model.ScreeningReview},
+		{"velocity ignores failed transfers", model.ScreeningRule{Type: model.ScreenVelocity, Action: model.ScreeningReview, Count: 3, WindowMinutes: 60}, toKnown, now, model.ScreeningAllow},
+		{"velocity out of window", model.ScreeningRule{Type: model.ScreenVelocity, Action: model.ScreeningReview, Count: 2, WindowMinutes: 30}, toKnown, now, model.ScreeningAllow},
+		{"known beneficiary", model.ScreeningRule{Type: model.ScreenNewBeneficiary, Action: model.ScreeningReview, Amount: 50}, toKnown, now, model.ScreeningAllow},
+		{"new beneficiary", model.ScreeningRule{Type: model.ScreenNewBeneficiary, Action: model.ScreeningReview, Amount: 50}, toUnknown, now, model.ScreeningReview},
+		{"new beneficiary small amount", model.ScreeningRule{Type: model.ScreenNewBeneficiary, Action: model.ScreeningReview, Amount: 100}, toUnknown, now, model.ScreeningAllow},
+		{"unusual hours around midnight", model.ScreeningRule{Type: model.ScreenUnusualHours, Action: model.ScreeningReview, FromHour: 23, ToHour: 6}, toKnown, now, model.ScreeningReview},
+		{"usual hours", model.ScreeningRule{Type: model.ScreenUnusualHours, Action: model.ScreeningReview, FromHour: 23, ToHour: 6}, toKnown, now.Add(-12 * time.Hour), model.ScreeningAllow},
+		{"unusual hours same day", model.ScreeningRule{Type: model.ScreenUnusualHours, Action: model.ScreeningReview, FromHour: 1, ToHour: 5}, toKnown, now.Add(4 * time.Hour), model.ScreeningReview},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			screening := model.ScreeningRules{c.rule}.Screen(c.transfer, history, c.at)
+			assert.Equal(t, c.decision, screening.Decision)
+		})
+	}
+
+	t.Run("the most severe decision wins", func(t *testing.T) {
+		rules := model.ScreeningRules{
+			{Name: "new beneficiary", Type: model.ScreenNewBeneficiary, Action: model.ScreeningReview},
+			{Name: "huge", Type: model.ScreenAmount, Action: model.ScreeningDeny, Amount: 50},
+		}
+		screening := rules.Screen(toUnknown, history, now)
+		assert.Equal(t, model.ScreeningDeny, screening.Decision)
+		assert.Equal(t, []string{"new beneficiary", "huge"}, screening.Reasons)
+	})
+}
@@ -0,0 +1,154 @@
+package model
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+type Frequency string
+
+const (
+	FrequencyDaily           Frequency = "daily"
+	FrequencyWeekly          Frequency = "weekly"
+	FrequencyMonthly         Frequency = "monthly"
+	FrequencyLastBusinessDay Frequency = "last_business_day"
+)
+
+// FailurePolicy decides what happens when the transfer of an occurrence fails
+type FailurePolicy string
+
+const (
+	// FailureRetry tries the same occurrence again up to MaxRetries times,
+	// then skips it and notifies
+	FailureRetry FailurePolicy = "retry"
+	// FailureSkip moves on to the next occurrence
+	FailureSkip FailurePolicy = "skip"
+	// FailureNotify skips the occurrence and notifies
+	FailureNotify FailurePolicy = "notify"
+)
+
+type StandingOrderStatus string
+
+const (
+	StandingOrderActive    StandingOrderStatus = "active"
+	StandingOrderExecuting StandingOrderStatus = "executing"
+	StandingOrderFinished  StandingOrderStatus = "finished"
+	StandingOrderCancelled StandingOrderStatus = "cancelled"
+)
+
+// StandingOrder is a transfer repeated following a recurrence rule. It ends
+// at EndAt or after Count occurrences, whatever comes first. Zero values mean
+// no limit.
+type StandingOrder struct {
+	ID         uuid.UUID
+	From       uuid.UUID
+	To         uuid.UUID
+	Amount     float64
+	Frequency  Frequency
+	StartAt    time.Time
+	EndAt      *time.Time
+	Count      int
+	OnFailure  FailurePolicy
+	MaxRetries int
+	Status     StandingOrderStatus
+	// Occurrences already consumed, either executed or skipped
+	Occurrences int
+	// Attempts made on the current occurrence
+	Attempts  int
+	NextRunAt time.Time
+	CreatedAt time.Time
+	// ClaimedAt is when it was last claimed for execution
+	ClaimedAt *time.Time
+	// Claims counts the claims of the current attempt, more than one means an
+	// earlier one was abandoned
+	Claims int
+}
+
+// Occurrence returns the date of the n-th occurrence, starting at zero
+func (o *StandingOrder) Occurrence(n int) time.Time {
+	switch o.Frequency {
+	case FrequencyDaily:
+		return o.StartAt.AddDate(0, 0, n)
+	case FrequencyWeekly:
+		return o.StartAt.AddDate(0, 0, 7*n)
+	case FrequencyMonthly:
+		return addMonthsClamped(o.StartAt, n)
+	case FrequencyLastBusinessDay:
+		// starting after the last business day of the month means the
+		// first occurrence is on the next one
+		if lastBusinessDay(o.StartAt, o.StartAt).Before(o.StartAt) {
+			n++
+		}
+		return lastBusinessDay(addMonthsClamped(firstOfMonth(o.StartAt), n), o.StartAt)
+	}
+	return o.StartAt
+}
+
+// HasOccurrence tells whether the n-th occurrence is within the order limits
+func (o *StandingOrder) HasOccurrence(n int) bool {
+	if o.Count > 0 && n >= o.Count {
+		return false
+	}
+	if o.EndAt != nil && o.Occurrence(n).After(*o.EndAt) {
+		return false
+	}
+	return true
+}
+
+// Advance moves the order to its next occurrence, finishing it when there is none
+func (o *StandingOrder) Advance() {
+	o.Occurrences++
+	o.Attempts = 0
+	if !o.HasOccurrence(o.Occurrences) {
+		o.Status = StandingOrderFinished
+		return
+	}
+	o.NextRunAt = o.Occurrence(o.Occurrences)
+}
+
+// addMonthsClamped adds months keeping the day of month, or the last day of
+// the month when it is shorter (Jan 31 + 1 month is Feb 28)
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := firstOfMonth(t).AddDate(0, months, 0)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := t.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+func firstOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+}
+
+// lastBusinessDay returns the last weekday of month at the time of day of clock
+func lastBusinessDay(month time.Time, clock time.Time) time.Time {
+	day := firstOfMonth(month).AddDate(0, 1, -1)
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), clock.Nanosecond(), clock.Location())
+}
+
+type StandingOrderRunStatus string
+
+const (
+	RunSucceeded StandingOrderRunStatus = "succeeded"
+	RunFailed    StandingOrderRunStatus = "failed"
+	// RunHeld is a transfer held by screening, the occurrence is not tried
+	// again as the operator reviewing it decides whether money moves
+	RunHeld StandingOrderRunStatus = "held"
+)
+
+// StandingOrderRun records every attempt made to execute an occurrence
+type StandingOrderRun struct {
+	ID            uuid.UUID
+	OrderID       uuid.UUID
+	Occurrence    int
+	ScheduledFor  time.Time
+	ExecutedAt    time.Time
+	Attempt       int
+	Status        StandingOrderRunStatus
+	FailureReason string
+}
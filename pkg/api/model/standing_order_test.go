@@ -0,0 +1,56 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestStandingOrder_Occurrence(t *testing.T) {
+	start := time.Date(2022, 1, 31, 9, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name      string
+		frequency model.Frequency
+		start     time.Time
+		n         int
+		expected  time.Time
+	}{
+		{"daily", model.FrequencyDaily, start, 3, time.Date(2022, 2, 3, 9, 0, 0, 0, time.UTC)},
+		{"weekly", model.FrequencyWeekly, start, 2, time.Date(2022, 2, 14, 9, 0, 0, 0, time.UTC)},
+		{"monthly clamps to shorter months", model.FrequencyMonthly, start, 1, time.Date(2022, 2, 28, 9, 0, 0, 0, time.UTC)},
+		{"monthly keeps the start day", model.FrequencyMonthly, start, 2, time.Date(2022, 3, 31, 9, 0, 0, 0, time.UTC)},
+		{"last business day skips weekends", model.FrequencyLastBusinessDay, time.Date(2022, 4, 1, 9, 0, 0, 0, time.UTC), 0, time.Date(2022, 4, 29, 9, 0, 0, 0, time.UTC)},
+		{"last business day starts next month when already passed", model.FrequencyLastBusinessDay, time.Date(2022, 4, 30, 9, 0, 0, 0, time.UTC), 0, time.Date(2022, 5, 31, 9, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			order := model.StandingOrder{Frequency: c.frequency, StartAt: c.start}
+			assert.Equal(t, c.expected, order.Occurrence(c.n))
+		})
+	}
+}
+
+func TestStandingOrder_Advance(t *testing.T) {
+	t.Run("Given an order limited to two occurrences", func(t *testing.T) {
+		order := model.StandingOrder{
+			Frequency: model.FrequencyDaily,
+			StartAt:   time.Date(2022, 1, 1, 9, 0, 0, 0, time.UTC),
+			Count:     2,
+			Status:    model.StandingOrderActive,
+		}
+
+		t.Run("When advancing twice", func(t *testing.T) {
+			order.Advance()
+			assert.Equal(t, model.StandingOrderActive, order.Status)
+			assert.Equal(t, order.Occurrence(1), order.NextRunAt)
+			order.Advance()
+
+			t.Run("Then it is finished", func(t *testing.T) {
+				assert.Equal(t, model.StandingOrderFinished, order.Status)
+			})
+		})
+	})
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// Statement sums up the movements of an account over a period, from its
+// opening balance to its closing one
+type Statement struct {
+	// From is the start of the period, To its end, excluded
+	From           time.Time
+	To             time.Time
+	OpeningBalance float64
+	ClosingBalance float64
+	// TotalCredits adds up the money received over the period, TotalDebits
+	// the money that left, as a positive amount
+	TotalCredits float64
+	TotalDebits  float64
+	// Entries of the period, oldest first
+	Entries []*LedgerEntry
+}
+
+// NewStatement builds the statement of the period from ledger, the whole
+// ledger of an account oldest first. balance is the current balance of the
+// account, used as the opening balance of an account that never moved.
+func NewStatement(ledger []*LedgerEntry, balance float64, from time.Time, to time.Time) *Statement {
+	statement := &Statement{From: from, To: to, OpeningBalance: balance}
+
+	var before *LedgerEntry
+	for _, entry := range ledger {
+		switch {
+		case entry.CreatedAt.Before(from):
+			before = entry
+		case entry.CreatedAt.Before(to):
+			statement.Entries = append(statement.Entries, entry)
+		}
+	}
+
+	// the balance of an account only changes with an entry, so it opens at
+	// the balance left by the last one before the period. Money an account
+	// was opened with has no entry, it is what the first entry started from.
+	if before != nil {
+		statement.OpeningBalance = before.Balance
+	} else {
+		for _, entry := range ledger {
+			if !entry.CreatedAt.Before(from) {
+				statement.OpeningBalance = entry.Balance - entry.Amount
+				break
+			}
+		}
+	}
+
+	statement.ClosingBalance = statement.OpeningBalance
+	for _, entry := range statement.Entries {
+		if entry.Amount > 0 {
+			statement.TotalCredits += entry.Amount
+		} else {
+			statement.TotalDebits -= entry.Amount
+		}
+		statement.ClosingBalance = entry.Balance
+	}
+	statement.OpeningBalance = roundCents(statement.OpeningBalance)
+	statement.TotalCredits = roundCents(statement.TotalCredits)
+	statement.TotalDebits = roundCents(statement.TotalDebits)
+	return statement
+}
+
+// roundCents drops the float noise added up below a cent
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,56 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNewStatement(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2022, 9, d, 12, 0, 0, 0, time.UTC) }
+	// the account was opened with 100.00
+	ledger := []*model.LedgerEntry{
+		{Type: model.EntryDeposit, Amount: 50.00, Balance: 150.00, CreatedAt: day(2)},
+		{Type: model.EntryTransferOut, Amount: -30.10, Balance: 119.90, CreatedAt: day(10)},
+		{Type: model.EntryFee, Amount: -0.50, Balance: 119.40, CreatedAt: day(10)},
+		{Type: model.EntryTransferIn, Amount: 20.20, Balance: 139.60, CreatedAt: day(20)},
+		{Type: model.EntryWithdrawal, Amount: -39.60, Balance: 100.00, CreatedAt: day(28)},
+	}
+	from, to := time.Date(2022, 9, 5, 0, 0, 0, 0, time.UTC), time.Date(2022, 9, 21, 0, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		from, to time.Time
+		opening  float64
+		closing  float64
+		credits  float64
+		debits   float64
+		entries  int
+	}{
+		{"opens after the last entry before it", from, to, 150.00, 139.60, 20.20, 30.60, 3},
+		{"opens with the money the account was opened with", day(1), from, 100.00, 150.00, 50.00, 0, 1},
+		{"without entries it neither moves", day(21), day(25), 139.60, 139.60, 0, 0, 0},
+		{"after every entry it closes at the balance", day(29), day(30), 100.00, 100.00, 0, 0, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			statement := model.NewStatement(ledger, 100.00, c.from, c.to)
+			assert.Equal(t, c.opening, statement.OpeningBalance)
+			assert.Equal(t, c.closing, statement.ClosingBalance)
+			assert.Equal(t, c.credits, statement.TotalCredits)
+			assert.Equal(t, c.debits, statement.TotalDebits)
+			assert.Len(t, statement.Entries, c.entries)
+		})
+	}
+
+	t.Run("Given an account that never moved", func(t *testing.T) {
+		statement := model.NewStatement(nil, 75.00, from, to)
+
+		t.Run("Then it opens and closes at its balance", func(t *testing.T) {
+			assert.Equal(t, 75.00, statement.OpeningBalance)
+			assert.Equal(t, 75.00, statement.ClosingBalance)
+		})
+	})
+}
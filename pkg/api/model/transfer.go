@@ -0,0 +1,99 @@
+package model
+
+import (
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrOverReversal         = errors.New("reversal exceeds the amount not yet reversed")
+	ErrTransferNotCompleted = errors.New("transfer is not completed")
+)
+
+type TransferStatus string
+
+const (
+	// TransferPending is a transfer whose money has not moved yet
+	TransferPending   TransferStatus = "pending"
+	TransferCompleted TransferStatus = "completed"
+	TransferFailed    TransferStatus = "failed"
+	// TransferInReview is a transfer held by screening until an operator
+	// approves or rejects it
+	TransferInReview TransferStatus = "review"
+	// TransferReversed is a completed transfer fully given back
+	TransferReversed TransferStatus = "reversed"
+)
+
+// TransferLeg is the part a single account takes in a split transfer
+type TransferLeg struct {
+	AccountID uuid.UUID
+	// Amount is negative for debits and positive for credits
+	Amount float64
+}
+
+// Transfer is a movement of money between two accounts. A reversal is a
+// transfer back to the sender linked to the original by ReversalOf. A split
+// transfer moves money between many accounts at once, it has Legs instead of
+// From and To.
+type Transfer struct {
+	ID     uuid.UUID
+	From   uuid.UUID
+	To     uuid.UUID
+	Amount float64
+	// Fee charged to From on top of Amount
+	Fee float64
+	// Reversed is the part of Amount already given back
+	Reversed   float64
+	ReversalOf *uuid.UUID
+	Status     TransferStatus
+	// FailureReason tells why a failed transfer did not complete
+	FailureReason string
+	// ScreeningReason tells which screening rules held or denied the transfer
+	ScreeningReason string
+	Memo            string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Legs            []TransferLeg
+}
+
+// Involves tells if the account sends or receives money in the transfer
+func (t *Transfer) Involves(accountID uuid.UUID) bool {
+	if t.From == accountID || t.To == accountID {
+		return true
+	}
+	for _, leg := range t.Legs {
+		if leg.AccountID == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+// Reversible returns the part of the transfer that can still be given back
+func (t *Transfer) Reversible() float64 {
+	return t.Amount - t.Reversed
+}
+
+// Reverse records amount as given back, it never goes over the transfer amount
+func (t *Transfer) Reverse(amount float64) error {
+	switch t.Status {
+	case TransferCompleted:
+	case TransferReversed:
+		return ErrOverReversal
+	default:
+		return ErrTransferNotCompleted
+	}
+	if amount <= 0 {
+		return errors.New("not valid amount")
+	}
+	// tolerate float noise after partial reversals
+	if amount > t.Reversible()+1e-9 {
+		return ErrOverReversal
+	}
+	t.Reversed = t.Reversed + amount
+	if t.Reversible() <= 1e-9 {
+		t.Status = TransferReversed
+	}
+	return nil
+}
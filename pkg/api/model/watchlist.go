@@ -0,0 +1,259 @@
+package model
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultMatchThreshold is the similarity, from 0 to 1, from which a name is
+// taken as a hit of a watchlist entry
+const DefaultMatchThreshold = 0.85
+
+type WatchlistAction string
+
+const (
+	// WatchlistBlock stops the account or transfer matching the entry
+	WatchlistBlock WatchlistAction = "block"
+	// WatchlistFlag lets it go ahead, held for review when it is a transfer
+	WatchlistFlag WatchlistAction = "flag"
+)
+
+// WatchlistEntry is a person or organization of a sanctions list
+type WatchlistEntry struct {
+	ID      string
+	Name    string
+	Aliases []string
+	// List is the sanctions list the entry comes from
+	List   string
+	Action WatchlistAction
+}
+
+// Watchlist holds every entry names are screened against
+type Watchlist []WatchlistEntry
+
+// Match returns the entries name is similar to at least by threshold, best
+// score first
+func (w Watchlist) Match(name string, threshold float64) []SanctionsMatch {
+	tokens := nameTokens(name)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var matches []SanctionsMatch
+	for _, entry := range w {
+		best := SanctionsMatch{EntryID: entry.ID, List: entry.List, Action: entry.Action}
+		for _, listed := range append([]string{entry.Name}, entry.Aliases...) {
+			if score := nameSimilarity(tokens, nameTokens(listed)); score > best.Score {
+				best.Name = listed
+				best.Score = score
+			}
+		}
+		if best.Score >= threshold {
+			matches = append(matches, best)
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	return matches
+}
+
+// nameTokens lower cases a name and splits it in words, dropping punctuation
+func nameTokens(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// nameSimilarity scores from 0 to 1 how alike a name is to a listed one. It is
+// the best of comparing them whole, words sorted so their order does not
+// matter, and of finding every listed word among the words of the name, so
+// middle names or titles added to a listed name do not hide it.
+func nameSimilarity(name []string, listed []string) float64 {
+	if len(listed) == 0 {
+		return 0
+	}
+
+	sortedName := append([]string(nil), name...)
+	sort.Strings(sortedName)
+	sortedListed := append([]string(nil), listed...)
+	sort.Strings(sortedListed)
+	whole := similarity(strings.Join(sortedName, " "), strings.Join(sortedListed, " "))
+
+	var words float64
+	for _, l := range listed {
+		var best float64
+		for _, n := range name {
+			if s := similarity(n, l); s > best {
+				best = s
+			}
+		}
+		words += best
+	}
+	words /= float64(len(listed))
+
+	if whole > words {
+		return whole
+	}
+	return words
+}
+
+// similarity is one minus the edit distance of a and b relative to the
+// longest of them
+func similarity(a string, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(longest)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// LoadWatchlist reads a watchlist from a csv or xml file, told apart by its
+// extension.
+//
+// csv files have a header and the columns id, name, aliases separated by
+// semicolons, list and action. xml files hold a watchlist element with an
+// entry element per entry, id, list and action attributes, and name and alias
+// child elements. Action defaults to block.
+func LoadWatchlist(path string) (Watchlist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var watchlist Watchlist
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		watchlist, err = readWatchlistCSV(file)
+	case ".xml":
+		watchlist, err = readWatchlistXML(file)
+	default:
+		return nil, fmt.Errorf("watchlist %s: unsupported format", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("watchlist %s: %w", path, err)
+	}
+
+	for i := range watchlist {
+		if watchlist[i].Action == "" {
+			watchlist[i].Action = WatchlistBlock
+		}
+		if watchlist[i].Name == "" {
+			return nil, fmt.Errorf("watchlist %s: entry %q has no name", path, watchlist[i].ID)
+		}
+		if watchlist[i].Action != WatchlistBlock && watchlist[i].Action != WatchlistFlag {
+			return nil, fmt.Errorf("watchlist %s: entry %q: action must be block or flag", path, watchlist[i].ID)
+		}
+	}
+	return watchlist, nil
+}
+
+func readWatchlistCSV(r io.Reader) (Watchlist, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 5
+	reader.TrimLeadingSpace = true
+
+	if _, err := reader.Read(); err != nil {
+		if errors.Is(err, io.EOF) {
+			return Watchlist{}, nil
+		}
+		return nil, err
+	}
+
+	watchlist := Watchlist{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return watchlist, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var aliases []string
+		for _, alias := range strings.Split(record[2], ";") {
+			if alias = strings.TrimSpace(alias); alias != "" {
+				aliases = append(aliases, alias)
+			}
+		}
+		watchlist = append(watchlist, WatchlistEntry{
+			ID:      record[0],
+			Name:    record[1],
+			Aliases: aliases,
+			List:    record[3],
+			Action:  WatchlistAction(record[4]),
+		})
+	}
+}
+
+type xmlWatchlist struct {
+	Entries []struct {
+		ID      string   `xml:"id,attr"`
+		List    string   `xml:"list,attr"`
+		Action  string   `xml:"action,attr"`
+		Name    string   `xml:"name"`
+		Aliases []string `xml:"alias"`
+	} `xml:"entry"`
+}
+
+func readWatchlistXML(r io.Reader) (Watchlist, error) {
+	var doc xmlWatchlist
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	watchlist := make(Watchlist, len(doc.Entries))
+	for i, entry := range doc.Entries {
+		watchlist[i] = WatchlistEntry{
+			ID:      entry.ID,
+			Name:    strings.TrimSpace(entry.Name),
+			Aliases: entry.Aliases,
+			List:    entry.List,
+			Action:  WatchlistAction(entry.Action),
+		}
+	}
+	return watchlist, nil
+}
@@ -0,0 +1,101 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWatchlist_Match(t *testing.T) {
+	watchlist := model.Watchlist{
+		{ID: "1", Name: "Ivan Petrovich Drago", Aliases: []string{"The Siberian Express"}, List: "test", Action: model.WatchlistBlock},
+		{ID: "2", Name: "Acme Shell Company Ltd", List: "test", Action: model.WatchlistFlag},
+	}
+
+	cases := []struct {
+		name  string
+		query string
+		entry string
+	}{
+		{"exact", "Ivan Petrovich Drago", "1"},
+		{"case and punctuation", "DRAGO, ivan petrovich", "1"},
+		{"typo", "Ivan Petrovitch Drago", "1"},
+		{"extra names", "Mr Ivan Petrovich Drago Jr", "1"},
+		{"alias", "the siberian express", "1"},
+		{"organization", "ACME Shell Company, Ltd.", "2"},
+		{"unrelated", "Maria Gonzalez", ""},
+		{"partial", "Ivan Drago", ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			matches := watchlist.Match(c.query, model.DefaultMatchThreshold)
+			if c.entry == "" {
+				assert.Empty(t, matches)
+				return
+			}
+			require.NotEmpty(t, matches)
+			assert.Equal(t, c.entry, matches[0].EntryID)
+		})
+	}
+
+	t.Run("the decision is as severe as the worst match", func(t *testing.T) {
+		assert.Equal(t, model.SanctionsClear, model.SanctionsDecisionOf(nil))
+		assert.Equal(t, model.SanctionsFlagged, model.SanctionsDecisionOf([]model.SanctionsMatch{{Action: model.WatchlistFlag}}))
+		assert.Equal(t, model.SanctionsBlocked, model.SanctionsDecisionOf([]model.SanctionsMatch{{Action: model.WatchlistFlag}, {Action: model.WatchlistBlock}}))
+	})
+}
+
+func TestLoadWatchlist(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		return path
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		path := write("list.csv", "id,name,aliases,list,action\n"+
+			"1,Ivan Drago,The Siberian Express; Ivan D,UN,block\n"+
+			"2,\"Acme, Ltd\",,EU,flag\n"+
+			"3,John Doe,,UN,\n")
+		watchlist, err := model.LoadWatchlist(path)
+		require.NoError(t, err)
+		require.Len(t, watchlist, 3)
+		assert.Equal(t, []string{"The Siberian Express", "Ivan D"}, watchlist[0].Aliases)
+		assert.Equal(t, "Acme, Ltd", watchlist[1].Name)
+		assert.Equal(t, model.WatchlistFlag, watchlist[1].Action)
+		assert.Equal(t, model.WatchlistBlock, watchlist[2].Action)
+	})
+
+	t.Run("xml", func(t *testing.T) {
+		path := write("list.xml", `<watchlist>
+	<entry id="1" list="UN" action="flag">
+		<name>Ivan Drago</name>
+		<alias>The Siberian Express</alias>
+	</entry>
+	<entry id="2" list="EU">
+		<name>Acme Ltd</name>
+	</entry>
+</watchlist>`)
+		watchlist, err := model.LoadWatchlist(path)
+		require.NoError(t, err)
+		require.Len(t, watchlist, 2)
+		assert.Equal(t, model.WatchlistEntry{ID: "1", Name: "Ivan Drago", Aliases: []string{"The Siberian Express"}, List: "UN", Action: model.WatchlistFlag}, watchlist[0])
+		assert.Equal(t, model.WatchlistBlock, watchlist[1].Action)
+	})
+
+	t.Run("unknown action", func(t *testing.T) {
+		path := write("bad.csv", "id,name,aliases,list,action\n1,Ivan Drago,,UN,ignore\n")
+		_, err := model.LoadWatchlist(path)
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		_, err := model.LoadWatchlist(write("list.json", "[]"))
+		assert.Error(t, err)
+	})
+}
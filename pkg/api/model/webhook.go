@@ -0,0 +1,173 @@
+package model
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// WebhookSubscription pushes the events of some accounts to a partner url
+type WebhookSubscription struct {
+	ID  uuid.UUID
+	URL string
+	// Secret signs every delivery, only the partner and the bank know it
+	Secret string
+	// EventTypes narrow the events delivered, empty means all
+	EventTypes []EventType
+	// Accounts are the accounts whose events are delivered, every account
+	// only when AllAccounts is set
+	Accounts    []uuid.UUID
+	AllAccounts bool
+	CreatedAt   time.Time
+}
+
+// Wants tells if event must be delivered to the subscription
+func (s *WebhookSubscription) Wants(event *Event) bool {
+	return s.wantsType(event.Type) && s.wantsAccounts(event.Accounts)
+}
+
+func (s *WebhookSubscription) wantsType(eventType EventType) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, wanted := range s.EventTypes {
+		if wanted == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *WebhookSubscription) wantsAccounts(accounts []uuid.UUID) bool {
+	if s.AllAccounts {
+		return true
+	}
+	for _, wanted := range s.Accounts {
+		for _, account := range accounts {
+			if wanted == account {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryDead deliveries ran out of attempts, they wait in the dead
+	// letter list until retried by hand
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is an event on its way to a subscription
+type WebhookDelivery struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	EventID        uuid.UUID
+	EventType      EventType
+	// Body is what is posted to the subscription url
+	Body          string
+	Status        DeliveryStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	// LastStatusCode and LastError tell how the last attempt went, the status
+	// code is 0 when no response came back
+	LastStatusCode int
+	LastError      string
+	// Claims counts how many times the delivery was claimed to be attempted
+	Claims      int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeliveredAt *time.Time
+}
+
+// DefaultRetryPolicy tries a delivery for about a day before it is dead
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 10,
+	Backoff:     30 * time.Second,
+	MaxBackoff:  4 * time.Hour,
+}
+
+// RetryPolicy tells how failed deliveries are tried again
+type RetryPolicy struct {
+	// MaxAttempts before a delivery is dead
+	MaxAttempts int
+	// Backoff is the wait after the first failure, doubled after every other
+	// one up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// Failed records a failed attempt of delivery at now, scheduling the next one
+// or leaving it dead
+func (p RetryPolicy) Failed(delivery *WebhookDelivery, now time.Time, statusCode int, reason string) {
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.LastError = reason
+	delivery.UpdatedAt = now
+	if delivery.Attempts >= p.MaxAttempts {
+		delivery.Status = DeliveryDead
+		return
+	}
+	delivery.NextAttemptAt = now.Add(p.delay(delivery.Attempts))
+}
+
+// delay returns the wait after the attempts failed so far
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// SignWebhook signs body sent at with secret. The signature covers the time so
+// a captured delivery can not be replayed later with a valid signature.
+func SignWebhook(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, webhookMAC(secret, timestamp, body))
+}
+
+// VerifyWebhook checks signature was made for body with secret, returning the
+// time it was signed at. Receivers should also refuse old timestamps.
+func VerifyWebhook(secret string, signature string, body []byte) (time.Time, error) {
+	var timestamp, mac string
+	for _, part := range strings.Split(signature, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "t":
+			timestamp = kv[1]
+		case "v1":
+			mac = kv[1]
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || !hmac.Equal([]byte(mac), []byte(webhookMAC(secret, timestamp, body))) {
+		return time.Time{}, ErrInvalidSignature
+	}
+	return time.Unix(unix, 0), nil
+}
+
+func webhookMAC(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,74 @@
+package model_test
+
+import (
+	"bank/pkg/api/model"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	at := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	body := []byte(`{"Type":"MoneyDeposited"}`)
+	signature := model.SignWebhook("secret", at, body)
+
+	t.Run("the signature verifies with the same secret and body", func(t *testing.T) {
+		signedAt, err := model.VerifyWebhook("secret", signature, body)
+		require.NoError(t, err)
+		assert.True(t, at.Equal(signedAt))
+	})
+
+	t.Run("another secret does not verify", func(t *testing.T) {
+		_, err := model.VerifyWebhook("other", signature, body)
+		assert.ErrorIs(t, err, model.ErrInvalidSignature)
+	})
+
+	t.Run("a changed body does not verify", func(t *testing.T) {
+		_, err := model.VerifyWebhook("secret", signature, []byte(`{"Type":"MoneyWithdrawn"}`))
+		assert.ErrorIs(t, err, model.ErrInvalidSignature)
+	})
+
+	t.Run("a changed timestamp does not verify", func(t *testing.T) {
+		replayed := model.SignWebhook("secret", at.Add(time.Hour), body)
+		_, err := model.VerifyWebhook("secret", replayed[:13]+signature[13:], body)
+		assert.ErrorIs(t, err, model.ErrInvalidSignature)
+	})
+}
+
+func TestRetryPolicy_Failed(t *testing.T) {
+	policy := model.RetryPolicy{MaxAttempts: 5, Backoff: time.Minute, MaxBackoff: 3 * time.Minute}
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	delivery := &model.WebhookDelivery{Status: model.DeliveryPending}
+
+	var delays []time.Duration
+	for i := 0; i < 4; i++ {
+		policy.Failed(delivery, now, 500, "unexpected status 500")
+		delays = append(delays, delivery.NextAttemptAt.Sub(now))
+	}
+
+	t.Run("the wait doubles up to the maximum", func(t *testing.T) {
+		assert.Equal(t, []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}, delays)
+		assert.Equal(t, model.DeliveryPending, delivery.Status)
+		assert.Equal(t, 500, delivery.LastStatusCode)
+	})
+
+	t.Run("the delivery is dead once out of attempts", func(t *testing.T) {
+		policy.Failed(delivery, now, 0, "connection refused")
+		assert.Equal(t, model.DeliveryDead, delivery.Status)
+		assert.Equal(t, 5, delivery.Attempts)
+		assert.Equal(t, "connection refused", delivery.LastError)
+	})
+}
+
+func TestWebhookSubscription_Wants(t *testing.T) {
+	mine, other := uuid.New(), uuid.New()
+	event := &model.Event{Type: model.EventTransferCompleted, Accounts: []uuid.UUID{other, mine}}
+
+	assert.False(t, (&model.WebhookSubscription{}).Wants(event), "no account means none")
+	assert.True(t, (&model.WebhookSubscription{AllAccounts: true}).Wants(event))
+	assert.True(t, (&model.WebhookSubscription{Accounts: []uuid.UUID{mine}, EventTypes: []model.EventType{model.EventTransferCompleted}}).Wants(event))
+	assert.False(t, (&model.WebhookSubscription{AllAccounts: true, EventTypes: []model.EventType{model.EventMoneyDeposited}}).Wants(event))
+	assert.False(t, (&model.WebhookSubscription{Accounts: []uuid.UUID{uuid.New()}}).Wants(event))
+}
@@ -0,0 +1,96 @@
+package repositories_test
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestAccountRepository_AuditTrail(t *testing.T) {
+	db, err := repositories.OpenDB(repositories.DriverSQLite, "")
+	require.NoError(t, err)
+
+	impls := map[string]repositories.AccountRepository{
+		"memory": repositories.NewMemoryRepository(),
+		"db":     repositories.NewDBRepository(db),
+	}
+
+	for name, repo := range impls {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+			acc := &model.Account{ID: uuid.New(), Name: "billy", Amount: 10.00}
+			record := func(action model.AuditAction, actor string, at time.Time) *model.AuditRecord {
+				return &model.AuditRecord{ID: uuid.New(), Action: action, Actor: actor, EntityType: "account", EntityID: acc.ID, After: `{}`, CreatedAt: at}
+			}
+
+			t.Run("Given changes audited by different actors", func(t *testing.T) {
+				created := record(model.AuditAccountCreated, "teller", now)
+				require.NoError(t, repo.Apply(ctx, repositories.Change{
+					Created: []*model.Account{acc},
+					Audit:   []*model.AuditRecord{created},
+				}))
+				acc.Amount = 20.00
+				deposit := record(model.AuditDeposit, "customer", now.Add(time.Minute))
+				require.NoError(t, repo.Apply(ctx, repositories.Change{
+					Accounts: []*model.Account{acc},
+					Audit:    []*model.AuditRecord{deposit},
+				}))
+
+				t.Run("Then the records are chained in order", func(t *testing.T) {
+					records, lErr := repo.AuditTrail(ctx, repositories.AuditFilter{})
+					require.NoError(t, lErr)
+					require.Len(t, records, 2)
+					assert.Equal(t, created.ID, records[0].ID)
+					assert.Equal(t, int64(1), records[0].Sequence)
+					assert.Equal(t, "", records[0].PrevHash)
+					assert.Equal(t, int64(2), records[1].Sequence)
+					assert.Equal(t, records[0].Hash, records[1].PrevHash)
+					assert.True(t, model.VerifyAuditChain(records).Valid)
+				})
+
+				t.Run("Then the account was created with its record", func(t *testing.T) {
+					stored, gErr := repo.Get(ctx, acc.ID)
+					require.NoError(t, gErr)
+					assert.Equal(t, 20.00, stored.Amount)
+				})
+
+				t.Run("Then they can be filtered", func(t *testing.T) {
+					byActor, lErr := repo.AuditTrail(ctx, repositories.AuditFilter{Actor: "customer"})
+					require.NoError(t, lErr)
+					require.Len(t, byActor, 1)
+					assert.Equal(t, deposit.ID, byActor[0].ID)
+
+					byTime, lErr := repo.AuditTrail(ctx, repositories.AuditFilter{EntityID: acc.ID, From: now, To: now.Add(time.Minute)})
+					require.NoError(t, lErr)
+					require.Len(t, byTime, 1)
+					assert.Equal(t, created.ID, byTime[0].ID)
+
+					paged, lErr := repo.AuditTrail(ctx, repositories.AuditFilter{AfterSequence: 1, Limit: 1})
+					require.NoError(t, lErr)
+					require.Len(t, paged, 1)
+					assert.Equal(t, deposit.ID, paged[0].ID)
+				})
+			})
+
+			t.Run("When a change fails", func(t *testing.T) {
+				aErr := repo.Apply(ctx, repositories.Change{
+					Created: []*model.Account{acc},
+					Audit:   []*model.AuditRecord{record(model.AuditAccountCreated, "teller", now)},
+				})
+
+				t.Run("Then its records are not kept", func(t *testing.T) {
+					assert.ErrorIs(t, aErr, repositories.ErrAccountExists)
+					records, lErr := repo.AuditTrail(ctx, repositories.AuditFilter{})
+					require.NoError(t, lErr)
+					assert.Len(t, records, 2)
+				})
+			})
+		})
+	}
+}
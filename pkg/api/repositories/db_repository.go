@@ -2,33 +2,182 @@ package repositories
 
 import (
 	"bank/pkg/api/model"
+	"context"
+	"encoding/json"
+	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"sort"
+	"strings"
+	"time"
 )
 
 type AccountEntity struct {
-	ID     uuid.UUID `gorm:"column:id;PRIMARY_KEY"`
-	Name   string
-	Amount float64
+	ID              uuid.UUID `gorm:"column:id;PRIMARY_KEY"`
+	Name            string
+	CustomerID      string `gorm:"index;size:64"`
+	Amount          float64
+	Held            float64
+	Product         string
+	AccruedInterest float64
+	AccruedThrough  *time.Time
+	// MaintenanceChargedAt is the month the maintenance fee was last charged for
+	MaintenanceChargedAt *time.Time
+}
+
+type LedgerEntryEntity struct {
+	// Seq is given by the database, it keeps entries written in the same
+	// instant in order
+	Seq         int64     `gorm:"primaryKey;autoIncrement"`
+	ID          uuid.UUID `gorm:"column:id;uniqueIndex"`
+	AccountID   uuid.UUID `gorm:"index"`
+	Type        string
+	Amount      float64
+	Balance     float64
+	Description string
+	CreatedAt   time.Time `gorm:"index"`
+}
+
+type InterestAccrualEntity struct {
+	AccountID uuid.UUID `gorm:"primaryKey"`
+	Date      time.Time `gorm:"primaryKey"`
+	Balance   float64
+	Rate      float64
+	Amount    float64
+}
+
+type HoldEntity struct {
+	ID        uuid.UUID `gorm:"column:id;PRIMARY_KEY"`
+	AccountID uuid.UUID `gorm:"index"`
+	To        uuid.UUID `gorm:"column:to_account"`
+	Amount    float64
+	Captured  float64
+	Status    string `gorm:"index"`
+	Reference string
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type AuditRecordEntity struct {
+	Sequence   int64     `gorm:"primaryKey;autoIncrement:false"`
+	ID         uuid.UUID `gorm:"column:id;uniqueIndex"`
+	Action     string    `gorm:"index"`
+	Actor      string    `gorm:"index"`
+	RequestID  string
+	SourceIP   string
+	EntityType string
+	EntityID   uuid.UUID `gorm:"index"`
+	Before     string
+	After      string
+	CreatedAt  time.Time `gorm:"index"`
+	PrevHash   string
+	Hash       string
+}
+
+// AuditHeadEntity is the single row pointing at the last audit record. Every
+// writer locks it, so records are chained one after the other even with
+// several api replicas.
+type AuditHeadEntity struct {
+	ID       int `gorm:"primaryKey;autoIncrement:false"`
+	Sequence int64
+	Hash     string
+}
+
+// OutboxEventEntity is an event waiting in the outbox, or already published
+type OutboxEventEntity struct {
+	Sequence    int64     `gorm:"primaryKey;autoIncrement"`
+	ID          uuid.UUID `gorm:"column:id;uniqueIndex"`
+	Type        string
+	AggregateID uuid.UUID
+	// Accounts are comma separated
+	Accounts    string
+	Payload     string
+	OccurredAt  time.Time
+	PublishedAt *time.Time `gorm:"index"`
+	Attempts    int
+	LastError   string
+}
+
+// OutboxEventAccountEntity indexes the events of the outbox by the accounts
+// they concern, so the events of an account are read without a scan
+type OutboxEventAccountEntity struct {
+	AccountID uuid.UUID `gorm:"primaryKey"`
+	Sequence  int64     `gorm:"primaryKey;autoIncrement:false"`
+}
+
+// AccountEventEntity is an event of the stream of an account, the key keeps
+// two writers from appending the same version
+type AccountEventEntity struct {
+	AccountID  uuid.UUID `gorm:"primaryKey"`
+	Version    int64     `gorm:"primaryKey;autoIncrement:false"`
+	Type       string
+	Data       string
+	OccurredAt time.Time
+}
+
+// AccountSnapshotEntity is the latest snapshot of an account, Account is its
+// json
+type AccountSnapshotEntity struct {
+	AccountID uuid.UUID `gorm:"primaryKey"`
+	Version   int64
+	Account   string
+	TakenAt   time.Time
+}
+
+type TransferEntity struct {
+	// Seq is given by the database when the transfer is first stored and
+	// kept by later updates, it keeps transfers stored in the same instant in
+	// order
+	Seq           int64     `gorm:"primaryKey;autoIncrement"`
+	ID            uuid.UUID `gorm:"column:id;uniqueIndex"`
+	From          uuid.UUID `gorm:"column:from_account;index"`
+	To            uuid.UUID `gorm:"column:to_account;index"`
+	Amount        float64
+	Fee           float64
+	Reversed      float64
+	ReversalOf    *uuid.UUID `gorm:"index"`
+	Status        string     `gorm:"index"`
+	FailureReason string
+	// ScreeningReason tells which fraud rules held or denied the transfer
+	ScreeningReason string
+	Memo            string
+	CreatedAt       time.Time `gorm:"index"`
+	UpdatedAt       time.Time
+	Legs            []TransferLegEntity `gorm:"foreignKey:TransferID;references:ID"`
+}
+
+type TransferLegEntity struct {
+	TransferID uuid.UUID `gorm:"primaryKey"`
+	AccountID  uuid.UUID `gorm:"primaryKey;index"`
+	Amount     float64
+	// Position keeps the legs in the order they were given
+	Position int
+}
+
+type LimitUsageEntity struct {
+	Subject   string `gorm:"primaryKey;size:64"`
+	Operation string `gorm:"primaryKey;size:16"`
+	Period    string `gorm:"primaryKey;size:16"`
+	Amount    float64
+	Count     int
 }
 
 type dbRepository struct {
-	db *gorm.DB
+	db      *gorm.DB
+	locking []clause.Expression
 }
 
-func (d *dbRepository) GetAll() ([]*model.Account, error) {
+func (d *dbRepository) GetAll(ctx context.Context) ([]*model.Account, error) {
 	var accountsEnt []AccountEntity
-	if err := d.db.Find(&accountsEnt).Error; err != nil {
+	if err := d.db.WithContext(ctx).Find(&accountsEnt).Error; err != nil {
 		return nil, err
 	}
 
 	accounts := make([]*model.Account, len(accountsEnt))
 	for i := range accountsEnt {
-		accounts[i] = &model.Account{
-			ID:     accountsEnt[i].ID,
-			Name:   accountsEnt[i].Name,
-			Amount: accountsEnt[i].Amount,
-		}
+		accounts[i] = toAccountModel(accountsEnt[i])
 	}
 
 	return accounts, nil
@@ -36,68 +185,777 @@ func (d *dbRepository) GetAll() ([]*model.Account, error) {
 
 func NewDBRepository(db *gorm.DB) AccountRepository {
 	return &dbRepository{
-		db: db,
+		db:      db,
+		locking: rowLocking(db.Dialector.Name()),
 	}
 }
 
-func (d *dbRepository) Create(account *model.Account) error {
-	entity := AccountEntity{
-		ID:     account.ID,
-		Name:   account.Name,
-		Amount: account.Amount,
-	}
+func (d *dbRepository) Create(ctx context.Context, account *model.Account) error {
+	entity := toAccountEntity(account)
 
-	if cErr := d.db.Create(&entity).Error; cErr != nil {
+	if cErr := d.db.WithContext(ctx).Create(&entity).Error; cErr != nil {
+		// duplicated keys are reported differently by every driver
+		var count int64
+		if d.db.WithContext(ctx).Model(&AccountEntity{}).Where("id = ?", entity.ID).Count(&count).Error == nil && count > 0 {
+			return ErrAccountExists
+		}
 		return cErr
 	}
 
 	return nil
 }
 
-func (d dbRepository) Update(account *model.Account) error {
-	accEnt := AccountEntity{
-		ID:     account.ID,
-		Name:   account.Name,
-		Amount: account.Amount,
-	}
-
-	if err := d.db.Save(&accEnt).Error; err != nil {
-		return err
-	}
-
-	return nil
+func (d *dbRepository) Update(ctx context.Context, account *model.Account) error {
+	return d.UpdatesTx(ctx, account)
 }
 
-func (d dbRepository) Get(accountID uuid.UUID) (*model.Account, error) {
+func (d *dbRepository) Get(ctx context.Context, accountID uuid.UUID) (*model.Account, error) {
 	var accEnt AccountEntity
 
-	if err := d.db.First(&accEnt, accountID).Error; err != nil {
+	if err := d.db.WithContext(ctx).First(&accEnt, accountID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAccountNotFound
+		}
 		return nil, err
 	}
 
-	return &model.Account{
-		ID:     accEnt.ID,
-		Name:   accEnt.Name,
-		Amount: accEnt.Amount,
-	}, nil
+	return toAccountModel(accEnt), nil
+}
+
+func (d *dbRepository) UpdatesTx(ctx context.Context, accounts ...*model.Account) error {
+	return d.Apply(ctx, Change{Accounts: accounts})
 }
 
-func (d *dbRepository) UpdatesTx(accounts ...*model.Account) error {
-	txErr := d.db.Transaction(func(tx *gorm.DB) error {
-		for _, acc := range accounts {
+func (d *dbRepository) Apply(ctx context.Context, change Change) error {
+	txErr := d.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if lErr := d.lock(tx, change); lErr != nil {
+			return lErr
+		}
+
+		for _, acc := range change.Created {
+			var count int64
+			if cErr := tx.Model(&AccountEntity{}).Where("id = ?", acc.ID).Count(&count).Error; cErr != nil {
+				return cErr
+			}
+			if count > 0 {
+				return ErrAccountExists
+			}
+			ent := toAccountEntity(acc)
+			if cErr := tx.Create(&ent).Error; cErr != nil {
+				return cErr
+			}
+		}
 
-			ent := AccountEntity{
-				ID:     acc.ID,
-				Name:   acc.Name,
-				Amount: acc.Amount,
+		for _, acc := range change.Accounts {
+			values := map[string]interface{}{
+				"name":                   acc.Name,
+				"amount":                 acc.Amount,
+				"held":                   acc.Held,
+				"product":                acc.Product,
+				"accrued_interest":       acc.AccruedInterest,
+				"accrued_through":        acc.AccruedThrough,
+				"maintenance_charged_at": acc.MaintenanceChargedAt,
 			}
 
-			if saveToErr := tx.Save(&ent).Error; saveToErr != nil {
+			if saveToErr := tx.Model(&AccountEntity{}).Where("id = ?", acc.ID).Updates(values).Error; saveToErr != nil {
 				return saveToErr
 			}
 		}
+
+		if len(change.Entries) > 0 {
+			entries := make([]LedgerEntryEntity, len(change.Entries))
+			for i, entry := range change.Entries {
+				entries[i] = LedgerEntryEntity{
+					ID:          entry.ID,
+					AccountID:   entry.AccountID,
+					Type:        string(entry.Type),
+					Amount:      entry.Amount,
+					Balance:     entry.Balance,
+					Description: entry.Description,
+					CreatedAt:   entry.CreatedAt,
+				}
+			}
+			if eErr := tx.Create(&entries).Error; eErr != nil {
+				return eErr
+			}
+		}
+
+		for _, hold := range change.Holds {
+			ent := toHoldEntity(hold)
+			if hErr := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&ent).Error; hErr != nil {
+				return hErr
+			}
+		}
+
+		if len(change.NewTransfers) > 0 {
+			ids := make([]uuid.UUID, len(change.NewTransfers))
+			for i, transfer := range change.NewTransfers {
+				ids[i] = transfer.ID
+			}
+			var count int64
+			if cErr := tx.Model(&TransferEntity{}).Where("id IN ?", ids).Count(&count).Error; cErr != nil {
+				return cErr
+			}
+			if count > 0 {
+				return ErrTransferExists
+			}
+		}
+
+		for _, transfer := range change.NewTransfers {
+			ent := toTransferEntity(transfer)
+			// no upsert, a transfer created concurrently fails on its key
+			if tErr := tx.Create(&ent).Error; tErr != nil {
+				return tErr
+			}
+		}
+		for _, transfer := range change.Transfers {
+			ent := toTransferEntity(transfer)
+			if tErr := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "id"}}, DoUpdates: clause.AssignmentColumns(transferUpdates)}).Create(&ent).Error; tErr != nil {
+				return tErr
+			}
+		}
+
+		for _, usage := range change.Usage {
+			ent := LimitUsageEntity{
+				Subject:   usage.Subject,
+				Operation: string(usage.Operation),
+				Period:    usage.Period,
+				Amount:    usage.Amount,
+				Count:     usage.Count,
+			}
+			if uErr := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&ent).Error; uErr != nil {
+				return uErr
+			}
+		}
+
+		if len(change.Accruals) > 0 {
+			accruals := make([]InterestAccrualEntity, len(change.Accruals))
+			for i, accrual := range change.Accruals {
+				accruals[i] = InterestAccrualEntity(*accrual)
+			}
+			if aErr := tx.Create(&accruals).Error; aErr != nil {
+				return aErr
+			}
+		}
+
+		if len(change.Events) > 0 {
+			ents := make([]OutboxEventEntity, len(change.Events))
+			for i, event := range change.Events {
+				ents[i] = OutboxEventEntity{
+					ID:          event.ID,
+					Type:        string(event.Type),
+					AggregateID: event.AggregateID,
+					Accounts:    joinIDs(event.Accounts),
+					Payload:     event.Payload,
+					OccurredAt:  event.OccurredAt,
+				}
+			}
+			if eErr := tx.Create(&ents).Error; eErr != nil {
+				return eErr
+			}
+			var index []OutboxEventAccountEntity
+			for i, event := range change.Events {
+				event.Sequence = ents[i].Sequence
+				for _, accountID := range event.Accounts {
+					index = append(index, OutboxEventAccountEntity{AccountID: accountID, Sequence: event.Sequence})
+				}
+			}
+			if len(index) > 0 {
+				if iErr := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&index).Error; iErr != nil {
+					return iErr
+				}
+			}
+		}
+
+		if len(change.History) > 0 {
+			if hErr := d.appendHistory(tx, change.History); hErr != nil {
+				return hErr
+			}
+		}
+
+		if len(change.Audit) > 0 {
+			return d.appendAudit(tx, change.Audit)
+		}
 		return nil
 	})
 
 	return txErr
 }
+
+// appendHistory stores events after the last one of their streams. The
+// accounts are locked already, so the versions read can not change.
+func (d *dbRepository) appendHistory(tx *gorm.DB, events []*model.AccountEvent) error {
+	if err := checkHistory(events, func(accountID uuid.UUID) (int64, error) {
+		var version int64
+		err := tx.Model(&AccountEventEntity{}).Where("account_id = ?", accountID).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+		return version, err
+	}); err != nil {
+		return err
+	}
+
+	ents := make([]AccountEventEntity, len(events))
+	for i, event := range events {
+		ents[i] = AccountEventEntity{
+			AccountID:  event.AccountID,
+			Version:    event.Version,
+			Type:       string(event.Type),
+			Data:       event.Data,
+			OccurredAt: event.OccurredAt,
+		}
+	}
+	return tx.Create(&ents).Error
+}
+
+// appendAudit chains records after the last stored one. The head is locked
+// after the accounts, always last, so it adds no deadlock.
+//
+// The head is a single row, so every transaction with audit records commits
+// one after the other, even on unrelated accounts. That is the price of a
+// single chain with gapless sequences, see the caveats of the README.
+func (d *dbRepository) appendAudit(tx *gorm.DB, records []*model.AuditRecord) error {
+	var head AuditHeadEntity
+	if err := tx.Clauses(d.locking...).Where("id = ?", auditHeadID).First(&head).Error; err != nil {
+		return err
+	}
+
+	ents := make([]AuditRecordEntity, len(records))
+	for i, record := range records {
+		record.Chain(head.Sequence+1, head.Hash)
+		head.Sequence, head.Hash = record.Sequence, record.Hash
+		ents[i] = AuditRecordEntity{
+			Sequence:   record.Sequence,
+			ID:         record.ID,
+			Action:     string(record.Action),
+			Actor:      record.Actor,
+			RequestID:  record.RequestID,
+			SourceIP:   record.SourceIP,
+			EntityType: record.EntityType,
+			EntityID:   record.EntityID,
+			Before:     record.Before,
+			After:      record.After,
+			CreatedAt:  record.CreatedAt,
+			PrevHash:   record.PrevHash,
+			Hash:       record.Hash,
+		}
+	}
+	if err := tx.Create(&ents).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&AuditHeadEntity{}).Where("id = ?", auditHeadID).
+		Updates(map[string]interface{}{"sequence": head.Sequence, "hash": head.Hash}).Error
+}
+
+// lock locks every account touched by change. Rows are locked always in the
+// same order so two transactions touching the same accounts can not deadlock
+// each other.
+func (d *dbRepository) lock(tx *gorm.DB, change Change) error {
+	unique := make(map[string]struct{})
+	ids := make([]string, 0, len(change.Accounts))
+	add := func(id uuid.UUID) {
+		if _, ok := unique[id.String()]; !ok {
+			unique[id.String()] = struct{}{}
+			ids = append(ids, id.String())
+		}
+	}
+	for _, acc := range change.Accounts {
+		add(acc.ID)
+	}
+	for _, entry := range change.Entries {
+		add(entry.AccountID)
+	}
+	for _, hold := range change.Holds {
+		add(hold.AccountID)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+
+	var locked []AccountEntity
+	if lErr := tx.Clauses(d.locking...).Where("id IN ?", ids).Order("id").Find(&locked).Error; lErr != nil {
+		return lErr
+	}
+	if len(locked) != len(ids) {
+		return ErrAccountNotFound
+	}
+
+	return nil
+}
+
+func (d *dbRepository) Entries(ctx context.Context, accountID uuid.UUID) ([]*model.LedgerEntry, error) {
+	var ents []LedgerEntryEntity
+	err := d.db.WithContext(ctx).
+		Where("account_id = ?", accountID).
+		Order("created_at, seq").
+		Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*model.LedgerEntry, len(ents))
+	for i, ent := range ents {
+		entries[i] = &model.LedgerEntry{
+			ID:          ent.ID,
+			AccountID:   ent.AccountID,
+			Type:        model.EntryType(ent.Type),
+			Amount:      ent.Amount,
+			Balance:     ent.Balance,
+			Description: ent.Description,
+			CreatedAt:   ent.CreatedAt,
+		}
+	}
+
+	return entries, nil
+}
+
+func (d *dbRepository) Accruals(ctx context.Context, accountID uuid.UUID) ([]*model.InterestAccrual, error) {
+	var ents []InterestAccrualEntity
+	err := d.db.WithContext(ctx).
+		Where("account_id = ?", accountID).
+		Order("date").
+		Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	accruals := make([]*model.InterestAccrual, len(ents))
+	for i := range ents {
+		accrual := model.InterestAccrual(ents[i])
+		accruals[i] = &accrual
+	}
+
+	return accruals, nil
+}
+
+func (d *dbRepository) GetHold(ctx context.Context, holdID uuid.UUID) (*model.Hold, error) {
+	var ent HoldEntity
+	if err := d.db.WithContext(ctx).Where("id = ?", holdID).First(&ent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrHoldNotFound
+		}
+		return nil, err
+	}
+
+	return toHoldModel(ent), nil
+}
+
+func (d *dbRepository) GetTransfer(ctx context.Context, transferID uuid.UUID) (*model.Transfer, error) {
+	var ent TransferEntity
+	if err := d.db.WithContext(ctx).Preload("Legs", orderLegs).Where("id = ?", transferID).First(&ent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+
+	return toTransferModel(ent), nil
+}
+
+func (d *dbRepository) Transfers(ctx context.Context, accountID uuid.UUID) ([]*model.Transfer, error) {
+	var ents []TransferEntity
+	legs := d.db.Model(&TransferLegEntity{}).Select("transfer_id").Where("account_id = ?", accountID)
+	err := d.db.WithContext(ctx).
+		Preload("Legs", orderLegs).
+		Where("from_account = ? OR to_account = ? OR id IN (?)", accountID, accountID, legs).
+		Order("created_at, seq").
+		Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := make([]*model.Transfer, len(ents))
+	for i := range ents {
+		transfers[i] = toTransferModel(ents[i])
+	}
+	return transfers, nil
+}
+
+func (d *dbRepository) TransfersByStatus(ctx context.Context, status model.TransferStatus) ([]*model.Transfer, error) {
+	var ents []TransferEntity
+	err := d.db.WithContext(ctx).
+		Preload("Legs", orderLegs).
+		Where("status = ?", string(status)).
+		Order("created_at, seq").
+		Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := make([]*model.Transfer, len(ents))
+	for i := range ents {
+		transfers[i] = toTransferModel(ents[i])
+	}
+	return transfers, nil
+}
+
+func (d *dbRepository) SentTransfers(ctx context.Context, filter SentFilter) ([]*model.Transfer, error) {
+	query := d.db.WithContext(ctx).Preload("Legs", orderLegs).Where("from_account = ?", filter.From)
+	if filter.To != uuid.Nil {
+		query = query.Where("to_account = ?", filter.To)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", string(filter.Status))
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var ents []TransferEntity
+	if err := query.Order("created_at, seq").Find(&ents).Error; err != nil {
+		return nil, err
+	}
+
+	transfers := make([]*model.Transfer, len(ents))
+	for i := range ents {
+		transfers[i] = toTransferModel(ents[i])
+	}
+	return transfers, nil
+}
+
+func (d *dbRepository) AuditTrail(ctx context.Context, filter AuditFilter) ([]*model.AuditRecord, error) {
+	query := d.db.WithContext(ctx).Where("sequence > ?", filter.AfterSequence)
+	if filter.EntityID != uuid.Nil {
+		query = query.Where("entity_id = ?", filter.EntityID)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", string(filter.Action))
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+
+	var ents []AuditRecordEntity
+	if err := query.Order("sequence").Find(&ents).Error; err != nil {
+		return nil, err
+	}
+
+	records := make([]*model.AuditRecord, len(ents))
+	for i, ent := range ents {
+		records[i] = &model.AuditRecord{
+			Sequence:   ent.Sequence,
+			ID:         ent.ID,
+			Action:     model.AuditAction(ent.Action),
+			Actor:      ent.Actor,
+			RequestID:  ent.RequestID,
+			SourceIP:   ent.SourceIP,
+			EntityType: ent.EntityType,
+			EntityID:   ent.EntityID,
+			Before:     ent.Before,
+			After:      ent.After,
+			CreatedAt:  ent.CreatedAt,
+			PrevHash:   ent.PrevHash,
+			Hash:       ent.Hash,
+		}
+	}
+	return records, nil
+}
+
+func (d *dbRepository) UnpublishedEvents(ctx context.Context, limit int) ([]*model.Event, error) {
+	var ents []OutboxEventEntity
+	if err := d.db.WithContext(ctx).Where("published_at IS NULL").Order("sequence").Limit(limit).Find(&ents).Error; err != nil {
+		return nil, err
+	}
+
+	return toEvents(ents), nil
+}
+
+func (d *dbRepository) LastEventSequence(ctx context.Context) (int64, error) {
+	var sequence int64
+	if err := d.db.WithContext(ctx).Model(&OutboxEventEntity{}).Select("COALESCE(MAX(sequence), 0)").Scan(&sequence).Error; err != nil {
+		return 0, err
+	}
+	return sequence, nil
+}
+
+func (d *dbRepository) AccountEvents(ctx context.Context, accountID uuid.UUID, after int64, limit int) ([]*model.Event, error) {
+	var ents []OutboxEventEntity
+	if err := d.db.WithContext(ctx).
+		Select("outbox_event_entities.*").
+		Joins("JOIN outbox_event_account_entities ea ON ea.sequence = outbox_event_entities.sequence").
+		Where("ea.account_id = ? AND ea.sequence > ?", accountID, after).
+		Order("ea.sequence").Limit(limit).Find(&ents).Error; err != nil {
+		return nil, err
+	}
+	return toEvents(ents), nil
+}
+
+func toEvents(ents []OutboxEventEntity) []*model.Event {
+	events := make([]*model.Event, len(ents))
+	for i, ent := range ents {
+		events[i] = &model.Event{
+			Sequence:    ent.Sequence,
+			ID:          ent.ID,
+			Type:        model.EventType(ent.Type),
+			AggregateID: ent.AggregateID,
+			Accounts:    splitIDs(ent.Accounts),
+			Payload:     ent.Payload,
+			OccurredAt:  ent.OccurredAt,
+			PublishedAt: ent.PublishedAt,
+			Attempts:    ent.Attempts,
+			LastError:   ent.LastError,
+		}
+	}
+	return events
+}
+
+func (d *dbRepository) History(ctx context.Context, accountID uuid.UUID) (*model.AccountSnapshot, []*model.AccountEvent, error) {
+	var snapshot *model.AccountSnapshot
+	var snapshotEnt AccountSnapshotEntity
+	res := d.db.WithContext(ctx).Where("account_id = ?", accountID).Limit(1).Find(&snapshotEnt)
+	if res.Error != nil {
+		return nil, nil, res.Error
+	}
+	if res.RowsAffected > 0 {
+		snapshot = &model.AccountSnapshot{Version: snapshotEnt.Version, TakenAt: snapshotEnt.TakenAt}
+		if err := json.Unmarshal([]byte(snapshotEnt.Account), &snapshot.Account); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var after int64
+	if snapshot != nil {
+		after = snapshot.Version
+	}
+	var ents []AccountEventEntity
+	if err := d.db.WithContext(ctx).Where("account_id = ? AND version > ?", accountID, after).Order("version").Find(&ents).Error; err != nil {
+		return nil, nil, err
+	}
+
+	events := make([]*model.AccountEvent, len(ents))
+	for i, ent := range ents {
+		events[i] = &model.AccountEvent{
+			AccountID:  ent.AccountID,
+			Version:    ent.Version,
+			Type:       model.AccountEventType(ent.Type),
+			Data:       ent.Data,
+			OccurredAt: ent.OccurredAt,
+		}
+	}
+	return snapshot, events, nil
+}
+
+func (d *dbRepository) SaveSnapshot(ctx context.Context, snapshot *model.AccountSnapshot) error {
+	account, err := json.Marshal(snapshot.Account)
+	if err != nil {
+		return err
+	}
+	ent := AccountSnapshotEntity{
+		AccountID: snapshot.Account.ID,
+		Version:   snapshot.Version,
+		Account:   string(account),
+		TakenAt:   snapshot.TakenAt,
+	}
+	return d.db.WithContext(ctx).Clauses(clause.OnConflict{UpdateAll: true}).Create(&ent).Error
+}
+
+func (d *dbRepository) MarkPublished(ctx context.Context, eventID uuid.UUID, at time.Time) error {
+	return d.updateEvent(ctx, eventID, map[string]interface{}{"published_at": at})
+}
+
+func (d *dbRepository) MarkFailed(ctx context.Context, eventID uuid.UUID, reason string) error {
+	return d.updateEvent(ctx, eventID, map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": reason,
+	})
+}
+
+func (d *dbRepository) updateEvent(ctx context.Context, eventID uuid.UUID, values map[string]interface{}) error {
+	res := d.db.WithContext(ctx).Model(&OutboxEventEntity{}).Where("id = ?", eventID).Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrEventNotFound
+	}
+	return nil
+}
+
+func (d *dbRepository) Usage(ctx context.Context, subject string, op model.LimitOperation, period string) (*model.LimitUsage, error) {
+	usage := &model.LimitUsage{Subject: subject, Operation: op, Period: period}
+
+	var ent LimitUsageEntity
+	err := d.db.WithContext(ctx).
+		Where("subject = ? AND operation = ? AND period = ?", subject, string(op), period).
+		First(&ent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return usage, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	usage.Amount = ent.Amount
+	usage.Count = ent.Count
+	return usage, nil
+}
+
+func (d *dbRepository) Holds(ctx context.Context, accountID uuid.UUID) ([]*model.Hold, error) {
+	return d.findHolds(d.db.WithContext(ctx).Where("account_id = ?", accountID))
+}
+
+func (d *dbRepository) ExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*model.Hold, error) {
+	return d.findHolds(d.db.WithContext(ctx).
+		Where("status = ? AND expires_at <= ?", model.HoldActive, now).
+		Limit(limit))
+}
+
+func (d *dbRepository) findHolds(query *gorm.DB) ([]*model.Hold, error) {
+	var ents []HoldEntity
+	if err := query.Order("created_at").Find(&ents).Error; err != nil {
+		return nil, err
+	}
+
+	holds := make([]*model.Hold, len(ents))
+	for i := range ents {
+		holds[i] = toHoldModel(ents[i])
+	}
+	return holds, nil
+}
+
+func toAccountEntity(account *model.Account) AccountEntity {
+	return AccountEntity{
+		ID:                   account.ID,
+		Name:                 account.Name,
+		CustomerID:           account.CustomerID,
+		Amount:               account.Amount,
+		Held:                 account.Held,
+		Product:              account.Product,
+		AccruedInterest:      account.AccruedInterest,
+		AccruedThrough:       account.AccruedThrough,
+		MaintenanceChargedAt: account.MaintenanceChargedAt,
+	}
+}
+
+func toAccountModel(ent AccountEntity) *model.Account {
+	return &model.Account{
+		ID:                   ent.ID,
+		Name:                 ent.Name,
+		CustomerID:           ent.CustomerID,
+		Amount:               ent.Amount,
+		Held:                 ent.Held,
+		Product:              ent.Product,
+		AccruedInterest:      ent.AccruedInterest,
+		AccruedThrough:       ent.AccruedThrough,
+		MaintenanceChargedAt: ent.MaintenanceChargedAt,
+	}
+}
+
+func toHoldEntity(hold *model.Hold) HoldEntity {
+	return HoldEntity{
+		ID:        hold.ID,
+		AccountID: hold.AccountID,
+		To:        hold.To,
+		Amount:    hold.Amount,
+		Captured:  hold.Captured,
+		Status:    string(hold.Status),
+		Reference: hold.Reference,
+		ExpiresAt: hold.ExpiresAt,
+		CreatedAt: hold.CreatedAt,
+		UpdatedAt: hold.UpdatedAt,
+	}
+}
+
+func toHoldModel(ent HoldEntity) *model.Hold {
+	return &model.Hold{
+		ID:        ent.ID,
+		AccountID: ent.AccountID,
+		To:        ent.To,
+		Amount:    ent.Amount,
+		Captured:  ent.Captured,
+		Status:    model.HoldStatus(ent.Status),
+		Reference: ent.Reference,
+		ExpiresAt: ent.ExpiresAt,
+		CreatedAt: ent.CreatedAt,
+		UpdatedAt: ent.UpdatedAt,
+	}
+}
+
+func orderLegs(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
+
+// transferUpdates are the columns of a stored transfer a later change may set
+var transferUpdates = []string{"from_account", "to_account", "amount", "fee", "reversed", "reversal_of", "status", "failure_reason", "screening_reason", "memo", "updated_at"}
+
+func toTransferEntity(transfer *model.Transfer) TransferEntity {
+	var legs []TransferLegEntity
+	for i, leg := range transfer.Legs {
+		legs = append(legs, TransferLegEntity{TransferID: transfer.ID, AccountID: leg.AccountID, Amount: leg.Amount, Position: i})
+	}
+	return TransferEntity{
+		ID:              transfer.ID,
+		From:            transfer.From,
+		To:              transfer.To,
+		Amount:          transfer.Amount,
+		Fee:             transfer.Fee,
+		Reversed:        transfer.Reversed,
+		ReversalOf:      transfer.ReversalOf,
+		Status:          string(transfer.Status),
+		FailureReason:   transfer.FailureReason,
+		ScreeningReason: transfer.ScreeningReason,
+		Memo:            transfer.Memo,
+		CreatedAt:       transfer.CreatedAt,
+		UpdatedAt:       transfer.UpdatedAt,
+		Legs:            legs,
+	}
+}
+
+func toTransferModel(ent TransferEntity) *model.Transfer {
+	var legs []model.TransferLeg
+	for _, leg := range ent.Legs {
+		legs = append(legs, model.TransferLeg{AccountID: leg.AccountID, Amount: leg.Amount})
+	}
+	return &model.Transfer{
+		ID:              ent.ID,
+		From:            ent.From,
+		To:              ent.To,
+		Amount:          ent.Amount,
+		Fee:             ent.Fee,
+		Reversed:        ent.Reversed,
+		ReversalOf:      ent.ReversalOf,
+		Status:          model.TransferStatus(ent.Status),
+		FailureReason:   ent.FailureReason,
+		ScreeningReason: ent.ScreeningReason,
+		Memo:            ent.Memo,
+		CreatedAt:       ent.CreatedAt,
+		UpdatedAt:       ent.UpdatedAt,
+		Legs:            legs,
+	}
+}
+
+// joinIDs keeps a list of ids in a single column, splitIDs reads it back
+func joinIDs(ids []uuid.UUID) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = id.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func splitIDs(column string) []uuid.UUID {
+	if column == "" {
+		return nil
+	}
+	parts := strings.Split(column, ",")
+	ids := make([]uuid.UUID, 0, len(parts))
+	for _, part := range parts {
+		// only ever written by joinIDs
+		if id, err := uuid.Parse(part); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,70 @@
+package repositories_test
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/repositories/repotest"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestDBRepository runs against an embedded sqlite database unless
+// TEST_DB_DRIVER selects another one
+func TestDBRepository(t *testing.T) {
+	driver := os.Getenv("TEST_DB_DRIVER")
+	if driver == "" {
+		driver = repositories.DriverSQLite
+	}
+
+	repotest.Run(t, func(t *testing.T) repositories.AccountRepository {
+		db, err := repositories.OpenDB(driver, os.Getenv("TEST_DB_DSN"))
+		require.NoError(t, err)
+		return repositories.NewDBRepository(db)
+	})
+}
+
+func TestDBRepository_TransferSeq(t *testing.T) {
+	ctx := context.Background()
+	db, err := repositories.OpenDB(repositories.DriverSQLite, "")
+	require.NoError(t, err)
+	repo := repositories.NewDBRepository(db)
+	seqOf := func(t *testing.T, transferID uuid.UUID) int64 {
+		var ent repositories.TransferEntity
+		require.NoError(t, db.Where("id = ?", transferID).First(&ent).Error)
+		return ent.Seq
+	}
+
+	t.Run("Given a stored transfer", func(t *testing.T) {
+		now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+		transfer := &model.Transfer{ID: uuid.New(), From: uuid.New(), To: uuid.New(), Amount: 1.00, Status: model.TransferInReview, CreatedAt: now, UpdatedAt: now}
+		require.NoError(t, repo.Apply(ctx, repositories.Change{Transfers: []*model.Transfer{transfer}}))
+		seq := seqOf(t, transfer.ID)
+
+		t.Run("When it is updated", func(t *testing.T) {
+			transfer.Status = model.TransferCompleted
+			transfer.UpdatedAt = now.Add(time.Minute)
+			require.NoError(t, repo.Apply(ctx, repositories.Change{Transfers: []*model.Transfer{transfer}}))
+
+			t.Run("Then it keeps its seq", func(t *testing.T) {
+				assert.Equal(t, seq, seqOf(t, transfer.ID))
+				got, gErr := repo.GetTransfer(ctx, transfer.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, model.TransferCompleted, got.Status)
+			})
+		})
+
+		t.Run("When another one is stored in the same instant", func(t *testing.T) {
+			later := &model.Transfer{ID: uuid.New(), From: uuid.New(), To: uuid.New(), Amount: 1.00, Status: model.TransferCompleted, CreatedAt: now, UpdatedAt: now}
+			require.NoError(t, repo.Apply(ctx, repositories.Change{NewTransfers: []*model.Transfer{later}}))
+
+			t.Run("Then the database gives it a greater seq", func(t *testing.T) {
+				assert.Greater(t, seqOf(t, later.ID), seq)
+			})
+		})
+	})
+}
@@ -0,0 +1,105 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type SanctionsCheckEntity struct {
+	ID        uuid.UUID `gorm:"column:id;PRIMARY_KEY"`
+	Subject   string
+	SubjectID uuid.UUID `gorm:"index"`
+	Name      string
+	Decision  string                 `gorm:"index"`
+	CreatedAt time.Time              `gorm:"index"`
+	Matches   []SanctionsMatchEntity `gorm:"foreignKey:CheckID"`
+}
+
+type SanctionsMatchEntity struct {
+	CheckID  uuid.UUID `gorm:"primaryKey"`
+	Position int       `gorm:"primaryKey"`
+	EntryID  string
+	List     string
+	Name     string
+	Score    float64
+	Action   string
+}
+
+type dbSanctionsRepository struct {
+	db *gorm.DB
+}
+
+func NewDBSanctionsRepository(db *gorm.DB) SanctionsRepository {
+	return &dbSanctionsRepository{
+		db: db,
+	}
+}
+
+func (d *dbSanctionsRepository) Record(ctx context.Context, check *model.SanctionsCheck) error {
+	var matches []SanctionsMatchEntity
+	for i, match := range check.Matches {
+		matches = append(matches, SanctionsMatchEntity{
+			CheckID:  check.ID,
+			Position: i,
+			EntryID:  match.EntryID,
+			List:     match.List,
+			Name:     match.Name,
+			Score:    match.Score,
+			Action:   string(match.Action),
+		})
+	}
+	ent := SanctionsCheckEntity{
+		ID:        check.ID,
+		Subject:   string(check.Subject),
+		SubjectID: check.SubjectID,
+		Name:      check.Name,
+		Decision:  string(check.Decision),
+		CreatedAt: check.CreatedAt,
+		Matches:   matches,
+	}
+	return d.db.WithContext(ctx).Create(&ent).Error
+}
+
+func (d *dbSanctionsRepository) List(ctx context.Context, filter SanctionsFilter) ([]*model.SanctionsCheck, error) {
+	query := d.db.WithContext(ctx).Preload("Matches", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	})
+	if filter.SubjectID != uuid.Nil {
+		query = query.Where("subject_id = ?", filter.SubjectID)
+	}
+	if filter.Decision != "" {
+		query = query.Where("decision = ?", string(filter.Decision))
+	}
+
+	var ents []SanctionsCheckEntity
+	if err := query.Order("created_at").Find(&ents).Error; err != nil {
+		return nil, err
+	}
+
+	checks := make([]*model.SanctionsCheck, len(ents))
+	for i, ent := range ents {
+		var matches []model.SanctionsMatch
+		for _, match := range ent.Matches {
+			matches = append(matches, model.SanctionsMatch{
+				EntryID: match.EntryID,
+				List:    match.List,
+				Name:    match.Name,
+				Score:   match.Score,
+				Action:  model.WatchlistAction(match.Action),
+			})
+		}
+		checks[i] = &model.SanctionsCheck{
+			ID:        ent.ID,
+			Subject:   model.SanctionsSubject(ent.Subject),
+			SubjectID: ent.SubjectID,
+			Name:      ent.Name,
+			Decision:  model.SanctionsDecision(ent.Decision),
+			Matches:   matches,
+			CreatedAt: ent.CreatedAt,
+		}
+	}
+	return checks, nil
+}
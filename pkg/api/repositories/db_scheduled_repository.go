@@ -0,0 +1,179 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type ScheduledTransferEntity struct {
+	ID            uuid.UUID `gorm:"column:id;PRIMARY_KEY"`
+	From          uuid.UUID `gorm:"column:from_account;index"`
+	To            uuid.UUID `gorm:"column:to_account;index"`
+	Amount        float64
+	ExecuteAt     time.Time `gorm:"index"`
+	Status        string    `gorm:"index"`
+	FailureReason string
+	CreatedAt     time.Time
+	ExecutedAt    *time.Time
+	ClaimedAt     *time.Time
+	Claims        int
+}
+
+type dbScheduledRepository struct {
+	db *gorm.DB
+}
+
+func NewDBScheduledRepository(db *gorm.DB) ScheduledTransferRepository {
+	return &dbScheduledRepository{
+		db: db,
+	}
+}
+
+func (d *dbScheduledRepository) Create(ctx context.Context, transfer *model.ScheduledTransfer) error {
+	ent := toScheduledEntity(transfer)
+	return d.db.WithContext(ctx).Create(&ent).Error
+}
+
+func (d *dbScheduledRepository) Get(ctx context.Context, transferID uuid.UUID) (*model.ScheduledTransfer, error) {
+	var ent ScheduledTransferEntity
+	if err := d.db.WithContext(ctx).Where("id = ?", transferID).First(&ent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduledTransferNotFound
+		}
+		return nil, err
+	}
+
+	return toScheduledModel(ent), nil
+}
+
+func (d *dbScheduledRepository) ListPending(ctx context.Context, accountID uuid.UUID) ([]*model.ScheduledTransfer, error) {
+	var ents []ScheduledTransferEntity
+	err := d.db.WithContext(ctx).
+		Where("status = ? AND (from_account = ? OR to_account = ?)", model.ScheduledPending, accountID, accountID).
+		Order("execute_at").
+		Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	transfers := make([]*model.ScheduledTransfer, len(ents))
+	for i := range ents {
+		transfers[i] = toScheduledModel(ents[i])
+	}
+	return transfers, nil
+}
+
+func (d *dbScheduledRepository) Cancel(ctx context.Context, transferID uuid.UUID) error {
+	res := d.db.WithContext(ctx).Model(&ScheduledTransferEntity{}).
+		Where("id = ? AND status = ?", transferID, model.ScheduledPending).
+		Update("status", model.ScheduledCancelled)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil
+	}
+
+	if _, err := d.Get(ctx, transferID); err != nil {
+		return err
+	}
+	return ErrScheduledTransferNotPending
+}
+
+// ClaimDue flips every due transfer from pending to executing with a
+// conditional update, so when several replicas race for the same transfer
+// only the one whose update affects the row gets it. Stale claims are taken
+// the same way, the update only matching the claim count that was read.
+func (d *dbScheduledRepository) ClaimDue(ctx context.Context, now time.Time, staleBefore time.Time, limit int) ([]*model.ScheduledTransfer, error) {
+	var ents []ScheduledTransferEntity
+	err := d.db.WithContext(ctx).
+		Where("(status = ? AND execute_at <= ?) OR (status = ? AND (claimed_at IS NULL OR claimed_at < ?))",
+			model.ScheduledPending, now, model.ScheduledExecuting, staleBefore).
+		Order("execute_at").
+		Limit(limit).
+		Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]*model.ScheduledTransfer, 0, len(ents))
+	for i := range ents {
+		claimedAt := now
+		res := d.db.WithContext(ctx).Model(&ScheduledTransferEntity{}).
+			Where("id = ? AND status = ? AND claims = ?", ents[i].ID, ents[i].Status, ents[i].Claims).
+			Updates(map[string]interface{}{
+				"status":     model.ScheduledExecuting,
+				"claimed_at": claimedAt,
+				"claims":     ents[i].Claims + 1,
+			})
+		if res.Error != nil {
+			return claimed, res.Error
+		}
+		if res.RowsAffected == 1 {
+			ents[i].Status = string(model.ScheduledExecuting)
+			ents[i].ClaimedAt = &claimedAt
+			ents[i].Claims++
+			claimed = append(claimed, toScheduledModel(ents[i]))
+		}
+	}
+
+	return claimed, nil
+}
+
+func (d *dbScheduledRepository) Finish(ctx context.Context, transfer *model.ScheduledTransfer) error {
+	values := map[string]interface{}{
+		"status":         transfer.Status,
+		"failure_reason": transfer.FailureReason,
+		"executed_at":    transfer.ExecutedAt,
+	}
+	res := d.db.WithContext(ctx).Model(&ScheduledTransferEntity{}).
+		Where("id = ? AND claims = ?", transfer.ID, transfer.Claims).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil
+	}
+
+	if _, err := d.Get(ctx, transfer.ID); err != nil {
+		return err
+	}
+	return ErrScheduledTransferClaimLost
+}
+
+func toScheduledEntity(transfer *model.ScheduledTransfer) ScheduledTransferEntity {
+	return ScheduledTransferEntity{
+		ID:            transfer.ID,
+		From:          transfer.From,
+		To:            transfer.To,
+		Amount:        transfer.Amount,
+		ExecuteAt:     transfer.ExecuteAt,
+		Status:        string(transfer.Status),
+		FailureReason: transfer.FailureReason,
+		CreatedAt:     transfer.CreatedAt,
+		ExecutedAt:    transfer.ExecutedAt,
+		ClaimedAt:     transfer.ClaimedAt,
+		Claims:        transfer.Claims,
+	}
+}
+
+func toScheduledModel(ent ScheduledTransferEntity) *model.ScheduledTransfer {
+	return &model.ScheduledTransfer{
+		ID:            ent.ID,
+		From:          ent.From,
+		To:            ent.To,
+		Amount:        ent.Amount,
+		ExecuteAt:     ent.ExecuteAt,
+		Status:        model.ScheduledTransferStatus(ent.Status),
+		FailureReason: ent.FailureReason,
+		CreatedAt:     ent.CreatedAt,
+		ExecutedAt:    ent.ExecutedAt,
+		ClaimedAt:     ent.ClaimedAt,
+		Claims:        ent.Claims,
+	}
+}
@@ -0,0 +1,246 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type StandingOrderEntity struct {
+	ID          uuid.UUID `gorm:"column:id;PRIMARY_KEY"`
+	From        uuid.UUID `gorm:"column:from_account;index"`
+	To          uuid.UUID `gorm:"column:to_account;index"`
+	Amount      float64
+	Frequency   string
+	StartAt     time.Time
+	EndAt       *time.Time
+	Count       int
+	OnFailure   string
+	MaxRetries  int
+	Status      string `gorm:"index"`
+	Occurrences int
+	Attempts    int
+	NextRunAt   time.Time `gorm:"index"`
+	CreatedAt   time.Time
+	ClaimedAt   *time.Time
+	Claims      int
+}
+
+type StandingOrderRunEntity struct {
+	ID            uuid.UUID `gorm:"column:id;PRIMARY_KEY"`
+	OrderID       uuid.UUID `gorm:"index"`
+	Occurrence    int
+	ScheduledFor  time.Time
+	ExecutedAt    time.Time
+	Attempt       int
+	Status        string
+	FailureReason string
+}
+
+type dbStandingOrderRepository struct {
+	db *gorm.DB
+}
+
+func NewDBStandingOrderRepository(db *gorm.DB) StandingOrderRepository {
+	return &dbStandingOrderRepository{
+		db: db,
+	}
+}
+
+func (d *dbStandingOrderRepository) Create(ctx context.Context, order *model.StandingOrder) error {
+	ent := toStandingOrderEntity(order)
+	return d.db.WithContext(ctx).Create(&ent).Error
+}
+
+func (d *dbStandingOrderRepository) Get(ctx context.Context, orderID uuid.UUID) (*model.StandingOrder, error) {
+	var ent StandingOrderEntity
+	if err := d.db.WithContext(ctx).Where("id = ?", orderID).First(&ent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrStandingOrderNotFound
+		}
+		return nil, err
+	}
+
+	return toStandingOrderModel(ent), nil
+}
+
+func (d *dbStandingOrderRepository) ListByAccount(ctx context.Context, accountID uuid.UUID) ([]*model.StandingOrder, error) {
+	var ents []StandingOrderEntity
+	err := d.db.WithContext(ctx).
+		Where("from_account = ? OR to_account = ?", accountID, accountID).
+		Order("created_at").
+		Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]*model.StandingOrder, len(ents))
+	for i := range ents {
+		orders[i] = toStandingOrderModel(ents[i])
+	}
+	return orders, nil
+}
+
+func (d *dbStandingOrderRepository) Cancel(ctx context.Context, orderID uuid.UUID) error {
+	res := d.db.WithContext(ctx).Model(&StandingOrderEntity{}).
+		Where("id = ? AND status = ?", orderID, model.StandingOrderActive).
+		Update("status", model.StandingOrderCancelled)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil
+	}
+
+	if _, err := d.Get(ctx, orderID); err != nil {
+		return err
+	}
+	return ErrStandingOrderNotActive
+}
+
+// ClaimDue uses the same conditional update as scheduled transfers so
+// replicas never run the same occurrence twice
+func (d *dbStandingOrderRepository) ClaimDue(ctx context.Context, now time.Time, staleBefore time.Time, limit int) ([]*model.StandingOrder, error) {
+	var ents []StandingOrderEntity
+	err := d.db.WithContext(ctx).
+		Where("(status = ? AND next_run_at <= ?) OR (status = ? AND (claimed_at IS NULL OR claimed_at < ?))",
+			model.StandingOrderActive, now, model.StandingOrderExecuting, staleBefore).
+		Order("next_run_at").
+		Limit(limit).
+		Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]*model.StandingOrder, 0, len(ents))
+	for i := range ents {
+		claimedAt := now
+		res := d.db.WithContext(ctx).Model(&StandingOrderEntity{}).
+			Where("id = ? AND status = ? AND claims = ?", ents[i].ID, ents[i].Status, ents[i].Claims).
+			Updates(map[string]interface{}{
+				"status":     model.StandingOrderExecuting,
+				"claimed_at": claimedAt,
+				"claims":     ents[i].Claims + 1,
+			})
+		if res.Error != nil {
+			return claimed, res.Error
+		}
+		if res.RowsAffected == 1 {
+			ents[i].Status = string(model.StandingOrderExecuting)
+			ents[i].ClaimedAt = &claimedAt
+			ents[i].Claims++
+			claimed = append(claimed, toStandingOrderModel(ents[i]))
+		}
+	}
+
+	return claimed, nil
+}
+
+func (d *dbStandingOrderRepository) Save(ctx context.Context, order *model.StandingOrder) error {
+	values := map[string]interface{}{
+		"status":      order.Status,
+		"occurrences": order.Occurrences,
+		"attempts":    order.Attempts,
+		"next_run_at": order.NextRunAt,
+		"claims":      order.Claims,
+	}
+	res := d.db.WithContext(ctx).Model(&StandingOrderEntity{}).
+		Where("id = ?", order.ID).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		if _, err := d.Get(ctx, order.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dbStandingOrderRepository) AddRun(ctx context.Context, run *model.StandingOrderRun) error {
+	ent := StandingOrderRunEntity{
+		ID:            run.ID,
+		OrderID:       run.OrderID,
+		Occurrence:    run.Occurrence,
+		ScheduledFor:  run.ScheduledFor,
+		ExecutedAt:    run.ExecutedAt,
+		Attempt:       run.Attempt,
+		Status:        string(run.Status),
+		FailureReason: run.FailureReason,
+	}
+	return d.db.WithContext(ctx).Create(&ent).Error
+}
+
+func (d *dbStandingOrderRepository) ListRuns(ctx context.Context, orderID uuid.UUID) ([]*model.StandingOrderRun, error) {
+	var ents []StandingOrderRunEntity
+	err := d.db.WithContext(ctx).
+		Where("order_id = ?", orderID).
+		Order("executed_at").
+		Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]*model.StandingOrderRun, len(ents))
+	for i, ent := range ents {
+		runs[i] = &model.StandingOrderRun{
+			ID:            ent.ID,
+			OrderID:       ent.OrderID,
+			Occurrence:    ent.Occurrence,
+			ScheduledFor:  ent.ScheduledFor,
+			ExecutedAt:    ent.ExecutedAt,
+			Attempt:       ent.Attempt,
+			Status:        model.StandingOrderRunStatus(ent.Status),
+			FailureReason: ent.FailureReason,
+		}
+	}
+	return runs, nil
+}
+
+func toStandingOrderEntity(order *model.StandingOrder) StandingOrderEntity {
+	return StandingOrderEntity{
+		ID:          order.ID,
+		From:        order.From,
+		To:          order.To,
+		Amount:      order.Amount,
+		Frequency:   string(order.Frequency),
+		StartAt:     order.StartAt,
+		EndAt:       order.EndAt,
+		Count:       order.Count,
+		OnFailure:   string(order.OnFailure),
+		MaxRetries:  order.MaxRetries,
+		Status:      string(order.Status),
+		Occurrences: order.Occurrences,
+		Attempts:    order.Attempts,
+		NextRunAt:   order.NextRunAt,
+		CreatedAt:   order.CreatedAt,
+		ClaimedAt:   order.ClaimedAt,
+		Claims:      order.Claims,
+	}
+}
+
+func toStandingOrderModel(ent StandingOrderEntity) *model.StandingOrder {
+	return &model.StandingOrder{
+		ID:          ent.ID,
+		From:        ent.From,
+		To:          ent.To,
+		Amount:      ent.Amount,
+		Frequency:   model.Frequency(ent.Frequency),
+		StartAt:     ent.StartAt,
+		EndAt:       ent.EndAt,
+		Count:       ent.Count,
+		OnFailure:   model.FailurePolicy(ent.OnFailure),
+		MaxRetries:  ent.MaxRetries,
+		Status:      model.StandingOrderStatus(ent.Status),
+		Occurrences: ent.Occurrences,
+		Attempts:    ent.Attempts,
+		NextRunAt:   ent.NextRunAt,
+		CreatedAt:   ent.CreatedAt,
+		ClaimedAt:   ent.ClaimedAt,
+		Claims:      ent.Claims,
+	}
+}
@@ -0,0 +1,262 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+type WebhookSubscriptionEntity struct {
+	ID     uuid.UUID `gorm:"column:id;PRIMARY_KEY"`
+	URL    string
+	Secret string
+	// EventTypes and Accounts are comma separated
+	EventTypes  string
+	Accounts    string
+	AllAccounts bool
+	CreatedAt   time.Time
+}
+
+type WebhookDeliveryEntity struct {
+	// Seq is given by the database, it keeps deliveries enqueued in the same
+	// instant in order
+	Seq            int64     `gorm:"primaryKey;autoIncrement"`
+	ID             uuid.UUID `gorm:"column:id;uniqueIndex"`
+	SubscriptionID uuid.UUID `gorm:"index"`
+	EventID        uuid.UUID
+	EventType      string
+	Body           string
+	Status         string `gorm:"index"`
+	Attempts       int
+	NextAttemptAt  time.Time `gorm:"index"`
+	LastStatusCode int
+	LastError      string
+	Claims         int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type dbWebhookRepository struct {
+	db *gorm.DB
+}
+
+func NewDBWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &dbWebhookRepository{
+		db: db,
+	}
+}
+
+func (d *dbWebhookRepository) Subscribe(ctx context.Context, subscription *model.WebhookSubscription) error {
+	eventTypes := make([]string, len(subscription.EventTypes))
+	for i, eventType := range subscription.EventTypes {
+		eventTypes[i] = string(eventType)
+	}
+	ent := WebhookSubscriptionEntity{
+		ID:          subscription.ID,
+		URL:         subscription.URL,
+		Secret:      subscription.Secret,
+		EventTypes:  strings.Join(eventTypes, ","),
+		Accounts:    joinIDs(subscription.Accounts),
+		AllAccounts: subscription.AllAccounts,
+		CreatedAt:   subscription.CreatedAt,
+	}
+	return d.db.WithContext(ctx).Create(&ent).Error
+}
+
+func (d *dbWebhookRepository) Subscription(ctx context.Context, subscriptionID uuid.UUID) (*model.WebhookSubscription, error) {
+	var ent WebhookSubscriptionEntity
+	if err := d.db.WithContext(ctx).Where("id = ?", subscriptionID).First(&ent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSubscriptionNotFound
+		}
+		return nil, err
+	}
+	return toSubscriptionModel(ent), nil
+}
+
+func (d *dbWebhookRepository) Subscriptions(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	var ents []WebhookSubscriptionEntity
+	if err := d.db.WithContext(ctx).Order("created_at").Find(&ents).Error; err != nil {
+		return nil, err
+	}
+
+	subscriptions := make([]*model.WebhookSubscription, len(ents))
+	for i := range ents {
+		subscriptions[i] = toSubscriptionModel(ents[i])
+	}
+	return subscriptions, nil
+}
+
+func (d *dbWebhookRepository) Unsubscribe(ctx context.Context, subscriptionID uuid.UUID) error {
+	res := d.db.WithContext(ctx).Where("id = ?", subscriptionID).Delete(&WebhookSubscriptionEntity{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrSubscriptionNotFound
+	}
+	return nil
+}
+
+func (d *dbWebhookRepository) Enqueue(ctx context.Context, deliveries ...*model.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	ents := make([]WebhookDeliveryEntity, len(deliveries))
+	for i, delivery := range deliveries {
+		ents[i] = toDeliveryEntity(delivery)
+	}
+	return d.db.WithContext(ctx).Create(&ents).Error
+}
+
+func (d *dbWebhookRepository) Delivery(ctx context.Context, deliveryID uuid.UUID) (*model.WebhookDelivery, error) {
+	var ent WebhookDeliveryEntity
+	if err := d.db.WithContext(ctx).Where("id = ?", deliveryID).First(&ent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+	return toDeliveryModel(ent), nil
+}
+
+func (d *dbWebhookRepository) ClaimDue(ctx context.Context, now time.Time, until time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	var ents []WebhookDeliveryEntity
+	err := d.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", string(model.DeliveryPending), now).
+		Order("next_attempt_at").Order("seq").Limit(limit).Find(&ents).Error
+	if err != nil {
+		return nil, err
+	}
+
+	claimed := make([]*model.WebhookDelivery, 0, len(ents))
+	for i := range ents {
+		res := d.db.WithContext(ctx).Model(&WebhookDeliveryEntity{}).
+			Where("id = ? AND status = ? AND claims = ?", ents[i].ID, string(model.DeliveryPending), ents[i].Claims).
+			Updates(map[string]interface{}{
+				"next_attempt_at": until,
+				"claims":          ents[i].Claims + 1,
+			})
+		if res.Error != nil {
+			return claimed, res.Error
+		}
+		if res.RowsAffected == 1 {
+			ents[i].NextAttemptAt = until
+			ents[i].Claims++
+			claimed = append(claimed, toDeliveryModel(ents[i]))
+		}
+	}
+	return claimed, nil
+}
+
+func (d *dbWebhookRepository) SaveDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	values := map[string]interface{}{
+		"status":           string(delivery.Status),
+		"attempts":         delivery.Attempts,
+		"next_attempt_at":  delivery.NextAttemptAt,
+		"last_status_code": delivery.LastStatusCode,
+		"last_error":       delivery.LastError,
+		"updated_at":       delivery.UpdatedAt,
+		"delivered_at":     delivery.DeliveredAt,
+	}
+	res := d.db.WithContext(ctx).Model(&WebhookDeliveryEntity{}).
+		Where("id = ? AND claims = ?", delivery.ID, delivery.Claims).
+		Updates(values)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 1 {
+		return nil
+	}
+
+	if _, err := d.Delivery(ctx, delivery.ID); err != nil {
+		return err
+	}
+	return ErrDeliveryClaimLost
+}
+
+func (d *dbWebhookRepository) Deliveries(ctx context.Context, filter DeliveryFilter) ([]*model.WebhookDelivery, error) {
+	query := d.db.WithContext(ctx)
+	if filter.SubscriptionID != uuid.Nil {
+		query = query.Where("subscription_id = ?", filter.SubscriptionID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", string(filter.Status))
+	}
+
+	var ents []WebhookDeliveryEntity
+	if err := query.Order("seq DESC").Find(&ents).Error; err != nil {
+		return nil, err
+	}
+	return toDeliveryModels(ents), nil
+}
+
+func toSubscriptionModel(ent WebhookSubscriptionEntity) *model.WebhookSubscription {
+	var eventTypes []model.EventType
+	if ent.EventTypes != "" {
+		for _, eventType := range strings.Split(ent.EventTypes, ",") {
+			eventTypes = append(eventTypes, model.EventType(eventType))
+		}
+	}
+	return &model.WebhookSubscription{
+		ID:          ent.ID,
+		URL:         ent.URL,
+		Secret:      ent.Secret,
+		EventTypes:  eventTypes,
+		Accounts:    splitIDs(ent.Accounts),
+		AllAccounts: ent.AllAccounts,
+		CreatedAt:   ent.CreatedAt,
+	}
+}
+
+func toDeliveryEntity(delivery *model.WebhookDelivery) WebhookDeliveryEntity {
+	return WebhookDeliveryEntity{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		EventType:      string(delivery.EventType),
+		Body:           delivery.Body,
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		Claims:         delivery.Claims,
+		CreatedAt:      delivery.CreatedAt,
+		UpdatedAt:      delivery.UpdatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+}
+
+func toDeliveryModels(ents []WebhookDeliveryEntity) []*model.WebhookDelivery {
+	deliveries := make([]*model.WebhookDelivery, len(ents))
+	for i := range ents {
+		deliveries[i] = toDeliveryModel(ents[i])
+	}
+	return deliveries
+}
+
+func toDeliveryModel(ent WebhookDeliveryEntity) *model.WebhookDelivery {
+	return &model.WebhookDelivery{
+		ID:             ent.ID,
+		SubscriptionID: ent.SubscriptionID,
+		EventID:        ent.EventID,
+		EventType:      model.EventType(ent.EventType),
+		Body:           ent.Body,
+		Status:         model.DeliveryStatus(ent.Status),
+		Attempts:       ent.Attempts,
+		NextAttemptAt:  ent.NextAttemptAt,
+		LastStatusCode: ent.LastStatusCode,
+		LastError:      ent.LastError,
+		Claims:         ent.Claims,
+		CreatedAt:      ent.CreatedAt,
+		UpdatedAt:      ent.UpdatedAt,
+		DeliveredAt:    ent.DeliveredAt,
+	}
+}
@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// sqliteMemoryDSN is used when no dsn is given for sqlite, every call gets a
+// new empty database. The cache is shared so every connection of the pool
+// sees the same one.
+const sqliteMemoryDSN = "file:%s?mode=memory&cache=shared"
+
+// auditHeadID is the id of the single AuditHeadEntity row
+const auditHeadID = 1
+
+// OpenDB opens a connection for the given driver and migrates the schema
+func OpenDB(driver string, dsn string) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch driver {
+	case DriverMySQL:
+		dialector = mysql.Open(dsn)
+	case DriverPostgres:
+		dialector = postgres.Open(dsn)
+	case DriverSQLite:
+		if dsn == "" {
+			dsn = fmt.Sprintf(sqliteMemoryDSN, uuid.New())
+		}
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == DriverSQLite {
+		// sqlite allows a single writer at a time and answers "database is
+		// locked" instead of waiting, so writes are serialized on one connection
+		sqlDB, dbErr := db.DB()
+		if dbErr != nil {
+			return nil, dbErr
+		}
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	if mErr := db.AutoMigrate(&AccountEntity{}, &HoldEntity{}, &TransferEntity{}, &TransferLegEntity{}, &LimitUsageEntity{}, &LedgerEntryEntity{}, &InterestAccrualEntity{}, &ScheduledTransferEntity{}, &StandingOrderEntity{}, &StandingOrderRunEntity{}, &SanctionsCheckEntity{}, &SanctionsMatchEntity{}, &AuditRecordEntity{}, &AuditHeadEntity{}, &OutboxEventEntity{}, &OutboxEventAccountEntity{}, &AccountEventEntity{}, &AccountSnapshotEntity{}, &WebhookSubscriptionEntity{}, &WebhookDeliveryEntity{}); mErr != nil {
+		return nil, mErr
+	}
+
+	// the audit head always exists so writers only ever need to lock it
+	head := AuditHeadEntity{ID: auditHeadID}
+	if hErr := db.Where(&head).FirstOrCreate(&head).Error; hErr != nil {
+		return nil, hErr
+	}
+
+	return db, nil
+}
+
+// rowLocking returns the clause used to lock rows read inside a transaction.
+// sqlite has no row level locks, the whole database is locked by the writer.
+func rowLocking(dialect string) []clause.Expression {
+	switch dialect {
+	case DriverMySQL, DriverPostgres:
+		return []clause.Expression{clause.Locking{Strength: "UPDATE"}}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+// eventSourcedRepository keeps accounts as the events that changed them,
+// rebuilding them from their latest snapshot and the events after it. The
+// wrapped repository stores the events in the same transaction as every
+// other record, and keeps the accounts table as a projection of the streams
+// to list accounts and check records point at existing ones.
+type eventSourcedRepository struct {
+	AccountRepository
+	snapshotEvery int64
+	clock         func() time.Time
+}
+
+// NewEventSourcedRepository keeps the accounts of repository as event streams,
+// taking a snapshot of an account once snapshotEvery events were appended
+// since the last one. Snapshots are never taken when it is not positive.
+// Events and snapshots are stamped with the time told by clock.
+func NewEventSourcedRepository(repository AccountRepository, snapshotEvery int, clock func() time.Time) AccountRepository {
+	return &eventSourcedRepository{
+		AccountRepository: repository,
+		snapshotEvery:     int64(snapshotEvery),
+		clock:             clock,
+	}
+}
+
+func (r *eventSourcedRepository) Create(ctx context.Context, account *model.Account) error {
+	return r.Apply(ctx, Change{Created: []*model.Account{account}})
+}
+
+func (r *eventSourcedRepository) Update(ctx context.Context, account *model.Account) error {
+	return r.UpdatesTx(ctx, account)
+}
+
+func (r *eventSourcedRepository) UpdatesTx(ctx context.Context, accounts ...*model.Account) error {
+	return r.Apply(ctx, Change{Accounts: accounts})
+}
+
+func (r *eventSourcedRepository) Get(ctx context.Context, accountID uuid.UUID) (*model.Account, error) {
+	aggregate, err := r.load(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	return &aggregate.Account, nil
+}
+
+func (r *eventSourcedRepository) GetAll(ctx context.Context) ([]*model.Account, error) {
+	projected, err := r.AccountRepository.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]*model.Account, len(projected))
+	for i, acc := range projected {
+		if accounts[i], err = r.Get(ctx, acc.ID); err != nil {
+			return nil, err
+		}
+	}
+	return accounts, nil
+}
+
+// Apply turns the accounts of change into the events leading to them. An
+// account must be at the version it was read at, it is refused with
+// ErrVersionConflict otherwise, also when another writer appends to its
+// stream before the change is stored. Stored accounts get their new version.
+func (r *eventSourcedRepository) Apply(ctx context.Context, change Change) error {
+	now := r.clock()
+	aggregates := make(map[uuid.UUID]*model.AccountAggregate)
+	for _, acc := range change.Created {
+		aggregate := &model.AccountAggregate{}
+		change.History = append(change.History, aggregate.Open(acc, now))
+		aggregates[acc.ID] = aggregate
+	}
+	for _, acc := range change.Accounts {
+		aggregate, ok := aggregates[acc.ID]
+		if !ok {
+			var err error
+			if aggregate, err = r.load(ctx, acc.ID); err != nil {
+				return err
+			}
+			if acc.Version != aggregate.Version {
+				return ErrVersionConflict
+			}
+			aggregates[acc.ID] = aggregate
+		}
+		change.History = append(change.History, aggregate.Record(acc, now)...)
+	}
+
+	if err := r.AccountRepository.Apply(ctx, change); err != nil {
+		return err
+	}
+
+	for _, acc := range change.Created {
+		acc.Version = aggregates[acc.ID].Version
+	}
+	for _, acc := range change.Accounts {
+		acc.Version = aggregates[acc.ID].Version
+	}
+	for _, aggregate := range aggregates {
+		if r.snapshotEvery > 0 && aggregate.Version-aggregate.SnapshotVersion >= r.snapshotEvery {
+			// snapshots only make reads faster, the events are stored already
+			if err := r.AccountRepository.SaveSnapshot(ctx, aggregate.Snapshot(now)); err != nil {
+				log.Printf("snapshot of account %s at version %d not saved: %v", aggregate.Account.ID, aggregate.Version, err)
+			}
+		}
+	}
+	return nil
+}
+
+// load rebuilds an account from its stream. Accounts stored before event
+// sourcing was enabled have none, they start from their stored state.
+func (r *eventSourcedRepository) load(ctx context.Context, accountID uuid.UUID) (*model.AccountAggregate, error) {
+	snapshot, events, err := r.AccountRepository.History(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil && len(events) == 0 {
+		account, gErr := r.AccountRepository.Get(ctx, accountID)
+		if gErr != nil {
+			return nil, gErr
+		}
+		account.Version = 0
+		return &model.AccountAggregate{Account: *account}, nil
+	}
+	return model.NewAccountAggregate(snapshot, events)
+}
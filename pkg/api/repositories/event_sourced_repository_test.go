@@ -0,0 +1,131 @@
+package repositories_test
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/repositories/repotest"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestEventSourcedRepository(t *testing.T) {
+	impls := map[string]repotest.Factory{
+		"memory": func(t *testing.T) repositories.AccountRepository {
+			return repositories.NewMemoryRepository()
+		},
+		"db": func(t *testing.T) repositories.AccountRepository {
+			db, err := repositories.OpenDB(repositories.DriverSQLite, "")
+			require.NoError(t, err)
+			return repositories.NewDBRepository(db)
+		},
+	}
+
+	for name, newStore := range impls {
+		t.Run(name, func(t *testing.T) {
+			repotest.Run(t, func(t *testing.T) repositories.AccountRepository {
+				return repositories.NewEventSourcedRepository(newStore(t), 3, time.Now)
+			})
+
+			t.Run("History", func(t *testing.T) {
+				ctx := context.Background()
+				store := newStore(t)
+				now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+				repo := repositories.NewEventSourcedRepository(store, 3, func() time.Time { return now })
+
+				t.Run("Given an account changed many times", func(t *testing.T) {
+					acc := &model.Account{ID: uuid.New(), Name: "bill smith", Amount: 100.00}
+					require.NoError(t, repo.Create(ctx, acc))
+					for i := 0; i < 4; i++ {
+						acc.Amount += 10.00
+						require.NoError(t, repo.Update(ctx, acc))
+					}
+
+					t.Run("Then it is rebuilt from a snapshot and the events after it", func(t *testing.T) {
+						snapshot, events, err := store.History(ctx, acc.ID)
+						require.NoError(t, err)
+						require.NotNil(t, snapshot)
+						assert.Equal(t, int64(3), snapshot.Version)
+						assert.Equal(t, now, snapshot.TakenAt.UTC())
+						require.Len(t, events, 2)
+						assert.Equal(t, model.BalanceChanged, events[1].Type)
+						assert.Equal(t, now, events[1].OccurredAt.UTC())
+
+						got, gErr := repo.Get(ctx, acc.ID)
+						require.NoError(t, gErr)
+						assert.Equal(t, 140.00, got.Amount)
+						assert.Equal(t, int64(5), got.Version)
+					})
+
+					t.Run("When it is updated from a stale read", func(t *testing.T) {
+						stale, gErr := repo.Get(ctx, acc.ID)
+						require.NoError(t, gErr)
+						fresh := *stale
+						fresh.Held = 20.00
+						require.NoError(t, repo.Update(ctx, &fresh))
+						stale.Amount = 0
+						err := repo.Update(ctx, stale)
+
+						t.Run("Then it is refused", func(t *testing.T) {
+							assert.ErrorIs(t, err, repositories.ErrVersionConflict)
+							got, _ := repo.Get(ctx, acc.ID)
+							assert.Equal(t, 140.00, got.Amount)
+							assert.Equal(t, 20.00, got.Held)
+						})
+					})
+				})
+
+				t.Run("Given an account stored before event sourcing", func(t *testing.T) {
+					acc := &model.Account{ID: uuid.New(), Name: "jane smith", Amount: 50.00}
+					require.NoError(t, store.Create(ctx, acc))
+
+					t.Run("Then it starts from its stored state", func(t *testing.T) {
+						got, err := repo.Get(ctx, acc.ID)
+						require.NoError(t, err)
+						assert.Equal(t, 50.00, got.Amount)
+						got.Amount = 60.00
+						require.NoError(t, repo.Update(ctx, got))
+						assert.Equal(t, int64(1), got.Version)
+					})
+				})
+			})
+		})
+	}
+}
+
+func TestStoreHistory(t *testing.T) {
+	db, err := repositories.OpenDB(repositories.DriverSQLite, "")
+	require.NoError(t, err)
+
+	impls := map[string]repositories.AccountRepository{
+		"memory": repositories.NewMemoryRepository(),
+		"db":     repositories.NewDBRepository(db),
+	}
+
+	for name, repo := range impls {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			acc := &model.Account{ID: uuid.New(), Name: "billy"}
+			opened := &model.AccountEvent{AccountID: acc.ID, Version: 1, Type: model.AccountOpened, Data: `{"Name":"billy"}`}
+			require.NoError(t, repo.Apply(ctx, repositories.Change{Created: []*model.Account{acc}, History: []*model.AccountEvent{opened}}))
+
+			t.Run("When two writers append the same version", func(t *testing.T) {
+				first := &model.AccountEvent{AccountID: acc.ID, Version: 2, Type: model.BalanceChanged, Data: `{"Amount":10}`}
+				second := &model.AccountEvent{AccountID: acc.ID, Version: 2, Type: model.BalanceChanged, Data: `{"Amount":20}`}
+				require.NoError(t, repo.Apply(ctx, repositories.Change{History: []*model.AccountEvent{first}}))
+				err := repo.Apply(ctx, repositories.Change{Accounts: []*model.Account{acc}, History: []*model.AccountEvent{second}})
+
+				t.Run("Then the second one stores nothing", func(t *testing.T) {
+					assert.ErrorIs(t, err, repositories.ErrVersionConflict)
+					_, events, hErr := repo.History(ctx, acc.ID)
+					require.NoError(t, hErr)
+					require.Len(t, events, 2)
+					assert.Equal(t, `{"Amount":10}`, events[1].Data)
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,543 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	ErrAccountExists   = errors.New("account already exists")
+	ErrEventNotFound   = errors.New("event not found")
+)
+
+// memoryRepository keeps accounts in process memory. It is meant for tests and
+// local development, every account is lost once the process ends.
+type memoryRepository struct {
+	mux       sync.RWMutex
+	accounts  map[uuid.UUID]model.Account
+	entries   map[uuid.UUID][]model.LedgerEntry
+	accruals  map[uuid.UUID][]model.InterestAccrual
+	holds     map[uuid.UUID]model.Hold
+	transfers map[uuid.UUID]model.Transfer
+	usage     map[usageKey]model.LimitUsage
+	audit     []model.AuditRecord
+	outbox    []model.Event
+	history   map[uuid.UUID][]model.AccountEvent
+	snapshots map[uuid.UUID]model.AccountSnapshot
+
+	// transferIDs keeps transfers in the order they were first stored, so
+	// those created at the same time list in that order, as the seq column
+	// does in a database
+	transferIDs []uuid.UUID
+}
+
+type usageKey struct {
+	subject string
+	op      model.LimitOperation
+	period  string
+}
+
+func NewMemoryRepository() AccountRepository {
+	return &memoryRepository{
+		accounts:  make(map[uuid.UUID]model.Account),
+		entries:   make(map[uuid.UUID][]model.LedgerEntry),
+		accruals:  make(map[uuid.UUID][]model.InterestAccrual),
+		holds:     make(map[uuid.UUID]model.Hold),
+		transfers: make(map[uuid.UUID]model.Transfer),
+		usage:     make(map[usageKey]model.LimitUsage),
+		history:   make(map[uuid.UUID][]model.AccountEvent),
+		snapshots: make(map[uuid.UUID]model.AccountSnapshot),
+	}
+}
+
+func (m *memoryRepository) Create(ctx context.Context, account *model.Account) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.accounts[account.ID]; ok {
+		return ErrAccountExists
+	}
+	m.accounts[account.ID] = *account
+
+	return nil
+}
+
+func (m *memoryRepository) Update(ctx context.Context, account *model.Account) error {
+	return m.UpdatesTx(ctx, account)
+}
+
+func (m *memoryRepository) Get(ctx context.Context, accountID uuid.UUID) (*model.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	acc, ok := m.accounts[accountID]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+
+	return &acc, nil
+}
+
+func (m *memoryRepository) GetAll(ctx context.Context) ([]*model.Account, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	accounts := make([]*model.Account, 0, len(m.accounts))
+	for id := range m.accounts {
+		acc := m.accounts[id]
+		accounts = append(accounts, &acc)
+	}
+
+	return accounts, nil
+}
+
+func (m *memoryRepository) UpdatesTx(ctx context.Context, accounts ...*model.Account) error {
+	return m.Apply(ctx, Change{Accounts: accounts})
+}
+
+// Apply validates every change first and only applies them once all of them
+// are valid, so a failure leaves the stored data untouched.
+func (m *memoryRepository) Apply(ctx context.Context, change Change) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	created := make(map[uuid.UUID]bool)
+	for _, acc := range change.Created {
+		if _, ok := m.accounts[acc.ID]; ok || created[acc.ID] {
+			return ErrAccountExists
+		}
+		created[acc.ID] = true
+	}
+	exists := func(accountID uuid.UUID) bool {
+		_, ok := m.accounts[accountID]
+		return ok || created[accountID]
+	}
+	for _, acc := range change.Accounts {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !exists(acc.ID) {
+			return ErrAccountNotFound
+		}
+	}
+	for _, entry := range change.Entries {
+		if !exists(entry.AccountID) {
+			return ErrAccountNotFound
+		}
+	}
+	for _, hold := range change.Holds {
+		if !exists(hold.AccountID) {
+			return ErrAccountNotFound
+		}
+	}
+	for _, transfer := range change.NewTransfers {
+		if _, ok := m.transfers[transfer.ID]; ok {
+			return ErrTransferExists
+		}
+	}
+	if err := checkHistory(change.History, func(accountID uuid.UUID) (int64, error) {
+		return int64(len(m.history[accountID])), nil
+	}); err != nil {
+		return err
+	}
+
+	for _, acc := range change.Created {
+		m.accounts[acc.ID] = *acc
+	}
+	for _, acc := range change.Accounts {
+		m.accounts[acc.ID] = *acc
+	}
+	for _, entry := range change.Entries {
+		m.entries[entry.AccountID] = append(m.entries[entry.AccountID], *entry)
+	}
+	for _, accrual := range change.Accruals {
+		m.accruals[accrual.AccountID] = append(m.accruals[accrual.AccountID], *accrual)
+	}
+	for _, hold := range change.Holds {
+		m.holds[hold.ID] = *hold
+	}
+	for _, transfer := range append(change.NewTransfers, change.Transfers...) {
+		if _, ok := m.transfers[transfer.ID]; !ok {
+			m.transferIDs = append(m.transferIDs, transfer.ID)
+		}
+		m.transfers[transfer.ID] = *transfer
+	}
+	for _, usage := range change.Usage {
+		m.usage[usageKey{usage.Subject, usage.Operation, usage.Period}] = *usage
+	}
+	for _, record := range change.Audit {
+		prev := ""
+		if len(m.audit) > 0 {
+			prev = m.audit[len(m.audit)-1].Hash
+		}
+		record.Chain(int64(len(m.audit)+1), prev)
+		m.audit = append(m.audit, *record)
+	}
+	for _, event := range change.Events {
+		event.Sequence = int64(len(m.outbox) + 1)
+		stored := *event
+		stored.Accounts = append([]uuid.UUID(nil), event.Accounts...)
+		m.outbox = append(m.outbox, stored)
+	}
+	for _, event := range change.History {
+		m.history[event.AccountID] = append(m.history[event.AccountID], *event)
+	}
+
+	return nil
+}
+
+func (m *memoryRepository) Entries(ctx context.Context, accountID uuid.UUID) ([]*model.LedgerEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	entries := make([]*model.LedgerEntry, len(m.entries[accountID]))
+	for i := range m.entries[accountID] {
+		entry := m.entries[accountID][i]
+		entries[i] = &entry
+	}
+
+	return entries, nil
+}
+
+func (m *memoryRepository) Accruals(ctx context.Context, accountID uuid.UUID) ([]*model.InterestAccrual, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	accruals := make([]*model.InterestAccrual, len(m.accruals[accountID]))
+	for i := range m.accruals[accountID] {
+		accrual := m.accruals[accountID][i]
+		accruals[i] = &accrual
+	}
+
+	return accruals, nil
+}
+
+func (m *memoryRepository) GetHold(ctx context.Context, holdID uuid.UUID) (*model.Hold, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	hold, ok := m.holds[holdID]
+	if !ok {
+		return nil, ErrHoldNotFound
+	}
+	return &hold, nil
+}
+
+func (m *memoryRepository) GetTransfer(ctx context.Context, transferID uuid.UUID) (*model.Transfer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	transfer, ok := m.transfers[transferID]
+	if !ok {
+		return nil, ErrTransferNotFound
+	}
+	return &transfer, nil
+}
+
+func (m *memoryRepository) Transfers(ctx context.Context, accountID uuid.UUID) ([]*model.Transfer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	transfers := make([]*model.Transfer, 0)
+	for _, id := range m.transferIDs {
+		transfer := m.transfers[id]
+		if transfer.Involves(accountID) {
+			transfers = append(transfers, &transfer)
+		}
+	}
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return transfers[i].CreatedAt.Before(transfers[j].CreatedAt)
+	})
+
+	return transfers, nil
+}
+
+func (m *memoryRepository) TransfersByStatus(ctx context.Context, status model.TransferStatus) ([]*model.Transfer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	transfers := make([]*model.Transfer, 0)
+	for _, id := range m.transferIDs {
+		transfer := m.transfers[id]
+		if transfer.Status == status {
+			transfers = append(transfers, &transfer)
+		}
+	}
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return transfers[i].CreatedAt.Before(transfers[j].CreatedAt)
+	})
+
+	return transfers, nil
+}
+
+func (m *memoryRepository) SentTransfers(ctx context.Context, filter SentFilter) ([]*model.Transfer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	transfers := make([]*model.Transfer, 0)
+	for _, id := range m.transferIDs {
+		transfer := m.transfers[id]
+		if filter.matches(&transfer) {
+			transfers = append(transfers, &transfer)
+		}
+	}
+	sort.SliceStable(transfers, func(i, j int) bool {
+		return transfers[i].CreatedAt.Before(transfers[j].CreatedAt)
+	})
+	if filter.Limit > 0 && len(transfers) > filter.Limit {
+		transfers = transfers[:filter.Limit]
+	}
+
+	return transfers, nil
+}
+
+func (m *memoryRepository) AuditTrail(ctx context.Context, filter AuditFilter) ([]*model.AuditRecord, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	records := make([]*model.AuditRecord, 0)
+	for i := range m.audit {
+		record := m.audit[i]
+		if !filter.matches(&record) {
+			continue
+		}
+		records = append(records, &record)
+		if filter.Limit > 0 && len(records) == filter.Limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+func (m *memoryRepository) UnpublishedEvents(ctx context.Context, limit int) ([]*model.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	events := make([]*model.Event, 0)
+	for i := range m.outbox {
+		if m.outbox[i].PublishedAt != nil {
+			continue
+		}
+		event := m.outbox[i]
+		events = append(events, &event)
+		if len(events) == limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+func (m *memoryRepository) LastEventSequence(ctx context.Context) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	if len(m.outbox) == 0 {
+		return 0, nil
+	}
+	return m.outbox[len(m.outbox)-1].Sequence, nil
+}
+
+func (m *memoryRepository) AccountEvents(ctx context.Context, accountID uuid.UUID, after int64, limit int) ([]*model.Event, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	events := make([]*model.Event, 0)
+	for i := range m.outbox {
+		if m.outbox[i].Sequence <= after || !concerns(&m.outbox[i], accountID) {
+			continue
+		}
+		event := m.outbox[i]
+		events = append(events, &event)
+		if len(events) == limit {
+			break
+		}
+	}
+	return events, nil
+}
+
+func concerns(event *model.Event, accountID uuid.UUID) bool {
+	for _, id := range event.Accounts {
+		if id == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *memoryRepository) History(ctx context.Context, accountID uuid.UUID) (*model.AccountSnapshot, []*model.AccountEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	var snapshot *model.AccountSnapshot
+	if stored, ok := m.snapshots[accountID]; ok {
+		snapshot = &stored
+	}
+	events := make([]*model.AccountEvent, 0)
+	for i := range m.history[accountID] {
+		event := m.history[accountID][i]
+		if snapshot == nil || event.Version > snapshot.Version {
+			events = append(events, &event)
+		}
+	}
+	return snapshot, events, nil
+}
+
+func (m *memoryRepository) SaveSnapshot(ctx context.Context, snapshot *model.AccountSnapshot) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.snapshots[snapshot.Account.ID] = *snapshot
+	return nil
+}
+
+func (m *memoryRepository) MarkPublished(ctx context.Context, eventID uuid.UUID, at time.Time) error {
+	return m.updateEvent(ctx, eventID, func(event *model.Event) {
+		event.PublishedAt = &at
+	})
+}
+
+func (m *memoryRepository) MarkFailed(ctx context.Context, eventID uuid.UUID, reason string) error {
+	return m.updateEvent(ctx, eventID, func(event *model.Event) {
+		event.Attempts++
+		event.LastError = reason
+	})
+}
+
+func (m *memoryRepository) updateEvent(ctx context.Context, eventID uuid.UUID, update func(event *model.Event)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for i := range m.outbox {
+		if m.outbox[i].ID == eventID {
+			update(&m.outbox[i])
+			return nil
+		}
+	}
+	return ErrEventNotFound
+}
+
+func (m *memoryRepository) Usage(ctx context.Context, subject string, op model.LimitOperation, period string) (*model.LimitUsage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	usage, ok := m.usage[usageKey{subject, op, period}]
+	if !ok {
+		return &model.LimitUsage{Subject: subject, Operation: op, Period: period}, nil
+	}
+	return &usage, nil
+}
+
+func (m *memoryRepository) Holds(ctx context.Context, accountID uuid.UUID) ([]*model.Hold, error) {
+	return m.filterHolds(ctx, 0, func(hold model.Hold) bool {
+		return hold.AccountID == accountID
+	})
+}
+
+func (m *memoryRepository) ExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*model.Hold, error) {
+	return m.filterHolds(ctx, limit, func(hold model.Hold) bool {
+		return hold.Status == model.HoldActive && !hold.ExpiresAt.After(now)
+	})
+}
+
+func (m *memoryRepository) filterHolds(ctx context.Context, limit int, match func(hold model.Hold) bool) ([]*model.Hold, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	holds := make([]*model.Hold, 0)
+	for id := range m.holds {
+		hold := m.holds[id]
+		if match(hold) {
+			holds = append(holds, &hold)
+		}
+	}
+	sort.Slice(holds, func(i, j int) bool {
+		return holds[i].CreatedAt.Before(holds[j].CreatedAt)
+	})
+	if limit > 0 && len(holds) > limit {
+		holds = holds[:limit]
+	}
+
+	return holds, nil
+}
@@ -0,0 +1,13 @@
+package repositories_test
+
+import (
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/repositories/repotest"
+	"testing"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) repositories.AccountRepository {
+		return repositories.NewMemoryRepository()
+	})
+}
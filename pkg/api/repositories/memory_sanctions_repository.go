@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"github.com/google/uuid"
+	"sync"
+)
+
+type memorySanctionsRepository struct {
+	mux    sync.Mutex
+	checks []model.SanctionsCheck
+}
+
+func NewMemorySanctionsRepository() SanctionsRepository {
+	return &memorySanctionsRepository{}
+}
+
+func (m *memorySanctionsRepository) Record(ctx context.Context, check *model.SanctionsCheck) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	stored := *check
+	stored.Matches = append([]model.SanctionsMatch(nil), check.Matches...)
+	m.checks = append(m.checks, stored)
+	return nil
+}
+
+func (m *memorySanctionsRepository) List(ctx context.Context, filter SanctionsFilter) ([]*model.SanctionsCheck, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	// checks are appended as they happen, already oldest first
+	checks := make([]*model.SanctionsCheck, 0)
+	for i := range m.checks {
+		check := m.checks[i]
+		if filter.SubjectID != uuid.Nil && check.SubjectID != filter.SubjectID {
+			continue
+		}
+		if filter.Decision != "" && check.Decision != filter.Decision {
+			continue
+		}
+		checks = append(checks, &check)
+	}
+	return checks, nil
+}
@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryScheduledRepository struct {
+	mux       sync.Mutex
+	transfers map[uuid.UUID]model.ScheduledTransfer
+}
+
+func NewMemoryScheduledRepository() ScheduledTransferRepository {
+	return &memoryScheduledRepository{
+		transfers: make(map[uuid.UUID]model.ScheduledTransfer),
+	}
+}
+
+func (m *memoryScheduledRepository) Create(ctx context.Context, transfer *model.ScheduledTransfer) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.transfers[transfer.ID] = *transfer
+	return nil
+}
+
+func (m *memoryScheduledRepository) Get(ctx context.Context, transferID uuid.UUID) (*model.ScheduledTransfer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	transfer, ok := m.transfers[transferID]
+	if !ok {
+		return nil, ErrScheduledTransferNotFound
+	}
+	return &transfer, nil
+}
+
+func (m *memoryScheduledRepository) ListPending(ctx context.Context, accountID uuid.UUID) ([]*model.ScheduledTransfer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	transfers := make([]*model.ScheduledTransfer, 0)
+	for id := range m.transfers {
+		transfer := m.transfers[id]
+		if transfer.Status != model.ScheduledPending {
+			continue
+		}
+		if transfer.From == accountID || transfer.To == accountID {
+			transfers = append(transfers, &transfer)
+		}
+	}
+	sortByExecuteAt(transfers)
+
+	return transfers, nil
+}
+
+func (m *memoryScheduledRepository) Cancel(ctx context.Context, transferID uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	transfer, ok := m.transfers[transferID]
+	if !ok {
+		return ErrScheduledTransferNotFound
+	}
+	if transfer.Status != model.ScheduledPending {
+		return ErrScheduledTransferNotPending
+	}
+	transfer.Status = model.ScheduledCancelled
+	m.transfers[transferID] = transfer
+
+	return nil
+}
+
+func (m *memoryScheduledRepository) ClaimDue(ctx context.Context, now time.Time, staleBefore time.Time, limit int) ([]*model.ScheduledTransfer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	due := make([]*model.ScheduledTransfer, 0)
+	for id := range m.transfers {
+		transfer := m.transfers[id]
+		pending := transfer.Status == model.ScheduledPending && !transfer.ExecuteAt.After(now)
+		stale := transfer.Status == model.ScheduledExecuting && (transfer.ClaimedAt == nil || transfer.ClaimedAt.Before(staleBefore))
+		if pending || stale {
+			due = append(due, &transfer)
+		}
+	}
+	sortByExecuteAt(due)
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, transfer := range due {
+		claimedAt := now
+		transfer.Status = model.ScheduledExecuting
+		transfer.ClaimedAt = &claimedAt
+		transfer.Claims++
+		m.transfers[transfer.ID] = *transfer
+	}
+
+	return due, nil
+}
+
+func (m *memoryScheduledRepository) Finish(ctx context.Context, transfer *model.ScheduledTransfer) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	stored, ok := m.transfers[transfer.ID]
+	if !ok {
+		return ErrScheduledTransferNotFound
+	}
+	if stored.Claims != transfer.Claims {
+		return ErrScheduledTransferClaimLost
+	}
+	m.transfers[transfer.ID] = *transfer
+
+	return nil
+}
+
+func sortByExecuteAt(transfers []*model.ScheduledTransfer) {
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].ExecuteAt.Before(transfers[j].ExecuteAt)
+	})
+}
@@ -0,0 +1,165 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryStandingOrderRepository struct {
+	mux    sync.Mutex
+	orders map[uuid.UUID]model.StandingOrder
+	runs   map[uuid.UUID][]model.StandingOrderRun
+}
+
+func NewMemoryStandingOrderRepository() StandingOrderRepository {
+	return &memoryStandingOrderRepository{
+		orders: make(map[uuid.UUID]model.StandingOrder),
+		runs:   make(map[uuid.UUID][]model.StandingOrderRun),
+	}
+}
+
+func (m *memoryStandingOrderRepository) Create(ctx context.Context, order *model.StandingOrder) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.orders[order.ID] = *order
+	return nil
+}
+
+func (m *memoryStandingOrderRepository) Get(ctx context.Context, orderID uuid.UUID) (*model.StandingOrder, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	order, ok := m.orders[orderID]
+	if !ok {
+		return nil, ErrStandingOrderNotFound
+	}
+	return &order, nil
+}
+
+func (m *memoryStandingOrderRepository) ListByAccount(ctx context.Context, accountID uuid.UUID) ([]*model.StandingOrder, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	orders := make([]*model.StandingOrder, 0)
+	for id := range m.orders {
+		order := m.orders[id]
+		if order.From == accountID || order.To == accountID {
+			orders = append(orders, &order)
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].CreatedAt.Before(orders[j].CreatedAt)
+	})
+
+	return orders, nil
+}
+
+func (m *memoryStandingOrderRepository) Cancel(ctx context.Context, orderID uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	order, ok := m.orders[orderID]
+	if !ok {
+		return ErrStandingOrderNotFound
+	}
+	if order.Status != model.StandingOrderActive {
+		return ErrStandingOrderNotActive
+	}
+	order.Status = model.StandingOrderCancelled
+	m.orders[orderID] = order
+
+	return nil
+}
+
+func (m *memoryStandingOrderRepository) ClaimDue(ctx context.Context, now time.Time, staleBefore time.Time, limit int) ([]*model.StandingOrder, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	due := make([]*model.StandingOrder, 0)
+	for id := range m.orders {
+		order := m.orders[id]
+		active := order.Status == model.StandingOrderActive && !order.NextRunAt.After(now)
+		stale := order.Status == model.StandingOrderExecuting && (order.ClaimedAt == nil || order.ClaimedAt.Before(staleBefore))
+		if active || stale {
+			due = append(due, &order)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(due[j].NextRunAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, order := range due {
+		claimedAt := now
+		order.Status = model.StandingOrderExecuting
+		order.ClaimedAt = &claimedAt
+		order.Claims++
+		m.orders[order.ID] = *order
+	}
+
+	return due, nil
+}
+
+func (m *memoryStandingOrderRepository) Save(ctx context.Context, order *model.StandingOrder) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if _, ok := m.orders[order.ID]; !ok {
+		return ErrStandingOrderNotFound
+	}
+	m.orders[order.ID] = *order
+
+	return nil
+}
+
+func (m *memoryStandingOrderRepository) AddRun(ctx context.Context, run *model.StandingOrderRun) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.runs[run.OrderID] = append(m.runs[run.OrderID], *run)
+	return nil
+}
+
+func (m *memoryStandingOrderRepository) ListRuns(ctx context.Context, orderID uuid.UUID) ([]*model.StandingOrderRun, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	runs := make([]*model.StandingOrderRun, len(m.runs[orderID]))
+	for i := range m.runs[orderID] {
+		run := m.runs[orderID][i]
+		runs[i] = &run
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,194 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"github.com/google/uuid"
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryWebhookRepository struct {
+	mux           sync.Mutex
+	subscriptions []model.WebhookSubscription
+	deliveries    map[uuid.UUID]model.WebhookDelivery
+	// order keeps deliveries as they were enqueued
+	order []uuid.UUID
+}
+
+func NewMemoryWebhookRepository() WebhookRepository {
+	return &memoryWebhookRepository{
+		deliveries: make(map[uuid.UUID]model.WebhookDelivery),
+	}
+}
+
+func (m *memoryWebhookRepository) Subscribe(ctx context.Context, subscription *model.WebhookSubscription) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.subscriptions = append(m.subscriptions, copySubscription(subscription))
+	return nil
+}
+
+func (m *memoryWebhookRepository) Subscription(ctx context.Context, subscriptionID uuid.UUID) (*model.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for i := range m.subscriptions {
+		if m.subscriptions[i].ID == subscriptionID {
+			subscription := copySubscription(&m.subscriptions[i])
+			return &subscription, nil
+		}
+	}
+	return nil, ErrSubscriptionNotFound
+}
+
+func (m *memoryWebhookRepository) Subscriptions(ctx context.Context) ([]*model.WebhookSubscription, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	subscriptions := make([]*model.WebhookSubscription, len(m.subscriptions))
+	for i := range m.subscriptions {
+		subscription := copySubscription(&m.subscriptions[i])
+		subscriptions[i] = &subscription
+	}
+	return subscriptions, nil
+}
+
+func (m *memoryWebhookRepository) Unsubscribe(ctx context.Context, subscriptionID uuid.UUID) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for i := range m.subscriptions {
+		if m.subscriptions[i].ID == subscriptionID {
+			m.subscriptions = append(m.subscriptions[:i], m.subscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return ErrSubscriptionNotFound
+}
+
+func (m *memoryWebhookRepository) Enqueue(ctx context.Context, deliveries ...*model.WebhookDelivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for _, delivery := range deliveries {
+		m.deliveries[delivery.ID] = *delivery
+		m.order = append(m.order, delivery.ID)
+	}
+	return nil
+}
+
+func (m *memoryWebhookRepository) Delivery(ctx context.Context, deliveryID uuid.UUID) (*model.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	delivery, ok := m.deliveries[deliveryID]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	return &delivery, nil
+}
+
+func (m *memoryWebhookRepository) ClaimDue(ctx context.Context, now time.Time, until time.Time, limit int) ([]*model.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	due := make([]*model.WebhookDelivery, 0)
+	for _, id := range m.order {
+		delivery := m.deliveries[id]
+		if delivery.Status == model.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, &delivery)
+		}
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, delivery := range due {
+		delivery.NextAttemptAt = until
+		delivery.Claims++
+		m.deliveries[delivery.ID] = *delivery
+	}
+	return due, nil
+}
+
+func (m *memoryWebhookRepository) SaveDelivery(ctx context.Context, delivery *model.WebhookDelivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	stored, ok := m.deliveries[delivery.ID]
+	if !ok {
+		return ErrDeliveryNotFound
+	}
+	if stored.Claims != delivery.Claims {
+		return ErrDeliveryClaimLost
+	}
+	m.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (m *memoryWebhookRepository) Deliveries(ctx context.Context, filter DeliveryFilter) ([]*model.WebhookDelivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	deliveries := make([]*model.WebhookDelivery, 0)
+	for i := len(m.order) - 1; i >= 0; i-- {
+		delivery := m.deliveries[m.order[i]]
+		if filter.SubscriptionID != uuid.Nil && delivery.SubscriptionID != filter.SubscriptionID {
+			continue
+		}
+		if filter.Status != "" && delivery.Status != filter.Status {
+			continue
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	return deliveries, nil
+}
+
+func copySubscription(subscription *model.WebhookSubscription) model.WebhookSubscription {
+	copied := *subscription
+	copied.EventTypes = append([]model.EventType(nil), subscription.EventTypes...)
+	copied.Accounts = append([]uuid.UUID(nil), subscription.Accounts...)
+	return copied
+}
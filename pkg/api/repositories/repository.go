@@ -2,18 +2,168 @@ package repositories
 
 import (
 	"bank/pkg/api/model"
+	"context"
+	"errors"
 	"github.com/google/uuid"
+	"time"
 )
 
+var (
+	ErrHoldNotFound     = errors.New("hold not found")
+	ErrTransferNotFound = errors.New("transfer not found")
+	ErrTransferExists   = errors.New("transfer already exists")
+	// ErrVersionConflict is returned when an account changed since it was read
+	ErrVersionConflict = errors.New("account was changed by someone else")
+)
+
+// Change groups every write that must be stored in a single transaction
+type Change struct {
+	// Created accounts are inserted, Accounts are updated
+	Created  []*model.Account
+	Accounts []*model.Account
+	Entries  []*model.LedgerEntry
+	Accruals []*model.InterestAccrual
+	// Holds are created or replaced
+	Holds []*model.Hold
+	// Transfers are created or replaced, NewTransfers are created and the
+	// change is refused with ErrTransferExists when any of them is stored
+	Transfers    []*model.Transfer
+	NewTransfers []*model.Transfer
+	// Usage of limits is created or replaced
+	Usage []*model.LimitUsage
+	// Audit records are appended to the trail in order, the repository
+	// chains them to the last stored one
+	Audit []*model.AuditRecord
+	// Events are added to the outbox in order, waiting to be published
+	Events []*model.Event
+	// History is appended to the event streams of the accounts, the first
+	// event of each stream must follow the last one stored or nothing is
+	// stored and ErrVersionConflict returned
+	History []*model.AccountEvent
+}
+
+// AuditFilter narrows the audit trail, zero values match everything
+type AuditFilter struct {
+	EntityID uuid.UUID
+	Actor    string
+	Action   model.AuditAction
+	// From is inclusive and To exclusive
+	From time.Time
+	To   time.Time
+	// AfterSequence skips the records up to it, for paging
+	AfterSequence int64
+	Limit         int
+}
+
+// SentFilter narrows the transfers sent by From, zero values of the other
+// fields match everything
+type SentFilter struct {
+	From   uuid.UUID
+	To     uuid.UUID
+	Status model.TransferStatus
+	// Since is inclusive
+	Since time.Time
+	Limit int
+}
+
 type AccountRepository interface {
 	// Create account
-	Create(account *model.Account) error
+	Create(ctx context.Context, account *model.Account) error
 	// Update account
-	Update(account *model.Account) error
+	Update(ctx context.Context, account *model.Account) error
 	// Get account
-	Get(accountID uuid.UUID) (*model.Account, error)
+	Get(ctx context.Context, accountID uuid.UUID) (*model.Account, error)
 	// GetAll accounts
-	GetAll() ([]*model.Account, error)
+	GetAll(ctx context.Context) ([]*model.Account, error)
 	// UpdatesTx updates a list of accounts in a transaction
-	UpdatesTx(account ...*model.Account) error
+	UpdatesTx(ctx context.Context, account ...*model.Account) error
+	// Apply stores the whole change in a transaction, nothing is stored if
+	// any part of it fails
+	Apply(ctx context.Context, change Change) error
+	// Entries returns the ledger of an account, oldest first
+	Entries(ctx context.Context, accountID uuid.UUID) ([]*model.LedgerEntry, error)
+	// Accruals returns the interest accrued by an account day by day, oldest first
+	Accruals(ctx context.Context, accountID uuid.UUID) ([]*model.InterestAccrual, error)
+	// GetHold returns a hold
+	GetHold(ctx context.Context, holdID uuid.UUID) (*model.Hold, error)
+	// Holds returns the holds placed on an account, oldest first
+	Holds(ctx context.Context, accountID uuid.UUID) ([]*model.Hold, error)
+	// ExpiredHolds returns up to limit active holds expired at now
+	ExpiredHolds(ctx context.Context, now time.Time, limit int) ([]*model.Hold, error)
+	// GetTransfer returns a transfer
+	GetTransfer(ctx context.Context, transferID uuid.UUID) (*model.Transfer, error)
+	// Transfers returns the transfers sent or received by an account, oldest first
+	Transfers(ctx context.Context, accountID uuid.UUID) ([]*model.Transfer, error)
+	// TransfersByStatus returns every transfer in a status, oldest first
+	TransfersByStatus(ctx context.Context, status model.TransferStatus) ([]*model.Transfer, error)
+	// SentTransfers returns the transfers with a single sender matching
+	// filter, oldest first
+	SentTransfers(ctx context.Context, filter SentFilter) ([]*model.Transfer, error)
+	// AuditTrail returns the audit records matching filter by sequence
+	AuditTrail(ctx context.Context, filter AuditFilter) ([]*model.AuditRecord, error)
+	// UnpublishedEvents returns up to limit events of the outbox not published
+	// yet, by sequence
+	UnpublishedEvents(ctx context.Context, limit int) ([]*model.Event, error)
+	// AccountEvents returns up to limit events of the outbox concerning
+	// accountID after sequence after, published or not, by sequence
+	AccountEvents(ctx context.Context, accountID uuid.UUID, after int64, limit int) ([]*model.Event, error)
+	// LastEventSequence returns the sequence of the last event of the
+	// outbox, 0 when it is empty
+	LastEventSequence(ctx context.Context) (int64, error)
+	// MarkPublished records the event was delivered at
+	MarkPublished(ctx context.Context, eventID uuid.UUID, at time.Time) error
+	// MarkFailed records a failed delivery of the event, it stays unpublished
+	MarkFailed(ctx context.Context, eventID uuid.UUID, reason string) error
+	// History returns the latest snapshot of an account, nil when there is
+	// none, and the events of its stream after it
+	History(ctx context.Context, accountID uuid.UUID) (*model.AccountSnapshot, []*model.AccountEvent, error)
+	// SaveSnapshot replaces the snapshot of an account
+	SaveSnapshot(ctx context.Context, snapshot *model.AccountSnapshot) error
+	// Usage returns what subject used of an operation in period, nothing used
+	// when it was never stored
+	Usage(ctx context.Context, subject string, op model.LimitOperation, period string) (*model.LimitUsage, error)
+}
+
+func (f SentFilter) matches(transfer *model.Transfer) bool {
+	switch {
+	case transfer.From != f.From,
+		f.To != uuid.Nil && transfer.To != f.To,
+		f.Status != "" && transfer.Status != f.Status,
+		!f.Since.IsZero() && transfer.CreatedAt.Before(f.Since):
+		return false
+	}
+	return true
+}
+
+func (f AuditFilter) matches(record *model.AuditRecord) bool {
+	switch {
+	case record.Sequence <= f.AfterSequence,
+		f.EntityID != uuid.Nil && record.EntityID != f.EntityID,
+		f.Actor != "" && record.Actor != f.Actor,
+		f.Action != "" && record.Action != f.Action,
+		!f.From.IsZero() && record.CreatedAt.Before(f.From),
+		!f.To.IsZero() && !record.CreatedAt.Before(f.To):
+		return false
+	}
+	return true
+}
+
+// checkHistory tells if every event follows the previous one of its stream,
+// current returns the last version stored of a stream
+func checkHistory(events []*model.AccountEvent, current func(accountID uuid.UUID) (int64, error)) error {
+	last := make(map[uuid.UUID]int64)
+	for _, event := range events {
+		version, ok := last[event.AccountID]
+		if !ok {
+			var err error
+			if version, err = current(event.AccountID); err != nil {
+				return err
+			}
+		}
+		if event.Version != version+1 {
+			return ErrVersionConflict
+		}
+		last[event.AccountID] = event.Version
+	}
+	return nil
 }
@@ -0,0 +1,643 @@
+// Package repotest holds the conformance tests every AccountRepository
+// implementation is expected to pass.
+package repotest
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Factory returns an empty, ready to use repository for a single test
+type Factory func(t *testing.T) repositories.AccountRepository
+
+// Run runs the whole conformance suite against the repositories built by newRepo
+func Run(t *testing.T, newRepo Factory) {
+	t.Run("CRUD", func(t *testing.T) { testCRUD(t, newRepo(t)) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, newRepo(t)) })
+	t.Run("UpdatesTx", func(t *testing.T) { testUpdatesTx(t, newRepo(t)) })
+	t.Run("Apply", func(t *testing.T) { testApply(t, newRepo(t)) })
+	t.Run("Holds", func(t *testing.T) { testHolds(t, newRepo(t)) })
+	t.Run("TransferOrder", func(t *testing.T) { testTransferOrder(t, newRepo(t)) })
+	t.Run("SentTransfers", func(t *testing.T) { testSentTransfers(t, newRepo(t)) })
+	t.Run("Outbox", func(t *testing.T) { testOutbox(t, newRepo(t)) })
+	t.Run("ConcurrentWriters", func(t *testing.T) { testConcurrentWriters(t, newRepo(t)) })
+	t.Run("CancelledContext", func(t *testing.T) { testCancelledContext(t, newRepo(t)) })
+}
+
+func newAccount(name string, amount float64) *model.Account {
+	return &model.Account{ID: uuid.New(), Name: name, Amount: amount}
+}
+
+func testCRUD(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+
+	t.Run("Given a new account", func(t *testing.T) {
+		acc := newAccount("bill smith", 100.00)
+
+		t.Run("When it is created", func(t *testing.T) {
+			require.NoError(t, repo.Create(ctx, acc))
+
+			t.Run("Then it can be read back", func(t *testing.T) {
+				got, err := repo.Get(ctx, acc.ID)
+				require.NoError(t, err)
+				assert.Equal(t, acc, got)
+			})
+
+			t.Run("Then it is listed", func(t *testing.T) {
+				all, err := repo.GetAll(ctx)
+				require.NoError(t, err)
+				assert.Contains(t, all, acc)
+			})
+
+			t.Run("Then creating it again fails", func(t *testing.T) {
+				assert.ErrorIs(t, repo.Create(ctx, acc), repositories.ErrAccountExists)
+			})
+		})
+
+		t.Run("When it is updated", func(t *testing.T) {
+			acc.Name = "billy smith"
+			acc.Amount = 250.00
+			require.NoError(t, repo.Update(ctx, acc))
+
+			t.Run("Then changes are stored", func(t *testing.T) {
+				got, err := repo.Get(ctx, acc.ID)
+				require.NoError(t, err)
+				assert.Equal(t, acc, got)
+			})
+		})
+
+		t.Run("When it is updated with the same values", func(t *testing.T) {
+			require.NoError(t, repo.Update(ctx, acc))
+
+			t.Run("Then nothing changes", func(t *testing.T) {
+				got, err := repo.Get(ctx, acc.ID)
+				require.NoError(t, err)
+				assert.Equal(t, acc, got)
+			})
+		})
+	})
+}
+
+func testNotFound(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+
+	t.Run("Given an account that was never created", func(t *testing.T) {
+		acc := newAccount("ghost", 10.00)
+
+		t.Run("Then Get fails as not found", func(t *testing.T) {
+			_, err := repo.Get(ctx, acc.ID)
+			assert.ErrorIs(t, err, repositories.ErrAccountNotFound)
+		})
+
+		t.Run("Then Update fails as not found", func(t *testing.T) {
+			assert.ErrorIs(t, repo.Update(ctx, acc), repositories.ErrAccountNotFound)
+		})
+
+		t.Run("Then Update does not create it", func(t *testing.T) {
+			_, err := repo.Get(ctx, acc.ID)
+			assert.ErrorIs(t, err, repositories.ErrAccountNotFound)
+		})
+	})
+}
+
+func testUpdatesTx(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+
+	t.Run("Given two existing accounts", func(t *testing.T) {
+		from := newAccount("billy", 100.00)
+		to := newAccount("jhon", 100.00)
+		require.NoError(t, repo.Create(ctx, from))
+		require.NoError(t, repo.Create(ctx, to))
+
+		t.Run("When both are updated in a transaction", func(t *testing.T) {
+			from.Amount = 50.00
+			to.Amount = 150.00
+			require.NoError(t, repo.UpdatesTx(ctx, from, to))
+
+			t.Run("Then both changes are stored", func(t *testing.T) {
+				assertAmount(t, repo, from.ID, 50.00)
+				assertAmount(t, repo, to.ID, 150.00)
+			})
+		})
+
+		t.Run("When one of the updated accounts does not exist", func(t *testing.T) {
+			changedFrom := *from
+			changedFrom.Amount = 0.00
+			changedTo := *to
+			changedTo.Amount = 200.00
+			err := repo.UpdatesTx(ctx, &changedFrom, newAccount("ghost", 0.00), &changedTo)
+
+			t.Run("Then it fails as not found", func(t *testing.T) {
+				assert.ErrorIs(t, err, repositories.ErrAccountNotFound)
+			})
+
+			t.Run("Then no account is changed", func(t *testing.T) {
+				assertAmount(t, repo, from.ID, 50.00)
+				assertAmount(t, repo, to.ID, 150.00)
+			})
+		})
+	})
+}
+
+func testApply(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+
+	t.Run("Given an existing account", func(t *testing.T) {
+		acc := newAccount("billy", 100.00)
+		require.NoError(t, repo.Create(ctx, acc))
+
+		t.Run("When a change with ledger entries and accruals is applied", func(t *testing.T) {
+			acc.Amount = 110.00
+			day := time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)
+			change := repositories.Change{
+				Accounts: []*model.Account{acc},
+				Entries: []*model.LedgerEntry{
+					{ID: uuid.New(), AccountID: acc.ID, Type: model.EntryInterest, Amount: 10.00, Balance: 110.00, CreatedAt: day},
+				},
+				Accruals: []*model.InterestAccrual{
+					{AccountID: acc.ID, Date: day, Balance: 100.00, Rate: 0.02, Amount: 10.00},
+				},
+			}
+			require.NoError(t, repo.Apply(ctx, change))
+
+			t.Run("Then everything is stored", func(t *testing.T) {
+				assertAmount(t, repo, acc.ID, 110.00)
+				entries, err := repo.Entries(ctx, acc.ID)
+				require.NoError(t, err)
+				require.Len(t, entries, 1)
+				assert.Equal(t, 10.00, entries[0].Amount)
+				accruals, aErr := repo.Accruals(ctx, acc.ID)
+				require.NoError(t, aErr)
+				require.Len(t, accruals, 1)
+			})
+		})
+
+		t.Run("When a change has an entry for an account that does not exist", func(t *testing.T) {
+			changed := *acc
+			changed.Amount = 0.00
+			change := repositories.Change{
+				Accounts: []*model.Account{&changed},
+				Entries: []*model.LedgerEntry{
+					{ID: uuid.New(), AccountID: uuid.New(), Type: model.EntryInterest, Amount: 10.00, CreatedAt: time.Now()},
+				},
+			}
+			err := repo.Apply(ctx, change)
+
+			t.Run("Then nothing is stored", func(t *testing.T) {
+				assert.ErrorIs(t, err, repositories.ErrAccountNotFound)
+				assertAmount(t, repo, acc.ID, 110.00)
+				entries, eErr := repo.Entries(ctx, acc.ID)
+				require.NoError(t, eErr)
+				assert.Len(t, entries, 1)
+			})
+		})
+	})
+}
+
+func testHolds(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Given an existing account", func(t *testing.T) {
+		acc := newAccount("billy", 100.00)
+		require.NoError(t, repo.Create(ctx, acc))
+
+		t.Run("When holds are applied with the held amount", func(t *testing.T) {
+			expired := &model.Hold{ID: uuid.New(), AccountID: acc.ID, To: uuid.New(), Amount: 10.00,
+				Status: model.HoldActive, ExpiresAt: now, CreatedAt: now.Add(-time.Hour), UpdatedAt: now}
+			active := &model.Hold{ID: uuid.New(), AccountID: acc.ID, To: uuid.New(), Amount: 20.00,
+				Status: model.HoldActive, Reference: "order 1", ExpiresAt: now.Add(time.Hour), CreatedAt: now, UpdatedAt: now}
+			acc.Held = 30.00
+			change := repositories.Change{Accounts: []*model.Account{acc}, Holds: []*model.Hold{expired, active}}
+			require.NoError(t, repo.Apply(ctx, change))
+
+			t.Run("Then they are stored", func(t *testing.T) {
+				got, err := repo.GetHold(ctx, active.ID)
+				require.NoError(t, err)
+				assert.Equal(t, active, got)
+				stored, aErr := repo.Get(ctx, acc.ID)
+				require.NoError(t, aErr)
+				assert.Equal(t, 30.00, stored.Held)
+				assert.Equal(t, 70.00, stored.Available())
+			})
+
+			t.Run("Then they are listed by account oldest first", func(t *testing.T) {
+				holds, err := repo.Holds(ctx, acc.ID)
+				require.NoError(t, err)
+				require.Len(t, holds, 2)
+				assert.Equal(t, expired.ID, holds[0].ID)
+				assert.Equal(t, active.ID, holds[1].ID)
+			})
+
+			t.Run("Then only the expired one is due", func(t *testing.T) {
+				due, err := repo.ExpiredHolds(ctx, now, 10)
+				require.NoError(t, err)
+				require.Len(t, due, 1)
+				assert.Equal(t, expired.ID, due[0].ID)
+			})
+
+			t.Run("Then an update replaces the hold", func(t *testing.T) {
+				expired.Status = model.HoldExpired
+				require.NoError(t, repo.Apply(ctx, repositories.Change{Holds: []*model.Hold{expired}}))
+				due, err := repo.ExpiredHolds(ctx, now, 10)
+				require.NoError(t, err)
+				assert.Empty(t, due)
+			})
+		})
+
+		t.Run("Then an unknown hold is not found", func(t *testing.T) {
+			_, err := repo.GetHold(ctx, uuid.New())
+			assert.ErrorIs(t, err, repositories.ErrHoldNotFound)
+		})
+	})
+}
+
+func testTransferOrder(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+
+	t.Run("Given two existing accounts", func(t *testing.T) {
+		from, to := newAccount("billy", 100.00), newAccount("jhon", 0.00)
+		require.NoError(t, repo.Create(ctx, from))
+		require.NoError(t, repo.Create(ctx, to))
+
+		t.Run("When transfers created at the same time are stored one by one", func(t *testing.T) {
+			now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+			stored := make([]uuid.UUID, 5)
+			for i := range stored {
+				transfer := &model.Transfer{ID: uuid.New(), From: from.ID, To: to.ID, Amount: 1.00, Status: model.TransferCompleted, CreatedAt: now, UpdatedAt: now}
+				require.NoError(t, repo.Apply(ctx, repositories.Change{Transfers: []*model.Transfer{transfer}}))
+				stored[i] = transfer.ID
+			}
+			first, err := repo.GetTransfer(ctx, stored[0])
+			require.NoError(t, err)
+			first.Reversed = 0.50
+			first.UpdatedAt = now.Add(time.Minute)
+			require.NoError(t, repo.Apply(ctx, repositories.Change{Transfers: []*model.Transfer{first}}))
+
+			ids := func(transfers []*model.Transfer) []uuid.UUID {
+				listed := make([]uuid.UUID, len(transfers))
+				for i, transfer := range transfers {
+					listed[i] = transfer.ID
+				}
+				return listed
+			}
+
+			t.Run("Then they are listed by account in that order, updates included", func(t *testing.T) {
+				transfers, tErr := repo.Transfers(ctx, from.ID)
+				require.NoError(t, tErr)
+				assert.Equal(t, stored, ids(transfers))
+			})
+
+			t.Run("Then they are listed by status in that order", func(t *testing.T) {
+				transfers, tErr := repo.TransfersByStatus(ctx, model.TransferCompleted)
+				require.NoError(t, tErr)
+				assert.Equal(t, stored, ids(transfers))
+			})
+		})
+
+		t.Run("When a transfer is created again along with a change", func(t *testing.T) {
+			now := time.Date(2022, 9, 1, 13, 0, 0, 0, time.UTC)
+			transfer := &model.Transfer{ID: uuid.New(), From: from.ID, To: to.ID, Amount: 1.00, Status: model.TransferCompleted, CreatedAt: now, UpdatedAt: now}
+			require.NoError(t, repo.Apply(ctx, repositories.Change{NewTransfers: []*model.Transfer{transfer}}))
+			again := *transfer
+			again.Amount = 2.00
+			changed, err := repo.Get(ctx, from.ID)
+			require.NoError(t, err)
+			changed.Amount = 0.00
+			err = repo.Apply(ctx, repositories.Change{Accounts: []*model.Account{changed}, NewTransfers: []*model.Transfer{&again}})
+
+			t.Run("Then it is refused and nothing is stored", func(t *testing.T) {
+				assert.ErrorIs(t, err, repositories.ErrTransferExists)
+				got, gErr := repo.GetTransfer(ctx, transfer.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, 1.00, got.Amount)
+				assertAmount(t, repo, from.ID, 100.00)
+			})
+		})
+	})
+}
+
+func testSentTransfers(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+
+	t.Run("Given transfers sent by an account over a day", func(t *testing.T) {
+		from, to, other := newAccount("billy", 100.00), newAccount("jhon", 0.00), newAccount("nick", 0.00)
+		for _, acc := range []*model.Account{from, to, other} {
+			require.NoError(t, repo.Create(ctx, acc))
+		}
+		now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+		sent := []*model.Transfer{
+			{ID: uuid.New(), From: from.ID, To: to.ID, Amount: 1.00, Status: model.TransferCompleted, CreatedAt: now.Add(-20 * time.Hour)},
+			{ID: uuid.New(), From: from.ID, To: other.ID, Amount: 2.00, Status: model.TransferCompleted, CreatedAt: now.Add(-2 * time.Hour)},
+			{ID: uuid.New(), From: from.ID, To: to.ID, Amount: 3.00, Status: model.TransferFailed, CreatedAt: now.Add(-time.Hour)},
+			{ID: uuid.New(), From: to.ID, To: from.ID, Amount: 4.00, Status: model.TransferCompleted, CreatedAt: now.Add(-time.Hour)},
+			{ID: uuid.New(), From: from.ID, To: to.ID, Amount: 5.00, Status: model.TransferCompleted, CreatedAt: now},
+		}
+		for _, transfer := range sent {
+			transfer.UpdatedAt = transfer.CreatedAt
+		}
+		require.NoError(t, repo.Apply(ctx, repositories.Change{Transfers: sent}))
+
+		amounts := func(t *testing.T, filter repositories.SentFilter) []float64 {
+			transfers, err := repo.SentTransfers(ctx, filter)
+			require.NoError(t, err)
+			listed := make([]float64, len(transfers))
+			for i, transfer := range transfers {
+				listed[i] = transfer.Amount
+			}
+			return listed
+		}
+
+		t.Run("Then only its own are listed oldest first", func(t *testing.T) {
+			assert.Equal(t, []float64{1.00, 2.00, 3.00, 5.00}, amounts(t, repositories.SentFilter{From: from.ID}))
+		})
+
+		t.Run("Then they can be narrowed from a time on", func(t *testing.T) {
+			assert.Equal(t, []float64{2.00, 3.00, 5.00}, amounts(t, repositories.SentFilter{From: from.ID, Since: now.Add(-2 * time.Hour)}))
+		})
+
+		t.Run("Then they can be narrowed by recipient and status", func(t *testing.T) {
+			assert.Equal(t, []float64{1.00, 5.00}, amounts(t, repositories.SentFilter{From: from.ID, To: to.ID, Status: model.TransferCompleted}))
+		})
+
+		t.Run("Then they can be limited to the oldest", func(t *testing.T) {
+			assert.Equal(t, []float64{1.00}, amounts(t, repositories.SentFilter{From: from.ID, To: to.ID, Limit: 1}))
+		})
+	})
+}
+
+func testOutbox(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	newEvent := func(eventType model.EventType, aggregateID uuid.UUID) *model.Event {
+		return &model.Event{ID: uuid.New(), Type: eventType, AggregateID: aggregateID, Accounts: []uuid.UUID{aggregateID}, Payload: `{"Amount":10}`, OccurredAt: now}
+	}
+
+	t.Run("Given events applied with the changes they tell about", func(t *testing.T) {
+		acc := newAccount("billy", 100.00)
+		created := newEvent(model.EventAccountCreated, acc.ID)
+		require.NoError(t, repo.Apply(ctx, repositories.Change{Created: []*model.Account{acc}, Events: []*model.Event{created}}))
+		acc.Amount = 110.00
+		deposited := newEvent(model.EventMoneyDeposited, acc.ID)
+		require.NoError(t, repo.Apply(ctx, repositories.Change{Accounts: []*model.Account{acc}, Events: []*model.Event{deposited}}))
+
+		t.Run("Then they wait in the outbox in order", func(t *testing.T) {
+			events, err := repo.UnpublishedEvents(ctx, 10)
+			require.NoError(t, err)
+			require.Len(t, events, 2)
+			assert.Equal(t, created.ID, events[0].ID)
+			assert.Equal(t, model.EventAccountCreated, events[0].Type)
+			assert.Equal(t, `{"Amount":10}`, events[0].Payload)
+			assert.Equal(t, []uuid.UUID{acc.ID}, events[0].Accounts)
+			assert.Equal(t, deposited.ID, events[1].ID)
+			assert.Less(t, events[0].Sequence, events[1].Sequence)
+		})
+
+		t.Run("When a change fails", func(t *testing.T) {
+			lost := newEvent(model.EventMoneyWithdrawn, uuid.New())
+			err := repo.Apply(ctx, repositories.Change{Accounts: []*model.Account{newAccount("ghost", 0)}, Events: []*model.Event{lost}})
+			require.ErrorIs(t, err, repositories.ErrAccountNotFound)
+
+			t.Run("Then its events are not kept", func(t *testing.T) {
+				events, lErr := repo.UnpublishedEvents(ctx, 10)
+				require.NoError(t, lErr)
+				assert.Len(t, events, 2)
+			})
+		})
+
+		t.Run("When a delivery fails and another succeeds", func(t *testing.T) {
+			require.NoError(t, repo.MarkFailed(ctx, created.ID, "broker down"))
+			require.NoError(t, repo.MarkPublished(ctx, deposited.ID, now))
+
+			t.Run("Then only the failed one is waiting, with its attempt", func(t *testing.T) {
+				events, err := repo.UnpublishedEvents(ctx, 10)
+				require.NoError(t, err)
+				require.Len(t, events, 1)
+				assert.Equal(t, created.ID, events[0].ID)
+				assert.Equal(t, 1, events[0].Attempts)
+				assert.Equal(t, "broker down", events[0].LastError)
+			})
+		})
+
+		t.Run("When reading the events of the account", func(t *testing.T) {
+			other := newAccount("jane", 0.00)
+			require.NoError(t, repo.Apply(ctx, repositories.Change{Created: []*model.Account{other}, Events: []*model.Event{newEvent(model.EventAccountCreated, other.ID)}}))
+			events, err := repo.AccountEvents(ctx, acc.ID, 0, 10)
+			require.NoError(t, err)
+
+			t.Run("Then published or not they come by sequence", func(t *testing.T) {
+				require.Len(t, events, 2)
+				assert.Equal(t, created.ID, events[0].ID)
+				assert.Equal(t, deposited.ID, events[1].ID)
+			})
+
+			t.Run("Then they resume after a sequence", func(t *testing.T) {
+				rest, rErr := repo.AccountEvents(ctx, acc.ID, events[0].Sequence, 10)
+				require.NoError(t, rErr)
+				require.Len(t, rest, 1)
+				assert.Equal(t, deposited.ID, rest[0].ID)
+			})
+
+			t.Run("Then no more than the limit come", func(t *testing.T) {
+				first, fErr := repo.AccountEvents(ctx, acc.ID, 0, 1)
+				require.NoError(t, fErr)
+				require.Len(t, first, 1)
+				assert.Equal(t, created.ID, first[0].ID)
+			})
+
+			t.Run("Then the last sequence is the one of the latest event of any account", func(t *testing.T) {
+				last, lErr := repo.LastEventSequence(ctx)
+				require.NoError(t, lErr)
+				otherEvents, oErr := repo.AccountEvents(ctx, other.ID, 0, 10)
+				require.NoError(t, oErr)
+				require.Len(t, otherEvents, 1)
+				assert.Equal(t, otherEvents[0].Sequence, last)
+			})
+		})
+
+		t.Run("Then an unknown event is not found", func(t *testing.T) {
+			assert.ErrorIs(t, repo.MarkPublished(ctx, uuid.New(), now), repositories.ErrEventNotFound)
+		})
+	})
+}
+
+func testConcurrentWriters(t *testing.T, repo repositories.AccountRepository) {
+	ctx := context.Background()
+	const writers, additions = 10, 20
+
+	t.Run("Given many accounts", func(t *testing.T) {
+		accounts := make([]*model.Account, writers)
+		for i := range accounts {
+			accounts[i] = newAccount("billy", 0.00)
+			require.NoError(t, repo.Create(ctx, accounts[i]))
+		}
+		stored, err := repo.Get(ctx, accounts[0].ID)
+		require.NoError(t, err)
+		versioned := stored.Version != 0
+
+		// every writer shares an account with the one before and the one
+		// after it, so their updates overlap in a ring
+		concurrently := func(t *testing.T, write func(ind int, own, next uuid.UUID) error) []error {
+			signal := make(chan int)
+			var wg sync.WaitGroup
+			errs := make([]error, writers)
+			for i := range accounts {
+				wg.Add(1)
+				go func(ind int) {
+					defer wg.Done()
+					<-signal
+					next := (ind + 1) % writers
+					errs[ind] = write(ind, accounts[ind].ID, accounts[next].ID)
+					// repositories with optimistic concurrency refuse
+					// writes made from stale reads, writers read again
+					for errors.Is(errs[ind], repositories.ErrVersionConflict) {
+						errs[ind] = write(ind, accounts[ind].ID, accounts[next].ID)
+					}
+				}(i)
+			}
+			done := make(chan struct{})
+			go func() {
+				wg.Wait()
+				close(done)
+			}()
+			close(signal)
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("writers deadlocked")
+			}
+			return errs
+		}
+
+		t.Run("When all of them are updated at the same moment", func(t *testing.T) {
+			errs := concurrently(t, func(ind int, own, next uuid.UUID) error {
+				acc, gErr := repo.Get(ctx, own)
+				if gErr != nil {
+					return gErr
+				}
+				other, gErr := repo.Get(ctx, next)
+				if gErr != nil {
+					return gErr
+				}
+				acc.Amount, other.Amount = float64(ind), float64(ind)
+				return repo.UpdatesTx(ctx, acc, other)
+			})
+
+			t.Run("Then every writer succeeds", func(t *testing.T) {
+				for _, err := range errs {
+					assert.NoError(t, err)
+				}
+			})
+
+			t.Run("Then every account keeps what one of its writers wrote", func(t *testing.T) {
+				for i, acc := range accounts {
+					got, gErr := repo.Get(ctx, acc.ID)
+					require.NoError(t, gErr)
+					previous := (i + writers - 1) % writers
+					assert.Contains(t, []float64{float64(i), float64(previous)}, got.Amount)
+				}
+			})
+		})
+
+		t.Run("When each keeps adding to its own and the next one at the same moment", func(t *testing.T) {
+			before := make([]float64, writers)
+			for i, acc := range accounts {
+				got, gErr := repo.Get(ctx, acc.ID)
+				require.NoError(t, gErr)
+				before[i] = got.Amount
+			}
+
+			// the others keep the last write, additions made from reads
+			// of each other would be lost, so writers take turns like the
+			// service locker makes them
+			var turns sync.Mutex
+			errs := concurrently(t, func(_ int, own, next uuid.UUID) error {
+				for n := 0; n < additions; n++ {
+					if !versioned {
+						turns.Lock()
+					}
+					err := addTo(ctx, repo, own, next)
+					if !versioned {
+						turns.Unlock()
+					}
+					for errors.Is(err, repositories.ErrVersionConflict) {
+						err = addTo(ctx, repo, own, next)
+					}
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+
+			t.Run("Then every writer succeeds", func(t *testing.T) {
+				for _, err := range errs {
+					assert.NoError(t, err)
+				}
+			})
+
+			t.Run("Then no addition is lost", func(t *testing.T) {
+				var sum, expected float64
+				for i, acc := range accounts {
+					assertAmount(t, repo, acc.ID, before[i]+11.00*additions)
+					got, gErr := repo.Get(ctx, acc.ID)
+					require.NoError(t, gErr)
+					sum += got.Amount
+					expected += before[i] + 11.00*additions
+				}
+				assert.Equal(t, expected, sum)
+			})
+		})
+	})
+}
+
+// addTo reads both accounts and adds 1 to own and 10 to next in a single
+// update
+func addTo(ctx context.Context, repo repositories.AccountRepository, own, next uuid.UUID) error {
+	acc, err := repo.Get(ctx, own)
+	if err != nil {
+		return err
+	}
+	other, err := repo.Get(ctx, next)
+	if err != nil {
+		return err
+	}
+	acc.Amount += 1.00
+	other.Amount += 10.00
+	return repo.UpdatesTx(ctx, acc, other)
+}
+
+func testCancelledContext(t *testing.T, repo repositories.AccountRepository) {
+	t.Run("Given an existing account", func(t *testing.T) {
+		acc := newAccount("bill", 100.00)
+		require.NoError(t, repo.Create(context.Background(), acc))
+
+		t.Run("When it is updated with a cancelled context", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			changed := *acc
+			changed.Amount = 0.00
+			err := repo.UpdatesTx(ctx, &changed)
+
+			t.Run("Then it fails and nothing changes", func(t *testing.T) {
+				assert.Error(t, err)
+				assertAmount(t, repo, acc.ID, 100.00)
+			})
+		})
+	})
+}
+
+func assertAmount(t *testing.T, repo repositories.AccountRepository, accountID uuid.UUID, amount float64) {
+	t.Helper()
+	acc, err := repo.Get(context.Background(), accountID)
+	require.NoError(t, err)
+	assert.Equal(t, amount, acc.Amount)
+}
@@ -0,0 +1,20 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"github.com/google/uuid"
+)
+
+// SanctionsFilter narrows the listed checks, zero values match everything
+type SanctionsFilter struct {
+	SubjectID uuid.UUID
+	Decision  model.SanctionsDecision
+}
+
+type SanctionsRepository interface {
+	// Record stores a sanctions check, checks are never changed afterwards
+	Record(ctx context.Context, check *model.SanctionsCheck) error
+	// List returns the checks matching filter, oldest first
+	List(ctx context.Context, filter SanctionsFilter) ([]*model.SanctionsCheck, error)
+}
@@ -0,0 +1,69 @@
+package repositories_test
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSanctionsRepository(t *testing.T) {
+	db, err := repositories.OpenDB(repositories.DriverSQLite, "")
+	require.NoError(t, err)
+
+	impls := map[string]repositories.SanctionsRepository{
+		"memory": repositories.NewMemorySanctionsRepository(),
+		"db":     repositories.NewDBSanctionsRepository(db),
+	}
+
+	for name, repo := range impls {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+			transferID := uuid.New()
+
+			t.Run("Given checks of an account and a transfer", func(t *testing.T) {
+				clear := &model.SanctionsCheck{ID: uuid.New(), Subject: model.SanctionsAccount, SubjectID: uuid.New(), Name: "billy", Decision: model.SanctionsClear, CreatedAt: now}
+				hit := &model.SanctionsCheck{
+					ID: uuid.New(), Subject: model.SanctionsTransfer, SubjectID: transferID, Name: "ivan drago", Decision: model.SanctionsBlocked, CreatedAt: now.Add(time.Second),
+					Matches: []model.SanctionsMatch{
+						{EntryID: "1", List: "UN", Name: "Ivan Drago", Score: 1, Action: model.WatchlistBlock},
+						{EntryID: "2", List: "EU", Name: "Ivan Dragos", Score: 0.9, Action: model.WatchlistFlag},
+					},
+				}
+				require.NoError(t, repo.Record(ctx, clear))
+				require.NoError(t, repo.Record(ctx, hit))
+
+				t.Run("When listing every check", func(t *testing.T) {
+					checks, lErr := repo.List(ctx, repositories.SanctionsFilter{})
+					require.NoError(t, lErr)
+
+					t.Run("Then they come oldest first with their matches", func(t *testing.T) {
+						require.Len(t, checks, 2)
+						assert.Equal(t, clear.ID, checks[0].ID)
+						assert.Empty(t, checks[0].Matches)
+						assert.Equal(t, hit.Matches, checks[1].Matches)
+					})
+				})
+
+				t.Run("When filtering by subject and decision", func(t *testing.T) {
+					bySubject, sErr := repo.List(ctx, repositories.SanctionsFilter{SubjectID: transferID})
+					require.NoError(t, sErr)
+					byDecision, dErr := repo.List(ctx, repositories.SanctionsFilter{Decision: model.SanctionsClear})
+					require.NoError(t, dErr)
+
+					t.Run("Then only the matching ones are returned", func(t *testing.T) {
+						require.Len(t, bySubject, 1)
+						assert.Equal(t, hit.ID, bySubject[0].ID)
+						require.Len(t, byDecision, 1)
+						assert.Equal(t, clear.ID, byDecision[0].ID)
+					})
+				})
+			})
+		})
+	}
+}
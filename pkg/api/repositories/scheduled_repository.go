@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrScheduledTransferNotFound   = errors.New("scheduled transfer not found")
+	ErrScheduledTransferNotPending = errors.New("scheduled transfer is not pending")
+	// ErrScheduledTransferClaimLost is returned when a transfer was claimed
+	// again since the claim its outcome comes from
+	ErrScheduledTransferClaimLost = errors.New("scheduled transfer was claimed again")
+)
+
+type ScheduledTransferRepository interface {
+	// Create scheduled transfer
+	Create(ctx context.Context, transfer *model.ScheduledTransfer) error
+	// Get scheduled transfer
+	Get(ctx context.Context, transferID uuid.UUID) (*model.ScheduledTransfer, error)
+	// ListPending returns the pending transfers where the account is source or destiny
+	ListPending(ctx context.Context, accountID uuid.UUID) ([]*model.ScheduledTransfer, error)
+	// Cancel moves a pending transfer to cancelled
+	Cancel(ctx context.Context, transferID uuid.UUID) error
+	// ClaimDue moves up to limit pending transfers due at now to executing and
+	// returns them, along with the executing ones claimed before staleBefore,
+	// whose execution was abandoned. A claim is only ever taken once, even by
+	// concurrent callers.
+	ClaimDue(ctx context.Context, now time.Time, staleBefore time.Time, limit int) ([]*model.ScheduledTransfer, error)
+	// Finish records the outcome of a claimed transfer as long as it was not
+	// claimed again, ErrScheduledTransferClaimLost otherwise
+	Finish(ctx context.Context, transfer *model.ScheduledTransfer) error
+}
@@ -0,0 +1,101 @@
+package repositories_test
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduledTransferRepository_ClaimDue(t *testing.T) {
+	db, err := repositories.OpenDB(repositories.DriverSQLite, "")
+	require.NoError(t, err)
+
+	impls := map[string]repositories.ScheduledTransferRepository{
+		"memory": repositories.NewMemoryScheduledRepository(),
+		"db":     repositories.NewDBScheduledRepository(db),
+	}
+
+	for name, repo := range impls {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+
+			t.Run("Given due and future transfers", func(t *testing.T) {
+				due := &model.ScheduledTransfer{ID: uuid.New(), From: uuid.New(), To: uuid.New(), Amount: 10.00, ExecuteAt: now.Add(-time.Minute), Status: model.ScheduledPending, CreatedAt: now}
+				future := &model.ScheduledTransfer{ID: uuid.New(), From: uuid.New(), To: uuid.New(), Amount: 10.00, ExecuteAt: now.Add(time.Hour), Status: model.ScheduledPending, CreatedAt: now}
+				require.NoError(t, repo.Create(ctx, due))
+				require.NoError(t, repo.Create(ctx, future))
+
+				t.Run("When several callers claim at the same moment", func(t *testing.T) {
+					signal := make(chan int)
+					var wg sync.WaitGroup
+					var mux sync.Mutex
+					claimed := 0
+					for i := 0; i < 5; i++ {
+						wg.Add(1)
+						go func() {
+							defer wg.Done()
+							<-signal
+							transfers, cErr := repo.ClaimDue(ctx, now, now.Add(-time.Hour), 10)
+							assert.NoError(t, cErr)
+							mux.Lock()
+							claimed += len(transfers)
+							mux.Unlock()
+						}()
+					}
+					close(signal)
+					wg.Wait()
+
+					t.Run("Then only the due transfer is claimed, and only once", func(t *testing.T) {
+						assert.Equal(t, 1, claimed)
+						got, gErr := repo.Get(ctx, due.ID)
+						require.NoError(t, gErr)
+						assert.Equal(t, model.ScheduledExecuting, got.Status)
+					})
+				})
+
+				t.Run("When claiming before its claim goes stale", func(t *testing.T) {
+					transfers, cErr := repo.ClaimDue(ctx, now, now.Add(-time.Minute), 10)
+					require.NoError(t, cErr)
+
+					t.Run("Then it is not claimed again", func(t *testing.T) {
+						assert.Empty(t, transfers)
+					})
+				})
+
+				t.Run("When claiming once its claim is stale", func(t *testing.T) {
+					transfers, cErr := repo.ClaimDue(ctx, now.Add(time.Minute), now.Add(time.Second), 10)
+					require.NoError(t, cErr)
+
+					t.Run("Then it is claimed again, counting both claims", func(t *testing.T) {
+						require.Len(t, transfers, 1)
+						assert.Equal(t, due.ID, transfers[0].ID)
+						assert.Equal(t, 2, transfers[0].Claims)
+						assert.Equal(t, now.Add(time.Minute), transfers[0].ClaimedAt.UTC())
+					})
+				})
+
+				t.Run("When the stale claim and the new one finish", func(t *testing.T) {
+					stale, fresh := *due, *due
+					stale.Claims, fresh.Claims = 1, 2
+					stale.Status, fresh.Status = model.ScheduledFailed, model.ScheduledCompleted
+					require.NoError(t, repo.Finish(ctx, &fresh))
+					sErr := repo.Finish(ctx, &stale)
+
+					t.Run("Then only the outcome of the new claim is stored", func(t *testing.T) {
+						assert.ErrorIs(t, sErr, repositories.ErrScheduledTransferClaimLost)
+						got, gErr := repo.Get(ctx, due.ID)
+						require.NoError(t, gErr)
+						assert.Equal(t, model.ScheduledCompleted, got.Status)
+					})
+				})
+			})
+		})
+	}
+}
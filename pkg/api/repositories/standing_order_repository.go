@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrStandingOrderNotFound  = errors.New("standing order not found")
+	ErrStandingOrderNotActive = errors.New("standing order is not active")
+)
+
+type StandingOrderRepository interface {
+	// Create standing order
+	Create(ctx context.Context, order *model.StandingOrder) error
+	// Get standing order
+	Get(ctx context.Context, orderID uuid.UUID) (*model.StandingOrder, error)
+	// ListByAccount returns the orders where the account is source or destiny
+	ListByAccount(ctx context.Context, accountID uuid.UUID) ([]*model.StandingOrder, error)
+	// Cancel moves an active order to cancelled
+	Cancel(ctx context.Context, orderID uuid.UUID) error
+	// ClaimDue moves up to limit active orders due at now to executing and
+	// returns them, along with the executing ones claimed before staleBefore,
+	// whose execution was abandoned. A claim is only ever taken once, even by
+	// concurrent callers.
+	ClaimDue(ctx context.Context, now time.Time, staleBefore time.Time, limit int) ([]*model.StandingOrder, error)
+	// Save stores the state of a claimed order after running it
+	Save(ctx context.Context, order *model.StandingOrder) error
+	// AddRun records the outcome of an execution attempt
+	AddRun(ctx context.Context, run *model.StandingOrderRun) error
+	// ListRuns returns the execution history of an order, oldest first
+	ListRuns(ctx context.Context, orderID uuid.UUID) ([]*model.StandingOrderRun, error)
+}
@@ -0,0 +1,66 @@
+package repositories_test
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStandingOrderRepository_ClaimDue(t *testing.T) {
+	db, err := repositories.OpenDB(repositories.DriverSQLite, "")
+	require.NoError(t, err)
+
+	impls := map[string]repositories.StandingOrderRepository{
+		"memory": repositories.NewMemoryStandingOrderRepository(),
+		"db":     repositories.NewDBStandingOrderRepository(db),
+	}
+
+	for name, repo := range impls {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+
+			t.Run("Given a due order", func(t *testing.T) {
+				order := &model.StandingOrder{ID: uuid.New(), From: uuid.New(), To: uuid.New(), Amount: 10.00, Frequency: model.FrequencyDaily, StartAt: now, OnFailure: model.FailureSkip, Status: model.StandingOrderActive, NextRunAt: now.Add(-time.Minute), CreatedAt: now}
+				require.NoError(t, repo.Create(ctx, order))
+
+				t.Run("When it is claimed", func(t *testing.T) {
+					claimed, cErr := repo.ClaimDue(ctx, now, now.Add(-time.Hour), 10)
+					require.NoError(t, cErr)
+
+					t.Run("Then it is executing", func(t *testing.T) {
+						require.Len(t, claimed, 1)
+						assert.Equal(t, model.StandingOrderExecuting, claimed[0].Status)
+						assert.Equal(t, 1, claimed[0].Claims)
+					})
+				})
+
+				t.Run("When claiming before its claim goes stale", func(t *testing.T) {
+					claimed, cErr := repo.ClaimDue(ctx, now, now.Add(-time.Minute), 10)
+					require.NoError(t, cErr)
+
+					t.Run("Then it is not claimed again", func(t *testing.T) {
+						assert.Empty(t, claimed)
+					})
+				})
+
+				t.Run("When claiming once its claim is stale", func(t *testing.T) {
+					claimed, cErr := repo.ClaimDue(ctx, now.Add(time.Minute), now.Add(time.Second), 10)
+					require.NoError(t, cErr)
+
+					t.Run("Then it is claimed again, counting both claims", func(t *testing.T) {
+						require.Len(t, claimed, 1)
+						assert.Equal(t, order.ID, claimed[0].ID)
+						assert.Equal(t, 2, claimed[0].Claims)
+						assert.Equal(t, now.Add(time.Minute), claimed[0].ClaimedAt.UTC())
+					})
+				})
+			})
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"bank/pkg/api/model"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrDeliveryNotFound     = errors.New("webhook delivery not found")
+	// ErrDeliveryClaimLost is returned when a delivery was claimed again
+	// since the claim its attempt comes from
+	ErrDeliveryClaimLost = errors.New("webhook delivery was claimed again")
+)
+
+// DeliveryFilter narrows the listed deliveries, zero values match everything
+type DeliveryFilter struct {
+	SubscriptionID uuid.UUID
+	Status         model.DeliveryStatus
+}
+
+type WebhookRepository interface {
+	// Subscribe stores a new subscription
+	Subscribe(ctx context.Context, subscription *model.WebhookSubscription) error
+	// Subscription returns a subscription
+	Subscription(ctx context.Context, subscriptionID uuid.UUID) (*model.WebhookSubscription, error)
+	// Subscriptions returns every subscription, oldest first
+	Subscriptions(ctx context.Context) ([]*model.WebhookSubscription, error)
+	// Unsubscribe removes a subscription, its deliveries are kept
+	Unsubscribe(ctx context.Context, subscriptionID uuid.UUID) error
+	// Enqueue stores new deliveries
+	Enqueue(ctx context.Context, deliveries ...*model.WebhookDelivery) error
+	// Delivery returns a delivery
+	Delivery(ctx context.Context, deliveryID uuid.UUID) (*model.WebhookDelivery, error)
+	// ClaimDue returns up to limit pending deliveries whose next attempt is
+	// due at now, by next attempt, and moves their next attempt to until. A
+	// claim is only ever taken once, even by concurrent callers, and an
+	// abandoned one is due again from until.
+	ClaimDue(ctx context.Context, now time.Time, until time.Time, limit int) ([]*model.WebhookDelivery, error)
+	// SaveDelivery stores the state of a delivery as long as it was not
+	// claimed again, ErrDeliveryClaimLost otherwise
+	SaveDelivery(ctx context.Context, delivery *model.WebhookDelivery) error
+	// Deliveries returns the deliveries matching filter, newest first
+	Deliveries(ctx context.Context, filter DeliveryFilter) ([]*model.WebhookDelivery, error)
+}
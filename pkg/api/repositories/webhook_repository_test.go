@@ -0,0 +1,150 @@
+package repositories_test
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestWebhookRepository(t *testing.T) {
+	db, err := repositories.OpenDB(repositories.DriverSQLite, "")
+	require.NoError(t, err)
+
+	impls := map[string]repositories.WebhookRepository{
+		"memory": repositories.NewMemoryWebhookRepository(),
+		"db":     repositories.NewDBWebhookRepository(db),
+	}
+
+	for name, repo := range impls {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().UTC().Truncate(time.Second)
+
+			subscription := &model.WebhookSubscription{
+				ID: uuid.New(), URL: "https://partner.example/hooks", Secret: "whsec_1", CreatedAt: now,
+				EventTypes: []model.EventType{model.EventMoneyDeposited, model.EventTransferCompleted},
+				Accounts:   []uuid.UUID{uuid.New(), uuid.New()},
+			}
+			other := &model.WebhookSubscription{ID: uuid.New(), URL: "https://other.example/hooks", Secret: "whsec_2", AllAccounts: true, CreatedAt: now.Add(time.Second)}
+
+			t.Run("Given two subscriptions", func(t *testing.T) {
+				require.NoError(t, repo.Subscribe(ctx, subscription))
+				require.NoError(t, repo.Subscribe(ctx, other))
+
+				t.Run("Then they are listed oldest first with their filters", func(t *testing.T) {
+					subscriptions, lErr := repo.Subscriptions(ctx)
+					require.NoError(t, lErr)
+					require.Len(t, subscriptions, 2)
+					assert.Equal(t, subscription.ID, subscriptions[0].ID)
+					assert.Equal(t, subscription.EventTypes, subscriptions[0].EventTypes)
+					assert.Equal(t, subscription.Accounts, subscriptions[0].Accounts)
+					assert.Empty(t, subscriptions[1].EventTypes)
+					assert.Empty(t, subscriptions[1].Accounts)
+					assert.False(t, subscriptions[0].AllAccounts)
+					assert.True(t, subscriptions[1].AllAccounts)
+				})
+
+				t.Run("When one is removed", func(t *testing.T) {
+					require.NoError(t, repo.Unsubscribe(ctx, other.ID))
+
+					t.Run("Then it is not found anymore", func(t *testing.T) {
+						_, gErr := repo.Subscription(ctx, other.ID)
+						assert.ErrorIs(t, gErr, repositories.ErrSubscriptionNotFound)
+						assert.ErrorIs(t, repo.Unsubscribe(ctx, other.ID), repositories.ErrSubscriptionNotFound)
+					})
+				})
+			})
+
+			t.Run("Given deliveries", func(t *testing.T) {
+				later := &model.WebhookDelivery{ID: uuid.New(), SubscriptionID: subscription.ID, EventID: uuid.New(), EventType: model.EventMoneyDeposited, Body: `{}`, Status: model.DeliveryPending, NextAttemptAt: now.Add(time.Minute), CreatedAt: now, UpdatedAt: now}
+				due := &model.WebhookDelivery{ID: uuid.New(), SubscriptionID: subscription.ID, EventID: uuid.New(), EventType: model.EventTransferCompleted, Body: `{}`, Status: model.DeliveryPending, NextAttemptAt: now, CreatedAt: now, UpdatedAt: now}
+				elsewhere := &model.WebhookDelivery{ID: uuid.New(), SubscriptionID: other.ID, EventID: uuid.New(), EventType: model.EventMoneyWithdrawn, Body: `{}`, Status: model.DeliveryPending, NextAttemptAt: now.Add(-time.Minute), CreatedAt: now, UpdatedAt: now}
+				require.NoError(t, repo.Enqueue(ctx, later, due))
+				require.NoError(t, repo.Enqueue(ctx, elsewhere))
+
+				t.Run("When the due ones are claimed", func(t *testing.T) {
+					until := now.Add(time.Hour)
+					first, fErr := repo.ClaimDue(ctx, now, until, 1)
+					require.NoError(t, fErr)
+					rest, rErr := repo.ClaimDue(ctx, now, until, 10)
+					require.NoError(t, rErr)
+
+					t.Run("Then they come by next attempt, each once", func(t *testing.T) {
+						require.Len(t, first, 1)
+						assert.Equal(t, elsewhere.ID, first[0].ID)
+						require.Len(t, rest, 1)
+						assert.Equal(t, due.ID, rest[0].ID)
+						assert.Equal(t, 1, rest[0].Claims)
+						assert.True(t, until.Equal(rest[0].NextAttemptAt))
+
+						again, aErr := repo.ClaimDue(ctx, now, until, 10)
+						require.NoError(t, aErr)
+						assert.Empty(t, again)
+					})
+
+					t.Run("When one is dead", func(t *testing.T) {
+						exhausted := rest[0]
+						exhausted.Status = model.DeliveryDead
+						exhausted.Attempts = 10
+						exhausted.LastStatusCode = 500
+						exhausted.LastError = "unexpected status 500"
+						require.NoError(t, repo.SaveDelivery(ctx, exhausted))
+
+						t.Run("Then it is saved", func(t *testing.T) {
+							got, gErr := repo.Delivery(ctx, due.ID)
+							require.NoError(t, gErr)
+							assert.Equal(t, model.DeliveryDead, got.Status)
+							assert.Equal(t, 10, got.Attempts)
+							assert.Equal(t, 500, got.LastStatusCode)
+							assert.Equal(t, "unexpected status 500", got.LastError)
+						})
+
+						t.Run("Then the filters find it", func(t *testing.T) {
+							dead, dErr := repo.Deliveries(ctx, repositories.DeliveryFilter{Status: model.DeliveryDead})
+							require.NoError(t, dErr)
+							require.Len(t, dead, 1)
+							assert.Equal(t, due.ID, dead[0].ID)
+
+							bySubscription, sErr := repo.Deliveries(ctx, repositories.DeliveryFilter{SubscriptionID: subscription.ID})
+							require.NoError(t, sErr)
+							require.Len(t, bySubscription, 2)
+							assert.Equal(t, due.ID, bySubscription[0].ID, "newest first")
+							assert.Equal(t, later.ID, bySubscription[1].ID)
+						})
+
+						t.Run("When the claims expire", func(t *testing.T) {
+							expired, eErr := repo.ClaimDue(ctx, until, until.Add(time.Hour), 10)
+							require.NoError(t, eErr)
+
+							t.Run("Then the pending ones are claimed again, not the dead one", func(t *testing.T) {
+								require.Len(t, expired, 2)
+								assert.Equal(t, later.ID, expired[0].ID)
+								assert.Equal(t, elsewhere.ID, expired[1].ID)
+								assert.Equal(t, 2, expired[1].Claims)
+							})
+
+							t.Run("Then the attempt of the expired claim is not saved", func(t *testing.T) {
+								first[0].Status = model.DeliveryDelivered
+								assert.ErrorIs(t, repo.SaveDelivery(ctx, first[0]), repositories.ErrDeliveryClaimLost)
+								got, gErr := repo.Delivery(ctx, elsewhere.ID)
+								require.NoError(t, gErr)
+								assert.Equal(t, model.DeliveryPending, got.Status)
+							})
+						})
+					})
+				})
+
+				t.Run("Then an unknown delivery is not found", func(t *testing.T) {
+					_, gErr := repo.Delivery(ctx, uuid.New())
+					assert.ErrorIs(t, gErr, repositories.ErrDeliveryNotFound)
+					assert.ErrorIs(t, repo.SaveDelivery(ctx, &model.WebhookDelivery{ID: uuid.New()}), repositories.ErrDeliveryNotFound)
+				})
+			})
+		})
+	}
+}
@@ -2,46 +2,234 @@ package service
 
 import (
 	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
 	"bank/pkg/api/repositories"
+	"context"
 	"errors"
 	"github.com/google/uuid"
-	"sync"
+	"log"
+	"time"
+)
+
+var (
+	ErrInconsistentData = errors.New("inconsistent data")
+	ErrUnknownProduct   = errors.New("unknown account product")
+	ErrNotReversible    = errors.New("transfer can not be reversed")
 )
 
 type AccountService interface {
-	Create(req dto.CreateAccountRequest) (dto.CreateAccountResponse, error)
-	AddMoney(accountID uuid.UUID, amount float64) (dto.UpdateAccountResponse, error)
-	Transfer(accFrom uuid.UUID, accTo uuid.UUID, amount float64) error
-	Get(accountID uuid.UUID) (dto.GetAccountResponse, error)
-	GetAll() (dto.GetAllAccountResponse, error)
+	Create(ctx context.Context, req dto.CreateAccountRequest) (dto.CreateAccountResponse, error)
+	AddMoney(ctx context.Context, accountID uuid.UUID, amount float64) (dto.UpdateAccountResponse, error)
+	Withdraw(ctx context.Context, accountID uuid.UUID, amount float64) (dto.UpdateAccountResponse, error)
+	// Transfer moves amount between two accounts. Transfers rejected for lack
+	// of funds are kept as failed. Transfers held by screening are kept in
+	// review, without error, until an operator approves or rejects them.
+	Transfer(ctx context.Context, accFrom uuid.UUID, accTo uuid.UUID, amount float64, memo string) (dto.TransferResponse, error)
+	// Split moves money from several debit accounts to several credit
+	// accounts at once, debits and credits must add up to the same amount
+	Split(ctx context.Context, req dto.SplitTransferRequest) (dto.TransferResponse, error)
+	// Batch executes many transfers holding the lock once. In atomic mode
+	// either all of them are stored or ErrBatchRejected is returned with the
+	// transfer that failed, or was held by screening; in best effort mode
+	// every transfer succeeds, fails or is held on its own.
+	Batch(ctx context.Context, req dto.BatchTransferRequest) (dto.BatchTransferResponse, error)
+	GetTransfer(ctx context.Context, transferID uuid.UUID) (dto.TransferResponse, error)
+	// Transfers returns the transfers sent or received by an account
+	Transfers(ctx context.Context, accountID uuid.UUID) (dto.GetTransfersResponse, error)
+	Get(ctx context.Context, accountID uuid.UUID) (dto.GetAccountResponse, error)
+	GetAll(ctx context.Context) (dto.GetAllAccountResponse, error)
+	// Reverse gives back amount of a transfer, everything not yet reversed
+	// when amount is zero, as a new transfer linked to the original
+	Reverse(ctx context.Context, transferID uuid.UUID, req dto.ReversalRequest) (dto.TransferResponse, error)
+	Transactions(ctx context.Context, accountID uuid.UUID) (dto.GetTransactionsResponse, error)
+	// Apply runs change on the current state of the accounts holding the same
+	// lock as any other balance change, then stores what it returns audited
+	// as action, along with the events of the holds, interest and fees it
+	// changed. It lets other services move money without racing with this
+	// one.
+	Apply(ctx context.Context, action model.AuditAction, accountIDs []uuid.UUID, change func(accounts []*model.Account) (repositories.Change, error)) error
+	// ReviewQueue returns the transfers held by screening, oldest first
+	ReviewQueue(ctx context.Context) (dto.GetTransfersResponse, error)
+	// Approve executes a transfer held by screening, it may still fail for
+	// lack of funds or limits
+	Approve(ctx context.Context, transferID uuid.UUID) (dto.TransferResponse, error)
+	// Reject fails a transfer held by screening without moving any money
+	Reject(ctx context.Context, transferID uuid.UUID, req dto.RejectTransferRequest) (dto.TransferResponse, error)
+	// LockStats tells how often operations waited on each other for accounts
+	LockStats(ctx context.Context) dto.LockStatsResponse
+	// Pay executes a pending transfer made by another service, such as the
+	// capture of a hold, as Transfer does: screened, within the limits of the
+	// sender and charged its fees. prepare runs first on the sender holding
+	// the lock and returns what else to store, audited as action. Unlike
+	// Transfer nothing is kept when it fails, nor when it is held for review,
+	// which comes back as ErrHeldForReview.
+	Pay(ctx context.Context, action model.AuditAction, transfer *model.Transfer, prepare func(from *model.Account) (repositories.Change, error)) (dto.TransferResponse, error)
+}
+
+// Option customizes the account service
+type Option func(a *accountService)
+
+// WithFees charges the fees of schedule on transfers, crediting them to the
+// revenue account
+func WithFees(schedule model.FeeSchedule, revenueAccountID uuid.UUID) Option {
+	return func(a *accountService) {
+		a.fees = schedule
+		a.revenueAccountID = revenueAccountID
+	}
+}
+
+// WithLimits bounds the money leaving accounts through transfers and
+// withdrawals
+func WithLimits(limits model.Limits) Option {
+	return func(a *accountService) {
+		a.limits = limits
+	}
+}
+
+// WithScreener screens every transfer before its money moves. Screeners add
+// up, the most severe decision of them wins.
+func WithScreener(screener TransferScreener) Option {
+	return func(a *accountService) {
+		a.screeners = append(a.screeners, screener)
+	}
+}
+
+// WithSanctions screens the name of every account opened, blocking it on a
+// hit, and the counterparties of every transfer, split transfer and hold
+// capture
+func WithSanctions(sanctions SanctionsService) Option {
+	return func(a *accountService) {
+		a.sanctions = sanctions
+		a.screeners = append(a.screeners, sanctions)
+	}
+}
+
+// WithEventStream wakes the streams of the accounts every committed change is
+// about, so they do not wait for their next poll
+func WithEventStream(stream EventStream) Option {
+	return func(a *accountService) {
+		a.stream = stream
+	}
 }
 
-func NewAccountService(repository repositories.AccountRepository) AccountService {
-	return &accountService{
+// WithLockStripes locks accounts on a table of stripes. At most queueSize
+// operations wait on a stripe, more are refused with ErrAccountBusy, and a
+// non positive queueSize lets them all wait as long as their context allows.
+// Counts below one fall back to DefaultLockStripes.
+func WithLockStripes(stripes int, queueSize int) Option {
+	return func(a *accountService) {
+		a.locks = newLockStripes(stripes, queueSize)
+	}
+}
+
+// WithClock replaces the clock used to date ledger entries
+func WithClock(clock Clock) Option {
+	return func(a *accountService) {
+		a.clock = clock
+	}
+}
+
+func NewAccountService(repository repositories.AccountRepository, opts ...Option) AccountService {
+	a := &accountService{
 		repository: repository,
+		clock:      time.Now,
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	if a.locks == nil {
+		a.locks = newLockStripes(DefaultLockStripes, DefaultLockQueue)
 	}
+	return a
 }
 
 type accountService struct {
-	mux        sync.RWMutex
-	repository repositories.AccountRepository
+	// locks are used for pessimistic locking, operations on the same accounts
+	// run one after the other. Unlike a sync.Mutex, waiting on them can be
+	// abandoned once the caller's context is done.
+	locks            locker
+	repository       repositories.AccountRepository
+	fees             model.FeeSchedule
+	revenueAccountID uuid.UUID
+	limits           model.Limits
+	screeners        []TransferScreener
+	sanctions        SanctionsService
+	stream           EventStream
+	clock            Clock
+}
+
+// acquire waits until the caller is the only one working on accountIDs and,
+// while limits track their usage, on the accounts of the same customers. The
+// returned release must be called once done.
+func (a *accountService) acquire(ctx context.Context, accountIDs ...uuid.UUID) (func(), error) {
+	keys := make([]string, 0, len(accountIDs))
+	for _, accountID := range accountIDs {
+		keys = append(keys, accountID.String())
+		if len(a.limits) == 0 {
+			continue
+		}
+		// the customer of an account never changes, it can be read before
+		// locking. Unknown accounts fail once locked.
+		if acc, err := a.repository.Get(ctx, accountID); err == nil && acc.CustomerID != "" {
+			keys = append(keys, model.CustomerSubject(acc.CustomerID))
+		}
+	}
+	return a.locks.acquire(ctx, keys)
+}
+
+func (a *accountService) LockStats(_ context.Context) dto.LockStatsResponse {
+	return a.locks.stats()
 }
 
-func (a *accountService) Get(accountID uuid.UUID) (dto.GetAccountResponse, error) {
-	account, err := a.repository.Get(accountID)
+// debit is money sent from an account, which may be charged fees
+type debit struct {
+	from   uuid.UUID
+	amount float64
+}
+
+// charged adds the revenue account to accountIDs when any of debits is charged
+// fees, so operations without fees do not all wait on it. Fees only depend on
+// the amount and the product of the sender, which never changes, so they are
+// known before locking.
+func (a *accountService) charged(ctx context.Context, accountIDs []uuid.UUID, debits ...debit) []uuid.UUID {
+	if len(a.fees) == 0 {
+		return accountIDs
+	}
+	for _, d := range debits {
+		// unknown accounts fail once locked
+		from, err := a.repository.Get(ctx, d.from)
+		if err == nil && model.TotalFees(a.transferFees(from, d.amount)) > 0 {
+			return append(accountIDs, a.revenueAccountID)
+		}
+	}
+	return accountIDs
+}
+
+// commit stores change, then wakes the streams of the accounts its events
+// are about
+func (a *accountService) commit(ctx context.Context, change repositories.Change) error {
+	if err := a.repository.Apply(ctx, change); err != nil {
+		return err
+	}
+	if a.stream != nil {
+		for _, event := range change.Events {
+			a.stream.Notify(event.Accounts)
+		}
+	}
+	return nil
+}
+
+func (a *accountService) Get(ctx context.Context, accountID uuid.UUID) (dto.GetAccountResponse, error) {
+	account, err := a.repository.Get(ctx, accountID)
 	if err != nil {
 		return dto.GetAccountResponse{}, err
 	}
 
-	return dto.GetAccountResponse{
-		ID:     account.ID,
-		Name:   account.Name,
-		Amount: account.Amount,
-	}, nil
+	return toAccountResponse(account), nil
 }
 
-func (a *accountService) GetAll() (dto.GetAllAccountResponse, error) {
-	accounts, err := a.repository.GetAll()
+func (a *accountService) GetAll(ctx context.Context) (dto.GetAllAccountResponse, error) {
+	accounts, err := a.repository.GetAll(ctx)
 	if err != nil {
 		return dto.GetAllAccountResponse{}, err
 	}
@@ -49,48 +237,76 @@ func (a *accountService) GetAll() (dto.GetAllAccountResponse, error) {
 	accResponses := make([]dto.GetAccountResponse, len(accounts))
 	for i := range accounts {
 		account := accounts[i]
-		accResponses[i] = dto.GetAccountResponse{
-			ID:     account.ID,
-			Name:   account.Name,
-			Amount: account.Amount,
-		}
+		accResponses[i] = toAccountResponse(account)
 	}
 
 	return dto.GetAllAccountResponse{Accounts: accResponses}, nil
 }
 
-func (a *accountService) Create(req dto.CreateAccountRequest) (dto.CreateAccountResponse, error) {
-	newAccount, nErr := NewAccount(req)
+func (a *accountService) Create(ctx context.Context, req dto.CreateAccountRequest) (dto.CreateAccountResponse, error) {
+	newAccount, nErr := NewAccount(req, a.clock())
 	if nErr != nil {
 		return dto.CreateAccountResponse{}, nErr
 	}
 
-	if cErr := a.repository.Create(newAccount); cErr != nil {
+	if a.sanctions != nil {
+		check, sErr := a.sanctions.CheckName(ctx, model.SanctionsAccount, newAccount.ID, newAccount.Name)
+		if sErr != nil {
+			return dto.CreateAccountResponse{}, sErr
+		}
+		if check.Decision == model.SanctionsBlocked {
+			return dto.CreateAccountResponse{}, ErrSanctioned
+		}
+	}
+
+	change := repositories.Change{
+		Created: []*model.Account{newAccount},
+		Audit: []*model.AuditRecord{
+			newAuditRecord(ctx, a.clock, model.AuditAccountCreated, AuditEntityAccount, newAccount.ID, nil, &auditState{Accounts: accountStates([]*model.Account{newAccount})}),
+		},
+		Events: []*model.Event{newEvent(a.clock, model.EventAccountCreated, newAccount.ID, []uuid.UUID{newAccount.ID}, toAccountResponse(newAccount))},
+	}
+	if cErr := a.commit(ctx, change); cErr != nil {
 		return dto.CreateAccountResponse{}, cErr
 	}
 
 	return dto.CreateAccountResponse{
-		ID:     newAccount.ID,
-		Name:   newAccount.Name,
-		Amount: newAccount.Amount,
+		ID:         newAccount.ID,
+		Name:       newAccount.Name,
+		CustomerID: newAccount.CustomerID,
+		Amount:     newAccount.Amount,
+		Product:    newAccount.Product,
 	}, nil
 }
 
-func (a *accountService) AddMoney(accountID uuid.UUID, amount float64) (dto.UpdateAccountResponse, error) {
+func (a *accountService) AddMoney(ctx context.Context, accountID uuid.UUID, amount float64) (dto.UpdateAccountResponse, error) {
 	// used for pessimistic locking
-	a.mux.Lock()
-	defer a.mux.Unlock()
+	release, lErr := a.acquire(ctx, accountID)
+	if lErr != nil {
+		return dto.UpdateAccountResponse{}, lErr
+	}
+	defer release()
 
-	acc, gErr := a.repository.Get(accountID)
+	acc, gErr := a.repository.Get(ctx, accountID)
 	if gErr != nil {
 		return dto.UpdateAccountResponse{}, gErr
 	}
+	before := &auditState{Accounts: accountStates([]*model.Account{acc})}
 
 	if addErr := acc.AddMoney(amount); addErr != nil {
 		return dto.UpdateAccountResponse{}, addErr
 	}
 
-	if updErr := a.repository.Update(acc); updErr != nil {
+	entry := a.entry(acc, model.EntryDeposit, amount, "deposit")
+	change := repositories.Change{
+		Accounts: []*model.Account{acc},
+		Entries:  []*model.LedgerEntry{entry},
+		Audit: []*model.AuditRecord{
+			newAuditRecord(ctx, a.clock, model.AuditDeposit, AuditEntityAccount, acc.ID, before, &auditState{Accounts: accountStates([]*model.Account{acc})}),
+		},
+		Events: []*model.Event{entryEvent(a.clock, model.EventMoneyDeposited, entry)},
+	}
+	if updErr := a.commit(ctx, change); updErr != nil {
 		return dto.UpdateAccountResponse{}, updErr
 	}
 
@@ -101,37 +317,660 @@ func (a *accountService) AddMoney(accountID uuid.UUID, amount float64) (dto.Upda
 	}, nil
 }
 
-func (a *accountService) Transfer(fromID uuid.UUID, toID uuid.UUID, amount float64) error {
+func (a *accountService) Withdraw(ctx context.Context, accountID uuid.UUID, amount float64) (dto.UpdateAccountResponse, error) {
 	// used for pessimistic locking
-	a.mux.Lock()
-	defer a.mux.Unlock()
+	release, lErr := a.acquire(ctx, accountID)
+	if lErr != nil {
+		return dto.UpdateAccountResponse{}, lErr
+	}
+	defer release()
+
+	if amount <= 0 {
+		return dto.UpdateAccountResponse{}, ErrInconsistentData
+	}
+
+	accounts := newAccountSet(ctx, a.repository)
+	acc, gErr := accounts.get(accountID)
+	if gErr != nil {
+		return dto.UpdateAccountResponse{}, gErr
+	}
+
+	usages, lErr := a.checkLimits(accounts, acc, model.LimitOnWithdrawal, amount)
+	if lErr != nil {
+		return dto.UpdateAccountResponse{}, lErr
+	}
 
+	if wErr := acc.Withdraw(amount); wErr != nil {
+		return dto.UpdateAccountResponse{}, wErr
+	}
+	a.useLimits(accounts, usages, amount)
+
+	before, after := accounts.states()
+	entry := a.entry(acc, model.EntryWithdrawal, -amount, "withdrawal")
+	change := repositories.Change{
+		Accounts: accounts.all(),
+		Entries:  []*model.LedgerEntry{entry},
+		Usage:    accounts.usages(),
+		Audit: []*model.AuditRecord{
+			newAuditRecord(ctx, a.clock, model.AuditWithdrawal, AuditEntityAccount, acc.ID, before, after),
+		},
+		Events: []*model.Event{entryEvent(a.clock, model.EventMoneyWithdrawn, entry)},
+	}
+	if updErr := a.commit(ctx, change); updErr != nil {
+		return dto.UpdateAccountResponse{}, updErr
+	}
+
+	return dto.UpdateAccountResponse{
+		ID:            acc.ID,
+		Name:          acc.Name,
+		CurrentAmount: acc.Amount,
+	}, nil
+}
+
+func (a *accountService) Transfer(ctx context.Context, fromID uuid.UUID, toID uuid.UUID, amount float64, memo string) (dto.TransferResponse, error) {
+	// used for pessimistic locking
+	release, lErr := a.acquire(ctx, a.charged(ctx, []uuid.UUID{fromID, toID}, debit{fromID, amount})...)
+	if lErr != nil {
+		return dto.TransferResponse{}, lErr
+	}
+	defer release()
+
+	accounts := newAccountSet(ctx, a.repository)
+	transfer, fees, entries, err := a.move(accounts, fromID, toID, amount, memo)
+	if errors.Is(err, ErrHeldForReview) {
+		// unlike failed transfers it must be stored, an operator acts on it
+		change := repositories.Change{
+			NewTransfers: []*model.Transfer{transfer},
+			Audit: []*model.AuditRecord{
+				newAuditRecord(ctx, a.clock, model.AuditTransfer, AuditEntityTransfer, transfer.ID, nil, &auditState{Transfers: transferStates(transfer)}),
+			},
+			Events: []*model.Event{transferEvent(a.clock, transfer)},
+		}
+		if sErr := a.commit(ctx, change); sErr != nil {
+			return dto.TransferResponse{}, sErr
+		}
+		return toTransferResponse(transfer), nil
+	}
+	if err != nil {
+		if transfer != nil {
+			a.fail(ctx, model.AuditTransfer, transfer)
+		}
+		return dto.TransferResponse{}, err
+	}
+
+	before, after := accounts.states()
+	after.Transfers = transferStates(transfer)
+	change := repositories.Change{
+		Accounts:     accounts.all(),
+		Entries:      entries,
+		NewTransfers: []*model.Transfer{transfer},
+		Usage:        accounts.usages(),
+		Audit: []*model.AuditRecord{
+			newAuditRecord(ctx, a.clock, model.AuditTransfer, AuditEntityTransfer, transfer.ID, before, after),
+		},
+		Events: []*model.Event{transferEvent(a.clock, transfer)},
+	}
+	// update every change in every account as transactional
+	if aErr := a.commit(ctx, change); aErr != nil {
+		return dto.TransferResponse{}, aErr
+	}
+
+	resp := toTransferResponse(transfer)
+	resp.Fees = toFeeResponses(fees)
+	return resp, nil
+}
+
+// move transfers amount plus its fees between accounts already loaded in set,
+// returning the ledger entries to store with the transfer. Nothing changes on
+// error. The transfer is returned along with the error when it is worth
+// keeping: failed for a reason such as a lack of funds, or held for review
+// with ErrHeldForReview.
+func (a *accountService) move(set *accountSet, fromID uuid.UUID, toID uuid.UUID, amount float64, memo string) (*model.Transfer, []model.Fee, []*model.LedgerEntry, error) {
 	if fromID == toID || amount <= 0 {
-		return errors.New("inconsistent data")
+		return nil, nil, nil, ErrInconsistentData
+	}
+
+	if _, fErr := set.get(fromID); fErr != nil {
+		return nil, nil, nil, fErr
+	}
+	if _, tErr := set.get(toID); tErr != nil {
+		return nil, nil, nil, tErr
+	}
+
+	now := a.clock()
+	transfer := &model.Transfer{
+		ID:        transferID(set.ctx),
+		From:      fromID,
+		To:        toID,
+		Amount:    amount,
+		Status:    model.TransferPending,
+		Memo:      memo,
+		CreatedAt: now,
+		UpdatedAt: now,
 	}
 
-	from, fErr := a.repository.Get(fromID)
+	err := a.screen(set.ctx, transfer)
+	var fees []model.Fee
+	var entries []*model.LedgerEntry
+	if err == nil {
+		fees, entries, err = a.execute(set, transfer)
+	}
+	if err != nil {
+		if transfer.Status == model.TransferPending {
+			return nil, nil, nil, err
+		}
+		return transfer, nil, nil, err
+	}
+
+	return transfer, fees, entries, nil
+}
+
+// execute moves the money of a pending transfer between accounts loaded in
+// set, charging its fees. The transfer is marked failed when rejected for a
+// reason worth keeping, it is left pending on any other error.
+func (a *accountService) execute(set *accountSet, transfer *model.Transfer) ([]model.Fee, []*model.LedgerEntry, error) {
+	from, fErr := set.get(transfer.From)
 	if fErr != nil {
-		return fErr
+		return nil, nil, fErr
 	}
 
-	to, tErr := a.repository.Get(toID)
+	to, tErr := set.get(transfer.To)
 	if tErr != nil {
-		return tErr
+		return nil, nil, tErr
 	}
 
-	if wErr := from.Withdraw(amount); wErr != nil {
-		return wErr
+	amount := transfer.Amount
+	fees := a.transferFees(from, amount)
+	fee := model.TotalFees(fees)
+
+	var revenue *model.Account
+	if fee > 0 {
+		var rErr error
+		if revenue, rErr = set.get(a.revenueAccountID); rErr != nil {
+			return nil, nil, rErr
+		}
+	}
+
+	transfer.Fee = fee
+	transfer.UpdatedAt = a.clock()
+
+	usages, lErr := a.checkLimits(set, from, model.LimitOnTransfer, amount)
+	if lErr != nil {
+		if errors.Is(lErr, model.ErrLimitExceeded) {
+			transfer.Status = model.TransferFailed
+			transfer.FailureReason = lErr.Error()
+		}
+		return nil, nil, lErr
+	}
+
+	if wErr := from.Withdraw(amount + fee); wErr != nil {
+		transfer.Status = model.TransferFailed
+		transfer.FailureReason = wErr.Error()
+		return nil, nil, wErr
 	}
 
 	if aErr := to.AddMoney(amount); aErr != nil {
-		return aErr
+		return nil, nil, aErr
 	}
+	a.useLimits(set, usages, amount)
 
-	// update both changes in both accounts as transactional
-	if err := a.repository.UpdatesTx(from, to); err != nil {
-		return err
+	transfer.Status = model.TransferCompleted
+	entries := []*model.LedgerEntry{
+		a.entryWithBalance(from, model.EntryTransferOut, -amount, from.Amount+fee, "transfer to "+to.ID.String()),
+		a.entry(to, model.EntryTransferIn, amount, "transfer from "+from.ID.String()),
+	}
+	if fee > 0 {
+		if aErr := revenue.AddMoney(fee); aErr != nil {
+			return nil, nil, aErr
+		}
+		balance := from.Amount + fee
+		for _, f := range fees {
+			balance -= f.Amount
+			entries = append(entries, a.entryWithBalance(from, model.EntryFee, -f.Amount, balance, f.Name))
+		}
+		entries = append(entries, a.entry(revenue, model.EntryFee, fee, "transfer fees from "+from.ID.String()))
 	}
 
-	return nil
+	return fees, entries, nil
+}
+
+// checkLimits fails with model.ErrLimitExceeded when moving amount out of acc
+// goes over any limit, counting what was already used in set. It returns the
+// usage of acc, and of its customer, in every window for useLimits, loaded
+// before any money moves so nothing can fail once it does.
+func (a *accountService) checkLimits(set *accountSet, acc *model.Account, op model.LimitOperation, amount float64) ([]*model.LimitUsage, error) {
+	if len(a.limits) == 0 {
+		return nil, nil
+	}
+	subjects := []string{model.AccountSubject(acc)}
+	if acc.CustomerID != "" {
+		subjects = append(subjects, model.CustomerSubject(acc.CustomerID))
+	}
+	now := a.clock()
+	var usages []*model.LimitUsage
+	for _, subject := range subjects {
+		for _, window := range model.Windows {
+			usage, err := set.usage(subject, op, window.Period(now))
+			if err != nil {
+				return nil, err
+			}
+			usages = append(usages, usage)
+		}
+	}
+	if err := a.limits.Check(acc, op, amount, now, set.usage); err != nil {
+		return nil, err
+	}
+	return usages, nil
+}
+
+// useLimits adds amount to usages returned by checkLimits. Usage is only
+// tracked while there are limits.
+func (a *accountService) useLimits(set *accountSet, usages []*model.LimitUsage, amount float64) {
+	for _, usage := range usages {
+		usage.Amount += amount
+		usage.Count++
+		set.used[usage] = true
+	}
+}
+
+// transferFees returns the fees charged to from for sending amount
+func (a *accountService) transferFees(from *model.Account, amount float64) []model.Fee {
+	// the bank does not charge itself
+	if from.ID == a.revenueAccountID {
+		return nil
+	}
+	return a.fees.Fees(model.FeeOnTransfer, from.Product, amount)
+}
+
+// fail keeps a transfer that failed as it was made, audited as action. It is
+// best effort, the error that made the transfer fail is what the caller needs
+// to know about.
+func (a *accountService) fail(ctx context.Context, action model.AuditAction, transfer *model.Transfer) {
+	change := repositories.Change{
+		NewTransfers: []*model.Transfer{transfer},
+		Audit: []*model.AuditRecord{
+			newAuditRecord(ctx, a.clock, action, AuditEntityTransfer, transfer.ID, nil, &auditState{Transfers: transferStates(transfer)}),
+		},
+		Events: []*model.Event{transferEvent(a.clock, transfer)},
+	}
+	if sErr := a.commit(ctx, change); sErr != nil {
+		log.Printf("failed transfer %s could not be stored: %v", transfer.ID, sErr)
+	}
+}
+
+func (a *accountService) GetTransfer(ctx context.Context, transferID uuid.UUID) (dto.TransferResponse, error) {
+	transfer, err := a.repository.GetTransfer(ctx, transferID)
+	if err != nil {
+		return dto.TransferResponse{}, err
+	}
+	return toTransferResponse(transfer), nil
+}
+
+func (a *accountService) Transfers(ctx context.Context, accountID uuid.UUID) (dto.GetTransfersResponse, error) {
+	if _, gErr := a.repository.Get(ctx, accountID); gErr != nil {
+		return dto.GetTransfersResponse{}, gErr
+	}
+
+	transfers, err := a.repository.Transfers(ctx, accountID)
+	if err != nil {
+		return dto.GetTransfersResponse{}, err
+	}
+
+	resp := make([]dto.TransferResponse, len(transfers))
+	for i := range transfers {
+		resp[i] = toTransferResponse(transfers[i])
+	}
+
+	return dto.GetTransfersResponse{Transfers: resp}, nil
+}
+
+func (a *accountService) Reverse(ctx context.Context, transferID uuid.UUID, req dto.ReversalRequest) (dto.TransferResponse, error) {
+	// the accounts of a transfer never change, they are read before locking
+	// them. Everything else is read again once locked.
+	locked, lgErr := a.repository.GetTransfer(ctx, transferID)
+	if lgErr != nil {
+		return dto.TransferResponse{}, lgErr
+	}
+
+	// used for pessimistic locking
+	release, lErr := a.acquire(ctx, locked.From, locked.To)
+	if lErr != nil {
+		return dto.TransferResponse{}, lErr
+	}
+	defer release()
+
+	original, gErr := a.repository.GetTransfer(ctx, transferID)
+	if gErr != nil {
+		return dto.TransferResponse{}, gErr
+	}
+	originalBefore := *original
+	// reversals are final and split transfers have no single sender
+	if original.ReversalOf != nil || len(original.Legs) > 0 {
+		return dto.TransferResponse{}, ErrNotReversible
+	}
+
+	amount := req.Amount
+	if amount == 0 {
+		amount = original.Reversible()
+	}
+	if rErr := original.Reverse(amount); rErr != nil {
+		return dto.TransferResponse{}, rErr
+	}
+
+	// money goes back the other way, fees are not refunded
+	from, fErr := a.repository.Get(ctx, original.To)
+	if fErr != nil {
+		return dto.TransferResponse{}, fErr
+	}
+	to, tErr := a.repository.Get(ctx, original.From)
+	if tErr != nil {
+		return dto.TransferResponse{}, tErr
+	}
+	before := &auditState{Accounts: accountStates([]*model.Account{from, to})}
+	now := a.clock()
+	reversal := &model.Transfer{
+		ID:         uuid.New(),
+		From:       from.ID,
+		To:         to.ID,
+		Amount:     amount,
+		ReversalOf: &original.ID,
+		Status:     model.TransferPending,
+		Memo:       req.Memo,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if wErr := from.Withdraw(amount); wErr != nil {
+		reversal.Status = model.TransferFailed
+		reversal.FailureReason = wErr.Error()
+		a.fail(ctx, model.AuditReversal, reversal)
+		return dto.TransferResponse{}, wErr
+	}
+	if aErr := to.AddMoney(amount); aErr != nil {
+		return dto.TransferResponse{}, aErr
+	}
+
+	reversal.Status = model.TransferCompleted
+	original.UpdatedAt = now
+	description := "reversal of transfer " + original.ID.String()
+	change := repositories.Change{
+		Accounts: []*model.Account{from, to},
+		Entries: []*model.LedgerEntry{
+			a.entry(from, model.EntryReversal, -amount, description),
+			a.entry(to, model.EntryReversal, amount, description),
+		},
+		Transfers: []*model.Transfer{original, reversal},
+	}
+	before.Transfers = []model.Transfer{originalBefore}
+	after := &auditState{Accounts: accountStates([]*model.Account{from, to}), Transfers: transferStates(original, reversal)}
+	change.Audit = []*model.AuditRecord{
+		newAuditRecord(ctx, a.clock, model.AuditReversal, AuditEntityTransfer, reversal.ID, before, after),
+	}
+	change.Events = []*model.Event{transferEvent(a.clock, reversal)}
+	if err := a.commit(ctx, change); err != nil {
+		return dto.TransferResponse{}, err
+	}
+
+	return toTransferResponse(reversal), nil
+}
+
+func (a *accountService) Transactions(ctx context.Context, accountID uuid.UUID) (dto.GetTransactionsResponse, error) {
+	if _, gErr := a.repository.Get(ctx, accountID); gErr != nil {
+		return dto.GetTransactionsResponse{}, gErr
+	}
+
+	entries, err := a.repository.Entries(ctx, accountID)
+	if err != nil {
+		return dto.GetTransactionsResponse{}, err
+	}
+
+	resp := make([]dto.TransactionResponse, len(entries))
+	for i, entry := range entries {
+		resp[i] = toTransactionResponse(entry)
+	}
+
+	return dto.GetTransactionsResponse{Transactions: resp}, nil
+}
+
+func toTransactionResponse(entry *model.LedgerEntry) dto.TransactionResponse {
+	return dto.TransactionResponse{
+		ID:          entry.ID,
+		Type:        string(entry.Type),
+		Amount:      entry.Amount,
+		Balance:     entry.Balance,
+		Description: entry.Description,
+		CreatedAt:   entry.CreatedAt,
+	}
+}
+
+func (a *accountService) Apply(ctx context.Context, action model.AuditAction, accountIDs []uuid.UUID, change func(accounts []*model.Account) (repositories.Change, error)) error {
+	// used for pessimistic locking
+	release, lErr := a.acquire(ctx, accountIDs...)
+	if lErr != nil {
+		return lErr
+	}
+	defer release()
+
+	accounts := make([]*model.Account, len(accountIDs))
+	for i, accountID := range accountIDs {
+		acc, gErr := a.repository.Get(ctx, accountID)
+		if gErr != nil {
+			return gErr
+		}
+		accounts[i] = acc
+	}
+
+	before := &auditState{Accounts: accountStates(accounts)}
+	ch, cErr := change(accounts)
+	if cErr != nil {
+		return cErr
+	}
+
+	// the change is about its hold when it has one, about the first account
+	// otherwise
+	entityType, entityID := AuditEntityAccount, uuid.Nil
+	if len(accountIDs) > 0 {
+		entityID = accountIDs[0]
+	}
+	if len(ch.Holds) == 1 {
+		entityType, entityID = AuditEntityHold, ch.Holds[0].ID
+	}
+	after := &auditState{Accounts: accountStates(accounts), Holds: make([]model.Hold, len(ch.Holds))}
+	for i, hold := range ch.Holds {
+		after.Holds[i] = *hold
+	}
+	// jobs run on every account, those it leaves as they were are not audited
+	if len(ch.Holds) > 0 || auditJSON(before) != auditJSON(after) {
+		ch.Audit = append(ch.Audit, newAuditRecord(ctx, a.clock, action, entityType, entityID, before, after))
+	}
+	ch.Events = append(ch.Events, changeEvents(a.clock, ch)...)
+
+	return a.commit(ctx, ch)
+}
+
+func (a *accountService) Pay(ctx context.Context, action model.AuditAction, transfer *model.Transfer, prepare func(from *model.Account) (repositories.Change, error)) (dto.TransferResponse, error) {
+	// used for pessimistic locking
+	release, lErr := a.acquire(ctx, a.charged(ctx, []uuid.UUID{transfer.From, transfer.To}, debit{transfer.From, transfer.Amount})...)
+	if lErr != nil {
+		return dto.TransferResponse{}, lErr
+	}
+	defer release()
+
+	accounts := newAccountSet(ctx, a.repository)
+	from, gErr := accounts.get(transfer.From)
+	if gErr != nil {
+		return dto.TransferResponse{}, gErr
+	}
+	change, pErr := prepare(from)
+	if pErr != nil {
+		return dto.TransferResponse{}, pErr
+	}
+	if sErr := a.screen(ctx, transfer); sErr != nil {
+		return dto.TransferResponse{}, sErr
+	}
+	fees, entries, err := a.execute(accounts, transfer)
+	if err != nil {
+		return dto.TransferResponse{}, err
+	}
+
+	// the change is about its hold when it has one, as with Apply
+	entityType, entityID := AuditEntityTransfer, transfer.ID
+	if len(change.Holds) == 1 {
+		entityType, entityID = AuditEntityHold, change.Holds[0].ID
+	}
+	before, after := accounts.states()
+	after.Transfers = transferStates(transfer)
+	for _, hold := range change.Holds {
+		after.Holds = append(after.Holds, *hold)
+	}
+	change.Accounts = accounts.all()
+	change.Entries = append(change.Entries, entries...)
+	change.NewTransfers = append(change.NewTransfers, transfer)
+	change.Usage = accounts.usages()
+	change.Audit = append(change.Audit, newAuditRecord(ctx, a.clock, action, entityType, entityID, before, after))
+	change.Events = append(changeEvents(a.clock, repositories.Change{Holds: change.Holds}), transferEvent(a.clock, transfer))
+	if aErr := a.commit(ctx, change); aErr != nil {
+		return dto.TransferResponse{}, aErr
+	}
+
+	resp := toTransferResponse(transfer)
+	resp.Fees = toFeeResponses(fees)
+	return resp, nil
+}
+
+// accountSet loads every account, and limit usage, once so movements chained
+// on the same account see each other
+type accountSet struct {
+	ctx        context.Context
+	repository repositories.AccountRepository
+	loaded     map[uuid.UUID]*model.Account
+	order      []*model.Account
+	// before keeps every account as it was loaded, for the audit
+	before     []model.Account
+	usageByKey map[string]*model.LimitUsage
+	used       map[*model.LimitUsage]bool
+}
+
+func newAccountSet(ctx context.Context, repository repositories.AccountRepository) *accountSet {
+	return &accountSet{
+		ctx:        ctx,
+		repository: repository,
+		loaded:     make(map[uuid.UUID]*model.Account),
+		usageByKey: make(map[string]*model.LimitUsage),
+		used:       make(map[*model.LimitUsage]bool),
+	}
+}
+
+func (s *accountSet) get(accountID uuid.UUID) (*model.Account, error) {
+	if acc, ok := s.loaded[accountID]; ok {
+		return acc, nil
+	}
+	acc, err := s.repository.Get(s.ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	s.loaded[accountID] = acc
+	s.order = append(s.order, acc)
+	s.before = append(s.before, *acc)
+	return acc, nil
+}
+
+// states returns every loaded account as it was loaded and as it is now
+func (s *accountSet) states() (*auditState, *auditState) {
+	return &auditState{Accounts: append([]model.Account(nil), s.before...)}, &auditState{Accounts: accountStates(s.order)}
+}
+
+// all returns every loaded account in loading order
+func (s *accountSet) all() []*model.Account {
+	return s.order
+}
+
+func (s *accountSet) usage(subject string, op model.LimitOperation, period string) (*model.LimitUsage, error) {
+	key := subject + "|" + string(op) + "|" + period
+	if usage, ok := s.usageByKey[key]; ok {
+		return usage, nil
+	}
+	usage, err := s.repository.Usage(s.ctx, subject, op, period)
+	if err != nil {
+		return nil, err
+	}
+	s.usageByKey[key] = usage
+	return usage, nil
+}
+
+// usages returns the usage changed in the set
+func (s *accountSet) usages() []*model.LimitUsage {
+	usages := make([]*model.LimitUsage, 0, len(s.used))
+	for usage := range s.used {
+		usages = append(usages, usage)
+	}
+	return usages
+}
+
+// entry builds a ledger entry for a movement already applied to acc
+func (a *accountService) entry(acc *model.Account, entryType model.EntryType, amount float64, description string) *model.LedgerEntry {
+	return a.entryWithBalance(acc, entryType, amount, acc.Amount, description)
+}
+
+func (a *accountService) entryWithBalance(acc *model.Account, entryType model.EntryType, amount float64, balance float64, description string) *model.LedgerEntry {
+	return &model.LedgerEntry{
+		ID:          uuid.New(),
+		AccountID:   acc.ID,
+		Type:        entryType,
+		Amount:      amount,
+		Balance:     balance,
+		Description: description,
+		CreatedAt:   a.clock(),
+	}
+}
+
+func toAccountResponse(account *model.Account) dto.GetAccountResponse {
+	return dto.GetAccountResponse{
+		ID:              account.ID,
+		Name:            account.Name,
+		CustomerID:      account.CustomerID,
+		Amount:          account.Amount,
+		Held:            account.Held,
+		Available:       account.Available(),
+		Product:         account.Product,
+		AccruedInterest: account.AccruedInterest,
+	}
+}
+
+func toTransferResponse(transfer *model.Transfer) dto.TransferResponse {
+	return dto.TransferResponse{
+		ID:              transfer.ID,
+		From:            transfer.From,
+		To:              transfer.To,
+		Amount:          transfer.Amount,
+		Fee:             transfer.Fee,
+		Fees:            []dto.FeeResponse{},
+		ReversalOf:      transfer.ReversalOf,
+		Reversed:        transfer.Reversed,
+		Status:          string(transfer.Status),
+		FailureReason:   transfer.FailureReason,
+		ScreeningReason: transfer.ScreeningReason,
+		Memo:            transfer.Memo,
+		CreatedAt:       transfer.CreatedAt,
+		UpdatedAt:       transfer.UpdatedAt,
+		Legs:            toLegResponses(transfer.Legs),
+	}
+}
+
+func toLegResponses(legs []model.TransferLeg) []dto.TransferLegResponse {
+	if len(legs) == 0 {
+		return nil
+	}
+	resp := make([]dto.TransferLegResponse, len(legs))
+	for i, leg := range legs {
+		resp[i] = dto.TransferLegResponse{AccountID: leg.AccountID, Amount: leg.Amount}
+	}
+	return resp
+}
+
+func toFeeResponses(fees []model.Fee) []dto.FeeResponse {
+	resp := make([]dto.FeeResponse, len(fees))
+	for i, fee := range fees {
+		resp[i] = dto.FeeResponse{Name: fee.Name, Amount: fee.Amount}
+	}
+	return resp
 }
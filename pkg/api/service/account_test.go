@@ -2,27 +2,28 @@ package service_test
 
 import (
 	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
 	"bank/pkg/api/repositories"
 	"bank/pkg/api/service"
+	"context"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"gorm.io/driver/mysql"
-	"gorm.io/gorm"
+	"os"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestAccountService_Create(t *testing.T) {
-	db := setup(t)
+	dbRepo := setup(t)
 
 	t.Run("Given a create service", func(t *testing.T) {
-		dbRepo := repositories.NewDBRepository(db)
 		accService := service.NewAccountService(dbRepo)
 
 		t.Run("When request to create account", func(t *testing.T) {
 			req := dto.CreateAccountRequest{Name: "bill smith", Amount: 100.00}
-			resp, err := accService.Create(req)
+			resp, err := accService.Create(context.Background(), req)
 			require.NoError(t, err)
 
 			t.Run("Then creates that new account", func(t *testing.T) {
@@ -35,14 +36,13 @@ func TestAccountService_Create(t *testing.T) {
 }
 
 func TestAccountService_Update_Concurrent(t *testing.T) {
-	db := setup(t)
+	dbRepo := setup(t)
 
 	t.Run("Given an existing account", func(t *testing.T) {
-		accEnt := repositories.AccountEntity{ID: uuid.New(), Name: "billy", Amount: 0.00}
-		require.NoError(t, db.Create(&accEnt).Error)
+		accEnt := model.Account{ID: uuid.New(), Name: "billy", Amount: 0.00}
+		require.NoError(t, dbRepo.Create(context.Background(), &accEnt))
 
 		t.Run("And an account service", func(t *testing.T) {
-			dbRepo := repositories.NewDBRepository(db)
 			accService := service.NewAccountService(dbRepo)
 
 			t.Run("When adding money multiple times at the same moment", func(t *testing.T) {
@@ -53,7 +53,7 @@ func TestAccountService_Update_Concurrent(t *testing.T) {
 					go func(ind int) {
 						defer wg.Done()
 						<-signal
-						_, err := accService.AddMoney(accEnt.ID, 100.00)
+						_, err := accService.AddMoney(context.Background(), accEnt.ID, 100.00)
 						require.NoError(t, err)
 					}(i)
 				}
@@ -61,8 +61,8 @@ func TestAccountService_Update_Concurrent(t *testing.T) {
 				wg.Wait()
 
 				t.Run("Then result should be consistent", func(t *testing.T) {
-					var currentAccEnt repositories.AccountEntity
-					require.NoError(t, db.Find(&currentAccEnt, accEnt.ID).Error)
+					currentAccEnt, err := dbRepo.Get(context.Background(), accEnt.ID)
+					require.NoError(t, err)
 					assert.Equal(t, currentAccEnt.Amount, 1000.00)
 				})
 			})
@@ -71,20 +71,19 @@ func TestAccountService_Update_Concurrent(t *testing.T) {
 }
 
 func TestAccountService_Transfer(t *testing.T) {
-	db := setup(t)
+	dbRepo := setup(t)
 	t.Run("Given two existing accounts", func(t *testing.T) {
-		from := repositories.AccountEntity{ID: uuid.New(), Name: "billy", Amount: 100.00}
-		require.NoError(t, db.Create(&from).Error)
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, dbRepo.Create(context.Background(), &from))
 
-		to := repositories.AccountEntity{ID: uuid.New(), Name: "jhon", Amount: 100.00}
-		require.NoError(t, db.Create(&to).Error)
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 100.00}
+		require.NoError(t, dbRepo.Create(context.Background(), &to))
 
 		t.Run("And an account service", func(t *testing.T) {
-			dbRepo := repositories.NewDBRepository(db)
 			accService := service.NewAccountService(dbRepo)
 
 			t.Run("When requesting to transfer money with no enough balance", func(t *testing.T) {
-				err := accService.Transfer(from.ID, to.ID, 200.00)
+				_, err := accService.Transfer(context.Background(), from.ID, to.ID, 200.00, "")
 
 				t.Run("Then fails", func(t *testing.T) {
 					assert.Error(t, err)
@@ -92,15 +91,14 @@ func TestAccountService_Transfer(t *testing.T) {
 			})
 			t.Run("When requesting to transfer money with enough balance", func(t *testing.T) {
 				transferAmount := 100.00
-				err := accService.Transfer(from.ID, to.ID, transferAmount)
+				_, err := accService.Transfer(context.Background(), from.ID, to.ID, transferAmount, "")
 				require.NoError(t, err)
 
 				t.Run("Then success", func(t *testing.T) {
-					var fromCurrent repositories.AccountEntity
-					var toCurrent repositories.AccountEntity
-
-					require.NoError(t, db.Find(&fromCurrent, from.ID).Error)
-					require.NoError(t, db.Find(&toCurrent, to.ID).Error)
+					fromCurrent, fErr := dbRepo.Get(context.Background(), from.ID)
+					require.NoError(t, fErr)
+					toCurrent, tErr := dbRepo.Get(context.Background(), to.ID)
+					require.NoError(t, tErr)
 
 					assert.Equal(t, fromCurrent.Amount, from.Amount-transferAmount)
 					assert.Equal(t, toCurrent.Amount, to.Amount+transferAmount)
@@ -110,10 +108,24 @@ func TestAccountService_Transfer(t *testing.T) {
 	})
 }
 
-func setup(t *testing.T) *gorm.DB {
-	dsn := "test:test@tcp(localhost:3306)/bank"
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// setup returns the in-memory repository unless TEST_DB_DRIVER selects a
+// database (e.g. sqlite, which needs no external service)
+func setup(t *testing.T) repositories.AccountRepository {
+	repo := repositories.NewMemoryRepository()
+	if driver := os.Getenv("TEST_DB_DRIVER"); driver != "" {
+		db, err := repositories.OpenDB(driver, os.Getenv("TEST_DB_DSN"))
+		require.NoError(t, err)
+		repo = repositories.NewDBRepository(db)
+	}
+	if os.Getenv("TEST_EVENT_SOURCED") != "" {
+		return repositories.NewEventSourcedRepository(repo, 10, time.Now)
+	}
+	return repo
+}
+
+func assertBalance(t *testing.T, repo repositories.AccountRepository, accountID uuid.UUID, amount float64) {
+	t.Helper()
+	acc, err := repo.Get(context.Background(), accountID)
 	require.NoError(t, err)
-	require.NoError(t, db.AutoMigrate(&repositories.AccountEntity{}))
-	return db
+	assert.InDelta(t, amount, acc.Amount, 1e-9)
 }
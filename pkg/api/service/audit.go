@@ -0,0 +1,164 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"log"
+)
+
+const (
+	AuditEntityAccount       = "account"
+	AuditEntityTransfer      = "transfer"
+	AuditEntityBatch         = "batch"
+	AuditEntityHold          = "hold"
+	AuditEntityScheduled     = "scheduled_transfer"
+	AuditEntityStandingOrder = "standing_order"
+)
+
+type actorKey struct{}
+
+// WithActor tells the services who asked for the operations run with ctx,
+// they are audited as done by the system otherwise
+func WithActor(ctx context.Context, actor model.Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+func actorFrom(ctx context.Context) model.Actor {
+	if actor, ok := ctx.Value(actorKey{}).(model.Actor); ok {
+		return actor
+	}
+	return model.Actor{ID: model.SystemActor}
+}
+
+// auditState is what the audit trail keeps of the entities an operation
+// changed, before or after it
+type auditState struct {
+	Accounts      []model.Account          `json:",omitempty"`
+	Transfers     []model.Transfer         `json:",omitempty"`
+	Holds         []model.Hold             `json:",omitempty"`
+	Scheduled     *model.ScheduledTransfer `json:",omitempty"`
+	StandingOrder *model.StandingOrder     `json:",omitempty"`
+}
+
+// newAuditRecord builds the record of action on an entity done by the actor
+// of ctx. A nil before means the entity was created.
+func newAuditRecord(ctx context.Context, clock Clock, action model.AuditAction, entityType string, entityID uuid.UUID, before *auditState, after *auditState) *model.AuditRecord {
+	actor := actorFrom(ctx)
+	return &model.AuditRecord{
+		ID:         uuid.New(),
+		Action:     action,
+		Actor:      actor.ID,
+		RequestID:  actor.RequestID,
+		SourceIP:   actor.SourceIP,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Before:     auditJSON(before),
+		After:      auditJSON(after),
+		CreatedAt:  clock(),
+	}
+}
+
+func auditJSON(state *auditState) string {
+	if state == nil {
+		return ""
+	}
+	// every field of the state marshals, it can not fail
+	content, _ := json.Marshal(state)
+	return string(content)
+}
+
+// accountStates copies accounts so later changes do not reach the audit
+func accountStates(accounts []*model.Account) []model.Account {
+	states := make([]model.Account, len(accounts))
+	for i, acc := range accounts {
+		states[i] = *acc
+	}
+	return states
+}
+
+func transferStates(transfers ...*model.Transfer) []model.Transfer {
+	states := make([]model.Transfer, len(transfers))
+	for i, transfer := range transfers {
+		states[i] = *transfer
+	}
+	return states
+}
+
+// audit appends a record that is not part of any balance change. It is best
+// effort, the operation already happened.
+func audit(ctx context.Context, repository repositories.AccountRepository, record *model.AuditRecord) {
+	if err := repository.Apply(ctx, repositories.Change{Audit: []*model.AuditRecord{record}}); err != nil {
+		log.Printf("audit record %s of %s could not be stored: %v", record.Action, record.EntityID, err)
+	}
+}
+
+type AuditService interface {
+	// Trail returns the audit records matching filter by sequence
+	Trail(ctx context.Context, filter repositories.AuditFilter) (dto.GetAuditRecordsResponse, error)
+	// Verify checks the whole trail was not tampered with
+	Verify(ctx context.Context) (dto.AuditVerificationResponse, error)
+}
+
+func NewAuditService(repository repositories.AccountRepository) AuditService {
+	return &auditService{
+		repository: repository,
+	}
+}
+
+type auditService struct {
+	repository repositories.AccountRepository
+}
+
+func (s *auditService) Trail(ctx context.Context, filter repositories.AuditFilter) (dto.GetAuditRecordsResponse, error) {
+	records, err := s.repository.AuditTrail(ctx, filter)
+	if err != nil {
+		return dto.GetAuditRecordsResponse{}, err
+	}
+
+	resp := make([]dto.AuditRecordResponse, len(records))
+	for i, record := range records {
+		resp[i] = dto.AuditRecordResponse{
+			Sequence:   record.Sequence,
+			ID:         record.ID,
+			Action:     string(record.Action),
+			Actor:      record.Actor,
+			RequestID:  record.RequestID,
+			SourceIP:   record.SourceIP,
+			EntityType: record.EntityType,
+			EntityID:   record.EntityID,
+			Before:     rawJSON(record.Before),
+			After:      rawJSON(record.After),
+			CreatedAt:  record.CreatedAt,
+			PrevHash:   record.PrevHash,
+			Hash:       record.Hash,
+		}
+	}
+
+	return dto.GetAuditRecordsResponse{Records: resp}, nil
+}
+
+func (s *auditService) Verify(ctx context.Context) (dto.AuditVerificationResponse, error) {
+	records, err := s.repository.AuditTrail(ctx, repositories.AuditFilter{})
+	if err != nil {
+		return dto.AuditVerificationResponse{}, err
+	}
+
+	verification := model.VerifyAuditChain(records)
+	return dto.AuditVerificationResponse{
+		Valid:    verification.Valid,
+		Records:  verification.Records,
+		BrokenAt: verification.BrokenAt,
+		Reason:   verification.Reason,
+	}, nil
+}
+
+func rawJSON(content string) json.RawMessage {
+	if content == "" {
+		return nil
+	}
+	return json.RawMessage(content)
+}
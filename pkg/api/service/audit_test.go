@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestAuditService(t *testing.T) {
+	repo := setup(t)
+	clock := func() time.Time { return time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC) }
+	accService := service.NewAccountService(repo, service.WithClock(clock))
+	auditService := service.NewAuditService(repo)
+
+	t.Run("Given operations made by a known actor", func(t *testing.T) {
+		ctx := service.WithActor(context.Background(), model.Actor{ID: "teller-7", RequestID: "req-1", SourceIP: "10.0.0.7"})
+		from, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "bill smith", Amount: 100.00})
+		require.NoError(t, err)
+		to, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "jane smith", Amount: 0.00})
+		require.NoError(t, err)
+		_, err = accService.AddMoney(ctx, from.ID, 50.00)
+		require.NoError(t, err)
+		transfer, err := accService.Transfer(ctx, from.ID, to.ID, 30.00, "rent")
+		require.NoError(t, err)
+
+		t.Run("Then the creation is audited with the actor and no before", func(t *testing.T) {
+			trail, lErr := auditService.Trail(ctx, repositories.AuditFilter{EntityID: from.ID, Action: model.AuditAccountCreated})
+			require.NoError(t, lErr)
+			require.Len(t, trail.Records, 1)
+			record := trail.Records[0]
+			assert.Equal(t, "teller-7", record.Actor)
+			assert.Equal(t, "req-1", record.RequestID)
+			assert.Equal(t, "10.0.0.7", record.SourceIP)
+			assert.Empty(t, record.Before)
+			assert.NotEmpty(t, record.After)
+		})
+
+		t.Run("Then the deposit keeps the balance before and after", func(t *testing.T) {
+			trail, lErr := auditService.Trail(ctx, repositories.AuditFilter{EntityID: from.ID, Action: model.AuditDeposit})
+			require.NoError(t, lErr)
+			require.Len(t, trail.Records, 1)
+			assert.Equal(t, 100.00, auditedBalance(t, trail.Records[0].Before))
+			assert.Equal(t, 150.00, auditedBalance(t, trail.Records[0].After))
+		})
+
+		t.Run("Then the transfer is audited under its id", func(t *testing.T) {
+			trail, lErr := auditService.Trail(ctx, repositories.AuditFilter{EntityID: transfer.ID})
+			require.NoError(t, lErr)
+			require.Len(t, trail.Records, 1)
+			assert.Equal(t, string(model.AuditTransfer), trail.Records[0].Action)
+			assert.Equal(t, 150.00, auditedBalance(t, trail.Records[0].Before))
+			assert.Equal(t, 120.00, auditedBalance(t, trail.Records[0].After))
+		})
+
+		t.Run("Then the trail verifies", func(t *testing.T) {
+			verification, vErr := auditService.Verify(ctx)
+			require.NoError(t, vErr)
+			assert.True(t, verification.Valid)
+			assert.GreaterOrEqual(t, verification.Records, 4)
+		})
+	})
+
+	t.Run("Given a hold released without an actor", func(t *testing.T) {
+		ctx := context.Background()
+		from, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "bill smith", Amount: 100.00})
+		require.NoError(t, err)
+		to, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "jane smith", Amount: 0.00})
+		require.NoError(t, err)
+		holdService := service.NewHoldService(repo, accService, clock)
+		hold, err := holdService.Place(ctx, from.ID, dto.PlaceHoldRequest{To: to.ID, Amount: 10.00})
+		require.NoError(t, err)
+		_, err = holdService.Release(ctx, hold.ID)
+		require.NoError(t, err)
+
+		t.Run("Then it is audited as done by the system", func(t *testing.T) {
+			trail, lErr := auditService.Trail(ctx, repositories.AuditFilter{EntityID: hold.ID})
+			require.NoError(t, lErr)
+			require.Len(t, trail.Records, 2)
+			assert.Equal(t, string(model.AuditHoldPlaced), trail.Records[0].Action)
+			assert.Equal(t, string(model.AuditHoldReleased), trail.Records[1].Action)
+			assert.Equal(t, model.SystemActor, trail.Records[1].Actor)
+		})
+	})
+}
+
+// auditedBalance returns the balance of the first account of an audited state
+func auditedBalance(t *testing.T, state json.RawMessage) float64 {
+	t.Helper()
+	var audited struct {
+		Accounts []model.Account
+	}
+	require.NoError(t, json.Unmarshal(state, &audited))
+	require.NotEmpty(t, audited.Accounts)
+	return audited.Accounts[0].Amount
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+var (
+	ErrBatchRejected = errors.New("batch rejected, no transfer was executed")
+	// errNotExecuted is reported for the transfers of a rejected atomic batch
+	// other than the one that made it fail
+	errNotExecuted = errors.New("not executed, the batch was rejected")
+)
+
+func (a *accountService) Batch(ctx context.Context, req dto.BatchTransferRequest) (dto.BatchTransferResponse, error) {
+	mode := req.Mode
+	if mode == "" {
+		mode = BatchAtomic
+	}
+	if mode != BatchAtomic && mode != BatchBestEffort {
+		return dto.BatchTransferResponse{}, ErrInconsistentData
+	}
+
+	// used for pessimistic locking, once for the whole batch
+	accountIDs := make([]uuid.UUID, 0, 2*len(req.Transfers))
+	debits := make([]debit, len(req.Transfers))
+	for i, leg := range req.Transfers {
+		accountIDs = append(accountIDs, leg.From, leg.To)
+		debits[i] = debit{leg.From, leg.Amount}
+	}
+	release, lErr := a.acquire(ctx, a.charged(ctx, accountIDs, debits...)...)
+	if lErr != nil {
+		return dto.BatchTransferResponse{}, lErr
+	}
+	defer release()
+
+	// every account is read once, so a source shared by many transfers sees
+	// the balance left by the previous ones
+	accounts := newAccountSet(ctx, a.repository)
+	change := repositories.Change{}
+	resp := dto.BatchTransferResponse{Mode: mode, Results: make([]dto.BatchTransferResult, len(req.Transfers))}
+
+	for i, leg := range req.Transfers {
+		resp.Results[i].Index = i
+		transfer, fees, entries, err := a.move(accounts, leg.From, leg.To, leg.Amount, leg.Memo)
+		if err != nil && !failsTransfer(transfer, err) {
+			// not about the transfer, such as the repository failing, the
+			// accounts read may not be trusted anymore
+			return dto.BatchTransferResponse{}, err
+		}
+		if err != nil {
+			if mode == BatchAtomic {
+				return rejectBatch(resp, i, err), ErrBatchRejected
+			}
+			if errors.Is(err, ErrHeldForReview) {
+				change.Transfers = append(change.Transfers, transfer)
+				transferResp := toTransferResponse(transfer)
+				resp.Results[i].Transfer = &transferResp
+				resp.InReview++
+				continue
+			}
+			if transfer != nil {
+				change.Transfers = append(change.Transfers, transfer)
+			}
+			resp.Results[i].Error = err.Error()
+			resp.Failed++
+			continue
+		}
+
+		change.Entries = append(change.Entries, entries...)
+		change.Transfers = append(change.Transfers, transfer)
+		transferResp := toTransferResponse(transfer)
+		transferResp.Fees = toFeeResponses(fees)
+		resp.Results[i].Transfer = &transferResp
+		resp.Succeeded++
+	}
+
+	change.Accounts = accounts.all()
+	change.Usage = accounts.usages()
+	before, after := accounts.states()
+	after.Transfers = transferStates(change.Transfers...)
+	change.Audit = []*model.AuditRecord{
+		newAuditRecord(ctx, a.clock, model.AuditBatchTransfer, AuditEntityBatch, uuid.New(), before, after),
+	}
+	for _, transfer := range change.Transfers {
+		change.Events = append(change.Events, transferEvent(a.clock, transfer))
+	}
+	// a single transaction for the whole batch, even in best effort mode the
+	// outcome of every transfer is already decided
+	if err := a.commit(ctx, change); err != nil {
+		return dto.BatchTransferResponse{}, err
+	}
+
+	return resp, nil
+}
+
+// failsTransfer tells whether err, returned by move along with transfer, is a
+// reason for the transfer to fail rather than a failure of the bank
+func failsTransfer(transfer *model.Transfer, err error) bool {
+	return transfer != nil ||
+		errors.Is(err, ErrInconsistentData) ||
+		errors.Is(err, repositories.ErrAccountNotFound)
+}
+
+// rejectBatch reports err for the transfer at failed and every other one as
+// not executed
+func rejectBatch(resp dto.BatchTransferResponse, failed int, err error) dto.BatchTransferResponse {
+	for i := range resp.Results {
+		resp.Results[i].Index = i
+		resp.Results[i].Transfer = nil
+		resp.Results[i].Error = errNotExecuted.Error()
+	}
+	resp.Results[failed].Error = err.Error()
+	resp.Succeeded = 0
+	resp.Failed = len(resp.Results)
+	return resp
+}
@@ -0,0 +1,154 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAccountService_Batch(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+
+	t.Run("Given a payroll account and three employees", func(t *testing.T) {
+		payroll := model.Account{ID: uuid.New(), Name: "acme payroll", Amount: 100.00}
+		require.NoError(t, repo.Create(ctx, &payroll))
+		employees := make([]uuid.UUID, 3)
+		for i := range employees {
+			employee := model.Account{ID: uuid.New(), Name: "employee", Amount: 0.00}
+			require.NoError(t, repo.Create(ctx, &employee))
+			employees[i] = employee.ID
+		}
+		accService := service.NewAccountService(repo)
+
+		legs := func(amounts ...float64) []dto.BatchTransferLeg {
+			result := make([]dto.BatchTransferLeg, len(amounts))
+			for i, amount := range amounts {
+				result[i] = dto.BatchTransferLeg{From: payroll.ID, To: employees[i], Amount: amount, Memo: "salary"}
+			}
+			return result
+		}
+
+		t.Run("When an atomic batch goes over the balance on its last transfer", func(t *testing.T) {
+			resp, err := accService.Batch(ctx, dto.BatchTransferRequest{Transfers: legs(40.00, 40.00, 40.00)})
+
+			t.Run("Then it is rejected telling which transfer failed", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrBatchRejected)
+				require.Len(t, resp.Results, 3)
+				assert.Equal(t, "not enough balance", resp.Results[2].Error)
+				assert.Nil(t, resp.Results[0].Transfer)
+			})
+
+			t.Run("Then nothing is stored", func(t *testing.T) {
+				assertBalance(t, repo, payroll.ID, 100.00)
+				assertBalance(t, repo, employees[0], 0.00)
+				transfers, lErr := accService.Transfers(ctx, payroll.ID)
+				require.NoError(t, lErr)
+				assert.Empty(t, transfers.Transfers)
+			})
+		})
+
+		t.Run("When an atomic batch fits the balance", func(t *testing.T) {
+			resp, err := accService.Batch(ctx, dto.BatchTransferRequest{Mode: service.BatchAtomic, Transfers: legs(30.00, 20.00, 10.00)})
+			require.NoError(t, err)
+
+			t.Run("Then every transfer is executed", func(t *testing.T) {
+				assert.Equal(t, 3, resp.Succeeded)
+				assertBalance(t, repo, payroll.ID, 40.00)
+				assertBalance(t, repo, employees[0], 30.00)
+				assertBalance(t, repo, employees[1], 20.00)
+				assertBalance(t, repo, employees[2], 10.00)
+			})
+
+			t.Run("Then the ledger follows the running balance", func(t *testing.T) {
+				history, hErr := accService.Transactions(ctx, payroll.ID)
+				require.NoError(t, hErr)
+				require.Len(t, history.Transactions, 3)
+				assert.Equal(t, 70.00, history.Transactions[0].Balance)
+				assert.Equal(t, 50.00, history.Transactions[1].Balance)
+				assert.Equal(t, 40.00, history.Transactions[2].Balance)
+			})
+		})
+
+		t.Run("When a best effort batch has a transfer over the balance", func(t *testing.T) {
+			resp, err := accService.Batch(ctx, dto.BatchTransferRequest{Mode: service.BatchBestEffort, Transfers: legs(15.00, 30.00, 20.00)})
+			require.NoError(t, err)
+
+			t.Run("Then the others are executed", func(t *testing.T) {
+				assert.Equal(t, 2, resp.Succeeded)
+				assert.Equal(t, 1, resp.Failed)
+				assert.NotNil(t, resp.Results[0].Transfer)
+				assert.Equal(t, "not enough balance", resp.Results[1].Error)
+				assert.NotNil(t, resp.Results[2].Transfer)
+				assertBalance(t, repo, payroll.ID, 5.00)
+				assertBalance(t, repo, employees[1], 20.00)
+				assertBalance(t, repo, employees[2], 30.00)
+			})
+
+			t.Run("Then the failed one is kept as failed", func(t *testing.T) {
+				transfers, lErr := accService.Transfers(ctx, employees[1])
+				require.NoError(t, lErr)
+				require.Len(t, transfers.Transfers, 2)
+				statuses := []string{transfers.Transfers[0].Status, transfers.Transfers[1].Status}
+				assert.Contains(t, statuses, string(model.TransferFailed))
+			})
+		})
+	})
+}
+
+// usageless fails to read the usage of limits of subject
+type usageless struct {
+	repositories.AccountRepository
+	subject string
+}
+
+func (u *usageless) Usage(ctx context.Context, subject string, op model.LimitOperation, period string) (*model.LimitUsage, error) {
+	if subject == u.subject {
+		return nil, errors.New("database gone")
+	}
+	return u.AccountRepository.Usage(ctx, subject, op, period)
+}
+
+func TestAccountService_BatchRepositoryFailure(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+
+	t.Run("Given two senders, the usage of the limits of the second one failing to be read", func(t *testing.T) {
+		first := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, repo.Create(ctx, &first))
+		second := model.Account{ID: uuid.New(), Name: "nick", CustomerID: "acme", Amount: 100.00}
+		require.NoError(t, repo.Create(ctx, &second))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, repo.Create(ctx, &to))
+		failing := &usageless{AccountRepository: repo, subject: model.CustomerSubject(second.CustomerID)}
+		accService := service.NewAccountService(failing, service.WithLimits(testLimits))
+
+		t.Run("When a best effort batch sends from both", func(t *testing.T) {
+			_, err := accService.Batch(ctx, dto.BatchTransferRequest{Mode: service.BatchBestEffort, Transfers: []dto.BatchTransferLeg{
+				{From: first.ID, To: to.ID, Amount: 10.00},
+				{From: second.ID, To: to.ID, Amount: 20.00},
+			}})
+
+			t.Run("Then the whole batch fails", func(t *testing.T) {
+				assert.Error(t, err)
+				assert.NotErrorIs(t, err, service.ErrBatchRejected)
+			})
+
+			t.Run("Then nothing is stored", func(t *testing.T) {
+				assertBalance(t, repo, first.ID, 100.00)
+				assertBalance(t, repo, second.ID, 100.00)
+				assertBalance(t, repo, to.ID, 0.00)
+				transfers, lErr := repo.Transfers(ctx, to.ID)
+				require.NoError(t, lErr)
+				assert.Empty(t, transfers)
+			})
+		})
+	})
+}
@@ -4,12 +4,28 @@ import (
 	"bank/pkg/api/dto"
 	"bank/pkg/api/model"
 	"github.com/google/uuid"
+	"time"
 )
 
-func NewAccount(req dto.CreateAccountRequest) (*model.Account, error) {
+// NewAccount builds the account req opens at now
+func NewAccount(req dto.CreateAccountRequest, now time.Time) (*model.Account, error) {
+	product := req.Product
+	if product == "" {
+		product = model.ProductCurrent
+	}
+	if _, ok := model.DefaultProducts()[product]; !ok {
+		return nil, ErrUnknownProduct
+	}
+
+	// interest starts accruing on the day the account is opened
+	accruedThrough := startOfDay(now).AddDate(0, 0, -1)
+
 	return &model.Account{
-		ID:     uuid.New(),
-		Name:   req.Name,
-		Amount: req.Amount,
+		ID:             uuid.New(),
+		Name:           req.Name,
+		CustomerID:     req.CustomerID,
+		Amount:         req.Amount,
+		Product:        product,
+		AccruedThrough: &accruedThrough,
 	}, nil
 }
@@ -0,0 +1,388 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	StatementCAMT053 = "camt053"
+	StatementOFX     = "ofx"
+
+	camt053Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+	camtDateTime     = "2006-01-02T15:04:05Z"
+	ofxDateTime      = "20060102150405.000[0:GMT]"
+)
+
+// WriteStatementCAMT053 writes statement as an ISO 20022 camt.053.001.02 bank
+// to customer statement, the format accounting software imports from banks
+func WriteStatementCAMT053(w io.Writer, statement dto.StatementResponse) error {
+	end := statement.To.AddDate(0, 0, 1).Add(-time.Second)
+	stmt := camtStatement{
+		ID:      camtID(uuid.New()),
+		Created: statement.GeneratedAt.UTC().Format(camtDateTime),
+		Period: camtPeriod{
+			From: statement.From.UTC().Format(camtDateTime),
+			To:   end.UTC().Format(camtDateTime),
+		},
+		Account: camtAccount{
+			ID:       camtAccountID{Other: camtOther{ID: camtID(statement.AccountID)}},
+			Currency: statement.Currency,
+			Name:     truncate(statement.Name, 70),
+		},
+		Balances: []camtBalance{
+			newCAMTBalance("OPBD", statement.OpeningBalance, statement.Currency, statement.From),
+			newCAMTBalance("CLBD", statement.ClosingBalance, statement.Currency, statement.To),
+		},
+	}
+
+	var credits, debits int
+	for _, tx := range statement.Transactions {
+		if tx.Amount > 0 {
+			credits++
+		} else {
+			debits++
+		}
+		stmt.Entries = append(stmt.Entries, newCAMTEntry(tx, statement.Currency))
+	}
+	net := statement.TotalCredits - statement.TotalDebits
+	stmt.Summary = camtSummary{
+		Total: camtTotal{
+			Count:     fmt.Sprint(credits + debits),
+			Sum:       money(statement.TotalCredits + statement.TotalDebits),
+			NetAmount: money(math.Abs(net)),
+			Indicator: creditDebit(net),
+		},
+		Credits: camtSum{Count: fmt.Sprint(credits), Sum: money(statement.TotalCredits)},
+		Debits:  camtSum{Count: fmt.Sprint(debits), Sum: money(statement.TotalDebits)},
+	}
+
+	doc := camtDocument{
+		Namespace: camt053Namespace,
+		Report: camtReport{
+			Header: camtHeader{
+				MessageID: stmt.ID,
+				Created:   stmt.Created,
+			},
+			Statement: stmt,
+		},
+	}
+	return writeXML(w, "", doc)
+}
+
+// WriteStatementOFX writes statement as an OFX 2.1.1 bank statement response
+func WriteStatementOFX(w io.Writer, statement dto.StatementResponse) error {
+	end := statement.To.AddDate(0, 0, 1).Add(-time.Second)
+	doc := ofxDocument{
+		SignOn: ofxSignOn{Response: ofxSignOnResponse{
+			Status:   ofxStatus{Code: "0", Severity: "INFO"},
+			Server:   statement.GeneratedAt.UTC().Format(ofxDateTime),
+			Language: "ENG",
+		}},
+		Bank: ofxBank{Transaction: ofxStatementTransaction{
+			ID:     camtID(uuid.New()),
+			Status: ofxStatus{Code: "0", Severity: "INFO"},
+			Response: ofxStatementResponse{
+				Currency: statement.Currency,
+				Account: ofxAccount{
+					BankID:    statement.BankID,
+					AccountID: ofxAccountID(statement.AccountID),
+					Type:      ofxAccountType(statement.Product),
+				},
+				Transactions: ofxTransactionList{
+					Start: statement.From.UTC().Format(ofxDateTime),
+					End:   end.UTC().Format(ofxDateTime),
+				},
+				Ledger: ofxBalance{
+					Amount: money(statement.ClosingBalance),
+					AsOf:   end.UTC().Format(ofxDateTime),
+				},
+			},
+		}},
+	}
+	for _, tx := range statement.Transactions {
+		doc.Bank.Transaction.Response.Transactions.Transactions = append(doc.Bank.Transaction.Response.Transactions.Transactions, ofxTransaction{
+			Type:   ofxTransactionType(tx),
+			Posted: tx.CreatedAt.UTC().Format(ofxDateTime),
+			Amount: money(tx.Amount),
+			ID:     tx.ID.String(),
+			Memo:   truncate(tx.Description, 255),
+		})
+	}
+
+	header := `<?OFX OFXHEADER="200" VERSION="211" SECURITY="NONE" OLDFILEUID="NONE" NEWFILEUID="NONE"?>` + "\n"
+	return writeXML(w, header, doc)
+}
+
+func writeXML(w io.Writer, header string, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header+header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type camtDocument struct {
+	XMLName   xml.Name   `xml:"Document"`
+	Namespace string     `xml:"xmlns,attr"`
+	Report    camtReport `xml:"BkToCstmrStmt"`
+}
+
+type camtReport struct {
+	Header    camtHeader    `xml:"GrpHdr"`
+	Statement camtStatement `xml:"Stmt"`
+}
+
+type camtHeader struct {
+	MessageID string `xml:"MsgId"`
+	Created   string `xml:"CreDtTm"`
+}
+
+type camtStatement struct {
+	ID       string        `xml:"Id"`
+	Created  string        `xml:"CreDtTm"`
+	Period   camtPeriod    `xml:"FrToDt"`
+	Account  camtAccount   `xml:"Acct"`
+	Balances []camtBalance `xml:"Bal"`
+	Summary  camtSummary   `xml:"TxsSummry"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtPeriod struct {
+	From string `xml:"FrDtTm"`
+	To   string `xml:"ToDtTm"`
+}
+
+type camtAccount struct {
+	ID       camtAccountID `xml:"Id"`
+	Currency string        `xml:"Ccy"`
+	Name     string        `xml:"Nm,omitempty"`
+}
+
+type camtAccountID struct {
+	Other camtOther `xml:"Othr"`
+}
+
+type camtOther struct {
+	ID string `xml:"Id"`
+}
+
+type camtBalance struct {
+	Type      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>Dt"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtSummary struct {
+	Total   camtTotal `xml:"TtlNtries"`
+	Credits camtSum   `xml:"TtlCdtNtries"`
+	Debits  camtSum   `xml:"TtlDbtNtries"`
+}
+
+type camtTotal struct {
+	Count     string `xml:"NbOfNtries"`
+	Sum       string `xml:"Sum"`
+	NetAmount string `xml:"TtlNetNtryAmt"`
+	Indicator string `xml:"CdtDbtInd"`
+}
+
+type camtSum struct {
+	Count string `xml:"NbOfNtries"`
+	Sum   string `xml:"Sum"`
+}
+
+type camtEntry struct {
+	Reference string       `xml:"NtryRef"`
+	Amount    camtAmount   `xml:"Amt"`
+	Indicator string       `xml:"CdtDbtInd"`
+	Reversal  bool         `xml:"RvslInd,omitempty"`
+	Status    string       `xml:"Sts"`
+	Booked    string       `xml:"BookgDt>DtTm"`
+	Value     string       `xml:"ValDt>Dt"`
+	Code      camtBankCode `xml:"BkTxCd"`
+	Info      string       `xml:"AddtlNtryInf,omitempty"`
+}
+
+type camtBankCode struct {
+	Domain      string `xml:"Domn>Cd"`
+	Family      string `xml:"Domn>Fmly>Cd"`
+	SubFamily   string `xml:"Domn>Fmly>SubFmlyCd"`
+	Proprietary string `xml:"Prtry>Cd"`
+}
+
+// camtCodes are the ISO bank transaction codes of every entry type: domain,
+// family and sub family
+var camtCodes = map[model.EntryType][3]string{
+	model.EntryDeposit:     {"PMNT", "CNTR", "CDPT"},
+	model.EntryWithdrawal:  {"PMNT", "CNTR", "CWDL"},
+	model.EntryTransferIn:  {"PMNT", "RCDT", "BOOK"},
+	model.EntryTransferOut: {"PMNT", "ICDT", "BOOK"},
+	model.EntryFee:         {"ACMT", "MDOP", "CHRG"},
+	model.EntryInterest:    {"ACMT", "MCOP", "INTR"},
+	model.EntryReversal:    {"PMNT", "RCDT", "RRTN"},
+}
+
+func newCAMTBalance(code string, amount float64, currency string, day time.Time) camtBalance {
+	return camtBalance{
+		Type:      code,
+		Amount:    camtAmount{Currency: currency, Value: money(math.Abs(amount))},
+		Indicator: creditDebit(amount),
+		Date:      day.Format(statementDate),
+	}
+}
+
+func newCAMTEntry(tx dto.TransactionResponse, currency string) camtEntry {
+	codes, ok := camtCodes[model.EntryType(tx.Type)]
+	if !ok {
+		codes = [3]string{"PMNT", "OTHR", "OTHR"}
+	}
+	return camtEntry{
+		Reference: camtID(tx.ID),
+		Amount:    camtAmount{Currency: currency, Value: money(math.Abs(tx.Amount))},
+		Indicator: creditDebit(tx.Amount),
+		Reversal:  tx.Type == string(model.EntryReversal),
+		Status:    "BOOK",
+		Booked:    tx.CreatedAt.UTC().Format(camtDateTime),
+		Value:     tx.CreatedAt.UTC().Format(statementDate),
+		Code: camtBankCode{
+			Domain:      codes[0],
+			Family:      codes[1],
+			SubFamily:   codes[2],
+			Proprietary: tx.Type,
+		},
+		Info: truncate(tx.Description, 500),
+	}
+}
+
+// camtID writes id in the 35 characters camt identifiers allow
+func camtID(id uuid.UUID) string {
+	return strings.ReplaceAll(id.String(), "-", "")
+}
+
+func creditDebit(amount float64) string {
+	if amount < 0 {
+		return "DBIT"
+	}
+	return "CRDT"
+}
+
+type ofxDocument struct {
+	XMLName xml.Name  `xml:"OFX"`
+	SignOn  ofxSignOn `xml:"SIGNONMSGSRSV1"`
+	Bank    ofxBank   `xml:"BANKMSGSRSV1"`
+}
+
+type ofxSignOn struct {
+	Response ofxSignOnResponse `xml:"SONRS"`
+}
+
+type ofxSignOnResponse struct {
+	Status   ofxStatus `xml:"STATUS"`
+	Server   string    `xml:"DTSERVER"`
+	Language string    `xml:"LANGUAGE"`
+}
+
+type ofxStatus struct {
+	Code     string `xml:"CODE"`
+	Severity string `xml:"SEVERITY"`
+}
+
+type ofxBank struct {
+	Transaction ofxStatementTransaction `xml:"STMTTRNRS"`
+}
+
+type ofxStatementTransaction struct {
+	ID       string               `xml:"TRNUID"`
+	Status   ofxStatus            `xml:"STATUS"`
+	Response ofxStatementResponse `xml:"STMTRS"`
+}
+
+type ofxStatementResponse struct {
+	Currency     string             `xml:"CURDEF"`
+	Account      ofxAccount         `xml:"BANKACCTFROM"`
+	Transactions ofxTransactionList `xml:"BANKTRANLIST"`
+	Ledger       ofxBalance         `xml:"LEDGERBAL"`
+}
+
+type ofxAccount struct {
+	BankID    string `xml:"BANKID"`
+	AccountID string `xml:"ACCTID"`
+	Type      string `xml:"ACCTTYPE"`
+}
+
+type ofxTransactionList struct {
+	Start        string           `xml:"DTSTART"`
+	End          string           `xml:"DTEND"`
+	Transactions []ofxTransaction `xml:"STMTTRN"`
+}
+
+type ofxTransaction struct {
+	Type   string `xml:"TRNTYPE"`
+	Posted string `xml:"DTPOSTED"`
+	Amount string `xml:"TRNAMT"`
+	ID     string `xml:"FITID"`
+	Memo   string `xml:"MEMO,omitempty"`
+}
+
+type ofxBalance struct {
+	Amount string `xml:"BALAMT"`
+	AsOf   string `xml:"DTASOF"`
+}
+
+// ofxAccountID writes id in the 22 characters ofx account ids allow
+func ofxAccountID(id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString(id[:])
+}
+
+func ofxAccountType(product string) string {
+	if product == model.ProductSavings {
+		return "SAVINGS"
+	}
+	return "CHECKING"
+}
+
+func ofxTransactionType(tx dto.TransactionResponse) string {
+	switch model.EntryType(tx.Type) {
+	case model.EntryDeposit:
+		return "DEP"
+	case model.EntryWithdrawal:
+		return "CASH"
+	case model.EntryTransferIn, model.EntryTransferOut:
+		return "XFER"
+	case model.EntryFee:
+		return "FEE"
+	case model.EntryInterest:
+		return "INT"
+	}
+	if tx.Amount < 0 {
+		return "DEBIT"
+	}
+	return "CREDIT"
+}
+
+// truncate cuts text to max characters
+func truncate(text string, max int) string {
+	if runes := []rune(text); len(runes) > max {
+		return string(runes[:max])
+	}
+	return text
+}
@@ -0,0 +1,159 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/service"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// assertSchema validates doc against the schema in testdata with xmllint,
+// which the tests need installed
+func assertSchema(t *testing.T, schema string, doc []byte) {
+	t.Helper()
+	xmllint, err := exec.LookPath("xmllint")
+	require.NoError(t, err, "xmllint is needed to validate exports, see Running tests in the README")
+	cmd := exec.Command(xmllint, "--noout", "--schema", filepath.Join("testdata", schema), "-")
+	cmd.Stdin = bytes.NewReader(doc)
+	out, err := cmd.CombinedOutput()
+	assert.NoError(t, err, string(out))
+}
+
+func TestStatementExport(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 2, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	accService := service.NewAccountService(repo, service.WithClock(clock))
+	statementService := service.NewStatementService(repo, service.Bank{ID: "123456789", Currency: "EUR"}, clock)
+
+	acc, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "bill <smith> & sons", Amount: 100.00})
+	require.NoError(t, err)
+	other, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "jane smith", Amount: 10.00})
+	require.NoError(t, err)
+	_, err = accService.AddMoney(ctx, acc.ID, 50.00)
+	require.NoError(t, err)
+	_, err = accService.Withdraw(ctx, acc.ID, 20.00)
+	require.NoError(t, err)
+	transfer, err := accService.Transfer(ctx, acc.ID, other.ID, 40.00, "rent")
+	require.NoError(t, err)
+	_, err = accService.Reverse(ctx, transfer.ID, dto.ReversalRequest{Amount: 15.00})
+	require.NoError(t, err)
+	now = time.Date(2022, 10, 1, 9, 0, 0, 0, time.UTC)
+
+	statement, err := statementService.Statement(ctx, acc.ID, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, statement.Transactions, 4)
+
+	t.Run("Given a statement written as camt.053", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, service.WriteStatementCAMT053(&out, statement))
+
+		t.Run("Then it is valid against the schema", func(t *testing.T) {
+			assertSchema(t, "camt.053.001.02.xsd", out.Bytes())
+		})
+
+		t.Run("Then it has the balances and an entry per transaction", func(t *testing.T) {
+			var doc struct {
+				XMLName xml.Name `xml:"urn:iso:std:iso:20022:tech:xsd:camt.053.001.02 Document"`
+				Stmt    struct {
+					Acct struct {
+						ID  string `xml:"Id>Othr>Id"`
+						Ccy string `xml:"Ccy"`
+						Nm  string `xml:"Nm"`
+					} `xml:"Acct"`
+					Bal []struct {
+						Cd        string `xml:"Tp>CdOrPrtry>Cd"`
+						Amt       string `xml:"Amt"`
+						CdtDbtInd string `xml:"CdtDbtInd"`
+						Dt        string `xml:"Dt>Dt"`
+					} `xml:"Bal"`
+					NbOfNtries string `xml:"TxsSummry>TtlNtries>NbOfNtries"`
+					Ntry       []struct {
+						Amt       string `xml:"Amt"`
+						CdtDbtInd string `xml:"CdtDbtInd"`
+						RvslInd   bool   `xml:"RvslInd"`
+						Prtry     string `xml:"BkTxCd>Prtry>Cd"`
+						SubFmlyCd string `xml:"BkTxCd>Domn>Fmly>SubFmlyCd"`
+					} `xml:"Ntry"`
+				} `xml:"BkToCstmrStmt>Stmt"`
+			}
+			require.NoError(t, xml.Unmarshal(out.Bytes(), &doc))
+
+			assert.Len(t, doc.Stmt.Acct.ID, 32)
+			assert.Equal(t, "EUR", doc.Stmt.Acct.Ccy)
+			assert.Equal(t, "bill <smith> & sons", doc.Stmt.Acct.Nm)
+			require.Len(t, doc.Stmt.Bal, 2)
+			assert.Equal(t, "OPBD", doc.Stmt.Bal[0].Cd)
+			assert.Equal(t, "100.00", doc.Stmt.Bal[0].Amt)
+			assert.Equal(t, "2022-09-01", doc.Stmt.Bal[0].Dt)
+			assert.Equal(t, "CLBD", doc.Stmt.Bal[1].Cd)
+			assert.Equal(t, "105.00", doc.Stmt.Bal[1].Amt)
+			assert.Equal(t, "2022-09-30", doc.Stmt.Bal[1].Dt)
+			assert.Equal(t, "4", doc.Stmt.NbOfNtries)
+			require.Len(t, doc.Stmt.Ntry, 4)
+			assert.Equal(t, "CRDT", doc.Stmt.Ntry[0].CdtDbtInd)
+			assert.Equal(t, "CDPT", doc.Stmt.Ntry[0].SubFmlyCd)
+			assert.Equal(t, "20.00", doc.Stmt.Ntry[1].Amt)
+			assert.Equal(t, "DBIT", doc.Stmt.Ntry[1].CdtDbtInd)
+			assert.Equal(t, "transfer_out", doc.Stmt.Ntry[2].Prtry)
+			assert.Equal(t, "15.00", doc.Stmt.Ntry[3].Amt)
+			assert.Equal(t, "CRDT", doc.Stmt.Ntry[3].CdtDbtInd)
+			assert.True(t, doc.Stmt.Ntry[3].RvslInd)
+		})
+	})
+
+	t.Run("Given a statement written as ofx", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, service.WriteStatementOFX(&out, statement))
+
+		t.Run("Then it is valid against the schema", func(t *testing.T) {
+			assertSchema(t, "ofx211.xsd", out.Bytes())
+		})
+
+		t.Run("Then it has the ledger balance and a transaction per entry", func(t *testing.T) {
+			assert.Contains(t, out.String(), `<?OFX OFXHEADER="200" VERSION="211"`)
+			var doc struct {
+				XMLName xml.Name `xml:"OFX"`
+				Stmt    struct {
+					Currency string `xml:"CURDEF"`
+					BankID   string `xml:"BANKACCTFROM>BANKID"`
+					Account  string `xml:"BANKACCTFROM>ACCTID"`
+					Type     string `xml:"BANKACCTFROM>ACCTTYPE"`
+					Start    string `xml:"BANKTRANLIST>DTSTART"`
+					End      string `xml:"BANKTRANLIST>DTEND"`
+					Trn      []struct {
+						Type   string `xml:"TRNTYPE"`
+						Amount string `xml:"TRNAMT"`
+						FITID  string `xml:"FITID"`
+						Memo   string `xml:"MEMO"`
+					} `xml:"BANKTRANLIST>STMTTRN"`
+					Balance string `xml:"LEDGERBAL>BALAMT"`
+				} `xml:"BANKMSGSRSV1>STMTTRNRS>STMTRS"`
+			}
+			require.NoError(t, xml.Unmarshal(out.Bytes(), &doc))
+
+			assert.Equal(t, "EUR", doc.Stmt.Currency)
+			assert.Equal(t, "123456789", doc.Stmt.BankID)
+			assert.Len(t, doc.Stmt.Account, 22)
+			assert.Equal(t, "CHECKING", doc.Stmt.Type)
+			assert.Equal(t, "20220901000000.000[0:GMT]", doc.Stmt.Start)
+			assert.Equal(t, "20220930235959.000[0:GMT]", doc.Stmt.End)
+			assert.Equal(t, "105.00", doc.Stmt.Balance)
+			require.Len(t, doc.Stmt.Trn, 4)
+			assert.Equal(t, "DEP", doc.Stmt.Trn[0].Type)
+			assert.Equal(t, "CASH", doc.Stmt.Trn[1].Type)
+			assert.Equal(t, "-20.00", doc.Stmt.Trn[1].Amount)
+			assert.Equal(t, "XFER", doc.Stmt.Trn[2].Type)
+			assert.Equal(t, "CREDIT", doc.Stmt.Trn[3].Type)
+			assert.Equal(t, statement.Transactions[3].ID.String(), doc.Stmt.Trn[3].FITID)
+		})
+	})
+}
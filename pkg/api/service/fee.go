@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"time"
+)
+
+type FeeService interface {
+	// ChargeMaintenance charges the monthly maintenance fees of every account
+	// not charged yet for the current month
+	ChargeMaintenance(ctx context.Context) error
+}
+
+func NewFeeService(
+	repository repositories.AccountRepository,
+	accountService AccountService,
+	schedule model.FeeSchedule,
+	revenueAccountID uuid.UUID,
+	clock Clock,
+) FeeService {
+	return &feeService{
+		repository:       repository,
+		accountService:   accountService,
+		schedule:         schedule,
+		revenueAccountID: revenueAccountID,
+		clock:            clock,
+	}
+}
+
+type feeService struct {
+	repository       repositories.AccountRepository
+	accountService   AccountService
+	schedule         model.FeeSchedule
+	revenueAccountID uuid.UUID
+	clock            Clock
+}
+
+func (s *feeService) ChargeMaintenance(ctx context.Context) error {
+	if !s.hasMaintenanceFees() {
+		return nil
+	}
+
+	accounts, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := s.clock().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for _, account := range accounts {
+		if account.ID == s.revenueAccountID || chargedFor(account, month) {
+			continue
+		}
+
+		aErr := s.accountService.Apply(ctx, model.AuditMaintenanceFee, []uuid.UUID{account.ID, s.revenueAccountID}, func(accounts []*model.Account) (repositories.Change, error) {
+			return s.chargeMaintenance(accounts[0], accounts[1], month, now), nil
+		})
+		if aErr != nil {
+			return aErr
+		}
+	}
+
+	return nil
+}
+
+// chargeMaintenance moves the maintenance fees of acc to revenue. The fee is
+// charged up to the available balance, as accounts can not go negative and
+// held money is already promised.
+func (s *feeService) chargeMaintenance(acc *model.Account, revenue *model.Account, month time.Time, now time.Time) repositories.Change {
+	change := repositories.Change{Accounts: []*model.Account{acc}}
+	if chargedFor(acc, month) {
+		return change
+	}
+	acc.MaintenanceChargedAt = &month
+
+	fees := s.schedule.Fees(model.FeeOnMaintenance, acc.Product, acc.Amount)
+	for _, fee := range fees {
+		charge := math.Min(fee.Amount, acc.Available())
+		if charge <= 0 {
+			break
+		}
+		acc.Amount -= charge
+		revenue.Amount += charge
+		change.Entries = append(change.Entries,
+			&model.LedgerEntry{
+				ID:          uuid.New(),
+				AccountID:   acc.ID,
+				Type:        model.EntryFee,
+				Amount:      -charge,
+				Balance:     acc.Amount,
+				Description: fmt.Sprintf("%s %s", fee.Name, month.Format("2006-01")),
+				CreatedAt:   now,
+			},
+			&model.LedgerEntry{
+				ID:          uuid.New(),
+				AccountID:   revenue.ID,
+				Type:        model.EntryFee,
+				Amount:      charge,
+				Balance:     revenue.Amount,
+				Description: fmt.Sprintf("%s %s from %s", fee.Name, month.Format("2006-01"), acc.ID),
+				CreatedAt:   now,
+			})
+	}
+	if len(change.Entries) > 0 {
+		change.Accounts = append(change.Accounts, revenue)
+	}
+
+	return change
+}
+
+func (s *feeService) hasMaintenanceFees() bool {
+	for _, rule := range s.schedule {
+		if rule.Event == model.FeeOnMaintenance {
+			return true
+		}
+	}
+	return false
+}
+
+func chargedFor(acc *model.Account, month time.Time) bool {
+	return acc.MaintenanceChargedAt != nil && !acc.MaintenanceChargedAt.Before(month)
+}
+
+// EnsureRevenueAccount creates the account collecting the bank fees when it
+// does not exist yet
+func EnsureRevenueAccount(ctx context.Context, repository repositories.AccountRepository, revenueAccountID uuid.UUID) error {
+	_, err := repository.Get(ctx, revenueAccountID)
+	if !errors.Is(err, repositories.ErrAccountNotFound) {
+		return err
+	}
+
+	return repository.Create(ctx, &model.Account{
+		ID:      revenueAccountID,
+		Name:    "bank revenue",
+		Product: model.ProductCurrent,
+	})
+}
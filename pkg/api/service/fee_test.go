@@ -0,0 +1,102 @@
+package service_test
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testFees = model.FeeSchedule{
+	{Name: "transfer fee", Event: model.FeeOnTransfer, Type: model.FeeFlat, Flat: 1.00},
+	{Name: "transfer commission", Event: model.FeeOnTransfer, Type: model.FeePercentage, Percentage: 0.01},
+	{Name: "maintenance fee", Event: model.FeeOnMaintenance, Type: model.FeeFlat, Flat: 3.00},
+}
+
+func TestAccountService_Transfer_Fees(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+
+	t.Run("Given two accounts and the bank revenue account", func(t *testing.T) {
+		revenueID := uuid.New()
+		require.NoError(t, service.EnsureRevenueAccount(ctx, repo, revenueID))
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, repo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, repo.Create(ctx, &to))
+
+		accService := service.NewAccountService(repo, service.WithFees(testFees, revenueID))
+
+		t.Run("When the amount plus fees is over the balance", func(t *testing.T) {
+			_, err := accService.Transfer(ctx, from.ID, to.ID, 99.50, "")
+
+			t.Run("Then fails and nothing moves", func(t *testing.T) {
+				assert.Error(t, err)
+				assertBalance(t, repo, from.ID, 100.00)
+				assertBalance(t, repo, revenueID, 0.00)
+			})
+		})
+
+		t.Run("When transferring 50", func(t *testing.T) {
+			resp, err := accService.Transfer(ctx, from.ID, to.ID, 50.00, "")
+			require.NoError(t, err)
+
+			t.Run("Then the fees are charged on top and credited to the bank", func(t *testing.T) {
+				assert.Equal(t, 1.50, resp.Fee)
+				assert.Len(t, resp.Fees, 2)
+				assertBalance(t, repo, from.ID, 48.50)
+				assertBalance(t, repo, to.ID, 50.00)
+				assertBalance(t, repo, revenueID, 1.50)
+			})
+
+			t.Run("Then the fees are in the history", func(t *testing.T) {
+				history, hErr := accService.Transactions(ctx, from.ID)
+				require.NoError(t, hErr)
+				require.Len(t, history.Transactions, 3)
+				assert.Equal(t, string(model.EntryTransferOut), history.Transactions[0].Type)
+				assert.Equal(t, string(model.EntryFee), history.Transactions[1].Type)
+				assert.Equal(t, 48.50, history.Transactions[2].Balance)
+			})
+		})
+	})
+}
+
+func TestFeeService_ChargeMaintenance(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 1, 0, 30, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given an account", func(t *testing.T) {
+		revenueID := uuid.New()
+		require.NoError(t, service.EnsureRevenueAccount(ctx, repo, revenueID))
+		acc := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, repo.Create(ctx, &acc))
+
+		accService := service.NewAccountService(repo, service.WithFees(testFees, revenueID))
+		feeService := service.NewFeeService(repo, accService, testFees, revenueID, clock)
+
+		t.Run("When maintenance runs several times in the same month", func(t *testing.T) {
+			require.NoError(t, feeService.ChargeMaintenance(ctx))
+			require.NoError(t, feeService.ChargeMaintenance(ctx))
+
+			t.Run("Then the fee is charged once", func(t *testing.T) {
+				assertBalance(t, repo, acc.ID, 97.00)
+				assertBalance(t, repo, revenueID, 3.00)
+			})
+		})
+
+		t.Run("When the next month starts", func(t *testing.T) {
+			now = now.AddDate(0, 1, 0)
+			require.NoError(t, feeService.ChargeMaintenance(ctx))
+
+			t.Run("Then it is charged again", func(t *testing.T) {
+				assertBalance(t, repo, acc.ID, 94.00)
+			})
+		})
+	})
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	// DefaultHoldDuration is used when a hold is placed without expiry
+	DefaultHoldDuration = 7 * 24 * time.Hour
+	// holdBatchSize bounds the holds expired on every run
+	holdBatchSize = 100
+)
+
+var (
+	ErrInvalidHold   = errors.New("invalid hold")
+	ErrHoldNotActive = errors.New("hold is not active")
+)
+
+type HoldService interface {
+	// Place reserves money of an account for a later payment
+	Place(ctx context.Context, accountID uuid.UUID, req dto.PlaceHoldRequest) (dto.HoldResponse, error)
+	// Capture pays amount of the hold, the whole hold when amount is zero,
+	// and gives back the rest. A hold is captured only once.
+	Capture(ctx context.Context, holdID uuid.UUID, amount float64) (dto.HoldResponse, error)
+	Release(ctx context.Context, holdID uuid.UUID) (dto.HoldResponse, error)
+	Get(ctx context.Context, holdID uuid.UUID) (dto.HoldResponse, error)
+	List(ctx context.Context, accountID uuid.UUID) (dto.GetHoldsResponse, error)
+	// ExpireDue releases every active hold whose expiry has been reached
+	ExpireDue(ctx context.Context) error
+}
+
+func NewHoldService(
+	repository repositories.AccountRepository,
+	accountService AccountService,
+	clock Clock,
+) HoldService {
+	return &holdService{
+		repository:     repository,
+		accountService: accountService,
+		clock:          clock,
+	}
+}
+
+type holdService struct {
+	repository     repositories.AccountRepository
+	accountService AccountService
+	clock          Clock
+}
+
+func (s *holdService) Place(ctx context.Context, accountID uuid.UUID, req dto.PlaceHoldRequest) (dto.HoldResponse, error) {
+	if accountID == req.To || req.Amount <= 0 {
+		return dto.HoldResponse{}, ErrInconsistentData
+	}
+
+	now := s.clock()
+	expiresAt := now.Add(DefaultHoldDuration)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+	if !expiresAt.After(now) {
+		return dto.HoldResponse{}, ErrInvalidHold
+	}
+
+	hold := &model.Hold{
+		ID:        uuid.New(),
+		AccountID: accountID,
+		To:        req.To,
+		Amount:    req.Amount,
+		Status:    model.HoldActive,
+		Reference: req.Reference,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := s.accountService.Apply(ctx, model.AuditHoldPlaced, []uuid.UUID{accountID, req.To}, func(accounts []*model.Account) (repositories.Change, error) {
+		acc := accounts[0]
+		if hErr := acc.Hold(hold.Amount); hErr != nil {
+			return repositories.Change{}, hErr
+		}
+		return repositories.Change{
+			Accounts: []*model.Account{acc},
+			Holds:    []*model.Hold{hold},
+		}, nil
+	})
+	if err != nil {
+		return dto.HoldResponse{}, err
+	}
+
+	return toHoldResponse(hold), nil
+}
+
+func (s *holdService) Capture(ctx context.Context, holdID uuid.UUID, amount float64) (dto.HoldResponse, error) {
+	hold, gErr := s.repository.GetHold(ctx, holdID)
+	if gErr != nil {
+		return dto.HoldResponse{}, gErr
+	}
+	if amount == 0 {
+		amount = hold.Amount
+	}
+	if amount < 0 || amount > hold.Amount {
+		return dto.HoldResponse{}, ErrInvalidHold
+	}
+
+	// it pays like a transfer, an operator can not step in once the merchant
+	// captures it
+	payment := &model.Transfer{
+		ID:     hold.ID,
+		From:   hold.AccountID,
+		To:     hold.To,
+		Amount: amount,
+		Status: model.TransferPending,
+		Memo:   hold.Reference,
+	}
+	_, err := s.accountService.Pay(ctx, model.AuditHoldCaptured, payment, func(from *model.Account) (repositories.Change, error) {
+		// read again under the lock, it may have been settled meanwhile
+		current, cErr := s.active(ctx, holdID)
+		if cErr != nil {
+			return repositories.Change{}, cErr
+		}
+		hold = current
+		// an expired hold is only waiting for ExpireDue to release it
+		now := s.clock()
+		if !now.Before(hold.ExpiresAt) {
+			return repositories.Change{}, ErrHoldNotActive
+		}
+
+		from.ReleaseHold(hold.Amount)
+		payment.CreatedAt, payment.UpdatedAt = now, now
+		hold.Status = model.HoldCaptured
+		hold.Captured = amount
+		hold.UpdatedAt = now
+		return repositories.Change{Holds: []*model.Hold{hold}}, nil
+	})
+	if err != nil {
+		return dto.HoldResponse{}, err
+	}
+
+	return toHoldResponse(hold), nil
+}
+
+func (s *holdService) Release(ctx context.Context, holdID uuid.UUID) (dto.HoldResponse, error) {
+	hold, err := s.settle(ctx, holdID, model.HoldReleased)
+	if err != nil {
+		return dto.HoldResponse{}, err
+	}
+	return toHoldResponse(hold), nil
+}
+
+func (s *holdService) Get(ctx context.Context, holdID uuid.UUID) (dto.HoldResponse, error) {
+	hold, err := s.repository.GetHold(ctx, holdID)
+	if err != nil {
+		return dto.HoldResponse{}, err
+	}
+	return toHoldResponse(hold), nil
+}
+
+func (s *holdService) List(ctx context.Context, accountID uuid.UUID) (dto.GetHoldsResponse, error) {
+	if _, gErr := s.repository.Get(ctx, accountID); gErr != nil {
+		return dto.GetHoldsResponse{}, gErr
+	}
+
+	holds, err := s.repository.Holds(ctx, accountID)
+	if err != nil {
+		return dto.GetHoldsResponse{}, err
+	}
+
+	resp := make([]dto.HoldResponse, len(holds))
+	for i := range holds {
+		resp[i] = toHoldResponse(holds[i])
+	}
+
+	return dto.GetHoldsResponse{Holds: resp}, nil
+}
+
+func (s *holdService) ExpireDue(ctx context.Context) error {
+	due, err := s.repository.ExpiredHolds(ctx, s.clock(), holdBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, hold := range due {
+		// captured or released since it was listed
+		if _, sErr := s.settle(ctx, hold.ID, model.HoldExpired); sErr != nil && !errors.Is(sErr, ErrHoldNotActive) {
+			return sErr
+		}
+	}
+
+	return nil
+}
+
+// settle gives back the money of an active hold, leaving it in status
+func (s *holdService) settle(ctx context.Context, holdID uuid.UUID, status model.HoldStatus) (*model.Hold, error) {
+	hold, gErr := s.repository.GetHold(ctx, holdID)
+	if gErr != nil {
+		return nil, gErr
+	}
+
+	action := model.AuditHoldReleased
+	if status == model.HoldExpired {
+		action = model.AuditHoldExpired
+	}
+	err := s.accountService.Apply(ctx, action, []uuid.UUID{hold.AccountID}, func(accounts []*model.Account) (repositories.Change, error) {
+		current, cErr := s.active(ctx, holdID)
+		if cErr != nil {
+			return repositories.Change{}, cErr
+		}
+		hold = current
+
+		acc := accounts[0]
+		acc.ReleaseHold(hold.Amount)
+		hold.Status = status
+		hold.UpdatedAt = s.clock()
+		return repositories.Change{
+			Accounts: []*model.Account{acc},
+			Holds:    []*model.Hold{hold},
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+func (s *holdService) active(ctx context.Context, holdID uuid.UUID) (*model.Hold, error) {
+	hold, err := s.repository.GetHold(ctx, holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold.Status != model.HoldActive {
+		return nil, ErrHoldNotActive
+	}
+	return hold, nil
+}
+
+func toHoldResponse(hold *model.Hold) dto.HoldResponse {
+	return dto.HoldResponse{
+		ID:        hold.ID,
+		AccountID: hold.AccountID,
+		To:        hold.To,
+		Amount:    hold.Amount,
+		Captured:  hold.Captured,
+		Status:    string(hold.Status),
+		Reference: hold.Reference,
+		ExpiresAt: hold.ExpiresAt,
+		CreatedAt: hold.CreatedAt,
+	}
+}
@@ -0,0 +1,199 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestHoldService(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given a card account and a merchant", func(t *testing.T) {
+		card := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, repo.Create(ctx, &card))
+		merchant := model.Account{ID: uuid.New(), Name: "shop", Amount: 0.00}
+		require.NoError(t, repo.Create(ctx, &merchant))
+
+		accService := service.NewAccountService(repo, service.WithClock(clock))
+		holdService := service.NewHoldService(repo, accService, clock)
+
+		t.Run("When holding 60", func(t *testing.T) {
+			hold, err := holdService.Place(ctx, card.ID, dto.PlaceHoldRequest{To: merchant.ID, Amount: 60.00})
+			require.NoError(t, err)
+
+			t.Run("Then only the available balance goes down", func(t *testing.T) {
+				acc, gErr := accService.Get(ctx, card.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, 100.00, acc.Amount)
+				assert.Equal(t, 60.00, acc.Held)
+				assert.Equal(t, 40.00, acc.Available)
+				assert.Equal(t, now.Add(service.DefaultHoldDuration), hold.ExpiresAt)
+			})
+
+			t.Run("Then held money can not be transferred", func(t *testing.T) {
+				_, tErr := accService.Transfer(ctx, card.ID, merchant.ID, 50.00, "")
+				assert.Error(t, tErr)
+				assertBalance(t, repo, card.ID, 100.00)
+			})
+
+			t.Run("Then capturing more than held fails", func(t *testing.T) {
+				_, cErr := holdService.Capture(ctx, hold.ID, 70.00)
+				assert.ErrorIs(t, cErr, service.ErrInvalidHold)
+			})
+
+			t.Run("Then a partial capture pays the merchant and frees the rest", func(t *testing.T) {
+				captured, cErr := holdService.Capture(ctx, hold.ID, 45.00)
+				require.NoError(t, cErr)
+				assert.Equal(t, string(model.HoldCaptured), captured.Status)
+				assert.Equal(t, 45.00, captured.Captured)
+
+				acc, gErr := accService.Get(ctx, card.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, 55.00, acc.Amount)
+				assert.Equal(t, 0.00, acc.Held)
+				assertBalance(t, repo, merchant.ID, 45.00)
+			})
+
+			t.Run("Then it can not be captured or released again", func(t *testing.T) {
+				_, cErr := holdService.Capture(ctx, hold.ID, 0)
+				assert.ErrorIs(t, cErr, service.ErrHoldNotActive)
+				_, rErr := holdService.Release(ctx, hold.ID)
+				assert.ErrorIs(t, rErr, service.ErrHoldNotActive)
+			})
+		})
+
+		t.Run("When holding more than available", func(t *testing.T) {
+			_, err := holdService.Place(ctx, card.ID, dto.PlaceHoldRequest{To: merchant.ID, Amount: 60.00})
+
+			t.Run("Then fails", func(t *testing.T) {
+				assert.Error(t, err)
+			})
+		})
+
+		t.Run("When a hold is released", func(t *testing.T) {
+			hold, err := holdService.Place(ctx, card.ID, dto.PlaceHoldRequest{To: merchant.ID, Amount: 20.00})
+			require.NoError(t, err)
+			released, rErr := holdService.Release(ctx, hold.ID)
+			require.NoError(t, rErr)
+
+			t.Run("Then the money is available again and nothing moves", func(t *testing.T) {
+				assert.Equal(t, string(model.HoldReleased), released.Status)
+				acc, gErr := accService.Get(ctx, card.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, 55.00, acc.Available)
+				assertBalance(t, repo, merchant.ID, 45.00)
+			})
+		})
+
+		t.Run("When a hold expires", func(t *testing.T) {
+			expiresAt := now.Add(time.Hour)
+			hold, err := holdService.Place(ctx, card.ID, dto.PlaceHoldRequest{To: merchant.ID, Amount: 30.00, ExpiresAt: &expiresAt})
+			require.NoError(t, err)
+
+			require.NoError(t, holdService.ExpireDue(ctx))
+			now = now.Add(2 * time.Hour)
+			require.NoError(t, holdService.ExpireDue(ctx))
+
+			t.Run("Then it is released once its expiry is reached", func(t *testing.T) {
+				expired, gErr := holdService.Get(ctx, hold.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.HoldExpired), expired.Status)
+
+				acc, aErr := accService.Get(ctx, card.ID)
+				require.NoError(t, aErr)
+				assert.Equal(t, 0.00, acc.Held)
+			})
+
+			t.Run("Then it can not be captured", func(t *testing.T) {
+				_, cErr := holdService.Capture(ctx, hold.ID, 0)
+				assert.ErrorIs(t, cErr, service.ErrHoldNotActive)
+			})
+		})
+
+		t.Run("When a hold past its expiry is captured before being released", func(t *testing.T) {
+			expiresAt := now.Add(time.Hour)
+			hold, err := holdService.Place(ctx, card.ID, dto.PlaceHoldRequest{To: merchant.ID, Amount: 30.00, ExpiresAt: &expiresAt})
+			require.NoError(t, err)
+			now = expiresAt
+			_, cErr := holdService.Capture(ctx, hold.ID, 0)
+
+			t.Run("Then it is refused and nothing moves", func(t *testing.T) {
+				assert.ErrorIs(t, cErr, service.ErrHoldNotActive)
+				assertBalance(t, repo, card.ID, 55.00)
+				assertBalance(t, repo, merchant.ID, 45.00)
+			})
+
+			t.Run("Then it is still released by the expiry", func(t *testing.T) {
+				require.NoError(t, holdService.ExpireDue(ctx))
+				expired, gErr := holdService.Get(ctx, hold.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.HoldExpired), expired.Status)
+			})
+		})
+	})
+}
+
+func TestHoldService_CaptureAsTransfer(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given a card account with transfer limits and fees, and a merchant", func(t *testing.T) {
+		revenueID := uuid.New()
+		require.NoError(t, service.EnsureRevenueAccount(ctx, repo, revenueID))
+		card := model.Account{ID: uuid.New(), Name: "billy", Amount: 200.00}
+		require.NoError(t, repo.Create(ctx, &card))
+		merchant := model.Account{ID: uuid.New(), Name: "shop", Amount: 0.00}
+		require.NoError(t, repo.Create(ctx, &merchant))
+
+		accService := service.NewAccountService(repo, service.WithClock(clock), service.WithLimits(testLimits), service.WithFees(testFees, revenueID))
+		holdService := service.NewHoldService(repo, accService, clock)
+
+		t.Run("When capturing a hold of 50", func(t *testing.T) {
+			hold, err := holdService.Place(ctx, card.ID, dto.PlaceHoldRequest{To: merchant.ID, Amount: 50.00, Reference: "order 1"})
+			require.NoError(t, err)
+			_, err = holdService.Capture(ctx, hold.ID, 0)
+			require.NoError(t, err)
+
+			t.Run("Then it is charged the fees of a transfer", func(t *testing.T) {
+				assertBalance(t, repo, card.ID, 148.50)
+				assertBalance(t, repo, merchant.ID, 50.00)
+				assertBalance(t, repo, revenueID, 1.50)
+			})
+
+			t.Run("Then it is kept as a transfer", func(t *testing.T) {
+				transfer, gErr := accService.GetTransfer(ctx, hold.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.TransferCompleted), transfer.Status)
+				assert.Equal(t, 50.00, transfer.Amount)
+				assert.Equal(t, "order 1", transfer.Memo)
+			})
+		})
+
+		t.Run("When capturing a hold that goes over the daily limit", func(t *testing.T) {
+			hold, err := holdService.Place(ctx, card.ID, dto.PlaceHoldRequest{To: merchant.ID, Amount: 60.00})
+			require.NoError(t, err)
+			_, err = holdService.Capture(ctx, hold.ID, 0)
+
+			t.Run("Then it is refused and nothing moves", func(t *testing.T) {
+				assert.ErrorIs(t, err, model.ErrLimitExceeded)
+				assertBalance(t, repo, card.ID, 148.50)
+				assertBalance(t, repo, merchant.ID, 50.00)
+				got, gErr := holdService.Get(ctx, hold.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.HoldActive), got.Status)
+			})
+		})
+	})
+}
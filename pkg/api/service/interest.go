@@ -0,0 +1,151 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+	"time"
+)
+
+type InterestService interface {
+	// Accrue accrues the interest of every account for each day up to
+	// yesterday, capitalizing it when a period ends. Days already accrued are
+	// skipped, so running it more than once a day is harmless.
+	Accrue(ctx context.Context) error
+	Accruals(ctx context.Context, accountID uuid.UUID) (dto.GetInterestResponse, error)
+}
+
+func NewInterestService(
+	repository repositories.AccountRepository,
+	accountService AccountService,
+	products map[string]model.Product,
+	clock Clock,
+) InterestService {
+	return &interestService{
+		repository:     repository,
+		accountService: accountService,
+		products:       products,
+		clock:          clock,
+	}
+}
+
+type interestService struct {
+	repository     repositories.AccountRepository
+	accountService AccountService
+	products       map[string]model.Product
+	clock          Clock
+}
+
+func (s *interestService) Accrue(ctx context.Context) error {
+	accounts, err := s.repository.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	through := startOfDay(s.clock()).AddDate(0, 0, -1)
+	for _, account := range accounts {
+		product, ok := s.products[account.Product]
+		if !ok || product.InterestRate == 0 {
+			continue
+		}
+
+		aErr := s.accountService.Apply(ctx, model.AuditInterest, []uuid.UUID{account.ID}, func(accounts []*model.Account) (repositories.Change, error) {
+			return accrue(accounts[0], product, through), nil
+		})
+		if aErr != nil {
+			return aErr
+		}
+	}
+
+	return nil
+}
+
+func (s *interestService) Accruals(ctx context.Context, accountID uuid.UUID) (dto.GetInterestResponse, error) {
+	account, gErr := s.repository.Get(ctx, accountID)
+	if gErr != nil {
+		return dto.GetInterestResponse{}, gErr
+	}
+
+	accruals, err := s.repository.Accruals(ctx, accountID)
+	if err != nil {
+		return dto.GetInterestResponse{}, err
+	}
+
+	resp := dto.GetInterestResponse{
+		AccountID:       account.ID,
+		Product:         account.Product,
+		AccruedInterest: account.AccruedInterest,
+		Accruals:        make([]dto.InterestAccrualResponse, len(accruals)),
+	}
+	if product, ok := s.products[account.Product]; ok {
+		resp.InterestRate = product.InterestRate
+	}
+	for i, accrual := range accruals {
+		resp.Accruals[i] = dto.InterestAccrualResponse{
+			Date:    accrual.Date,
+			Balance: accrual.Balance,
+			Rate:    accrual.Rate,
+			Amount:  accrual.Amount,
+		}
+	}
+
+	return resp, nil
+}
+
+// accrue accrues acc day by day up to through. At the end of every
+// capitalization period the accrued interest, truncated to cents, is credited
+// to the balance through a ledger entry; the remainder keeps accruing.
+func accrue(acc *model.Account, product model.Product, through time.Time) repositories.Change {
+	change := repositories.Change{Accounts: []*model.Account{acc}}
+	if acc.AccruedThrough == nil {
+		acc.AccruedThrough = &through
+		return change
+	}
+
+	for day := acc.AccruedThrough.AddDate(0, 0, 1); !day.After(through); day = day.AddDate(0, 0, 1) {
+		amount := 0.0
+		if acc.Amount > 0 {
+			amount = acc.Amount * product.InterestRate * product.DayCount.YearFraction(day, day.AddDate(0, 0, 1))
+		}
+		acc.AccruedInterest += amount
+		change.Accruals = append(change.Accruals, &model.InterestAccrual{
+			AccountID: acc.ID,
+			Date:      day,
+			Balance:   acc.Amount,
+			Rate:      product.InterestRate,
+			Amount:    amount,
+		})
+
+		if !product.Capitalization.IsPeriodEnd(day) {
+			continue
+		}
+		// the epsilon absorbs float errors such as 2.9999999 for 3.00
+		credit := math.Floor(acc.AccruedInterest*100+1e-6) / 100
+		if credit <= 0 {
+			continue
+		}
+		acc.Amount += credit
+		acc.AccruedInterest -= credit
+		change.Entries = append(change.Entries, &model.LedgerEntry{
+			ID:          uuid.New(),
+			AccountID:   acc.ID,
+			Type:        model.EntryInterest,
+			Amount:      credit,
+			Balance:     acc.Amount,
+			Description: fmt.Sprintf("interest for period ending %s", day.Format("2006-01-02")),
+			CreatedAt:   day.AddDate(0, 0, 1),
+		})
+	}
+
+	acc.AccruedThrough = &through
+	return change
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
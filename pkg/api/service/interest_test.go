@@ -0,0 +1,104 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestInterestService_Accrue(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 16, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	products := map[string]model.Product{
+		model.ProductCurrent: {Code: model.ProductCurrent},
+		model.ProductSavings: {
+			Code:           model.ProductSavings,
+			InterestRate:   0.0365,
+			DayCount:       model.DayCountActual365,
+			Capitalization: model.CapitalizeMonthly,
+		},
+	}
+
+	t.Run("Given a savings and a current account opened on September 1st", func(t *testing.T) {
+		accruedThrough := time.Date(2022, 8, 31, 0, 0, 0, 0, time.UTC)
+		savings := model.Account{ID: uuid.New(), Name: "billy", Amount: 1000.00, Product: model.ProductSavings, AccruedThrough: &accruedThrough}
+		require.NoError(t, repo.Create(ctx, &savings))
+		current := model.Account{ID: uuid.New(), Name: "jhon", Amount: 1000.00, Product: model.ProductCurrent, AccruedThrough: &accruedThrough}
+		require.NoError(t, repo.Create(ctx, &current))
+
+		interestService := service.NewInterestService(repo, service.NewAccountService(repo), products, clock)
+
+		t.Run("When accrual runs twice in the middle of the month", func(t *testing.T) {
+			require.NoError(t, interestService.Accrue(ctx))
+			require.NoError(t, interestService.Accrue(ctx))
+
+			t.Run("Then every day is accrued once and nothing is credited yet", func(t *testing.T) {
+				resp, err := interestService.Accruals(ctx, savings.ID)
+				require.NoError(t, err)
+				assert.Len(t, resp.Accruals, 15)
+				assert.InDelta(t, 1.50, resp.AccruedInterest, 1e-9)
+
+				acc, gErr := repo.Get(ctx, savings.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, 1000.00, acc.Amount)
+			})
+		})
+
+		t.Run("When accrual runs after the month end", func(t *testing.T) {
+			now = time.Date(2022, 10, 1, 3, 0, 0, 0, time.UTC)
+			require.NoError(t, interestService.Accrue(ctx))
+
+			t.Run("Then the interest of the month is credited through the ledger", func(t *testing.T) {
+				acc, gErr := repo.Get(ctx, savings.ID)
+				require.NoError(t, gErr)
+				assert.InDelta(t, 1003.00, acc.Amount, 1e-9)
+				assert.InDelta(t, 0.00, acc.AccruedInterest, 1e-9)
+
+				entries, eErr := repo.Entries(ctx, savings.ID)
+				require.NoError(t, eErr)
+				require.Len(t, entries, 1)
+				assert.Equal(t, model.EntryInterest, entries[0].Type)
+				assert.InDelta(t, 3.00, entries[0].Amount, 1e-9)
+			})
+
+			t.Run("Then accounts without interest are untouched", func(t *testing.T) {
+				acc, gErr := repo.Get(ctx, current.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, 1000.00, acc.Amount)
+			})
+		})
+	})
+}
+
+func TestInterestService_AccrueFromOpening(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	accService := service.NewAccountService(repo, service.WithClock(clock))
+
+	t.Run("Given a savings account opened on September 1st", func(t *testing.T) {
+		acc, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "billy", Amount: 1000.00, Product: model.ProductSavings})
+		require.NoError(t, err)
+
+		t.Run("When accrual runs in the middle of the month", func(t *testing.T) {
+			now = time.Date(2022, 9, 16, 10, 0, 0, 0, time.UTC)
+			interestService := service.NewInterestService(repo, accService, model.DefaultProducts(), clock)
+			require.NoError(t, interestService.Accrue(ctx))
+
+			t.Run("Then every day since it was opened is accrued", func(t *testing.T) {
+				resp, aErr := interestService.Accruals(ctx, acc.ID)
+				require.NoError(t, aErr)
+				assert.Len(t, resp.Accruals, 15)
+			})
+		})
+	})
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Clock returns the current time. Services take it as a dependency so tests
+// can move time at will.
+type Clock func() time.Time
+
+// RunPeriodically calls job every interval until ctx is done. Errors are
+// logged and the job is retried on the next tick. A non positive interval
+// disables the job.
+func RunPeriodically(ctx context.Context, name string, interval time.Duration, job func(ctx context.Context) error) {
+	if interval <= 0 {
+		log.Printf("%s job disabled, interval is %v", name, interval)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := job(ctx); err != nil {
+				log.Printf("error running %s job: %v", name, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package service_test
+
+import (
+	"bank/pkg/api/service"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestRunPeriodically(t *testing.T) {
+	t.Run("Given a job with no interval", func(t *testing.T) {
+		runs := 0
+		job := func(ctx context.Context) error {
+			runs++
+			return nil
+		}
+
+		t.Run("When it is run", func(t *testing.T) {
+			done := make(chan struct{})
+			go func() {
+				service.RunPeriodically(context.Background(), "test", 0, job)
+				close(done)
+			}()
+
+			t.Run("Then it returns without running the job", func(t *testing.T) {
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+					t.Fatal("job kept running")
+				}
+				assert.Zero(t, runs)
+			})
+		})
+	})
+}
@@ -0,0 +1,98 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testLimits = model.Limits{
+	{Name: "daily transfers", Scope: model.LimitPerAccount, Operation: model.LimitOnTransfer, Window: model.LimitDaily, MaxAmount: 100},
+	{Name: "monthly per customer", Scope: model.LimitPerCustomer, Window: model.LimitMonthly, MaxAmount: 200},
+	{Name: "one withdrawal a day", Scope: model.LimitPerAccount, Operation: model.LimitOnWithdrawal, Window: model.LimitDaily, MaxCount: 1},
+}
+
+func TestAccountService_Limits(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given a customer with two accounts", func(t *testing.T) {
+		first := model.Account{ID: uuid.New(), Name: "billy", CustomerID: "c-1", Amount: 1000.00}
+		require.NoError(t, repo.Create(ctx, &first))
+		second := model.Account{ID: uuid.New(), Name: "billy", CustomerID: "c-1", Amount: 1000.00}
+		require.NoError(t, repo.Create(ctx, &second))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, repo.Create(ctx, &to))
+
+		accService := service.NewAccountService(repo, service.WithLimits(testLimits), service.WithClock(clock))
+
+		t.Run("When transferring over the daily limit", func(t *testing.T) {
+			_, err := accService.Transfer(ctx, first.ID, to.ID, 60.00, "")
+			require.NoError(t, err)
+			_, err = accService.Transfer(ctx, first.ID, to.ID, 50.00, "")
+
+			t.Run("Then fails telling the limit", func(t *testing.T) {
+				assert.ErrorIs(t, err, model.ErrLimitExceeded)
+				assert.Contains(t, err.Error(), "daily transfers")
+				assertBalance(t, repo, first.ID, 940.00)
+			})
+
+			t.Run("Then the transfer is kept as failed", func(t *testing.T) {
+				transfers, lErr := accService.Transfers(ctx, first.ID)
+				require.NoError(t, lErr)
+				require.Len(t, transfers.Transfers, 2)
+				assert.Equal(t, string(model.TransferFailed), transfers.Transfers[1].Status)
+			})
+		})
+
+		t.Run("When the day changes", func(t *testing.T) {
+			now = now.Add(24 * time.Hour)
+			_, err := accService.Transfer(ctx, first.ID, to.ID, 50.00, "")
+
+			t.Run("Then the daily limit starts again", func(t *testing.T) {
+				assert.NoError(t, err)
+			})
+		})
+
+		t.Run("When another account of the customer goes over the monthly limit", func(t *testing.T) {
+			_, err := accService.Transfer(ctx, second.ID, to.ID, 95.00, "")
+
+			t.Run("Then fails adding up both accounts", func(t *testing.T) {
+				assert.ErrorIs(t, err, model.ErrLimitExceeded)
+				assert.Contains(t, err.Error(), "monthly per customer")
+			})
+		})
+
+		t.Run("When withdrawing twice the same day", func(t *testing.T) {
+			_, err := accService.Withdraw(ctx, second.ID, 10.00)
+			require.NoError(t, err)
+			_, err = accService.Withdraw(ctx, second.ID, 10.00)
+
+			t.Run("Then the second one goes over the count", func(t *testing.T) {
+				assert.ErrorIs(t, err, model.ErrLimitExceeded)
+				assertBalance(t, repo, second.ID, 990.00)
+			})
+		})
+
+		t.Run("When a batch goes over the limit on its second transfer", func(t *testing.T) {
+			now = now.AddDate(0, 1, 0)
+			_, err := accService.Batch(ctx, dto.BatchTransferRequest{Transfers: []dto.BatchTransferLeg{
+				{From: first.ID, To: to.ID, Amount: 60.00},
+				{From: first.ID, To: to.ID, Amount: 60.00},
+			}})
+
+			t.Run("Then the usage of the first one is counted", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrBatchRejected)
+				assertBalance(t, repo, first.ID, 890.00)
+			})
+		})
+	})
+}
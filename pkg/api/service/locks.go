@@ -0,0 +1,169 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultLockStripes is how many stripes accounts are locked on when no
+	// other count is given
+	DefaultLockStripes = 1024
+	// DefaultLockQueue is how many operations may wait on a stripe when no
+	// other size is given
+	DefaultLockQueue = 256
+)
+
+// ErrAccountBusy is returned when too many operations already wait on an
+// account, callers should try again later
+var ErrAccountBusy = errors.New("too many operations waiting on the account")
+
+// locker gives operations the exclusive use of the keys they work on, the
+// accounts and customers they change
+type locker interface {
+	// acquire waits until keys are all the caller's, who must call the
+	// returned release once done. It gives up when ctx is done.
+	acquire(ctx context.Context, keys []string) (func(), error)
+	stats() dto.LockStatsResponse
+}
+
+// lockCounters records how often operations waited on each other, it is safe
+// for concurrent use
+type lockCounters struct {
+	acquired  int64
+	contended int64
+	refused   int64
+	waited    int64
+}
+
+// record counts an operation that got its keys when acquired, that gave up
+// waiting for them otherwise
+func (c *lockCounters) record(acquired bool, contended bool, waited time.Duration) {
+	if acquired {
+		atomic.AddInt64(&c.acquired, 1)
+	}
+	if contended {
+		atomic.AddInt64(&c.contended, 1)
+		atomic.AddInt64(&c.waited, int64(waited))
+	}
+}
+
+func (c *lockCounters) refuse() {
+	atomic.AddInt64(&c.refused, 1)
+}
+
+func (c *lockCounters) stats() dto.LockStatsResponse {
+	return dto.LockStatsResponse{
+		Acquired:      atomic.LoadInt64(&c.acquired),
+		Contended:     atomic.LoadInt64(&c.contended),
+		Refused:       atomic.LoadInt64(&c.refused),
+		WaitedSeconds: time.Duration(atomic.LoadInt64(&c.waited)).Seconds(),
+	}
+}
+
+// slotsOf spreads keys on n slots, returning the slots of keys, each once, in
+// ascending order. Taking slots in that order keeps two operations sharing
+// some from each holding one the other waits for.
+func slotsOf(keys []string, n int) []int {
+	seen := make(map[int]bool, len(keys))
+	slots := make([]int, 0, len(keys))
+	for _, key := range keys {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		slot := int(h.Sum32() % uint32(n))
+		if !seen[slot] {
+			seen[slot] = true
+			slots = append(slots, slot)
+		}
+	}
+	sort.Ints(slots)
+	return slots
+}
+
+// lockStripes locks accounts on a fixed table of locks, each guarding the
+// accounts whose key falls on it. Operations on accounts of other stripes do
+// not wait for each other. Operations run on the caller's goroutine, the
+// stripes only decide when.
+type lockStripes struct {
+	// stripes are channels of one, unlike a sync.Mutex waiting on them can
+	// be abandoned once the caller's context is done
+	stripes []chan struct{}
+	// queue, when positive, bounds the operations waiting on a stripe, as
+	// counted in waiting
+	queue    int32
+	waiting  []int32
+	counters lockCounters
+}
+
+func newLockStripes(stripes int, queue int) *lockStripes {
+	if stripes < 1 {
+		stripes = DefaultLockStripes
+	}
+	l := &lockStripes{stripes: make([]chan struct{}, stripes), waiting: make([]int32, stripes)}
+	if queue > 0 {
+		l.queue = int32(queue)
+	}
+	for i := range l.stripes {
+		l.stripes[i] = make(chan struct{}, 1)
+	}
+	return l
+}
+
+// wait counts the caller among the operations waiting on stripe, unless the
+// queue of the stripe is full
+func (l *lockStripes) wait(stripe int) bool {
+	waiting := atomic.AddInt32(&l.waiting[stripe], 1)
+	if l.queue > 0 && waiting > l.queue {
+		atomic.AddInt32(&l.waiting[stripe], -1)
+		return false
+	}
+	return true
+}
+
+func (l *lockStripes) stopWaiting(stripe int) {
+	atomic.AddInt32(&l.waiting[stripe], -1)
+}
+
+func (l *lockStripes) acquire(ctx context.Context, keys []string) (func(), error) {
+	var held []int
+	release := func() {
+		for _, stripe := range held {
+			<-l.stripes[stripe]
+		}
+	}
+
+	contended, start := false, time.Now()
+	for _, stripe := range slotsOf(keys, len(l.stripes)) {
+		select {
+		case l.stripes[stripe] <- struct{}{}:
+		default:
+			contended = true
+			if !l.wait(stripe) {
+				release()
+				l.counters.refuse()
+				return nil, ErrAccountBusy
+			}
+			select {
+			case l.stripes[stripe] <- struct{}{}:
+				l.stopWaiting(stripe)
+			case <-ctx.Done():
+				l.stopWaiting(stripe)
+				release()
+				l.counters.record(false, true, time.Since(start))
+				return nil, ctx.Err()
+			}
+		}
+		held = append(held, stripe)
+	}
+	l.counters.record(true, contended, time.Since(start))
+	return release, nil
+}
+
+func (l *lockStripes) stats() dto.LockStatsResponse {
+	return l.counters.stats()
+}
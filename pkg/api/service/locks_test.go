@@ -0,0 +1,250 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errNothingToDo = errors.New("nothing to do")
+
+// occupy keeps accountID busy until the returned func is called
+func occupy(t *testing.T, accService service.AccountService, accountID uuid.UUID) func() {
+	t.Helper()
+	started, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		_ = accService.Apply(context.Background(), model.AuditDeposit, []uuid.UUID{accountID}, func([]*model.Account) (repositories.Change, error) {
+			close(started)
+			<-done
+			return repositories.Change{}, errNothingToDo
+		})
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		require.FailNow(t, "account not occupied")
+	}
+	return func() { close(done) }
+}
+
+func TestAccountService_Locks(t *testing.T) {
+	lockers := map[string]service.Option{
+		"bounded queue":   service.WithLockStripes(service.DefaultLockStripes, service.DefaultLockQueue),
+		"unbounded queue": service.WithLockStripes(service.DefaultLockStripes, 0),
+	}
+	for name, locks := range lockers {
+		t.Run(name, func(t *testing.T) {
+			testLocks(t, locks)
+		})
+	}
+}
+
+func testLocks(t *testing.T, locks service.Option) {
+	ctx := context.Background()
+	repo := setup(t)
+	revenueID := uuid.New()
+	require.NoError(t, service.EnsureRevenueAccount(ctx, repo, revenueID))
+	fees := model.FeeSchedule{{Name: "savings transfer fee", Event: model.FeeOnTransfer, Product: model.ProductSavings, Type: model.FeeFlat, Flat: 1.00}}
+	accService := service.NewAccountService(repo, locks, service.WithFees(fees, revenueID))
+
+	createProduct := func(t *testing.T, amount float64, product string) uuid.UUID {
+		acc, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "bill smith", Amount: amount, Product: product})
+		require.NoError(t, err)
+		return acc.ID
+	}
+	create := func(t *testing.T, amount float64) uuid.UUID {
+		return createProduct(t, amount, model.ProductCurrent)
+	}
+
+	t.Run("Given an account busy with a long operation", func(t *testing.T) {
+		busy := create(t, 100.00)
+		free := occupy(t, accService, busy)
+
+		t.Run("When it is used meanwhile", func(t *testing.T) {
+			wCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			_, err := accService.AddMoney(wCtx, busy, 10.00)
+
+			t.Run("Then the operation waits for it", func(t *testing.T) {
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+			})
+
+			t.Run("Then the wait is counted as contention", func(t *testing.T) {
+				stats := accService.LockStats(ctx)
+				assert.GreaterOrEqual(t, stats.Contended, int64(1))
+				assert.GreaterOrEqual(t, stats.WaitedSeconds, 0.05)
+			})
+		})
+
+		t.Run("When other accounts move money meanwhile", func(t *testing.T) {
+			// accounts sharing the stripe of the busy one wait for it too, a
+			// few pairs are tried so some are on other ones
+			moved := 0
+			for i := 0; i < 5; i++ {
+				from, to := create(t, 10.00), create(t, 0.00)
+				tCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+				if _, err := accService.Transfer(tCtx, from, to, 10.00, ""); err == nil {
+					moved++
+				}
+				cancel()
+			}
+
+			t.Run("Then they do not wait for it", func(t *testing.T) {
+				assert.Greater(t, moved, 0)
+			})
+		})
+
+		free()
+
+		t.Run("When it is free again", func(t *testing.T) {
+			_, err := accService.AddMoney(ctx, busy, 10.00)
+
+			t.Run("Then it can be used", func(t *testing.T) {
+				require.NoError(t, err)
+				assertBalance(t, repo, busy, 110.00)
+			})
+		})
+	})
+
+	t.Run("Given the revenue account busy with a long operation", func(t *testing.T) {
+		free := occupy(t, accService, revenueID)
+
+		t.Run("When accounts not charged fees move money meanwhile", func(t *testing.T) {
+			// as above, a few pairs are tried so some are on other stripes
+			// than the revenue account
+			moved := 0
+			for i := 0; i < 5; i++ {
+				from, to := create(t, 10.00), create(t, 0.00)
+				tCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+				if _, err := accService.Transfer(tCtx, from, to, 10.00, ""); err == nil {
+					moved++
+				}
+				cancel()
+			}
+
+			t.Run("Then they do not wait for it", func(t *testing.T) {
+				assert.Greater(t, moved, 0)
+			})
+		})
+
+		t.Run("When an account charged fees moves money meanwhile", func(t *testing.T) {
+			from, to := createProduct(t, 10.00, model.ProductSavings), create(t, 0.00)
+			tCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+			defer cancel()
+			_, err := accService.Transfer(tCtx, from, to, 5.00, "")
+
+			t.Run("Then it waits for it", func(t *testing.T) {
+				assert.ErrorIs(t, err, context.DeadlineExceeded)
+			})
+		})
+
+		free()
+
+		t.Run("When it is free again", func(t *testing.T) {
+			from, to := createProduct(t, 10.00, model.ProductSavings), create(t, 0.00)
+			_, err := accService.Transfer(ctx, from, to, 5.00, "")
+
+			t.Run("Then the fee is credited to it", func(t *testing.T) {
+				require.NoError(t, err)
+				assertBalance(t, repo, revenueID, 1.00)
+			})
+		})
+	})
+
+	t.Run("Given two accounts sending money to each other at once", func(t *testing.T) {
+		first, second := create(t, 100.00), create(t, 100.00)
+		acquired := accService.LockStats(ctx).Acquired
+
+		tCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		var wg sync.WaitGroup
+		errs := make(chan error, 40)
+		for i := 0; i < 20; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := accService.Transfer(tCtx, first, second, 1.00, "")
+				errs <- err
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := accService.Transfer(tCtx, second, first, 1.00, "")
+				errs <- err
+			}()
+		}
+		wg.Wait()
+		close(errs)
+
+		t.Run("Then every transfer completes without deadlock", func(t *testing.T) {
+			for err := range errs {
+				assert.NoError(t, err)
+			}
+			assertBalance(t, repo, first, 100.00)
+			assertBalance(t, repo, second, 100.00)
+		})
+
+		t.Run("Then every transfer is counted", func(t *testing.T) {
+			assert.Equal(t, acquired+40, accService.LockStats(ctx).Acquired)
+		})
+	})
+}
+
+func TestAccountService_Locks_Backpressure(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	// a single stripe with room for a single waiting operation
+	accService := service.NewAccountService(repo, service.WithLockStripes(1, 1))
+
+	t.Run("Given a busy stripe with an operation waiting", func(t *testing.T) {
+		busy, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "bill smith", Amount: 100.00})
+		require.NoError(t, err)
+		other, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "jane smith", Amount: 100.00})
+		require.NoError(t, err)
+		free := occupy(t, accService, busy.ID)
+
+		wCtx, cancel := context.WithCancel(ctx)
+		waited := make(chan error, 1)
+		go func() {
+			_, wErr := accService.AddMoney(wCtx, other.ID, 10.00)
+			waited <- wErr
+		}()
+
+		t.Run("When one more operation comes", func(t *testing.T) {
+			// until the waiting one is queued, this one waits in its place
+			// and gives up
+			var aErr error
+			require.Eventually(t, func() bool {
+				pCtx, pCancel := context.WithTimeout(ctx, 20*time.Millisecond)
+				defer pCancel()
+				_, aErr = accService.AddMoney(pCtx, other.ID, 10.00)
+				return !errors.Is(aErr, context.DeadlineExceeded)
+			}, time.Second, time.Millisecond)
+
+			t.Run("Then it is refused without waiting", func(t *testing.T) {
+				assert.ErrorIs(t, aErr, service.ErrAccountBusy)
+				assert.Equal(t, int64(1), accService.LockStats(ctx).Refused)
+			})
+		})
+
+		t.Run("When the waiting operation gives up", func(t *testing.T) {
+			cancel()
+			require.ErrorIs(t, <-waited, context.Canceled)
+			free()
+
+			t.Run("Then operations are accepted again", func(t *testing.T) {
+				_, aErr := accService.AddMoney(ctx, other.ID, 10.00)
+				require.NoError(t, aErr)
+				assertBalance(t, repo, other.ID, 110.00)
+			})
+		})
+	})
+}
@@ -0,0 +1,24 @@
+package service
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"log"
+)
+
+// Notifier tells an account holder about something that needs their attention
+type Notifier interface {
+	Notify(ctx context.Context, accountID uuid.UUID, message string) error
+}
+
+// NewLogNotifier returns a Notifier that only writes to the log
+func NewLogNotifier() Notifier {
+	return logNotifier{}
+}
+
+type logNotifier struct{}
+
+func (logNotifier) Notify(_ context.Context, accountID uuid.UUID, message string) error {
+	log.Printf("notification for account %s: %s", accountID, message)
+	return nil
+}
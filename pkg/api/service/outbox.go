@@ -0,0 +1,187 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+)
+
+// outboxBatchSize bounds the events read from the outbox at once
+const outboxBatchSize = 100
+
+// Broker delivers domain events to downstream systems. Publish may be called
+// more than once for the same event, consumers tell duplicates apart by its id.
+type Broker interface {
+	Publish(ctx context.Context, event *model.Event) error
+}
+
+// NewLogBroker returns a Broker that only writes to the log
+func NewLogBroker() Broker {
+	return logBroker{}
+}
+
+type logBroker struct{}
+
+func (logBroker) Publish(_ context.Context, event *model.Event) error {
+	log.Printf("event %s %s of %s: %s", event.Type, event.ID, event.AggregateID, event.Payload)
+	return nil
+}
+
+// NewBrokers returns a Broker publishing to every broker, in order. When one
+// fails the event is published again to all of them later.
+func NewBrokers(brokers ...Broker) Broker {
+	return multiBroker(brokers)
+}
+
+type multiBroker []Broker
+
+func (m multiBroker) Publish(ctx context.Context, event *model.Event) error {
+	for _, broker := range m {
+		if err := broker.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type OutboxRelay interface {
+	// Relay publishes every event waiting in the outbox, oldest first
+	Relay(ctx context.Context) error
+}
+
+func NewOutboxRelay(repository repositories.AccountRepository, broker Broker, clock Clock) OutboxRelay {
+	return &outboxRelay{
+		repository: repository,
+		broker:     broker,
+		clock:      clock,
+	}
+}
+
+type outboxRelay struct {
+	repository repositories.AccountRepository
+	broker     Broker
+	clock      Clock
+}
+
+// Relay stops at the first event the broker does not take so events are not
+// published out of order, it is tried again on the next run. An event is only
+// marked published once the broker took it, if marking it fails it is
+// published again later: delivery is at least once.
+func (r *outboxRelay) Relay(ctx context.Context) error {
+	for {
+		events, err := r.repository.UnpublishedEvents(ctx, outboxBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if pErr := r.broker.Publish(ctx, event); pErr != nil {
+				if mErr := r.repository.MarkFailed(ctx, event.ID, pErr.Error()); mErr != nil {
+					log.Printf("failed delivery of event %s could not be recorded: %v", event.ID, mErr)
+				}
+				return fmt.Errorf("publishing event %s: %w", event.ID, pErr)
+			}
+			if mErr := r.repository.MarkPublished(ctx, event.ID, r.clock()); mErr != nil {
+				return mErr
+			}
+		}
+
+		if len(events) < outboxBatchSize {
+			return nil
+		}
+	}
+}
+
+// toEventMessage returns event as partners receive it
+func toEventMessage(event *model.Event) dto.EventMessage {
+	return dto.EventMessage{
+		ID:          event.ID,
+		Type:        string(event.Type),
+		AggregateID: event.AggregateID,
+		Accounts:    event.Accounts,
+		OccurredAt:  event.OccurredAt,
+		Data:        json.RawMessage(event.Payload),
+	}
+}
+
+// newEvent builds an event about aggregateID concerning accounts, payload is
+// usually the response the api gives for it
+func newEvent(clock Clock, eventType model.EventType, aggregateID uuid.UUID, accounts []uuid.UUID, payload interface{}) *model.Event {
+	// payloads are plain dtos, they always marshal
+	content, _ := json.Marshal(payload)
+	return &model.Event{
+		ID:          uuid.New(),
+		Type:        eventType,
+		AggregateID: aggregateID,
+		Accounts:    accounts,
+		Payload:     string(content),
+		OccurredAt:  clock(),
+	}
+}
+
+// transferEvent tells the outcome of transfer from its status
+func transferEvent(clock Clock, transfer *model.Transfer) *model.Event {
+	eventType := model.EventTransferCompleted
+	switch {
+	case transfer.Status == model.TransferFailed:
+		eventType = model.EventTransferFailed
+	case transfer.Status == model.TransferInReview:
+		eventType = model.EventTransferHeldForReview
+	case transfer.ReversalOf != nil:
+		eventType = model.EventTransferReversed
+	}
+	accounts := []uuid.UUID{transfer.From, transfer.To}
+	if len(transfer.Legs) > 0 {
+		accounts = accounts[:0]
+		for _, leg := range transfer.Legs {
+			accounts = append(accounts, leg.AccountID)
+		}
+	}
+	return newEvent(clock, eventType, transfer.ID, accounts, toTransferResponse(transfer))
+}
+
+// entryEvent tells money moved in or out of an account outside of transfers
+func entryEvent(clock Clock, eventType model.EventType, entry *model.LedgerEntry) *model.Event {
+	return newEvent(clock, eventType, entry.AccountID, []uuid.UUID{entry.AccountID}, dto.MoneyMovedEvent{
+		AccountID:   entry.AccountID,
+		EntryID:     entry.ID,
+		Amount:      entry.Amount,
+		Balance:     entry.Balance,
+		Description: entry.Description,
+	})
+}
+
+var holdEvents = map[model.HoldStatus]model.EventType{
+	model.HoldActive:   model.EventHoldPlaced,
+	model.HoldCaptured: model.EventHoldCaptured,
+	model.HoldReleased: model.EventHoldReleased,
+	model.HoldExpired:  model.EventHoldExpired,
+}
+
+// changeEvents tells what a change made through Apply did: holds by their
+// status, interest credited and fees charged by their ledger entries
+func changeEvents(clock Clock, change repositories.Change) []*model.Event {
+	var events []*model.Event
+	for _, hold := range change.Holds {
+		if eventType, ok := holdEvents[hold.Status]; ok {
+			events = append(events, newEvent(clock, eventType, hold.ID, []uuid.UUID{hold.AccountID, hold.To}, toHoldResponse(hold)))
+		}
+	}
+	for _, entry := range change.Entries {
+		switch entry.Type {
+		case model.EntryInterest:
+			events = append(events, entryEvent(clock, model.EventInterestCredited, entry))
+		case model.EntryFee:
+			// the revenue account receiving it is not charged
+			if entry.Amount < 0 {
+				events = append(events, entryEvent(clock, model.EventFeeCharged, entry))
+			}
+		}
+	}
+	return events
+}
@@ -0,0 +1,115 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/service"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// fakeBroker keeps what it is given, refusing the first failures events
+type fakeBroker struct {
+	failures  int
+	published []*model.Event
+}
+
+func (b *fakeBroker) Publish(_ context.Context, event *model.Event) error {
+	if b.failures > 0 {
+		b.failures--
+		return errors.New("broker unavailable")
+	}
+	b.published = append(b.published, event)
+	return nil
+}
+
+// publishedFor returns the types of the events published about aggregateID
+func (b *fakeBroker) publishedFor(aggregateID uuid.UUID) []model.EventType {
+	var types []model.EventType
+	for _, event := range b.published {
+		if event.AggregateID == aggregateID {
+			types = append(types, event.Type)
+		}
+	}
+	return types
+}
+
+func TestOutboxRelay(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	clock := func() time.Time { return time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC) }
+	accService := service.NewAccountService(repo, service.WithClock(clock))
+
+	t.Run("Given balance changes", func(t *testing.T) {
+		from, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "bill smith", Amount: 100.00})
+		require.NoError(t, err)
+		to, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "jane smith", Amount: 0.00})
+		require.NoError(t, err)
+		_, err = accService.AddMoney(ctx, from.ID, 50.00)
+		require.NoError(t, err)
+		_, err = accService.Withdraw(ctx, from.ID, 20.00)
+		require.NoError(t, err)
+		transfer, err := accService.Transfer(ctx, from.ID, to.ID, 30.00, "rent")
+		require.NoError(t, err)
+		_, fErr := accService.Transfer(ctx, to.ID, from.ID, 1000.00, "")
+		require.Error(t, fErr)
+
+		t.Run("When the broker is down", func(t *testing.T) {
+			broker := &fakeBroker{failures: 1}
+			relay := service.NewOutboxRelay(repo, broker, clock)
+			rErr := relay.Relay(ctx)
+
+			t.Run("Then nothing is published and the attempt recorded", func(t *testing.T) {
+				assert.Error(t, rErr)
+				assert.Empty(t, broker.published)
+				events, lErr := repo.UnpublishedEvents(ctx, 1)
+				require.NoError(t, lErr)
+				require.Len(t, events, 1)
+				assert.Equal(t, 1, events[0].Attempts)
+			})
+
+			t.Run("When it is back", func(t *testing.T) {
+				require.NoError(t, relay.Relay(ctx))
+
+				t.Run("Then every change was published in order", func(t *testing.T) {
+					assert.Equal(t, []model.EventType{model.EventAccountCreated, model.EventMoneyDeposited, model.EventMoneyWithdrawn}, broker.publishedFor(from.ID))
+					assert.Equal(t, []model.EventType{model.EventTransferCompleted}, broker.publishedFor(transfer.ID))
+				})
+
+				t.Run("Then failed transfers are published too", func(t *testing.T) {
+					failed := broker.published[len(broker.published)-1]
+					assert.Equal(t, model.EventTransferFailed, failed.Type)
+				})
+
+				t.Run("Then nothing is left to publish", func(t *testing.T) {
+					events, lErr := repo.UnpublishedEvents(ctx, 10)
+					require.NoError(t, lErr)
+					assert.Empty(t, events)
+				})
+			})
+		})
+	})
+
+	t.Run("Given a hold placed through another service", func(t *testing.T) {
+		from, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "bill smith", Amount: 100.00})
+		require.NoError(t, err)
+		to, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "jane smith", Amount: 0.00})
+		require.NoError(t, err)
+		holdService := service.NewHoldService(repo, accService, clock)
+		hold, err := holdService.Place(ctx, from.ID, dto.PlaceHoldRequest{To: to.ID, Amount: 10.00})
+		require.NoError(t, err)
+		_, err = holdService.Capture(ctx, hold.ID, 0)
+		require.NoError(t, err)
+
+		t.Run("Then its events are published, the capture paying as a transfer", func(t *testing.T) {
+			broker := &fakeBroker{}
+			require.NoError(t, service.NewOutboxRelay(repo, broker, clock).Relay(ctx))
+			assert.Equal(t, []model.EventType{model.EventHoldPlaced, model.EventHoldCaptured, model.EventTransferCompleted}, broker.publishedFor(hold.ID))
+		})
+	})
+}
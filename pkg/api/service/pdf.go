@@ -0,0 +1,94 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// pdfLinesPerPage of 12pt fit an A4 page between margins of 50pt, the
+	// footer goes in the bottom one
+	pdfLinesPerPage = 60
+	pdfFontSize     = 9
+	pdfLineHeight   = 12
+	pdfPageWidth    = 595
+	pdfPageHeight   = 842
+	pdfMargin       = 50
+)
+
+// pdfDocument lays lines of text out on A4 pages in a monospaced font, which
+// is all reports need, without depending on a pdf library
+type pdfDocument struct {
+	pages []pdfPage
+}
+
+type pdfPage struct {
+	// lines from the top of the page, at most pdfLinesPerPage
+	lines []string
+	// footer is written at the bottom of the page
+	footer string
+}
+
+// write writes the document as pdf 1.4. The catalog, the page tree and the
+// font come first, then every page followed by its content.
+func (d *pdfDocument) write(w io.Writer) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	for i, page := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, 5+2*i))
+
+		var content bytes.Buffer
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin)
+		for _, line := range page.lines {
+			fmt.Fprintf(&content, "(%s) Tj T*\n", pdfText(line))
+		}
+		fmt.Fprintf(&content, "ET\nBT\n/F1 %d Tf\n%d %d Td\n(%s) Tj\nET", pdfFontSize, pdfMargin, pdfMargin/2, pdfText(page.footer))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// pdfText escapes line as a pdf string in the font encoding, characters out
+// of printable latin-1 are replaced
+func pdfText(line string) string {
+	var text strings.Builder
+	for _, r := range line {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			text.WriteByte('\\')
+			text.WriteByte(byte(r))
+		case r < ' ':
+			text.WriteByte(' ')
+		case r > 0xff || (r >= 0x7f && r < 0xa0):
+			text.WriteByte('?')
+		default:
+			text.WriteByte(byte(r))
+		}
+	}
+	return text.String()
+}
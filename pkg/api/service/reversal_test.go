@@ -0,0 +1,98 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAccountService_Reverse(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+
+	t.Run("Given a transfer of 80 charged with fees", func(t *testing.T) {
+		revenueID := uuid.New()
+		require.NoError(t, service.EnsureRevenueAccount(ctx, repo, revenueID))
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, repo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, repo.Create(ctx, &to))
+
+		accService := service.NewAccountService(repo, service.WithFees(testFees, revenueID))
+		transfer, err := accService.Transfer(ctx, from.ID, to.ID, 80.00, "")
+		require.NoError(t, err)
+		require.NotEqual(t, uuid.Nil, transfer.ID)
+		assertBalance(t, repo, from.ID, 18.20)
+
+		t.Run("When reversing 30 of it", func(t *testing.T) {
+			reversal, rErr := accService.Reverse(ctx, transfer.ID, dto.ReversalRequest{Amount: 30.00, Memo: "wrong amount"})
+			require.NoError(t, rErr)
+
+			t.Run("Then the money goes back linked to the original", func(t *testing.T) {
+				assert.Equal(t, to.ID, reversal.From)
+				assert.Equal(t, from.ID, reversal.To)
+				require.NotNil(t, reversal.ReversalOf)
+				assert.Equal(t, transfer.ID, *reversal.ReversalOf)
+				assertBalance(t, repo, from.ID, 48.20)
+				assertBalance(t, repo, to.ID, 50.00)
+			})
+
+			t.Run("Then fees are not refunded", func(t *testing.T) {
+				assertBalance(t, repo, revenueID, 1.80)
+			})
+
+			t.Run("Then it is in the history of both accounts", func(t *testing.T) {
+				history, hErr := accService.Transactions(ctx, to.ID)
+				require.NoError(t, hErr)
+				last := history.Transactions[len(history.Transactions)-1]
+				assert.Equal(t, string(model.EntryReversal), last.Type)
+				assert.Equal(t, -30.00, last.Amount)
+			})
+		})
+
+		t.Run("When reversing more than what is left", func(t *testing.T) {
+			_, rErr := accService.Reverse(ctx, transfer.ID, dto.ReversalRequest{Amount: 60.00})
+
+			t.Run("Then fails and nothing moves", func(t *testing.T) {
+				assert.ErrorIs(t, rErr, model.ErrOverReversal)
+				assertBalance(t, repo, to.ID, 50.00)
+			})
+		})
+
+		t.Run("When reversing the rest", func(t *testing.T) {
+			reversal, rErr := accService.Reverse(ctx, transfer.ID, dto.ReversalRequest{})
+			require.NoError(t, rErr)
+
+			t.Run("Then the whole amount is back", func(t *testing.T) {
+				assert.Equal(t, 50.00, reversal.Amount)
+				assertBalance(t, repo, from.ID, 98.20)
+				assertBalance(t, repo, to.ID, 0.00)
+			})
+
+			t.Run("Then nothing else can be reversed", func(t *testing.T) {
+				_, again := accService.Reverse(ctx, transfer.ID, dto.ReversalRequest{Amount: 0.01})
+				assert.ErrorIs(t, again, model.ErrOverReversal)
+			})
+
+			t.Run("Then the reversal itself can not be reversed", func(t *testing.T) {
+				_, again := accService.Reverse(ctx, reversal.ID, dto.ReversalRequest{})
+				assert.ErrorIs(t, again, service.ErrNotReversible)
+			})
+		})
+	})
+
+	t.Run("Given an unknown transfer", func(t *testing.T) {
+		accService := service.NewAccountService(repo)
+		_, err := accService.Reverse(ctx, uuid.New(), dto.ReversalRequest{})
+
+		t.Run("Then it is not found", func(t *testing.T) {
+			assert.ErrorIs(t, err, repositories.ErrTransferNotFound)
+		})
+	})
+}
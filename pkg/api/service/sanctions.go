@@ -0,0 +1,127 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+var ErrSanctioned = errors.New("blocked by sanctions screening")
+
+// sanctionsReason is all a held or denied transfer tells about a hit, which
+// entry it matched is only in the recorded check
+const sanctionsReason = "sanctions list match"
+
+// SanctionsService screens names against a sanctions watchlist, recording
+// every check for audit. As a TransferScreener it screens both counterparties
+// of a transfer, denying it on a blocking hit and holding it for review on a
+// flagging one.
+type SanctionsService interface {
+	TransferScreener
+	// CheckName screens and records name for subject
+	CheckName(ctx context.Context, subject model.SanctionsSubject, subjectID uuid.UUID, name string) (*model.SanctionsCheck, error)
+	// Checks returns the recorded checks matching filter, oldest first
+	Checks(ctx context.Context, filter repositories.SanctionsFilter) (dto.GetSanctionsChecksResponse, error)
+}
+
+func NewSanctionsService(
+	repository repositories.SanctionsRepository,
+	accounts repositories.AccountRepository,
+	watchlist model.Watchlist,
+	threshold float64,
+	clock Clock,
+) SanctionsService {
+	return &sanctionsService{
+		repository: repository,
+		accounts:   accounts,
+		watchlist:  watchlist,
+		threshold:  threshold,
+		clock:      clock,
+	}
+}
+
+type sanctionsService struct {
+	repository repositories.SanctionsRepository
+	accounts   repositories.AccountRepository
+	watchlist  model.Watchlist
+	threshold  float64
+	clock      Clock
+}
+
+func (s *sanctionsService) CheckName(ctx context.Context, subject model.SanctionsSubject, subjectID uuid.UUID, name string) (*model.SanctionsCheck, error) {
+	matches := s.watchlist.Match(name, s.threshold)
+	check := &model.SanctionsCheck{
+		ID:        uuid.New(),
+		Subject:   subject,
+		SubjectID: subjectID,
+		Name:      name,
+		Decision:  model.SanctionsDecisionOf(matches),
+		Matches:   matches,
+		CreatedAt: s.clock(),
+	}
+	if err := s.repository.Record(ctx, check); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+func (s *sanctionsService) Screen(ctx context.Context, transfer *model.Transfer) (model.Screening, error) {
+	screening := model.Screening{Decision: model.ScreeningAllow}
+	decision := model.SanctionsClear
+	for _, accountID := range parties(transfer) {
+		acc, gErr := s.accounts.Get(ctx, accountID)
+		if gErr != nil {
+			return model.Screening{}, gErr
+		}
+		check, cErr := s.CheckName(ctx, model.SanctionsTransfer, transfer.ID, acc.Name)
+		if cErr != nil {
+			return model.Screening{}, cErr
+		}
+		if check.Decision == model.SanctionsBlocked || decision == model.SanctionsClear {
+			decision = check.Decision
+		}
+	}
+
+	switch decision {
+	case model.SanctionsBlocked:
+		screening.Add(model.ScreeningDeny, sanctionsReason)
+	case model.SanctionsFlagged:
+		screening.Add(model.ScreeningReview, sanctionsReason)
+	}
+	return screening, nil
+}
+
+func (s *sanctionsService) Checks(ctx context.Context, filter repositories.SanctionsFilter) (dto.GetSanctionsChecksResponse, error) {
+	checks, err := s.repository.List(ctx, filter)
+	if err != nil {
+		return dto.GetSanctionsChecksResponse{}, err
+	}
+
+	resp := make([]dto.SanctionsCheckResponse, len(checks))
+	for i, check := range checks {
+		matches := make([]dto.SanctionsMatchResponse, len(check.Matches))
+		for j, match := range check.Matches {
+			matches[j] = dto.SanctionsMatchResponse{
+				EntryID: match.EntryID,
+				List:    match.List,
+				Name:    match.Name,
+				Score:   match.Score,
+				Action:  string(match.Action),
+			}
+		}
+		resp[i] = dto.SanctionsCheckResponse{
+			ID:        check.ID,
+			Subject:   string(check.Subject),
+			SubjectID: check.SubjectID,
+			Name:      check.Name,
+			Decision:  string(check.Decision),
+			Matches:   matches,
+			CreatedAt: check.CreatedAt,
+		}
+	}
+
+	return dto.GetSanctionsChecksResponse{Checks: resp}, nil
+}
@@ -0,0 +1,134 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testWatchlist = model.Watchlist{
+	{ID: "1", Name: "Ivan Drago", List: "test", Action: model.WatchlistBlock},
+	{ID: "2", Name: "Apollo Creed", List: "test", Action: model.WatchlistFlag},
+}
+
+func TestAccountService_Sanctions(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	clock := func() time.Time { return time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC) }
+
+	t.Run("Given names screened against a watchlist", func(t *testing.T) {
+		checks := repositories.NewMemorySanctionsRepository()
+		sanctions := service.NewSanctionsService(checks, repo, testWatchlist, model.DefaultMatchThreshold, clock)
+		accService := service.NewAccountService(repo, service.WithSanctions(sanctions), service.WithClock(clock))
+
+		t.Run("When opening an account for a blocked name", func(t *testing.T) {
+			_, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "Ivan  Dragoo", Amount: 10.00})
+
+			t.Run("Then it is refused and the hit recorded", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrSanctioned)
+
+				blocked, lErr := sanctions.Checks(ctx, repositories.SanctionsFilter{Decision: model.SanctionsBlocked})
+				require.NoError(t, lErr)
+				require.Len(t, blocked.Checks, 1)
+				assert.Equal(t, string(model.SanctionsAccount), blocked.Checks[0].Subject)
+				assert.Equal(t, "1", blocked.Checks[0].Matches[0].EntryID)
+			})
+		})
+
+		t.Run("When opening an account for a clear name", func(t *testing.T) {
+			resp, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "Rocky Balboa", Amount: 100.00})
+			require.NoError(t, err)
+
+			t.Run("Then it is opened and the check recorded", func(t *testing.T) {
+				recorded, lErr := sanctions.Checks(ctx, repositories.SanctionsFilter{SubjectID: resp.ID})
+				require.NoError(t, lErr)
+				require.Len(t, recorded.Checks, 1)
+				assert.Equal(t, string(model.SanctionsClear), recorded.Checks[0].Decision)
+			})
+		})
+
+		t.Run("When transferring to an account flagged after it was opened", func(t *testing.T) {
+			from := model.Account{ID: uuid.New(), Name: "Rocky Balboa", Amount: 100.00}
+			require.NoError(t, repo.Create(ctx, &from))
+			flagged := model.Account{ID: uuid.New(), Name: "Apollo Creed", Amount: 0.00}
+			require.NoError(t, repo.Create(ctx, &flagged))
+			blocked := model.Account{ID: uuid.New(), Name: "Ivan Drago", Amount: 0.00}
+			require.NoError(t, repo.Create(ctx, &blocked))
+
+			held, err := accService.Transfer(ctx, from.ID, flagged.ID, 10.00, "")
+			require.NoError(t, err)
+			_, dErr := accService.Transfer(ctx, from.ID, blocked.ID, 10.00, "")
+
+			t.Run("Then a flagged counterparty holds it for review", func(t *testing.T) {
+				assert.Equal(t, string(model.TransferInReview), held.Status)
+				assert.Equal(t, "sanctions list match", held.ScreeningReason)
+				assertBalance(t, repo, flagged.ID, 0.00)
+			})
+
+			t.Run("Then a blocked counterparty denies it", func(t *testing.T) {
+				assert.ErrorIs(t, dErr, service.ErrTransferDenied)
+				assertBalance(t, repo, from.ID, 100.00)
+			})
+
+			t.Run("Then both counterparties are recorded for the transfer", func(t *testing.T) {
+				recorded, lErr := sanctions.Checks(ctx, repositories.SanctionsFilter{SubjectID: held.ID})
+				require.NoError(t, lErr)
+				require.Len(t, recorded.Checks, 2)
+				assert.Equal(t, string(model.SanctionsClear), recorded.Checks[0].Decision)
+				assert.Equal(t, string(model.SanctionsFlagged), recorded.Checks[1].Decision)
+			})
+		})
+
+		t.Run("When a split transfer pays a blocked account", func(t *testing.T) {
+			from := model.Account{ID: uuid.New(), Name: "Rocky Balboa", Amount: 100.00}
+			require.NoError(t, repo.Create(ctx, &from))
+			landlord := model.Account{ID: uuid.New(), Name: "Adrian Pennino", Amount: 0.00}
+			require.NoError(t, repo.Create(ctx, &landlord))
+			blocked := model.Account{ID: uuid.New(), Name: "Ivan Drago", Amount: 0.00}
+			require.NoError(t, repo.Create(ctx, &blocked))
+
+			_, err := accService.Split(ctx, dto.SplitTransferRequest{
+				Debits:  []dto.SplitLeg{{Account: from.ID, Amount: 20.00}},
+				Credits: []dto.SplitLeg{{Account: landlord.ID, Amount: 10.00}, {Account: blocked.ID, Amount: 10.00}},
+			})
+
+			t.Run("Then it is denied and nothing moves", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrTransferDenied)
+				assertBalance(t, repo, from.ID, 100.00)
+				assertBalance(t, repo, landlord.ID, 0.00)
+				assertBalance(t, repo, blocked.ID, 0.00)
+			})
+		})
+
+		t.Run("When a hold is captured by a flagged account", func(t *testing.T) {
+			from := model.Account{ID: uuid.New(), Name: "Rocky Balboa", Amount: 100.00}
+			require.NoError(t, repo.Create(ctx, &from))
+			flagged := model.Account{ID: uuid.New(), Name: "Apollo Creed", Amount: 0.00}
+			require.NoError(t, repo.Create(ctx, &flagged))
+
+			holdService := service.NewHoldService(repo, accService, clock)
+			hold, err := holdService.Place(ctx, from.ID, dto.PlaceHoldRequest{To: flagged.ID, Amount: 10.00})
+			require.NoError(t, err)
+			_, cErr := holdService.Capture(ctx, hold.ID, 0)
+
+			t.Run("Then it is refused as it can not wait for an operator", func(t *testing.T) {
+				assert.ErrorIs(t, cErr, service.ErrHeldForReview)
+				assertBalance(t, repo, flagged.ID, 0.00)
+			})
+
+			t.Run("Then the counterparties are recorded for the hold", func(t *testing.T) {
+				recorded, lErr := sanctions.Checks(ctx, repositories.SanctionsFilter{SubjectID: hold.ID})
+				require.NoError(t, lErr)
+				require.Len(t, recorded.Checks, 2)
+				assert.Equal(t, string(model.SanctionsFlagged), recorded.Checks[1].Decision)
+			})
+		})
+	})
+}
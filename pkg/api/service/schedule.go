@@ -0,0 +1,234 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+const (
+	// scheduleBatchSize bounds the transfers claimed on every run
+	scheduleBatchSize = 100
+	// scheduleClaimTimeout is how long a transfer may stay executing before
+	// its claim is taken as abandoned, by a crash for instance, and taken again
+	scheduleClaimTimeout = 15 * time.Minute
+)
+
+var ErrInvalidSchedule = errors.New("scheduled transfer must be executed in the future")
+
+type ScheduleService interface {
+	Schedule(ctx context.Context, req dto.TransferenceRequest) (dto.ScheduledTransferResponse, error)
+	ListPending(ctx context.Context, accountID uuid.UUID) (dto.GetScheduledTransfersResponse, error)
+	Cancel(ctx context.Context, transferID uuid.UUID) error
+	// ExecuteDue executes every pending transfer whose date has been reached
+	ExecuteDue(ctx context.Context) error
+}
+
+func NewScheduleService(
+	repository repositories.ScheduledTransferRepository,
+	accounts repositories.AccountRepository,
+	accountService AccountService,
+	clock Clock,
+) ScheduleService {
+	return &scheduleService{
+		repository:     repository,
+		accounts:       accounts,
+		accountService: accountService,
+		clock:          clock,
+	}
+}
+
+type scheduleService struct {
+	repository     repositories.ScheduledTransferRepository
+	accounts       repositories.AccountRepository
+	accountService AccountService
+	clock          Clock
+}
+
+func (s *scheduleService) Schedule(ctx context.Context, req dto.TransferenceRequest) (dto.ScheduledTransferResponse, error) {
+	if req.From == req.To || req.Amount <= 0 {
+		return dto.ScheduledTransferResponse{}, ErrInconsistentData
+	}
+
+	now := s.clock()
+	if req.ExecuteAt == nil || !req.ExecuteAt.After(now) {
+		return dto.ScheduledTransferResponse{}, ErrInvalidSchedule
+	}
+
+	// fail early if any of the accounts does not exist
+	if _, fErr := s.accounts.Get(ctx, req.From); fErr != nil {
+		return dto.ScheduledTransferResponse{}, fErr
+	}
+	if _, tErr := s.accounts.Get(ctx, req.To); tErr != nil {
+		return dto.ScheduledTransferResponse{}, tErr
+	}
+
+	transfer := &model.ScheduledTransfer{
+		ID:        uuid.New(),
+		From:      req.From,
+		To:        req.To,
+		Amount:    req.Amount,
+		ExecuteAt: *req.ExecuteAt,
+		Status:    model.ScheduledPending,
+		CreatedAt: now,
+	}
+	if cErr := s.repository.Create(ctx, transfer); cErr != nil {
+		return dto.ScheduledTransferResponse{}, cErr
+	}
+	audit(ctx, s.accounts, newAuditRecord(ctx, s.clock, model.AuditScheduledCreated, AuditEntityScheduled, transfer.ID, nil, &auditState{Scheduled: transfer}))
+
+	return toScheduledResponse(transfer), nil
+}
+
+func (s *scheduleService) ListPending(ctx context.Context, accountID uuid.UUID) (dto.GetScheduledTransfersResponse, error) {
+	transfers, err := s.repository.ListPending(ctx, accountID)
+	if err != nil {
+		return dto.GetScheduledTransfersResponse{}, err
+	}
+
+	resp := make([]dto.ScheduledTransferResponse, len(transfers))
+	for i := range transfers {
+		resp[i] = toScheduledResponse(transfers[i])
+	}
+
+	return dto.GetScheduledTransfersResponse{Transfers: resp}, nil
+}
+
+func (s *scheduleService) Cancel(ctx context.Context, transferID uuid.UUID) error {
+	before, gErr := s.repository.Get(ctx, transferID)
+	if gErr != nil {
+		return gErr
+	}
+	if cErr := s.repository.Cancel(ctx, transferID); cErr != nil {
+		return cErr
+	}
+
+	after := *before
+	after.Status = model.ScheduledCancelled
+	audit(ctx, s.accounts, newAuditRecord(ctx, s.clock, model.AuditScheduledCancelled, AuditEntityScheduled, transferID, &auditState{Scheduled: before}, &auditState{Scheduled: &after}))
+	return nil
+}
+
+func (s *scheduleService) ExecuteDue(ctx context.Context) error {
+	now := s.clock()
+	due, err := s.repository.ClaimDue(ctx, now, now.Add(-scheduleClaimTimeout), scheduleBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, transfer := range due {
+		s.execute(ctx, transfer)
+		if transfer.Status != model.ScheduledPending {
+			executedAt := s.clock()
+			transfer.ExecutedAt = &executedAt
+		}
+
+		// the outcome is recorded even when ctx is already done, otherwise
+		// the transfer would be left executing until its claim goes stale
+		if fErr := s.repository.Finish(context.Background(), transfer); fErr != nil {
+			log.Printf("outcome of scheduled transfer %s could not be stored: %v", transfer.ID, fErr)
+		}
+	}
+
+	return nil
+}
+
+// execute makes the transfer of a claimed scheduled transfer and sets its outcome
+func (s *scheduleService) execute(ctx context.Context, transfer *model.ScheduledTransfer) {
+	if ctx.Err() != nil {
+		// not executed, give it back so the next run picks it up
+		transfer.Status = model.ScheduledPending
+		return
+	}
+
+	// the transfer made takes the id of the scheduled transfer
+	resp, tErr := once(ctx, s.accounts, transfer.ID, transfer.Claims > 1, func(ctx context.Context) (dto.TransferResponse, error) {
+		return s.accountService.Transfer(ctx, transfer.From, transfer.To, transfer.Amount, "scheduled transfer "+transfer.ID.String())
+	})
+
+	switch {
+	case errors.Is(tErr, errOutcomeUnknown):
+		// not known whether it was made, give it back to find out next run
+		transfer.Status = model.ScheduledPending
+	case tErr != nil:
+		transfer.Status = model.ScheduledFailed
+		transfer.FailureReason = tErr.Error()
+	case resp.Status == string(model.TransferInReview):
+		transfer.Status = model.ScheduledHeld
+	default:
+		transfer.Status = model.ScheduledCompleted
+	}
+}
+
+var (
+	errNotMade        = errors.New("transfer not made")
+	errOutcomeUnknown = errors.New("outcome of the transfer unknown")
+)
+
+type transferIDKey struct{}
+
+// withTransferID makes the transfer made with ctx take id, so a job making it
+// again is refused with repositories.ErrTransferExists
+func withTransferID(ctx context.Context, id uuid.UUID) context.Context {
+	return context.WithValue(ctx, transferIDKey{}, id)
+}
+
+// transferID returns the id given with withTransferID, a new one otherwise
+func transferID(ctx context.Context) uuid.UUID {
+	if id, ok := ctx.Value(transferIDKey{}).(uuid.UUID); ok {
+		return id
+	}
+	return uuid.New()
+}
+
+// once makes the transfer of a job with id by calling transfer, unless a
+// claim of the job made it before, when reclaimed, or meanwhile, the stored
+// one is returned then. errOutcomeUnknown when it can not be told.
+func once(ctx context.Context, accounts repositories.AccountRepository, id uuid.UUID, reclaimed bool, transfer func(ctx context.Context) (dto.TransferResponse, error)) (dto.TransferResponse, error) {
+	if reclaimed {
+		// an earlier claim was abandoned, maybe after moving the money
+		if resp, err := made(ctx, accounts, id); !errors.Is(err, errNotMade) {
+			return resp, err
+		}
+	}
+	resp, err := transfer(withTransferID(ctx, id))
+	if errors.Is(err, repositories.ErrTransferExists) {
+		// a claim still running made it first
+		return made(ctx, accounts, id)
+	}
+	return resp, err
+}
+
+// made returns the transfer with id, errNotMade when there is none. A failed
+// one comes with its failure as error.
+func made(ctx context.Context, accounts repositories.AccountRepository, id uuid.UUID) (dto.TransferResponse, error) {
+	transfer, err := accounts.GetTransfer(ctx, id)
+	if errors.Is(err, repositories.ErrTransferNotFound) {
+		return dto.TransferResponse{}, errNotMade
+	}
+	if err != nil {
+		return dto.TransferResponse{}, fmt.Errorf("%w: %v", errOutcomeUnknown, err)
+	}
+	if transfer.Status == model.TransferFailed {
+		return toTransferResponse(transfer), errors.New(transfer.FailureReason)
+	}
+	return toTransferResponse(transfer), nil
+}
+
+func toScheduledResponse(transfer *model.ScheduledTransfer) dto.ScheduledTransferResponse {
+	return dto.ScheduledTransferResponse{
+		ID:            transfer.ID,
+		From:          transfer.From,
+		To:            transfer.To,
+		Amount:        transfer.Amount,
+		ExecuteAt:     transfer.ExecuteAt,
+		Status:        string(transfer.Status),
+		FailureReason: transfer.FailureReason,
+	}
+}
@@ -0,0 +1,297 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduleService_ExecuteDue(t *testing.T) {
+	ctx := context.Background()
+	accRepo := setup(t)
+	now := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given two existing accounts", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, accRepo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 100.00}
+		require.NoError(t, accRepo.Create(ctx, &to))
+
+		t.Run("And a transfer scheduled for tomorrow", func(t *testing.T) {
+			accService := service.NewAccountService(accRepo)
+			schService := service.NewScheduleService(repositories.NewMemoryScheduledRepository(), accRepo, accService, clock)
+
+			executeAt := now.Add(24 * time.Hour)
+			scheduled, err := schService.Schedule(ctx, dto.TransferenceRequest{From: from.ID, To: to.ID, Amount: 30.00, ExecuteAt: &executeAt})
+			require.NoError(t, err)
+
+			t.Run("When the scheduler runs before the date", func(t *testing.T) {
+				require.NoError(t, schService.ExecuteDue(ctx))
+
+				t.Run("Then nothing is transferred", func(t *testing.T) {
+					current, gErr := accRepo.Get(ctx, from.ID)
+					require.NoError(t, gErr)
+					assert.Equal(t, 100.00, current.Amount)
+				})
+			})
+
+			t.Run("When several schedulers run after the date at the same moment", func(t *testing.T) {
+				now = executeAt.Add(time.Minute)
+				signal := make(chan int)
+				var wg sync.WaitGroup
+				for i := 0; i < 5; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						<-signal
+						assert.NoError(t, schService.ExecuteDue(ctx))
+					}()
+				}
+				close(signal)
+				wg.Wait()
+
+				t.Run("Then the transfer is executed exactly once", func(t *testing.T) {
+					fromCurrent, fErr := accRepo.Get(ctx, from.ID)
+					require.NoError(t, fErr)
+					toCurrent, tErr := accRepo.Get(ctx, to.ID)
+					require.NoError(t, tErr)
+					assert.Equal(t, 70.00, fromCurrent.Amount)
+					assert.Equal(t, 130.00, toCurrent.Amount)
+				})
+
+				t.Run("Then it is no longer pending and can not be cancelled", func(t *testing.T) {
+					pending, lErr := schService.ListPending(ctx, from.ID)
+					require.NoError(t, lErr)
+					assert.Empty(t, pending.Transfers)
+					assert.ErrorIs(t, schService.Cancel(ctx, scheduled.ID), repositories.ErrScheduledTransferNotPending)
+				})
+			})
+		})
+	})
+}
+
+func TestScheduleService_Cancel(t *testing.T) {
+	ctx := context.Background()
+	accRepo := setup(t)
+	now := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given a scheduled transfer", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, accRepo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 100.00}
+		require.NoError(t, accRepo.Create(ctx, &to))
+
+		schService := service.NewScheduleService(repositories.NewMemoryScheduledRepository(), accRepo, service.NewAccountService(accRepo), clock)
+		executeAt := now.Add(time.Hour)
+		scheduled, err := schService.Schedule(ctx, dto.TransferenceRequest{From: from.ID, To: to.ID, Amount: 30.00, ExecuteAt: &executeAt})
+		require.NoError(t, err)
+
+		t.Run("When it is cancelled before the date", func(t *testing.T) {
+			require.NoError(t, schService.Cancel(ctx, scheduled.ID))
+			now = executeAt.Add(time.Minute)
+			require.NoError(t, schService.ExecuteDue(ctx))
+
+			t.Run("Then it is never executed", func(t *testing.T) {
+				current, gErr := accRepo.Get(ctx, from.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, 100.00, current.Amount)
+			})
+		})
+	})
+
+	t.Run("When scheduling a transfer in the past", func(t *testing.T) {
+		executeAt := now.Add(-time.Hour)
+		schService := service.NewScheduleService(repositories.NewMemoryScheduledRepository(), accRepo, service.NewAccountService(accRepo), clock)
+		_, err := schService.Schedule(ctx, dto.TransferenceRequest{From: uuid.New(), To: uuid.New(), Amount: 30.00, ExecuteAt: &executeAt})
+
+		t.Run("Then fails", func(t *testing.T) {
+			assert.ErrorIs(t, err, service.ErrInvalidSchedule)
+		})
+	})
+}
+
+func TestScheduleService_Screening(t *testing.T) {
+	ctx := context.Background()
+	accRepo := setup(t)
+	now := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given a transfer scheduled to a beneficiary screened for review", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, accRepo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, accRepo.Create(ctx, &to))
+
+		screener := service.NewRulesScreener(accRepo, testScreeningRules, clock)
+		accService := service.NewAccountService(accRepo, service.WithScreener(screener), service.WithClock(clock))
+		schRepo := repositories.NewMemoryScheduledRepository()
+		schService := service.NewScheduleService(schRepo, accRepo, accService, clock)
+
+		executeAt := now.Add(time.Hour)
+		scheduled, err := schService.Schedule(ctx, dto.TransferenceRequest{From: from.ID, To: to.ID, Amount: 60.00, ExecuteAt: &executeAt})
+		require.NoError(t, err)
+
+		t.Run("When it is executed", func(t *testing.T) {
+			now = executeAt.Add(time.Minute)
+			require.NoError(t, schService.ExecuteDue(ctx))
+
+			t.Run("Then it is held rather than completed and no money moves", func(t *testing.T) {
+				got, gErr := schRepo.Get(ctx, scheduled.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, model.ScheduledHeld, got.Status)
+				assertBalance(t, accRepo, from.ID, 100.00)
+				assertBalance(t, accRepo, to.ID, 0.00)
+			})
+		})
+	})
+}
+
+// unfinishable fails to store the outcome of the transfer it is given
+type unfinishable struct {
+	repositories.ScheduledTransferRepository
+	transferID uuid.UUID
+}
+
+func (u *unfinishable) Finish(ctx context.Context, transfer *model.ScheduledTransfer) error {
+	if transfer.ID == u.transferID {
+		return errors.New("database gone")
+	}
+	return u.ScheduledTransferRepository.Finish(ctx, transfer)
+}
+
+// racing makes every transfer twice, as a claim still running and the one
+// that took it over would
+type racing struct {
+	service.AccountService
+}
+
+func (r *racing) Transfer(ctx context.Context, from uuid.UUID, to uuid.UUID, amount float64, memo string) (dto.TransferResponse, error) {
+	if _, err := r.AccountService.Transfer(ctx, from, to, amount, memo); err != nil {
+		return dto.TransferResponse{}, err
+	}
+	return r.AccountService.Transfer(ctx, from, to, amount, memo)
+}
+
+func TestScheduleService_Recovery(t *testing.T) {
+	ctx := context.Background()
+	accRepo := setup(t)
+	now := time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given two due transfers, the outcome of the first one failing to be stored", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, accRepo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, accRepo.Create(ctx, &to))
+
+		schRepo := &unfinishable{ScheduledTransferRepository: repositories.NewMemoryScheduledRepository()}
+		schService := service.NewScheduleService(schRepo, accRepo, service.NewAccountService(accRepo), clock)
+		first := now.Add(time.Hour)
+		unfinished, err := schService.Schedule(ctx, dto.TransferenceRequest{From: from.ID, To: to.ID, Amount: 30.00, ExecuteAt: &first})
+		require.NoError(t, err)
+		second := first.Add(time.Minute)
+		finished, err := schService.Schedule(ctx, dto.TransferenceRequest{From: from.ID, To: to.ID, Amount: 20.00, ExecuteAt: &second})
+		require.NoError(t, err)
+		schRepo.transferID = unfinished.ID
+
+		t.Run("When the scheduler runs", func(t *testing.T) {
+			now = second.Add(time.Minute)
+			require.NoError(t, schService.ExecuteDue(ctx))
+
+			t.Run("Then the rest of the batch is still executed", func(t *testing.T) {
+				got, gErr := schRepo.Get(ctx, finished.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, model.ScheduledCompleted, got.Status)
+				assertBalance(t, accRepo, to.ID, 50.00)
+			})
+
+			t.Run("Then the first one is left executing", func(t *testing.T) {
+				got, gErr := schRepo.Get(ctx, unfinished.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, model.ScheduledExecuting, got.Status)
+			})
+		})
+
+		t.Run("When the scheduler runs once its claim is stale", func(t *testing.T) {
+			schRepo.transferID = uuid.Nil
+			now = now.Add(time.Hour)
+			require.NoError(t, schService.ExecuteDue(ctx))
+
+			t.Run("Then it is completed without moving the money again", func(t *testing.T) {
+				got, gErr := schRepo.Get(ctx, unfinished.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, model.ScheduledCompleted, got.Status)
+				assertBalance(t, accRepo, from.ID, 50.00)
+				assertBalance(t, accRepo, to.ID, 50.00)
+			})
+		})
+	})
+
+	t.Run("Given a transfer whose claim was abandoned before executing it", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, accRepo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, accRepo.Create(ctx, &to))
+
+		schRepo := repositories.NewMemoryScheduledRepository()
+		schService := service.NewScheduleService(schRepo, accRepo, service.NewAccountService(accRepo), clock)
+		executeAt := now.Add(time.Hour)
+		scheduled, err := schService.Schedule(ctx, dto.TransferenceRequest{From: from.ID, To: to.ID, Amount: 30.00, ExecuteAt: &executeAt})
+		require.NoError(t, err)
+		now = executeAt
+		_, cErr := schRepo.ClaimDue(ctx, now, now, 10)
+		require.NoError(t, cErr)
+
+		t.Run("When the scheduler runs once its claim is stale", func(t *testing.T) {
+			now = now.Add(time.Hour)
+			require.NoError(t, schService.ExecuteDue(ctx))
+
+			t.Run("Then it is executed", func(t *testing.T) {
+				got, gErr := schRepo.Get(ctx, scheduled.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, model.ScheduledCompleted, got.Status)
+				assertBalance(t, accRepo, to.ID, 30.00)
+			})
+		})
+	})
+
+	t.Run("Given a transfer whose stale claim is still running", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, accRepo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, accRepo.Create(ctx, &to))
+
+		schRepo := repositories.NewMemoryScheduledRepository()
+		schService := service.NewScheduleService(schRepo, accRepo, &racing{service.NewAccountService(accRepo)}, clock)
+		executeAt := now.Add(time.Hour)
+		scheduled, err := schService.Schedule(ctx, dto.TransferenceRequest{From: from.ID, To: to.ID, Amount: 30.00, ExecuteAt: &executeAt})
+		require.NoError(t, err)
+		now = executeAt
+		_, cErr := schRepo.ClaimDue(ctx, now, now, 10)
+		require.NoError(t, cErr)
+
+		t.Run("When it is claimed again and both claims make the transfer", func(t *testing.T) {
+			now = now.Add(time.Hour)
+			require.NoError(t, schService.ExecuteDue(ctx))
+
+			t.Run("Then the money is moved once", func(t *testing.T) {
+				got, gErr := schRepo.Get(ctx, scheduled.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, model.ScheduledCompleted, got.Status)
+				assertBalance(t, accRepo, from.ID, 70.00)
+				assertBalance(t, accRepo, to.ID, 30.00)
+			})
+		})
+	})
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTransferDenied = errors.New("transfer denied by screening")
+	// ErrHeldForReview is returned where a transfer held by screening can not
+	// wait for an operator, such as inside an atomic batch
+	ErrHeldForReview       = errors.New("transfer held for review")
+	ErrTransferNotInReview = errors.New("transfer is not waiting for review")
+)
+
+// TransferScreener decides if a transfer may go ahead before its money moves
+type TransferScreener interface {
+	Screen(ctx context.Context, transfer *model.Transfer) (model.Screening, error)
+}
+
+// NewRulesScreener screens transfers with fraud rules, looking at the
+// transfers previously sent by the same account
+func NewRulesScreener(repository repositories.AccountRepository, rules model.ScreeningRules, clock Clock) TransferScreener {
+	screener := &rulesScreener{
+		repository: repository,
+		rules:      rules,
+		clock:      clock,
+	}
+	for _, rule := range rules {
+		switch rule.Type {
+		case model.ScreenVelocity:
+			screener.velocity = true
+			if window := time.Duration(rule.WindowMinutes) * time.Minute; window > screener.window {
+				screener.window = window
+			}
+		case model.ScreenNewBeneficiary:
+			screener.beneficiary = true
+		}
+	}
+	return screener
+}
+
+type rulesScreener struct {
+	repository repositories.AccountRepository
+	rules      model.ScreeningRules
+	clock      Clock
+	// velocity rules look at the transfers sent within window, the longest
+	// of theirs, beneficiary ones at those completed to the same recipient
+	velocity    bool
+	window      time.Duration
+	beneficiary bool
+}
+
+func (r *rulesScreener) Screen(ctx context.Context, transfer *model.Transfer) (model.Screening, error) {
+	screening := model.Screening{Decision: model.ScreeningAllow}
+	seen := make(map[string]bool)
+	now := r.clock()
+	for _, part := range sentParts(transfer) {
+		history, err := r.history(ctx, part, now)
+		if err != nil {
+			return model.Screening{}, err
+		}
+		s := r.rules.Screen(part, history, now)
+		for _, reason := range s.Reasons {
+			if !seen[reason] {
+				seen[reason] = true
+				screening.Add(s.Decision, reason)
+			}
+		}
+	}
+	return screening, nil
+}
+
+// history returns the transfers previously sent by the sender of part the
+// rules look at, rather than all of them
+func (r *rulesScreener) history(ctx context.Context, part *model.Transfer, now time.Time) ([]*model.Transfer, error) {
+	var history []*model.Transfer
+	seen := make(map[uuid.UUID]bool)
+	add := func(filter repositories.SentFilter) error {
+		transfers, err := r.repository.SentTransfers(ctx, filter)
+		for _, transfer := range transfers {
+			if !seen[transfer.ID] {
+				seen[transfer.ID] = true
+				history = append(history, transfer)
+			}
+		}
+		return err
+	}
+
+	if r.velocity {
+		if err := add(repositories.SentFilter{From: part.From, Since: now.Add(-r.window)}); err != nil {
+			return nil, err
+		}
+	}
+	if r.beneficiary {
+		// a single one tells the recipient is known
+		if err := add(repositories.SentFilter{From: part.From, To: part.To, Status: model.TransferCompleted, Limit: 1}); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
+
+// sentParts returns what every sender of transfer sends to every recipient,
+// as transfers with a single sender and recipient rules can look at: the
+// transfer itself, or one per debit and credit leg of a split transfer moving
+// the amount of the debit
+func sentParts(transfer *model.Transfer) []*model.Transfer {
+	if len(transfer.Legs) == 0 {
+		return []*model.Transfer{transfer}
+	}
+	var parts []*model.Transfer
+	for _, debit := range transfer.Legs {
+		if debit.Amount >= 0 {
+			continue
+		}
+		for _, credit := range transfer.Legs {
+			if credit.Amount <= 0 {
+				continue
+			}
+			part := *transfer
+			part.From, part.To, part.Amount, part.Legs = debit.AccountID, credit.AccountID, -debit.Amount, nil
+			parts = append(parts, &part)
+		}
+	}
+	return parts
+}
+
+// parties returns every account sending or receiving money in transfer
+func parties(transfer *model.Transfer) []uuid.UUID {
+	if len(transfer.Legs) == 0 {
+		return []uuid.UUID{transfer.From, transfer.To}
+	}
+	accountIDs := make([]uuid.UUID, len(transfer.Legs))
+	for i, leg := range transfer.Legs {
+		accountIDs[i] = leg.AccountID
+	}
+	return accountIDs
+}
+
+// screen runs every screener on a pending transfer. A denied transfer is
+// marked failed and a held one in review, both come back with an error.
+func (a *accountService) screen(ctx context.Context, transfer *model.Transfer) error {
+	screening := model.Screening{Decision: model.ScreeningAllow}
+	for _, screener := range a.screeners {
+		s, err := screener.Screen(ctx, transfer)
+		if err != nil {
+			return err
+		}
+		for _, reason := range s.Reasons {
+			screening.Add(s.Decision, reason)
+		}
+	}
+
+	reasons := strings.Join(screening.Reasons, ", ")
+	switch screening.Decision {
+	case model.ScreeningDeny:
+		dErr := fmt.Errorf("%w: %s", ErrTransferDenied, reasons)
+		transfer.Status = model.TransferFailed
+		transfer.FailureReason = dErr.Error()
+		transfer.ScreeningReason = reasons
+		return dErr
+	case model.ScreeningReview:
+		transfer.Status = model.TransferInReview
+		transfer.ScreeningReason = reasons
+		return ErrHeldForReview
+	}
+	return nil
+}
+
+func (a *accountService) ReviewQueue(ctx context.Context) (dto.GetTransfersResponse, error) {
+	transfers, err := a.repository.TransfersByStatus(ctx, model.TransferInReview)
+	if err != nil {
+		return dto.GetTransfersResponse{}, err
+	}
+
+	resp := make([]dto.TransferResponse, len(transfers))
+	for i := range transfers {
+		resp[i] = toTransferResponse(transfers[i])
+	}
+
+	return dto.GetTransfersResponse{Transfers: resp}, nil
+}
+
+func (a *accountService) Approve(ctx context.Context, transferID uuid.UUID) (dto.TransferResponse, error) {
+	// the accounts of a transfer never change, they are read before locking
+	// them
+	locked, lgErr := a.repository.GetTransfer(ctx, transferID)
+	if lgErr != nil {
+		return dto.TransferResponse{}, lgErr
+	}
+
+	// used for pessimistic locking
+	release, lErr := a.acquire(ctx, a.charged(ctx, []uuid.UUID{locked.From, locked.To}, debit{locked.From, locked.Amount})...)
+	if lErr != nil {
+		return dto.TransferResponse{}, lErr
+	}
+	defer release()
+
+	transfer, gErr := a.reviewed(ctx, transferID)
+	if gErr != nil {
+		return dto.TransferResponse{}, gErr
+	}
+	reviewed := *transfer
+
+	// approved transfers are not screened again, limits and funds are checked
+	// as they are when it is executed
+	transfer.Status = model.TransferPending
+	accounts := newAccountSet(ctx, a.repository)
+	fees, entries, err := a.execute(accounts, transfer)
+	if err != nil {
+		if transfer.Status == model.TransferFailed {
+			change := repositories.Change{
+				Transfers: []*model.Transfer{transfer},
+				Audit: []*model.AuditRecord{
+					newAuditRecord(ctx, a.clock, model.AuditTransferApproved, AuditEntityTransfer, transfer.ID, &auditState{Transfers: []model.Transfer{reviewed}}, &auditState{Transfers: transferStates(transfer)}),
+				},
+				Events: []*model.Event{transferEvent(a.clock, transfer)},
+			}
+			// best effort as for any failed transfer
+			if sErr := a.commit(ctx, change); sErr != nil {
+				log.Printf("failed transfer %s could not be stored: %v", transfer.ID, sErr)
+			}
+		}
+		return dto.TransferResponse{}, err
+	}
+
+	change := repositories.Change{
+		Accounts:  accounts.all(),
+		Entries:   entries,
+		Transfers: []*model.Transfer{transfer},
+		Usage:     accounts.usages(),
+	}
+	before, after := accounts.states()
+	before.Transfers = []model.Transfer{reviewed}
+	after.Transfers = transferStates(transfer)
+	change.Audit = []*model.AuditRecord{
+		newAuditRecord(ctx, a.clock, model.AuditTransferApproved, AuditEntityTransfer, transfer.ID, before, after),
+	}
+	change.Events = []*model.Event{transferEvent(a.clock, transfer)}
+	if aErr := a.commit(ctx, change); aErr != nil {
+		return dto.TransferResponse{}, aErr
+	}
+
+	resp := toTransferResponse(transfer)
+	resp.Fees = toFeeResponses(fees)
+	return resp, nil
+}
+
+func (a *accountService) Reject(ctx context.Context, transferID uuid.UUID, req dto.RejectTransferRequest) (dto.TransferResponse, error) {
+	// the accounts of a transfer never change, they are read before locking
+	// them
+	locked, lgErr := a.repository.GetTransfer(ctx, transferID)
+	if lgErr != nil {
+		return dto.TransferResponse{}, lgErr
+	}
+
+	// used for pessimistic locking
+	release, lErr := a.acquire(ctx, locked.From, locked.To)
+	if lErr != nil {
+		return dto.TransferResponse{}, lErr
+	}
+	defer release()
+
+	transfer, gErr := a.reviewed(ctx, transferID)
+	if gErr != nil {
+		return dto.TransferResponse{}, gErr
+	}
+
+	before := &auditState{Transfers: transferStates(transfer)}
+	transfer.Status = model.TransferFailed
+	transfer.FailureReason = "rejected on review"
+	if req.Reason != "" {
+		transfer.FailureReason += ": " + req.Reason
+	}
+	transfer.UpdatedAt = a.clock()
+	change := repositories.Change{
+		Transfers: []*model.Transfer{transfer},
+		Audit: []*model.AuditRecord{
+			newAuditRecord(ctx, a.clock, model.AuditTransferRejected, AuditEntityTransfer, transfer.ID, before, &auditState{Transfers: transferStates(transfer)}),
+		},
+		Events: []*model.Event{transferEvent(a.clock, transfer)},
+	}
+	if err := a.commit(ctx, change); err != nil {
+		return dto.TransferResponse{}, err
+	}
+
+	return toTransferResponse(transfer), nil
+}
+
+// reviewed returns a transfer waiting for review
+func (a *accountService) reviewed(ctx context.Context, transferID uuid.UUID) (*model.Transfer, error) {
+	transfer, err := a.repository.GetTransfer(ctx, transferID)
+	if err != nil {
+		return nil, err
+	}
+	if transfer.Status != model.TransferInReview {
+		return nil, ErrTransferNotInReview
+	}
+	return transfer, nil
+}
@@ -0,0 +1,245 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+var testScreeningRules = model.ScreeningRules{
+	{Name: "new beneficiary", Type: model.ScreenNewBeneficiary, Action: model.ScreeningReview, Amount: 50},
+	{Name: "huge amount", Type: model.ScreenAmount, Action: model.ScreeningDeny, Amount: 500},
+}
+
+func TestAccountService_Screening(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given transfers screened by fraud rules", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 1000.00}
+		require.NoError(t, repo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, repo.Create(ctx, &to))
+
+		screener := service.NewRulesScreener(repo, testScreeningRules, clock)
+		accService := service.NewAccountService(repo, service.WithScreener(screener), service.WithClock(clock))
+		other := func(t *testing.T) uuid.UUID {
+			acc := model.Account{ID: uuid.New(), Name: "mary", Amount: 0.00}
+			require.NoError(t, repo.Create(ctx, &acc))
+			return acc.ID
+		}
+
+		t.Run("When a transfer breaks a deny rule", func(t *testing.T) {
+			_, err := accService.Transfer(ctx, from.ID, to.ID, 600.00, "")
+
+			t.Run("Then fails telling the rule and keeps it as failed", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrTransferDenied)
+				assert.Contains(t, err.Error(), "huge amount")
+				assertBalance(t, repo, from.ID, 1000.00)
+
+				transfers, lErr := accService.Transfers(ctx, from.ID)
+				require.NoError(t, lErr)
+				require.Len(t, transfers.Transfers, 1)
+				assert.Equal(t, string(model.TransferFailed), transfers.Transfers[0].Status)
+				assert.Equal(t, "new beneficiary, huge amount", transfers.Transfers[0].ScreeningReason)
+			})
+		})
+
+		t.Run("When the first transfer to a beneficiary is held for review", func(t *testing.T) {
+			resp, err := accService.Transfer(ctx, from.ID, to.ID, 100.00, "")
+			require.NoError(t, err)
+
+			t.Run("Then no money moves until it is reviewed", func(t *testing.T) {
+				assert.Equal(t, string(model.TransferInReview), resp.Status)
+				assert.Equal(t, "new beneficiary", resp.ScreeningReason)
+				assertBalance(t, repo, from.ID, 1000.00)
+				assertBalance(t, repo, to.ID, 0.00)
+			})
+
+			t.Run("Then it waits in the review queue", func(t *testing.T) {
+				queue, qErr := accService.ReviewQueue(ctx)
+				require.NoError(t, qErr)
+				require.Len(t, queue.Transfers, 1)
+				assert.Equal(t, resp.ID, queue.Transfers[0].ID)
+			})
+
+			t.Run("Then it can not be reversed", func(t *testing.T) {
+				_, rErr := accService.Reverse(ctx, resp.ID, dto.ReversalRequest{})
+				assert.ErrorIs(t, rErr, model.ErrTransferNotCompleted)
+			})
+
+			t.Run("Then an operator approving it moves the money", func(t *testing.T) {
+				approved, aErr := accService.Approve(ctx, resp.ID)
+				require.NoError(t, aErr)
+				assert.Equal(t, string(model.TransferCompleted), approved.Status)
+				assertBalance(t, repo, from.ID, 900.00)
+				assertBalance(t, repo, to.ID, 100.00)
+
+				_, aErr = accService.Approve(ctx, resp.ID)
+				assert.ErrorIs(t, aErr, service.ErrTransferNotInReview)
+			})
+
+			t.Run("Then the beneficiary is no longer new", func(t *testing.T) {
+				next, nErr := accService.Transfer(ctx, from.ID, to.ID, 100.00, "")
+				require.NoError(t, nErr)
+				assert.Equal(t, string(model.TransferCompleted), next.Status)
+			})
+		})
+
+		t.Run("When an operator rejects a held transfer", func(t *testing.T) {
+			other := model.Account{ID: uuid.New(), Name: "mary", Amount: 0.00}
+			require.NoError(t, repo.Create(ctx, &other))
+			held, err := accService.Transfer(ctx, from.ID, other.ID, 100.00, "")
+			require.NoError(t, err)
+
+			rejected, rErr := accService.Reject(ctx, held.ID, dto.RejectTransferRequest{Reason: "customer did not confirm"})
+			require.NoError(t, rErr)
+
+			t.Run("Then it fails without moving money and leaves the queue", func(t *testing.T) {
+				assert.Equal(t, string(model.TransferFailed), rejected.Status)
+				assert.Equal(t, "rejected on review: customer did not confirm", rejected.FailureReason)
+				assertBalance(t, repo, from.ID, 800.00)
+				assertBalance(t, repo, other.ID, 0.00)
+
+				queue, qErr := accService.ReviewQueue(ctx)
+				require.NoError(t, qErr)
+				assert.Empty(t, queue.Transfers)
+			})
+		})
+
+		t.Run("When an atomic batch holds a transfer for review", func(t *testing.T) {
+			other := model.Account{ID: uuid.New(), Name: "ann", Amount: 0.00}
+			require.NoError(t, repo.Create(ctx, &other))
+			_, err := accService.Batch(ctx, dto.BatchTransferRequest{Transfers: []dto.BatchTransferLeg{
+				{From: from.ID, To: to.ID, Amount: 10.00},
+				{From: from.ID, To: other.ID, Amount: 100.00},
+			}})
+
+			t.Run("Then the batch is rejected", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrBatchRejected)
+				assertBalance(t, repo, from.ID, 800.00)
+			})
+		})
+
+		t.Run("When a split transfer breaks a deny rule", func(t *testing.T) {
+			_, err := accService.Split(ctx, dto.SplitTransferRequest{
+				Debits:  []dto.SplitLeg{{Account: from.ID, Amount: 600.00}},
+				Credits: []dto.SplitLeg{{Account: to.ID, Amount: 300.00}, {Account: other(t), Amount: 300.00}},
+			})
+
+			t.Run("Then fails telling the rule and keeps it as failed", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrTransferDenied)
+				assert.Contains(t, err.Error(), "huge amount")
+				assertBalance(t, repo, from.ID, 800.00)
+
+				transfers, lErr := accService.Transfers(ctx, from.ID)
+				require.NoError(t, lErr)
+				last := transfers.Transfers[len(transfers.Transfers)-1]
+				assert.NotEmpty(t, last.Legs)
+				assert.Equal(t, string(model.TransferFailed), last.Status)
+			})
+		})
+
+		t.Run("When a split transfer pays a new beneficiary", func(t *testing.T) {
+			beneficiary := other(t)
+			_, err := accService.Split(ctx, dto.SplitTransferRequest{
+				Debits:  []dto.SplitLeg{{Account: from.ID, Amount: 100.00}},
+				Credits: []dto.SplitLeg{{Account: to.ID, Amount: 40.00}, {Account: beneficiary, Amount: 60.00}},
+			})
+
+			t.Run("Then it is refused as it can not wait for an operator", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrHeldForReview)
+				assertBalance(t, repo, from.ID, 800.00)
+				assertBalance(t, repo, beneficiary, 0.00)
+
+				queue, qErr := accService.ReviewQueue(ctx)
+				require.NoError(t, qErr)
+				assert.Empty(t, queue.Transfers)
+			})
+		})
+
+		t.Run("When a hold is captured by a new beneficiary", func(t *testing.T) {
+			holdService := service.NewHoldService(repo, accService, clock)
+			merchant := other(t)
+			hold, err := holdService.Place(ctx, from.ID, dto.PlaceHoldRequest{To: merchant, Amount: 100.00})
+			require.NoError(t, err)
+			_, cErr := holdService.Capture(ctx, hold.ID, 0)
+
+			t.Run("Then it is refused and the hold stays active", func(t *testing.T) {
+				assert.ErrorIs(t, cErr, service.ErrHeldForReview)
+				assertBalance(t, repo, merchant, 0.00)
+
+				got, gErr := holdService.Get(ctx, hold.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.HoldActive), got.Status)
+			})
+		})
+
+		t.Run("When a hold over a deny rule is captured", func(t *testing.T) {
+			holdService := service.NewHoldService(repo, accService, clock)
+			hold, err := holdService.Place(ctx, from.ID, dto.PlaceHoldRequest{To: to.ID, Amount: 600.00})
+			require.NoError(t, err)
+			_, cErr := holdService.Capture(ctx, hold.ID, 0)
+
+			t.Run("Then it is denied without moving money", func(t *testing.T) {
+				assert.ErrorIs(t, cErr, service.ErrTransferDenied)
+				assertBalance(t, repo, from.ID, 800.00)
+			})
+		})
+	})
+}
+
+func TestAccountService_ScreeningVelocity(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	rules := model.ScreeningRules{
+		{Name: "too many transfers", Type: model.ScreenVelocity, Action: model.ScreeningReview, Count: 2, WindowMinutes: 60},
+	}
+
+	t.Run("Given transfers screened by a velocity rule", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 1000.00}
+		require.NoError(t, repo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, repo.Create(ctx, &to))
+
+		screener := service.NewRulesScreener(repo, rules, clock)
+		accService := service.NewAccountService(repo, service.WithScreener(screener), service.WithClock(clock))
+		transferAt := func(t *testing.T, at time.Time) dto.TransferResponse {
+			now = at
+			resp, err := accService.Transfer(ctx, from.ID, to.ID, 10.00, "")
+			require.NoError(t, err)
+			return resp
+		}
+
+		start := now
+
+		t.Run("When only one earlier transfer is within the window", func(t *testing.T) {
+			transferAt(t, start)
+			transferAt(t, start.Add(90*time.Minute))
+			resp := transferAt(t, start.Add(2*time.Hour))
+
+			t.Run("Then it goes ahead", func(t *testing.T) {
+				assert.Equal(t, string(model.TransferCompleted), resp.Status)
+			})
+		})
+
+		t.Run("When two earlier transfers are within the window", func(t *testing.T) {
+			resp := transferAt(t, start.Add(2*time.Hour+time.Minute))
+
+			t.Run("Then it is held for review", func(t *testing.T) {
+				assert.Equal(t, string(model.TransferInReview), resp.Status)
+				assert.Equal(t, "too many transfers", resp.ScreeningReason)
+			})
+		})
+	})
+}
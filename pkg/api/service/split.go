@@ -0,0 +1,193 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"math"
+	"sort"
+)
+
+var ErrUnbalancedTransfer = errors.New("debits and credits of the transfer do not balance")
+
+func (a *accountService) Split(ctx context.Context, req dto.SplitTransferRequest) (dto.TransferResponse, error) {
+	legs, total, vErr := splitLegs(req)
+	if vErr != nil {
+		return dto.TransferResponse{}, vErr
+	}
+
+	ids := make([]uuid.UUID, len(legs))
+	var debits []debit
+	for i, leg := range legs {
+		ids[i] = leg.AccountID
+		if leg.Amount < 0 {
+			debits = append(debits, debit{leg.AccountID, -leg.Amount})
+		}
+	}
+
+	// used for pessimistic locking
+	release, lErr := a.acquire(ctx, a.charged(ctx, ids, debits...)...)
+	if lErr != nil {
+		return dto.TransferResponse{}, lErr
+	}
+	defer release()
+
+	// split transfers sharing accounts can not deadlock each other, the
+	// locker takes their slots in ascending order (slotsOf). Reading the
+	// accounts in a canonical order only makes every change write, and the
+	// database lock, their rows in the same order
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+	accounts := newAccountSet(ctx, a.repository)
+	for _, id := range ids {
+		if _, gErr := accounts.get(id); gErr != nil {
+			return dto.TransferResponse{}, gErr
+		}
+	}
+
+	now := a.clock()
+	transfer := &model.Transfer{
+		ID:        uuid.New(),
+		Amount:    total,
+		Status:    model.TransferPending,
+		Memo:      req.Memo,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Legs:      legs,
+	}
+	description := "split transfer " + transfer.ID.String()
+
+	// an operator can only approve transfers with a single sender and
+	// recipient, a split transfer held for review is refused instead
+	if sErr := a.screen(ctx, transfer); sErr != nil {
+		if transfer.Status == model.TransferFailed {
+			a.fail(ctx, model.AuditSplitTransfer, transfer)
+		}
+		return dto.TransferResponse{}, sErr
+	}
+
+	// debits go first, changes stay in memory until everything is valid
+	var entries []*model.LedgerEntry
+	var totalFee float64
+	for _, leg := range legs {
+		acc, _ := accounts.get(leg.AccountID)
+		if leg.Amount > 0 {
+			if aErr := acc.AddMoney(leg.Amount); aErr != nil {
+				return dto.TransferResponse{}, aErr
+			}
+			entries = append(entries, a.entry(acc, model.EntryTransferIn, leg.Amount, description))
+			continue
+		}
+
+		amount := -leg.Amount
+		fees := a.transferFees(acc, amount)
+		fee := model.TotalFees(fees)
+		usages, wErr := a.checkLimits(accounts, acc, model.LimitOnTransfer, amount)
+		if wErr != nil && !errors.Is(wErr, model.ErrLimitExceeded) {
+			return dto.TransferResponse{}, wErr
+		}
+		if wErr == nil {
+			wErr = acc.Withdraw(amount + fee)
+		}
+		if wErr != nil {
+			transfer.Status = model.TransferFailed
+			transfer.FailureReason = wErr.Error()
+			a.fail(ctx, model.AuditSplitTransfer, transfer)
+			return dto.TransferResponse{}, wErr
+		}
+		a.useLimits(accounts, usages, amount)
+		entries = append(entries, a.entryWithBalance(acc, model.EntryTransferOut, leg.Amount, acc.Amount+fee, description))
+		balance := acc.Amount + fee
+		for _, f := range fees {
+			balance -= f.Amount
+			entries = append(entries, a.entryWithBalance(acc, model.EntryFee, -f.Amount, balance, f.Name))
+		}
+		totalFee += fee
+	}
+
+	if totalFee > 0 {
+		revenue, rErr := accounts.get(a.revenueAccountID)
+		if rErr != nil {
+			return dto.TransferResponse{}, rErr
+		}
+		if aErr := revenue.AddMoney(totalFee); aErr != nil {
+			return dto.TransferResponse{}, aErr
+		}
+		entries = append(entries, a.entry(revenue, model.EntryFee, totalFee, "fees of "+description))
+	}
+
+	transfer.Fee = totalFee
+	transfer.Status = model.TransferCompleted
+	change := repositories.Change{
+		Accounts:  accounts.all(),
+		Entries:   entries,
+		Transfers: []*model.Transfer{transfer},
+		Usage:     accounts.usages(),
+	}
+	before, after := accounts.states()
+	after.Transfers = transferStates(transfer)
+	change.Audit = []*model.AuditRecord{
+		newAuditRecord(ctx, a.clock, model.AuditSplitTransfer, AuditEntityTransfer, transfer.ID, before, after),
+	}
+	change.Events = []*model.Event{transferEvent(a.clock, transfer)}
+	if err := a.commit(ctx, change); err != nil {
+		return dto.TransferResponse{}, err
+	}
+
+	return toTransferResponse(transfer), nil
+}
+
+// splitLegs validates a split transfer, returning its legs, debits first, and
+// the amount it moves
+func splitLegs(req dto.SplitTransferRequest) ([]model.TransferLeg, float64, error) {
+	if len(req.Debits) == 0 || len(req.Credits) == 0 {
+		return nil, 0, ErrInconsistentData
+	}
+
+	legs := make([]model.TransferLeg, 0, len(req.Debits)+len(req.Credits))
+	seen := make(map[uuid.UUID]bool)
+	// legs add up in cents, so no money is made or lost to float noise
+	var debits, credits int64
+	add := func(leg dto.SplitLeg, sign int64) (int64, error) {
+		amount, whole := toCents(leg.Amount)
+		// an account takes a single part in the transfer
+		if amount <= 0 || !whole || seen[leg.Account] {
+			return 0, ErrInconsistentData
+		}
+		seen[leg.Account] = true
+		legs = append(legs, model.TransferLeg{AccountID: leg.Account, Amount: float64(sign*amount) / 100})
+		return amount, nil
+	}
+	for _, leg := range req.Debits {
+		amount, err := add(leg, -1)
+		if err != nil {
+			return nil, 0, err
+		}
+		debits += amount
+	}
+	for _, leg := range req.Credits {
+		amount, err := add(leg, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		credits += amount
+	}
+
+	if debits != credits {
+		return nil, 0, ErrUnbalancedTransfer
+	}
+
+	return legs, float64(debits) / 100, nil
+}
+
+// toCents returns amount in cents, and whether it is a whole number of them.
+// A binary float is rarely exactly a number of cents, only a difference far
+// below one is taken for its representation.
+func toCents(amount float64) (int64, bool) {
+	cents := math.Round(amount * 100)
+	return int64(cents), math.Abs(amount*100-cents) < 1e-6
+}
@@ -0,0 +1,140 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestAccountService_Split(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+
+	t.Run("Given three roommates and two utilities", func(t *testing.T) {
+		newAccount := func(amount float64) uuid.UUID {
+			acc := model.Account{ID: uuid.New(), Name: "account", Amount: amount}
+			require.NoError(t, repo.Create(ctx, &acc))
+			return acc.ID
+		}
+		ann, bob, cid := newAccount(100.00), newAccount(100.00), newAccount(10.00)
+		power, water := newAccount(0.00), newAccount(0.00)
+		accService := service.NewAccountService(repo)
+
+		t.Run("When debits and credits do not balance", func(t *testing.T) {
+			_, err := accService.Split(ctx, dto.SplitTransferRequest{
+				Debits:  []dto.SplitLeg{{Account: ann, Amount: 30.00}},
+				Credits: []dto.SplitLeg{{Account: power, Amount: 20.00}},
+			})
+
+			t.Run("Then fails", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrUnbalancedTransfer)
+			})
+		})
+
+		t.Run("When they are a fraction of a cent apart", func(t *testing.T) {
+			_, err := accService.Split(ctx, dto.SplitTransferRequest{
+				Debits:  []dto.SplitLeg{{Account: ann, Amount: 10.00}},
+				Credits: []dto.SplitLeg{{Account: power, Amount: 5.00}, {Account: water, Amount: 5.004}},
+			})
+
+			t.Run("Then fails", func(t *testing.T) {
+				assert.ErrorIs(t, err, service.ErrInconsistentData)
+				assertBalance(t, repo, ann, 100.00)
+			})
+		})
+
+		t.Run("When legs add up only once in cents", func(t *testing.T) {
+			// 0.1 + 0.2 is not 0.3 in floats
+			first, second, shop := newAccount(1.00), newAccount(1.00), newAccount(0.00)
+			_, err := accService.Split(ctx, dto.SplitTransferRequest{
+				Debits:  []dto.SplitLeg{{Account: first, Amount: 0.10}, {Account: second, Amount: 0.20}},
+				Credits: []dto.SplitLeg{{Account: shop, Amount: 0.30}},
+			})
+			require.NoError(t, err)
+
+			t.Run("Then they balance", func(t *testing.T) {
+				assertBalance(t, repo, first, 0.90)
+				assertBalance(t, repo, second, 0.80)
+				assertBalance(t, repo, shop, 0.30)
+			})
+		})
+
+		t.Run("When one of the debits has not enough balance", func(t *testing.T) {
+			_, err := accService.Split(ctx, dto.SplitTransferRequest{
+				Debits:  []dto.SplitLeg{{Account: ann, Amount: 20.00}, {Account: cid, Amount: 20.00}},
+				Credits: []dto.SplitLeg{{Account: power, Amount: 40.00}},
+			})
+
+			t.Run("Then nothing moves", func(t *testing.T) {
+				assert.Error(t, err)
+				assertBalance(t, repo, ann, 100.00)
+				assertBalance(t, repo, cid, 10.00)
+				assertBalance(t, repo, power, 0.00)
+			})
+		})
+
+		t.Run("When the bills are paid by all of them", func(t *testing.T) {
+			resp, err := accService.Split(ctx, dto.SplitTransferRequest{
+				Debits:  []dto.SplitLeg{{Account: ann, Amount: 30.00}, {Account: bob, Amount: 30.00}, {Account: cid, Amount: 10.00}},
+				Credits: []dto.SplitLeg{{Account: power, Amount: 45.00}, {Account: water, Amount: 25.00}},
+				Memo:    "bills of march",
+			})
+			require.NoError(t, err)
+
+			t.Run("Then every leg moves at once", func(t *testing.T) {
+				assert.Equal(t, 70.00, resp.Amount)
+				assert.Len(t, resp.Legs, 5)
+				assertBalance(t, repo, ann, 70.00)
+				assertBalance(t, repo, bob, 70.00)
+				assertBalance(t, repo, cid, 0.00)
+				assertBalance(t, repo, power, 45.00)
+				assertBalance(t, repo, water, 25.00)
+			})
+
+			t.Run("Then it is listed for every account taking part", func(t *testing.T) {
+				transfers, lErr := accService.Transfers(ctx, water)
+				require.NoError(t, lErr)
+				require.Len(t, transfers.Transfers, 1)
+				assert.Equal(t, resp.ID, transfers.Transfers[0].ID)
+				assert.Len(t, transfers.Transfers[0].Legs, 5)
+			})
+
+			t.Run("Then it can not be reversed", func(t *testing.T) {
+				_, rErr := accService.Reverse(ctx, resp.ID, dto.ReversalRequest{})
+				assert.ErrorIs(t, rErr, service.ErrNotReversible)
+			})
+		})
+
+		t.Run("When split transfers over the same accounts run at the same moment in both directions", func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				debit, credit := ann, bob
+				if i%2 == 1 {
+					debit, credit = bob, ann
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := accService.Split(ctx, dto.SplitTransferRequest{
+						Debits:  []dto.SplitLeg{{Account: debit, Amount: 5.00}},
+						Credits: []dto.SplitLeg{{Account: credit, Amount: 2.00}, {Account: water, Amount: 3.00}},
+					})
+					assert.NoError(t, err)
+				}()
+			}
+			wg.Wait()
+
+			t.Run("Then all of them complete", func(t *testing.T) {
+				assertBalance(t, repo, ann, 55.00)
+				assertBalance(t, repo, bob, 55.00)
+				assertBalance(t, repo, water, 55.00)
+			})
+		})
+	})
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+const (
+	standingOrderBatchSize  = 100
+	standingOrderRetryDelay = time.Hour
+	// standingOrderClaimTimeout is how long an order may stay executing
+	// before its claim is taken as abandoned and taken again
+	standingOrderClaimTimeout = 15 * time.Minute
+	defaultMaxRetries         = 3
+)
+
+type StandingOrderService interface {
+	Create(ctx context.Context, req dto.CreateStandingOrderRequest) (dto.StandingOrderResponse, error)
+	Get(ctx context.Context, orderID uuid.UUID) (dto.StandingOrderResponse, error)
+	List(ctx context.Context, accountID uuid.UUID) (dto.GetStandingOrdersResponse, error)
+	Cancel(ctx context.Context, orderID uuid.UUID) error
+	Runs(ctx context.Context, orderID uuid.UUID) (dto.GetStandingOrderRunsResponse, error)
+	// ExecuteDue runs every active order whose next occurrence has been reached
+	ExecuteDue(ctx context.Context) error
+}
+
+func NewStandingOrderService(
+	repository repositories.StandingOrderRepository,
+	accounts repositories.AccountRepository,
+	accountService AccountService,
+	notifier Notifier,
+	clock Clock,
+) StandingOrderService {
+	return &standingOrderService{
+		repository:     repository,
+		accounts:       accounts,
+		accountService: accountService,
+		notifier:       notifier,
+		clock:          clock,
+	}
+}
+
+type standingOrderService struct {
+	repository     repositories.StandingOrderRepository
+	accounts       repositories.AccountRepository
+	accountService AccountService
+	notifier       Notifier
+	clock          Clock
+}
+
+func (s *standingOrderService) Create(ctx context.Context, req dto.CreateStandingOrderRequest) (dto.StandingOrderResponse, error) {
+	if req.From == req.To || req.Amount <= 0 {
+		return dto.StandingOrderResponse{}, ErrInconsistentData
+	}
+
+	now := s.clock()
+	if req.StartAt.Before(now) {
+		return dto.StandingOrderResponse{}, ErrInvalidSchedule
+	}
+
+	if _, fErr := s.accounts.Get(ctx, req.From); fErr != nil {
+		return dto.StandingOrderResponse{}, fErr
+	}
+	if _, tErr := s.accounts.Get(ctx, req.To); tErr != nil {
+		return dto.StandingOrderResponse{}, tErr
+	}
+
+	order := &model.StandingOrder{
+		ID:         uuid.New(),
+		From:       req.From,
+		To:         req.To,
+		Amount:     req.Amount,
+		Frequency:  model.Frequency(req.Frequency),
+		StartAt:    req.StartAt,
+		EndAt:      req.EndAt,
+		Count:      req.Count,
+		OnFailure:  model.FailurePolicy(req.OnFailure),
+		MaxRetries: req.MaxRetries,
+		Status:     model.StandingOrderActive,
+		CreatedAt:  now,
+	}
+	if order.OnFailure == "" {
+		order.OnFailure = model.FailureSkip
+	}
+	if order.OnFailure == model.FailureRetry && order.MaxRetries == 0 {
+		order.MaxRetries = defaultMaxRetries
+	}
+	if !order.HasOccurrence(0) {
+		return dto.StandingOrderResponse{}, ErrInvalidSchedule
+	}
+	order.NextRunAt = order.Occurrence(0)
+
+	if cErr := s.repository.Create(ctx, order); cErr != nil {
+		return dto.StandingOrderResponse{}, cErr
+	}
+	audit(ctx, s.accounts, newAuditRecord(ctx, s.clock, model.AuditStandingOrderCreated, AuditEntityStandingOrder, order.ID, nil, &auditState{StandingOrder: order}))
+
+	return toStandingOrderResponse(order), nil
+}
+
+func (s *standingOrderService) Get(ctx context.Context, orderID uuid.UUID) (dto.StandingOrderResponse, error) {
+	order, err := s.repository.Get(ctx, orderID)
+	if err != nil {
+		return dto.StandingOrderResponse{}, err
+	}
+	return toStandingOrderResponse(order), nil
+}
+
+func (s *standingOrderService) List(ctx context.Context, accountID uuid.UUID) (dto.GetStandingOrdersResponse, error) {
+	orders, err := s.repository.ListByAccount(ctx, accountID)
+	if err != nil {
+		return dto.GetStandingOrdersResponse{}, err
+	}
+
+	resp := make([]dto.StandingOrderResponse, len(orders))
+	for i := range orders {
+		resp[i] = toStandingOrderResponse(orders[i])
+	}
+	return dto.GetStandingOrdersResponse{Orders: resp}, nil
+}
+
+func (s *standingOrderService) Cancel(ctx context.Context, orderID uuid.UUID) error {
+	before, gErr := s.repository.Get(ctx, orderID)
+	if gErr != nil {
+		return gErr
+	}
+	if cErr := s.repository.Cancel(ctx, orderID); cErr != nil {
+		return cErr
+	}
+
+	after := *before
+	after.Status = model.StandingOrderCancelled
+	audit(ctx, s.accounts, newAuditRecord(ctx, s.clock, model.AuditStandingOrderCancelled, AuditEntityStandingOrder, orderID, &auditState{StandingOrder: before}, &auditState{StandingOrder: &after}))
+	return nil
+}
+
+func (s *standingOrderService) Runs(ctx context.Context, orderID uuid.UUID) (dto.GetStandingOrderRunsResponse, error) {
+	if _, gErr := s.repository.Get(ctx, orderID); gErr != nil {
+		return dto.GetStandingOrderRunsResponse{}, gErr
+	}
+
+	runs, err := s.repository.ListRuns(ctx, orderID)
+	if err != nil {
+		return dto.GetStandingOrderRunsResponse{}, err
+	}
+
+	resp := make([]dto.StandingOrderRunResponse, len(runs))
+	for i, run := range runs {
+		resp[i] = dto.StandingOrderRunResponse{
+			Occurrence:    run.Occurrence,
+			ScheduledFor:  run.ScheduledFor,
+			ExecutedAt:    run.ExecutedAt,
+			Attempt:       run.Attempt,
+			Status:        string(run.Status),
+			FailureReason: run.FailureReason,
+		}
+	}
+	return dto.GetStandingOrderRunsResponse{Runs: resp}, nil
+}
+
+func (s *standingOrderService) ExecuteDue(ctx context.Context) error {
+	now := s.clock()
+	due, err := s.repository.ClaimDue(ctx, now, now.Add(-standingOrderClaimTimeout), standingOrderBatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, order := range due {
+		if ctx.Err() == nil {
+			s.run(ctx, order)
+		}
+
+		if order.Status == model.StandingOrderExecuting {
+			order.Status = model.StandingOrderActive
+		}
+		// as with scheduled transfers the new state is stored even if ctx
+		// is done, or the order would be left executing until its claim
+		// goes stale
+		if sErr := s.repository.Save(context.Background(), order); sErr != nil {
+			log.Printf("standing order %s could not be stored: %v", order.ID, sErr)
+		}
+	}
+
+	return nil
+}
+
+// run executes the current occurrence of order and moves it forward
+// following its failure policy
+func (s *standingOrderService) run(ctx context.Context, order *model.StandingOrder) {
+	run := &model.StandingOrderRun{
+		ID:           uuid.New(),
+		OrderID:      order.ID,
+		Occurrence:   order.Occurrences,
+		ScheduledFor: order.Occurrence(order.Occurrences),
+		Attempt:      order.Attempts + 1,
+		Status:       model.RunSucceeded,
+	}
+
+	// every attempt of an occurrence makes its own transfer
+	transferID := uuid.NewSHA1(order.ID, []byte(fmt.Sprintf("%d/%d", run.Occurrence, run.Attempt)))
+	resp, tErr := once(ctx, s.accounts, transferID, order.Claims > 1, func(ctx context.Context) (dto.TransferResponse, error) {
+		return s.accountService.Transfer(ctx, order.From, order.To, order.Amount, fmt.Sprintf("standing order %s #%d", order.ID, order.Occurrences))
+	})
+	if errors.Is(tErr, errOutcomeUnknown) {
+		// not known whether it was made, left as is to find out next run
+		return
+	}
+	order.Claims = 0
+	run.ExecutedAt = s.clock()
+	if tErr != nil {
+		run.Status = model.RunFailed
+		run.FailureReason = tErr.Error()
+	} else if resp.Status == string(model.TransferInReview) {
+		run.Status = model.RunHeld
+	}
+	if aErr := s.repository.AddRun(context.Background(), run); aErr != nil {
+		log.Printf("run of standing order %s could not be stored: %v", order.ID, aErr)
+	}
+
+	if tErr == nil {
+		order.Advance()
+		return
+	}
+
+	switch order.OnFailure {
+	case model.FailureRetry:
+		order.Attempts++
+		if order.Attempts <= order.MaxRetries {
+			order.NextRunAt = run.ExecutedAt.Add(standingOrderRetryDelay)
+			return
+		}
+		s.notify(ctx, order, fmt.Sprintf("standing order %s skipped after %d attempts: %v", order.ID, order.Attempts, tErr))
+	case model.FailureNotify:
+		s.notify(ctx, order, fmt.Sprintf("standing order %s skipped: %v", order.ID, tErr))
+	}
+	order.Advance()
+}
+
+// notify is best effort, a notification failure must not block the order
+func (s *standingOrderService) notify(ctx context.Context, order *model.StandingOrder, message string) {
+	_ = s.notifier.Notify(ctx, order.From, message)
+}
+
+func toStandingOrderResponse(order *model.StandingOrder) dto.StandingOrderResponse {
+	resp := dto.StandingOrderResponse{
+		ID:          order.ID,
+		From:        order.From,
+		To:          order.To,
+		Amount:      order.Amount,
+		Frequency:   string(order.Frequency),
+		StartAt:     order.StartAt,
+		EndAt:       order.EndAt,
+		Count:       order.Count,
+		OnFailure:   string(order.OnFailure),
+		MaxRetries:  order.MaxRetries,
+		Status:      string(order.Status),
+		Occurrences: order.Occurrences,
+	}
+	if order.Status == model.StandingOrderActive {
+		nextRunAt := order.NextRunAt
+		resp.NextRunAt = &nextRunAt
+	}
+	return resp
+}
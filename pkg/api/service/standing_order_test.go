@@ -0,0 +1,216 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type recordingNotifier struct {
+	messages []string
+}
+
+func (r *recordingNotifier) Notify(_ context.Context, _ uuid.UUID, message string) error {
+	r.messages = append(r.messages, message)
+	return nil
+}
+
+func TestStandingOrderService_ExecuteDue(t *testing.T) {
+	ctx := context.Background()
+	accRepo := setup(t)
+	now := time.Date(2022, 9, 1, 8, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given a monthly standing order of two occurrences retried once on failure", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 150.00}
+		require.NoError(t, accRepo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "landlord", Amount: 0.00}
+		require.NoError(t, accRepo.Create(ctx, &to))
+
+		notifier := &recordingNotifier{}
+		accService := service.NewAccountService(accRepo)
+		orderService := service.NewStandingOrderService(repositories.NewMemoryStandingOrderRepository(), accRepo, accService, notifier, clock)
+
+		order, err := orderService.Create(ctx, dto.CreateStandingOrderRequest{
+			From:       from.ID,
+			To:         to.ID,
+			Amount:     100.00,
+			Frequency:  string(model.FrequencyMonthly),
+			StartAt:    time.Date(2022, 9, 1, 9, 0, 0, 0, time.UTC),
+			Count:      2,
+			OnFailure:  string(model.FailureRetry),
+			MaxRetries: 1,
+		})
+		require.NoError(t, err)
+
+		t.Run("When the first occurrence is due", func(t *testing.T) {
+			now = time.Date(2022, 9, 1, 9, 0, 0, 0, time.UTC)
+			require.NoError(t, orderService.ExecuteDue(ctx))
+
+			t.Run("Then the money is transferred and the next run is a month later", func(t *testing.T) {
+				current, gErr := accRepo.Get(ctx, to.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, 100.00, current.Amount)
+
+				got, oErr := orderService.Get(ctx, order.ID)
+				require.NoError(t, oErr)
+				assert.Equal(t, time.Date(2022, 10, 1, 9, 0, 0, 0, time.UTC), *got.NextRunAt)
+			})
+		})
+
+		t.Run("When the second occurrence has not enough balance", func(t *testing.T) {
+			now = time.Date(2022, 10, 1, 9, 0, 0, 0, time.UTC)
+			require.NoError(t, orderService.ExecuteDue(ctx))
+			now = now.Add(2 * time.Hour)
+			require.NoError(t, orderService.ExecuteDue(ctx))
+
+			t.Run("Then it is retried, skipped and the holder notified", func(t *testing.T) {
+				runs, rErr := orderService.Runs(ctx, order.ID)
+				require.NoError(t, rErr)
+				require.Len(t, runs.Runs, 3)
+				assert.Equal(t, string(model.RunSucceeded), runs.Runs[0].Status)
+				assert.Equal(t, string(model.RunFailed), runs.Runs[1].Status)
+				assert.Equal(t, 2, runs.Runs[2].Attempt)
+				assert.Len(t, notifier.messages, 1)
+
+				got, oErr := orderService.Get(ctx, order.ID)
+				require.NoError(t, oErr)
+				assert.Equal(t, string(model.StandingOrderFinished), got.Status)
+			})
+		})
+	})
+}
+
+func TestStandingOrderService_Screening(t *testing.T) {
+	ctx := context.Background()
+	accRepo := setup(t)
+	now := time.Date(2022, 9, 1, 8, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given a standing order retried on failure to a beneficiary screened for review", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 150.00}
+		require.NoError(t, accRepo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "landlord", Amount: 0.00}
+		require.NoError(t, accRepo.Create(ctx, &to))
+
+		screener := service.NewRulesScreener(accRepo, testScreeningRules, clock)
+		accService := service.NewAccountService(accRepo, service.WithScreener(screener), service.WithClock(clock))
+		orderService := service.NewStandingOrderService(repositories.NewMemoryStandingOrderRepository(), accRepo, accService, &recordingNotifier{}, clock)
+
+		order, err := orderService.Create(ctx, dto.CreateStandingOrderRequest{
+			From:      from.ID,
+			To:        to.ID,
+			Amount:    100.00,
+			Frequency: string(model.FrequencyMonthly),
+			StartAt:   time.Date(2022, 9, 1, 9, 0, 0, 0, time.UTC),
+			OnFailure: string(model.FailureRetry),
+		})
+		require.NoError(t, err)
+
+		t.Run("When the first occurrence is due", func(t *testing.T) {
+			now = time.Date(2022, 9, 1, 9, 0, 0, 0, time.UTC)
+			require.NoError(t, orderService.ExecuteDue(ctx))
+
+			t.Run("Then the run is held rather than succeeded and no money moves", func(t *testing.T) {
+				runs, rErr := orderService.Runs(ctx, order.ID)
+				require.NoError(t, rErr)
+				require.Len(t, runs.Runs, 1)
+				assert.Equal(t, string(model.RunHeld), runs.Runs[0].Status)
+				assertBalance(t, accRepo, to.ID, 0.00)
+			})
+
+			t.Run("Then it is not retried, the next run is a month later", func(t *testing.T) {
+				got, oErr := orderService.Get(ctx, order.ID)
+				require.NoError(t, oErr)
+				assert.Equal(t, 1, got.Occurrences)
+				assert.Equal(t, time.Date(2022, 10, 1, 9, 0, 0, 0, time.UTC), *got.NextRunAt)
+			})
+		})
+	})
+}
+
+// unsavable fails to store the order it is given
+type unsavable struct {
+	repositories.StandingOrderRepository
+	orderID uuid.UUID
+}
+
+func (u *unsavable) Save(ctx context.Context, order *model.StandingOrder) error {
+	if order.ID == u.orderID {
+		return errors.New("database gone")
+	}
+	return u.StandingOrderRepository.Save(ctx, order)
+}
+
+func TestStandingOrderService_Recovery(t *testing.T) {
+	ctx := context.Background()
+	accRepo := setup(t)
+	now := time.Date(2022, 9, 1, 8, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given two due orders, the first one failing to be stored", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 150.00}
+		require.NoError(t, accRepo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "landlord", Amount: 0.00}
+		require.NoError(t, accRepo.Create(ctx, &to))
+
+		orderRepo := &unsavable{StandingOrderRepository: repositories.NewMemoryStandingOrderRepository()}
+		orderService := service.NewStandingOrderService(orderRepo, accRepo, service.NewAccountService(accRepo), &recordingNotifier{}, clock)
+		create := func(t *testing.T, amount float64, startAt time.Time) dto.StandingOrderResponse {
+			order, err := orderService.Create(ctx, dto.CreateStandingOrderRequest{
+				From:      from.ID,
+				To:        to.ID,
+				Amount:    amount,
+				Frequency: string(model.FrequencyMonthly),
+				StartAt:   startAt,
+			})
+			require.NoError(t, err)
+			return order
+		}
+		unsaved := create(t, 30.00, time.Date(2022, 9, 1, 9, 0, 0, 0, time.UTC))
+		saved := create(t, 20.00, time.Date(2022, 9, 1, 9, 1, 0, 0, time.UTC))
+		orderRepo.orderID = unsaved.ID
+
+		t.Run("When they are due", func(t *testing.T) {
+			now = time.Date(2022, 9, 1, 9, 5, 0, 0, time.UTC)
+			require.NoError(t, orderService.ExecuteDue(ctx))
+
+			t.Run("Then the rest of the batch is still run", func(t *testing.T) {
+				got, gErr := orderService.Get(ctx, saved.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.StandingOrderActive), got.Status)
+				assert.Equal(t, 1, got.Occurrences)
+				assertBalance(t, accRepo, to.ID, 50.00)
+			})
+
+			t.Run("Then the first one is left executing", func(t *testing.T) {
+				got, gErr := orderService.Get(ctx, unsaved.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.StandingOrderExecuting), got.Status)
+			})
+		})
+
+		t.Run("When its claim is stale", func(t *testing.T) {
+			orderRepo.orderID = uuid.Nil
+			now = now.Add(time.Hour)
+			require.NoError(t, orderService.ExecuteDue(ctx))
+
+			t.Run("Then it moves to its next occurrence without moving the money again", func(t *testing.T) {
+				got, gErr := orderService.Get(ctx, unsaved.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.StandingOrderActive), got.Status)
+				assert.Equal(t, 1, got.Occurrences)
+				assertBalance(t, accRepo, from.ID, 100.00)
+				assertBalance(t, accRepo, to.ID, 50.00)
+			})
+		})
+	})
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"time"
+)
+
+const (
+	StatementJSON = "json"
+	StatementCSV  = "csv"
+	StatementPDF  = "pdf"
+
+	// maxStatementDays bounds the period of a statement, so it stays
+	// readable and cheap to render
+	maxStatementDays = 366
+	statementDate    = "2006-01-02"
+)
+
+var ErrInvalidStatement = errors.New("invalid statement period or format")
+
+type StatementService interface {
+	// Statement sums up the transactions of an account from the day from to
+	// the day to, both included. A zero from starts on the first day of last
+	// month, a zero to ends on the last day of the month from is in.
+	Statement(ctx context.Context, accountID uuid.UUID, from time.Time, to time.Time) (dto.StatementResponse, error)
+}
+
+// Bank tells who keeps the accounts in statements
+type Bank struct {
+	// ID is the routing number or code of the bank, at most 9 characters in
+	// ofx statements
+	ID string
+	// Currency of every account, as an ISO 4217 code
+	Currency string
+}
+
+func NewStatementService(repository repositories.AccountRepository, bank Bank, clock Clock) StatementService {
+	return &statementService{
+		repository: repository,
+		bank:       bank,
+		clock:      clock,
+	}
+}
+
+type statementService struct {
+	repository repositories.AccountRepository
+	bank       Bank
+	clock      Clock
+}
+
+func (s *statementService) Statement(ctx context.Context, accountID uuid.UUID, from time.Time, to time.Time) (dto.StatementResponse, error) {
+	now := s.clock()
+	if from.IsZero() {
+		from = startOfDay(now).AddDate(0, 0, 1-now.UTC().Day()).AddDate(0, -1, 0)
+	}
+	from = startOfDay(from)
+	if to.IsZero() {
+		to = from.AddDate(0, 0, 1-from.Day()).AddDate(0, 1, -1)
+	}
+	// the last day is included, the period ends on the next one
+	end := startOfDay(to).AddDate(0, 0, 1)
+	if !end.After(from) || end.After(from.AddDate(0, 0, maxStatementDays)) {
+		return dto.StatementResponse{}, ErrInvalidStatement
+	}
+
+	account, gErr := s.repository.Get(ctx, accountID)
+	if gErr != nil {
+		return dto.StatementResponse{}, gErr
+	}
+	ledger, err := s.repository.Entries(ctx, accountID)
+	if err != nil {
+		return dto.StatementResponse{}, err
+	}
+
+	statement := model.NewStatement(ledger, account.Amount, from, end)
+	resp := dto.StatementResponse{
+		AccountID:      account.ID,
+		Name:           account.Name,
+		CustomerID:     account.CustomerID,
+		Product:        account.Product,
+		Currency:       s.bank.Currency,
+		BankID:         s.bank.ID,
+		From:           statement.From,
+		To:             statement.To.AddDate(0, 0, -1),
+		OpeningBalance: statement.OpeningBalance,
+		ClosingBalance: statement.ClosingBalance,
+		TotalCredits:   statement.TotalCredits,
+		TotalDebits:    statement.TotalDebits,
+		Transactions:   make([]dto.TransactionResponse, len(statement.Entries)),
+		GeneratedAt:    now,
+	}
+	for i, entry := range statement.Entries {
+		resp.Transactions[i] = toTransactionResponse(entry)
+	}
+	return resp, nil
+}
+
+// WriteStatementCSV writes statement as csv, one row per transaction between
+// a row with the opening balance and rows with the totals and closing balance
+func WriteStatementCSV(w io.Writer, statement dto.StatementResponse) error {
+	out := csv.NewWriter(w)
+	rows := [][]string{
+		{"date", "id", "type", "description", "amount", "balance"},
+		{statement.From.Format(statementDate), "", "opening_balance", "", "", money(statement.OpeningBalance)},
+	}
+	for _, tx := range statement.Transactions {
+		rows = append(rows, []string{tx.CreatedAt.UTC().Format(time.RFC3339), tx.ID.String(), tx.Type, tx.Description, money(tx.Amount), money(tx.Balance)})
+	}
+	rows = append(rows,
+		[]string{statement.To.Format(statementDate), "", "total_credits", "", money(statement.TotalCredits), ""},
+		[]string{statement.To.Format(statementDate), "", "total_debits", "", money(statement.TotalDebits), ""},
+		[]string{statement.To.Format(statementDate), "", "closing_balance", "", "", money(statement.ClosingBalance)},
+	)
+	if err := out.WriteAll(rows); err != nil {
+		return err
+	}
+	return out.Error()
+}
+
+// WriteStatementPDF writes statement as a printable pdf, the summary of the
+// period followed by its transactions
+func WriteStatementPDF(w io.Writer, statement dto.StatementResponse) error {
+	summary := []string{
+		"ACCOUNT STATEMENT",
+		"",
+		fmt.Sprintf("Account:  %s", statement.AccountID),
+		fmt.Sprintf("Name:     %s", statement.Name),
+	}
+	if statement.CustomerID != "" {
+		summary = append(summary, fmt.Sprintf("Customer: %s", statement.CustomerID))
+	}
+	summary = append(summary,
+		fmt.Sprintf("Period:   %s to %s", statement.From.Format(statementDate), statement.To.Format(statementDate)),
+		fmt.Sprintf("Currency: %s", statement.Currency),
+		"",
+		fmt.Sprintf("Opening balance  %14s", money(statement.OpeningBalance)),
+		fmt.Sprintf("Total credits    %14s", money(statement.TotalCredits)),
+		fmt.Sprintf("Total debits     %14s", money(statement.TotalDebits)),
+		fmt.Sprintf("Closing balance  %14s", money(statement.ClosingBalance)),
+		"",
+	)
+
+	header := statementRow("Date", "Type", "Description", "Amount", "Balance")
+	rows := make([]string, len(statement.Transactions))
+	for i, tx := range statement.Transactions {
+		rows[i] = statementRow(tx.CreatedAt.UTC().Format(statementDate), tx.Type, tx.Description, money(tx.Amount), money(tx.Balance))
+	}
+	if len(rows) == 0 {
+		rows = []string{"No transactions over the period"}
+	}
+
+	doc := &pdfDocument{}
+	lines := append(summary, header)
+	for _, row := range rows {
+		if len(lines) == pdfLinesPerPage {
+			doc.pages = append(doc.pages, pdfPage{lines: lines})
+			lines = []string{header}
+		}
+		lines = append(lines, row)
+	}
+	doc.pages = append(doc.pages, pdfPage{lines: lines})
+	for i := range doc.pages {
+		doc.pages[i].footer = fmt.Sprintf("Generated %s, page %d of %d",
+			statement.GeneratedAt.UTC().Format(time.RFC3339), i+1, len(doc.pages))
+	}
+	return doc.write(w)
+}
+
+func statementRow(date string, kind string, description string, amount string, balance string) string {
+	if runes := []rune(description); len(runes) > 32 {
+		description = string(runes[:31]) + "~"
+	}
+	return fmt.Sprintf("%-10s  %-12s  %-32s  %12s  %12s", date, kind, description, amount, balance)
+}
+
+func money(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
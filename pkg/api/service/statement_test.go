@@ -0,0 +1,165 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// assertPDF checks the structure of a pdf: every object is where the cross
+// reference table says it is
+func assertPDF(t *testing.T, pdf []byte) {
+	t.Helper()
+	require.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+
+	startxref := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	require.NotNil(t, startxref)
+	xref, err := strconv.Atoi(string(startxref[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(pdf[xref:], -1)
+	require.NotEmpty(t, entries)
+	for i, entry := range entries {
+		offset, oErr := strconv.Atoi(string(entry[1]))
+		require.NoError(t, oErr)
+		assert.True(t, bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+}
+
+func TestStatementService(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 2, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	accService := service.NewAccountService(repo, service.WithClock(clock))
+	statementService := service.NewStatementService(repo, service.Bank{ID: "123456789", Currency: "EUR"}, clock)
+
+	t.Run("Given an unknown account", func(t *testing.T) {
+		_, err := statementService.Statement(ctx, uuid.New(), time.Time{}, time.Time{})
+
+		t.Run("Then it has no statement", func(t *testing.T) {
+			assert.ErrorIs(t, err, repositories.ErrAccountNotFound)
+		})
+	})
+
+	t.Run("Given an account that moved money over two months", func(t *testing.T) {
+		acc, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "bill smith", Amount: 100.00})
+		require.NoError(t, err)
+		_, err = accService.AddMoney(ctx, acc.ID, 50.00)
+		require.NoError(t, err)
+		now = time.Date(2022, 10, 3, 12, 0, 0, 0, time.UTC)
+		_, err = accService.Withdraw(ctx, acc.ID, 20.00)
+		require.NoError(t, err)
+		now = time.Date(2022, 11, 5, 9, 0, 0, 0, time.UTC)
+
+		t.Run("When no period is given", func(t *testing.T) {
+			statement, sErr := statementService.Statement(ctx, acc.ID, time.Time{}, time.Time{})
+			require.NoError(t, sErr)
+
+			t.Run("Then it covers last month", func(t *testing.T) {
+				assert.Equal(t, time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC), statement.From)
+				assert.Equal(t, time.Date(2022, 10, 31, 0, 0, 0, 0, time.UTC), statement.To)
+				assert.Equal(t, 150.00, statement.OpeningBalance)
+				assert.Equal(t, 130.00, statement.ClosingBalance)
+				assert.Equal(t, 0.00, statement.TotalCredits)
+				assert.Equal(t, 20.00, statement.TotalDebits)
+				require.Len(t, statement.Transactions, 1)
+				assert.Equal(t, "withdrawal", statement.Transactions[0].Type)
+			})
+		})
+
+		t.Run("When only the first day is given", func(t *testing.T) {
+			statement, sErr := statementService.Statement(ctx, acc.ID, time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+			require.NoError(t, sErr)
+
+			t.Run("Then it covers the month of that day", func(t *testing.T) {
+				assert.Equal(t, time.Date(2022, 9, 30, 0, 0, 0, 0, time.UTC), statement.To)
+				assert.Equal(t, 100.00, statement.OpeningBalance)
+				assert.Equal(t, 150.00, statement.ClosingBalance)
+				assert.Equal(t, 50.00, statement.TotalCredits)
+			})
+		})
+
+		t.Run("When the period ends before it starts, or is too long", func(t *testing.T) {
+			_, backwards := statementService.Statement(ctx, acc.ID, time.Date(2022, 10, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC))
+			_, long := statementService.Statement(ctx, acc.ID, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC))
+
+			t.Run("Then it is refused", func(t *testing.T) {
+				assert.ErrorIs(t, backwards, service.ErrInvalidStatement)
+				assert.ErrorIs(t, long, service.ErrInvalidStatement)
+			})
+		})
+
+		t.Run("When it is written as csv", func(t *testing.T) {
+			statement, sErr := statementService.Statement(ctx, acc.ID, time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 10, 31, 0, 0, 0, 0, time.UTC))
+			require.NoError(t, sErr)
+			var out bytes.Buffer
+			require.NoError(t, service.WriteStatementCSV(&out, statement))
+			rows, rErr := csv.NewReader(&out).ReadAll()
+			require.NoError(t, rErr)
+
+			t.Run("Then transactions come between the opening balance and the totals", func(t *testing.T) {
+				require.Len(t, rows, 7)
+				assert.Equal(t, []string{"date", "id", "type", "description", "amount", "balance"}, rows[0])
+				assert.Equal(t, []string{"2022-09-01", "", "opening_balance", "", "", "100.00"}, rows[1])
+				assert.Equal(t, "deposit", rows[2][2])
+				assert.Equal(t, "50.00", rows[2][4])
+				assert.Equal(t, "-20.00", rows[3][4])
+				assert.Equal(t, []string{"2022-10-31", "", "total_credits", "", "50.00", ""}, rows[4])
+				assert.Equal(t, []string{"2022-10-31", "", "total_debits", "", "20.00", ""}, rows[5])
+				assert.Equal(t, []string{"2022-10-31", "", "closing_balance", "", "", "130.00"}, rows[6])
+			})
+		})
+
+		t.Run("When it is written as pdf", func(t *testing.T) {
+			statement, sErr := statementService.Statement(ctx, acc.ID, time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+			require.NoError(t, sErr)
+			var out bytes.Buffer
+			require.NoError(t, service.WriteStatementPDF(&out, statement))
+
+			t.Run("Then it is a valid pdf with the summary", func(t *testing.T) {
+				assertPDF(t, out.Bytes())
+				assert.Contains(t, out.String(), "/Count 1")
+				assert.Contains(t, out.String(), "(Closing balance          150.00) Tj")
+				assert.Contains(t, out.String(), "(Name:     bill smith) Tj")
+			})
+		})
+	})
+
+	t.Run("Given an account with many transactions", func(t *testing.T) {
+		now = time.Date(2022, 9, 2, 12, 0, 0, 0, time.UTC)
+		acc, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "jane (smith)", Amount: 1.00})
+		require.NoError(t, err)
+		for i := 0; i < 120; i++ {
+			_, err = accService.AddMoney(ctx, acc.ID, 1.00)
+			require.NoError(t, err)
+		}
+		statement, sErr := statementService.Statement(ctx, acc.ID, time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+		require.NoError(t, sErr)
+
+		t.Run("When it is written as pdf", func(t *testing.T) {
+			var out bytes.Buffer
+			require.NoError(t, service.WriteStatementPDF(&out, statement))
+
+			t.Run("Then transactions run over several pages", func(t *testing.T) {
+				assertPDF(t, out.Bytes())
+				assert.Contains(t, out.String(), "/Count 3")
+				assert.Contains(t, out.String(), "page 3 of 3")
+				assert.Contains(t, out.String(), `(Name:     jane \(smith\)) Tj`)
+			})
+		})
+	})
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/repositories"
+	"context"
+	"github.com/google/uuid"
+	"log"
+	"sync"
+	"time"
+)
+
+// streamBatchSize bounds the events read from the outbox at once for a stream
+const streamBatchSize = 100
+
+// StreamFromHead, given to Subscribe as after, starts a stream at the events
+// committed from now on instead of the whole history of the account
+const StreamFromHead int64 = -1
+
+// EventStream pushes the events of an account as they are committed. Events
+// are read from the outbox, so a stream resumed after the last sequence it got
+// misses none of them.
+type EventStream interface {
+	// Subscribe streams the events concerning accountID after sequence after,
+	// each batch followed by the balance of the account, until ctx is done or
+	// the outbox can not be read. The balance is also sent first.
+	// StreamFromHead skips the events committed so far.
+	Subscribe(ctx context.Context, accountID uuid.UUID, after int64) (<-chan dto.AccountStreamMessage, error)
+	// Notify wakes the streams of accounts, changes about them were committed
+	Notify(accounts []uuid.UUID)
+}
+
+// NewEventStream returns an EventStream reading repository. Streams are woken
+// by Notify and look for events every poll anyway, to get those committed by
+// other instances.
+func NewEventStream(repository repositories.AccountRepository, poll time.Duration) EventStream {
+	return &eventStream{
+		repository: repository,
+		poll:       poll,
+		listeners:  make(map[uuid.UUID]map[chan struct{}]bool),
+	}
+}
+
+type eventStream struct {
+	repository repositories.AccountRepository
+	poll       time.Duration
+
+	mux       sync.Mutex
+	listeners map[uuid.UUID]map[chan struct{}]bool
+}
+
+func (s *eventStream) Subscribe(ctx context.Context, accountID uuid.UUID, after int64) (<-chan dto.AccountStreamMessage, error) {
+	if _, err := s.repository.Get(ctx, accountID); err != nil {
+		return nil, err
+	}
+	if after == StreamFromHead {
+		head, err := s.repository.LastEventSequence(ctx)
+		if err != nil {
+			return nil, err
+		}
+		after = head
+	}
+
+	// a single pending wake up is enough, the stream reads everything new
+	wake := make(chan struct{}, 1)
+	s.listen(accountID, wake)
+	messages := make(chan dto.AccountStreamMessage)
+	go func() {
+		defer close(messages)
+		defer s.forget(accountID, wake)
+		if err := s.stream(ctx, accountID, after, wake, messages); err != nil && ctx.Err() == nil {
+			log.Printf("event stream of account %s stopped: %v", accountID, err)
+		}
+	}()
+	return messages, nil
+}
+
+func (s *eventStream) stream(ctx context.Context, accountID uuid.UUID, after int64, wake <-chan struct{}, messages chan<- dto.AccountStreamMessage) error {
+	send := func(message dto.AccountStreamMessage) error {
+		select {
+		case messages <- message:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	first := true
+	for {
+		events, err := s.repository.AccountEvents(ctx, accountID, after, streamBatchSize)
+		if err != nil {
+			return err
+		}
+		for _, event := range events {
+			message := toEventMessage(event)
+			if sErr := send(dto.AccountStreamMessage{Sequence: event.Sequence, Event: &message}); sErr != nil {
+				return sErr
+			}
+			after = event.Sequence
+		}
+		if len(events) == streamBatchSize {
+			continue
+		}
+
+		if first || len(events) > 0 {
+			account, gErr := s.repository.Get(ctx, accountID)
+			if gErr != nil {
+				return gErr
+			}
+			balance := toAccountResponse(account)
+			if sErr := send(dto.AccountStreamMessage{Sequence: after, Balance: &balance}); sErr != nil {
+				return sErr
+			}
+			first = false
+		}
+
+		select {
+		case <-wake:
+		case <-time.After(s.poll):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (s *eventStream) Notify(accounts []uuid.UUID) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, accountID := range accounts {
+		for wake := range s.listeners[accountID] {
+			select {
+			case wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (s *eventStream) listen(accountID uuid.UUID, wake chan struct{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.listeners[accountID] == nil {
+		s.listeners[accountID] = make(map[chan struct{}]bool)
+	}
+	s.listeners[accountID][wake] = true
+}
+
+func (s *eventStream) forget(accountID uuid.UUID, wake chan struct{}) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.listeners[accountID], wake)
+	if len(s.listeners[accountID]) == 0 {
+		delete(s.listeners, accountID)
+	}
+}
@@ -0,0 +1,137 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// receive returns the next message of messages, failing if none comes soon
+func receive(t *testing.T, messages <-chan dto.AccountStreamMessage) dto.AccountStreamMessage {
+	t.Helper()
+	select {
+	case message, ok := <-messages:
+		require.True(t, ok, "stream closed")
+		return message
+	case <-time.After(time.Second):
+		require.FailNow(t, "no message streamed")
+		return dto.AccountStreamMessage{}
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	// streams are only woken by the account service, they never poll here
+	stream := service.NewEventStream(repo, time.Hour)
+	accService := service.NewAccountService(repo, service.WithEventStream(stream))
+
+	t.Run("Given an unknown account", func(t *testing.T) {
+		_, err := stream.Subscribe(ctx, uuid.New(), 0)
+
+		t.Run("Then it can not be streamed", func(t *testing.T) {
+			assert.ErrorIs(t, err, repositories.ErrAccountNotFound)
+		})
+	})
+
+	t.Run("Given an account with some history", func(t *testing.T) {
+		acc, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "bill smith", Amount: 100.00})
+		require.NoError(t, err)
+		other, err := accService.Create(ctx, dto.CreateAccountRequest{Name: "jane smith", Amount: 0.00})
+		require.NoError(t, err)
+		_, err = accService.AddMoney(ctx, acc.ID, 50.00)
+		require.NoError(t, err)
+
+		t.Run("When it is streamed from the start", func(t *testing.T) {
+			sCtx, cancel := context.WithCancel(ctx)
+			messages, sErr := stream.Subscribe(sCtx, acc.ID, 0)
+			require.NoError(t, sErr)
+
+			created, deposited, balance := receive(t, messages), receive(t, messages), receive(t, messages)
+
+			t.Run("Then its history comes first, followed by its balance", func(t *testing.T) {
+				require.NotNil(t, created.Event)
+				assert.Equal(t, "AccountCreated", created.Event.Type)
+				require.NotNil(t, deposited.Event)
+				assert.Equal(t, "MoneyDeposited", deposited.Event.Type)
+				assert.Less(t, created.Sequence, deposited.Sequence)
+				require.NotNil(t, balance.Balance)
+				assert.Equal(t, 150.00, balance.Balance.Amount)
+				assert.Equal(t, deposited.Sequence, balance.Sequence)
+			})
+
+			t.Run("When money moves", func(t *testing.T) {
+				_, tErr := accService.Transfer(ctx, acc.ID, other.ID, 30.00, "")
+				require.NoError(t, tErr)
+				transferred, updated := receive(t, messages), receive(t, messages)
+
+				t.Run("Then the stream gets it as it happens", func(t *testing.T) {
+					require.NotNil(t, transferred.Event)
+					assert.Equal(t, "TransferCompleted", transferred.Event.Type)
+					require.NotNil(t, updated.Balance)
+					assert.Equal(t, 120.00, updated.Balance.Amount)
+				})
+
+				t.Run("When a split transfer involves it", func(t *testing.T) {
+					_, sErr := accService.Split(ctx, dto.SplitTransferRequest{
+						Debits:  []dto.SplitLeg{{Account: other.ID, Amount: 10.00}},
+						Credits: []dto.SplitLeg{{Account: acc.ID, Amount: 10.00}},
+					})
+					require.NoError(t, sErr)
+					split := receive(t, messages)
+					receive(t, messages)
+
+					t.Run("Then the stream gets it too", func(t *testing.T) {
+						require.NotNil(t, split.Event)
+						assert.Equal(t, "TransferCompleted", split.Event.Type)
+					})
+				})
+
+				t.Run("When the stream is resumed after the deposit", func(t *testing.T) {
+					resumed, rErr := stream.Subscribe(ctx, acc.ID, deposited.Sequence)
+					require.NoError(t, rErr)
+
+					t.Run("Then only what came next is streamed", func(t *testing.T) {
+						message := receive(t, resumed)
+						require.NotNil(t, message.Event)
+						assert.Equal(t, transferred.Event.ID, message.Event.ID)
+					})
+				})
+			})
+
+			t.Run("When it is streamed from the head", func(t *testing.T) {
+				hCtx, hCancel := context.WithCancel(ctx)
+				defer hCancel()
+				fromHead, hErr := stream.Subscribe(hCtx, acc.ID, service.StreamFromHead)
+				require.NoError(t, hErr)
+				balance := receive(t, fromHead)
+				_, aErr := accService.AddMoney(ctx, acc.ID, 5.00)
+				require.NoError(t, aErr)
+				next := receive(t, fromHead)
+
+				t.Run("Then its history is skipped", func(t *testing.T) {
+					require.NotNil(t, balance.Balance)
+					assert.Greater(t, balance.Sequence, deposited.Sequence)
+					require.NotNil(t, next.Event)
+					assert.Equal(t, "MoneyDeposited", next.Event.Type)
+					assert.Greater(t, next.Sequence, balance.Sequence)
+				})
+			})
+
+			t.Run("When the client goes away", func(t *testing.T) {
+				cancel()
+
+				t.Run("Then the stream ends", func(t *testing.T) {
+					for range messages {
+					}
+				})
+			})
+		})
+	})
+}
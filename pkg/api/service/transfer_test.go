@@ -0,0 +1,87 @@
+package service_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestAccountService_Transfer_Records(t *testing.T) {
+	ctx := context.Background()
+	repo := setup(t)
+	now := time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("Given two existing accounts", func(t *testing.T) {
+		from := model.Account{ID: uuid.New(), Name: "billy", Amount: 100.00}
+		require.NoError(t, repo.Create(ctx, &from))
+		to := model.Account{ID: uuid.New(), Name: "jhon", Amount: 0.00}
+		require.NoError(t, repo.Create(ctx, &to))
+
+		accService := service.NewAccountService(repo, service.WithClock(clock))
+
+		t.Run("When a transfer completes", func(t *testing.T) {
+			resp, err := accService.Transfer(ctx, from.ID, to.ID, 40.00, "invoice 42")
+			require.NoError(t, err)
+
+			t.Run("Then it can be retrieved by its id", func(t *testing.T) {
+				got, gErr := accService.GetTransfer(ctx, resp.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.TransferCompleted), got.Status)
+				assert.Equal(t, "invoice 42", got.Memo)
+				assert.Equal(t, 40.00, got.Amount)
+				assert.True(t, now.Equal(got.CreatedAt))
+			})
+		})
+
+		t.Run("When a transfer has not enough balance", func(t *testing.T) {
+			now = now.Add(time.Minute)
+			_, err := accService.Transfer(ctx, from.ID, to.ID, 500.00, "")
+			require.Error(t, err)
+
+			t.Run("Then it is kept as failed with the reason", func(t *testing.T) {
+				transfers, lErr := accService.Transfers(ctx, from.ID)
+				require.NoError(t, lErr)
+				require.Len(t, transfers.Transfers, 2)
+				failed := transfers.Transfers[1]
+				assert.Equal(t, string(model.TransferFailed), failed.Status)
+				assert.Equal(t, err.Error(), failed.FailureReason)
+				assertBalance(t, repo, from.ID, 60.00)
+			})
+
+			t.Run("Then it can not be reversed", func(t *testing.T) {
+				transfers, lErr := accService.Transfers(ctx, from.ID)
+				require.NoError(t, lErr)
+				_, rErr := accService.Reverse(ctx, transfers.Transfers[1].ID, dto.ReversalRequest{})
+				assert.ErrorIs(t, rErr, model.ErrTransferNotCompleted)
+			})
+		})
+
+		t.Run("When a transfer is fully reversed", func(t *testing.T) {
+			now = now.Add(time.Minute)
+			resp, err := accService.Transfer(ctx, from.ID, to.ID, 10.00, "")
+			require.NoError(t, err)
+			_, rErr := accService.Reverse(ctx, resp.ID, dto.ReversalRequest{})
+			require.NoError(t, rErr)
+
+			t.Run("Then it is reversed", func(t *testing.T) {
+				got, gErr := accService.GetTransfer(ctx, resp.ID)
+				require.NoError(t, gErr)
+				assert.Equal(t, string(model.TransferReversed), got.Status)
+				assert.Equal(t, 10.00, got.Reversed)
+			})
+
+			t.Run("Then both accounts list it and its reversal", func(t *testing.T) {
+				received, lErr := accService.Transfers(ctx, to.ID)
+				require.NoError(t, lErr)
+				assert.Len(t, received.Transfers, 4)
+			})
+		})
+	})
+}
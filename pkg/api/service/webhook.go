@@ -0,0 +1,441 @@
+package service
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// webhookBatchSize bounds the deliveries claimed on every run
+	webhookBatchSize = 100
+	// webhookConcurrency is how many subscriptions are delivered to at once,
+	// the deliveries of each one are attempted in order
+	webhookConcurrency = 8
+	// webhookBudget is how long a run starts attempts for, the deliveries it
+	// did not get to are claimed again once their claim expires
+	webhookBudget = 30 * time.Second
+
+	SignatureHeader = "X-Webhook-Signature"
+	EventIDHeader   = "X-Webhook-Event-ID"
+	EventTypeHeader = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+var (
+	ErrInvalidWebhook  = errors.New("webhook needs a public http url, known event types and its accounts")
+	ErrDeliveryNotDead = errors.New("only dead deliveries can be retried")
+
+	errPrivateAddress = errors.New("webhook address is not public")
+)
+
+type WebhookService interface {
+	// Broker turns every event into deliveries for the subscriptions wanting
+	// it, the outbox relay publishes to it
+	Broker
+	Subscribe(ctx context.Context, req dto.CreateWebhookRequest) (dto.WebhookResponse, error)
+	Get(ctx context.Context, subscriptionID uuid.UUID) (dto.WebhookResponse, error)
+	List(ctx context.Context) (dto.GetWebhooksResponse, error)
+	Unsubscribe(ctx context.Context, subscriptionID uuid.UUID) error
+	// Deliveries returns the delivery log of a subscription, newest first
+	Deliveries(ctx context.Context, subscriptionID uuid.UUID) (dto.GetWebhookDeliveriesResponse, error)
+	// DeadLetters returns the deliveries that ran out of attempts
+	DeadLetters(ctx context.Context) (dto.GetWebhookDeliveriesResponse, error)
+	// Retry tries a dead delivery again from its first attempt
+	Retry(ctx context.Context, deliveryID uuid.UUID) (dto.WebhookDeliveryResponse, error)
+	// Deliver attempts every delivery that is due
+	Deliver(ctx context.Context) error
+}
+
+// WebhookOption configures the webhook service
+type WebhookOption func(*webhookService)
+
+// WithPrivateWebhooks lets subscriptions point at loopback and private
+// addresses, for tests and partners reached on the private network
+func WithPrivateWebhooks() WebhookOption {
+	return func(s *webhookService) {
+		s.private = true
+	}
+}
+
+// NewWebhookService returns a WebhookService posting with client. The
+// timeout of client bounds every attempt, NewWebhookClient makes one that only
+// reaches public addresses.
+func NewWebhookService(repository repositories.WebhookRepository, client *http.Client, policy model.RetryPolicy, clock Clock, opts ...WebhookOption) WebhookService {
+	s := &webhookService{
+		repository: repository,
+		client:     client,
+		policy:     policy,
+		clock:      clock,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// NewWebhookClient returns a client giving up on attempts after timeout that
+// refuses to connect to addresses that are not public, whatever a host
+// resolves to by the time of the attempt
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+				return fmt.Errorf("%w: %s", errPrivateAddress, address)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// a partner redirecting elsewhere is a failed delivery
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+type webhookService struct {
+	repository repositories.WebhookRepository
+	client     *http.Client
+	policy     model.RetryPolicy
+	clock      Clock
+	// private lets subscriptions point at addresses that are not public
+	private bool
+}
+
+func (s *webhookService) Subscribe(ctx context.Context, req dto.CreateWebhookRequest) (dto.WebhookResponse, error) {
+	endpoint, pErr := url.Parse(req.URL)
+	if pErr != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Hostname() == "" || endpoint.User != nil {
+		return dto.WebhookResponse{}, ErrInvalidWebhook
+	}
+	// either some accounts or, explicitly, every account
+	if (len(req.Accounts) > 0) == req.AllAccounts {
+		return dto.WebhookResponse{}, ErrInvalidWebhook
+	}
+	if !s.private {
+		if err := s.checkPublic(ctx, endpoint.Hostname()); err != nil {
+			return dto.WebhookResponse{}, err
+		}
+	}
+	eventTypes := make([]model.EventType, len(req.EventTypes))
+	for i, eventType := range req.EventTypes {
+		eventTypes[i] = model.EventType(eventType)
+		if !eventTypes[i].Valid() {
+			return dto.WebhookResponse{}, ErrInvalidWebhook
+		}
+	}
+
+	secret := make([]byte, 32)
+	if _, rErr := rand.Read(secret); rErr != nil {
+		return dto.WebhookResponse{}, rErr
+	}
+	subscription := &model.WebhookSubscription{
+		ID:          uuid.New(),
+		URL:         req.URL,
+		Secret:      "whsec_" + hex.EncodeToString(secret),
+		EventTypes:  eventTypes,
+		Accounts:    req.Accounts,
+		AllAccounts: req.AllAccounts,
+		CreatedAt:   s.clock(),
+	}
+	if err := s.repository.Subscribe(ctx, subscription); err != nil {
+		return dto.WebhookResponse{}, err
+	}
+
+	resp := toWebhookResponse(subscription)
+	resp.Secret = subscription.Secret
+	return resp, nil
+}
+
+// checkPublic refuses host when it resolves to an address that is not public,
+// so partners can not make the bank post to its own network
+func (s *webhookService) checkPublic(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return ErrInvalidWebhook
+	}
+	for _, addr := range addrs {
+		if !publicIP(addr.IP) {
+			return ErrInvalidWebhook
+		}
+	}
+	return nil
+}
+
+func publicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+func (s *webhookService) Get(ctx context.Context, subscriptionID uuid.UUID) (dto.WebhookResponse, error) {
+	subscription, err := s.repository.Subscription(ctx, subscriptionID)
+	if err != nil {
+		return dto.WebhookResponse{}, err
+	}
+	return toWebhookResponse(subscription), nil
+}
+
+func (s *webhookService) List(ctx context.Context) (dto.GetWebhooksResponse, error) {
+	subscriptions, err := s.repository.Subscriptions(ctx)
+	if err != nil {
+		return dto.GetWebhooksResponse{}, err
+	}
+
+	resp := make([]dto.WebhookResponse, len(subscriptions))
+	for i := range subscriptions {
+		resp[i] = toWebhookResponse(subscriptions[i])
+	}
+	return dto.GetWebhooksResponse{Webhooks: resp}, nil
+}
+
+func (s *webhookService) Unsubscribe(ctx context.Context, subscriptionID uuid.UUID) error {
+	return s.repository.Unsubscribe(ctx, subscriptionID)
+}
+
+func (s *webhookService) Deliveries(ctx context.Context, subscriptionID uuid.UUID) (dto.GetWebhookDeliveriesResponse, error) {
+	if _, gErr := s.repository.Subscription(ctx, subscriptionID); gErr != nil {
+		return dto.GetWebhookDeliveriesResponse{}, gErr
+	}
+	return s.deliveries(ctx, repositories.DeliveryFilter{SubscriptionID: subscriptionID})
+}
+
+func (s *webhookService) DeadLetters(ctx context.Context) (dto.GetWebhookDeliveriesResponse, error) {
+	return s.deliveries(ctx, repositories.DeliveryFilter{Status: model.DeliveryDead})
+}
+
+func (s *webhookService) deliveries(ctx context.Context, filter repositories.DeliveryFilter) (dto.GetWebhookDeliveriesResponse, error) {
+	deliveries, err := s.repository.Deliveries(ctx, filter)
+	if err != nil {
+		return dto.GetWebhookDeliveriesResponse{}, err
+	}
+
+	resp := make([]dto.WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		resp[i] = toDeliveryResponse(deliveries[i])
+	}
+	return dto.GetWebhookDeliveriesResponse{Deliveries: resp}, nil
+}
+
+func (s *webhookService) Retry(ctx context.Context, deliveryID uuid.UUID) (dto.WebhookDeliveryResponse, error) {
+	delivery, gErr := s.repository.Delivery(ctx, deliveryID)
+	if gErr != nil {
+		return dto.WebhookDeliveryResponse{}, gErr
+	}
+	if delivery.Status != model.DeliveryDead {
+		return dto.WebhookDeliveryResponse{}, ErrDeliveryNotDead
+	}
+
+	now := s.clock()
+	delivery.Status = model.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+	if err := s.repository.SaveDelivery(ctx, delivery); err != nil {
+		return dto.WebhookDeliveryResponse{}, err
+	}
+	return toDeliveryResponse(delivery), nil
+}
+
+// Publish only enqueues the deliveries, so the outbox relay is never slowed
+// down by partners. The same event published twice is delivered twice, with
+// the same event id.
+func (s *webhookService) Publish(ctx context.Context, event *model.Event) error {
+	subscriptions, err := s.repository.Subscriptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	body, mErr := json.Marshal(toEventMessage(event))
+	if mErr != nil {
+		return mErr
+	}
+
+	now := s.clock()
+	var deliveries []*model.WebhookDelivery
+	for _, subscription := range subscriptions {
+		if !subscription.Wants(event) {
+			continue
+		}
+		deliveries = append(deliveries, &model.WebhookDelivery{
+			ID:             uuid.New(),
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Body:           string(body),
+			Status:         model.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+			UpdatedAt:      now,
+		})
+	}
+	return s.repository.Enqueue(ctx, deliveries...)
+}
+
+// Deliver claims the due deliveries, so other instances do not attempt them
+// too, and delivers to webhookConcurrency subscriptions at once. Attempts are
+// only started for webhookBudget, the claim lasts until the last of them may
+// end.
+func (s *webhookService) Deliver(ctx context.Context) error {
+	start := s.clock()
+	due, err := s.repository.ClaimDue(ctx, start, start.Add(webhookBudget+s.client.Timeout), webhookBatchSize)
+	if err != nil {
+		return err
+	}
+
+	subscriptions := make(map[uuid.UUID]*model.WebhookSubscription)
+	bySubscription := make(map[uuid.UUID][]*model.WebhookDelivery)
+	var order []uuid.UUID
+	for _, delivery := range due {
+		if _, ok := subscriptions[delivery.SubscriptionID]; !ok {
+			subscription, sErr := s.repository.Subscription(ctx, delivery.SubscriptionID)
+			if sErr != nil && !errors.Is(sErr, repositories.ErrSubscriptionNotFound) {
+				return sErr
+			}
+			subscriptions[delivery.SubscriptionID] = subscription
+			order = append(order, delivery.SubscriptionID)
+		}
+		bySubscription[delivery.SubscriptionID] = append(bySubscription[delivery.SubscriptionID], delivery)
+	}
+
+	queue := make(chan uuid.UUID, len(order))
+	for _, subscriptionID := range order {
+		queue <- subscriptionID
+	}
+	close(queue)
+	errs := make(chan error, len(due))
+	var wg sync.WaitGroup
+	for i := 0; i < webhookConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for subscriptionID := range queue {
+				for _, delivery := range bySubscription[subscriptionID] {
+					if ctx.Err() != nil || s.clock().Sub(start) >= webhookBudget {
+						return
+					}
+					if sErr := s.deliver(ctx, subscriptions[subscriptionID], delivery); sErr != nil {
+						errs <- sErr
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// the first error, nil when there was none
+	return <-errs
+}
+
+// deliver attempts delivery and saves how it went, unless another instance
+// claimed it in the meantime
+func (s *webhookService) deliver(ctx context.Context, subscription *model.WebhookSubscription, delivery *model.WebhookDelivery) error {
+	s.attempt(ctx, subscription, delivery)
+	err := s.repository.SaveDelivery(ctx, delivery)
+	if errors.Is(err, repositories.ErrDeliveryClaimLost) {
+		log.Printf("webhook delivery %s was claimed again during its attempt", delivery.ID)
+		return nil
+	}
+	return err
+}
+
+// attempt posts delivery to subscription, leaving in delivery how it went. A
+// removed subscription, nil, is not tried again.
+func (s *webhookService) attempt(ctx context.Context, subscription *model.WebhookSubscription, delivery *model.WebhookDelivery) {
+	now := s.clock()
+	if subscription == nil {
+		delivery.Status = model.DeliveryDead
+		delivery.LastError = "subscription removed"
+		delivery.UpdatedAt = now
+		return
+	}
+
+	req, rErr := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, strings.NewReader(delivery.Body))
+	if rErr != nil {
+		s.policy.Failed(delivery, now, 0, rErr.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, model.SignWebhook(subscription.Secret, now, []byte(delivery.Body)))
+	req.Header.Set(EventIDHeader, delivery.EventID.String())
+	req.Header.Set(EventTypeHeader, string(delivery.EventType))
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+
+	resp, dErr := s.client.Do(req)
+	if dErr != nil {
+		s.policy.Failed(delivery, now, 0, dErr.Error())
+		return
+	}
+	// the body is not needed, reading it lets the connection be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		s.policy.Failed(delivery, now, resp.StatusCode, fmt.Sprintf("unexpected status %d", resp.StatusCode))
+		return
+	}
+	delivery.Attempts++
+	delivery.Status = model.DeliveryDelivered
+	delivery.LastStatusCode = resp.StatusCode
+	delivery.LastError = ""
+	delivery.UpdatedAt = now
+	delivery.DeliveredAt = &now
+}
+
+func toWebhookResponse(subscription *model.WebhookSubscription) dto.WebhookResponse {
+	eventTypes := make([]string, len(subscription.EventTypes))
+	for i, eventType := range subscription.EventTypes {
+		eventTypes[i] = string(eventType)
+	}
+	return dto.WebhookResponse{
+		ID:          subscription.ID,
+		URL:         subscription.URL,
+		EventTypes:  eventTypes,
+		Accounts:    subscription.Accounts,
+		AllAccounts: subscription.AllAccounts,
+		CreatedAt:   subscription.CreatedAt,
+	}
+}
+
+func toDeliveryResponse(delivery *model.WebhookDelivery) dto.WebhookDeliveryResponse {
+	return dto.WebhookDeliveryResponse{
+		ID:             delivery.ID,
+		SubscriptionID: delivery.SubscriptionID,
+		EventID:        delivery.EventID,
+		EventType:      string(delivery.EventType),
+		Status:         string(delivery.Status),
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt,
+		UpdatedAt:      delivery.UpdatedAt,
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+}
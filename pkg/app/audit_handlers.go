@@ -0,0 +1,74 @@
+package app
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func (s *Server) ListAuditRecords() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		filter, pErr := auditFilter(ctx)
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, lErr := s.auditService.Trail(ctx.Request.Context(), filter)
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) VerifyAudit() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resp, vErr := s.auditService.Verify(ctx.Request.Context())
+		if vErr != nil {
+			ctx.AbortWithStatus(errorStatus(vErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+// auditFilter reads the filter of the audit trail from the query, times are
+// RFC 3339
+func auditFilter(ctx *gin.Context) (repositories.AuditFilter, error) {
+	filter := repositories.AuditFilter{
+		Actor:  ctx.Query("actor"),
+		Action: model.AuditAction(ctx.Query("action")),
+	}
+	var err error
+	if entity := ctx.Query("entity"); entity != "" {
+		if filter.EntityID, err = uuid.Parse(entity); err != nil {
+			return filter, err
+		}
+	}
+	if from := ctx.Query("from"); from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return filter, err
+		}
+	}
+	if to := ctx.Query("to"); to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return filter, err
+		}
+	}
+	if after := ctx.Query("after"); after != "" {
+		if filter.AfterSequence, err = strconv.ParseInt(after, 10, 64); err != nil {
+			return filter, err
+		}
+	}
+	if limit := ctx.Query("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
@@ -2,6 +2,11 @@ package app
 
 import (
 	"bank/pkg/api/dto"
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -19,9 +24,10 @@ func (s *Server) Create() gin.HandlerFunc {
 				})
 			return
 		}
-		resp, cErr := s.accountService.Create(req)
+		resp, cErr := s.accountService.Create(ctx.Request.Context(), req)
 		if cErr != nil {
-			ctx.AbortWithStatus(http.StatusInternalServerError)
+			abortWithReason(ctx, cErr)
+			return
 		}
 		ctx.IndentedJSON(http.StatusCreated, resp)
 		return
@@ -42,15 +48,38 @@ func (s *Server) AddMoney() gin.HandlerFunc {
 			ctx.IndentedJSON(http.StatusBadRequest, bindErr)
 			return
 		}
-		resp, cErr := s.accountService.AddMoney(accID, req.Amount)
+		resp, cErr := s.accountService.AddMoney(ctx.Request.Context(), accID, req.Amount)
 		if cErr != nil {
-			ctx.AbortWithStatus(http.StatusInternalServerError)
+			ctx.AbortWithStatus(errorStatus(cErr))
+			return
 		}
 		ctx.IndentedJSON(http.StatusAccepted, resp)
 		return
 	}
 }
 
+func (s *Server) Withdraw() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accID, pErr := uuid.Parse(ctx.Param("accountID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		var req dto.UpdateAccountRequest
+		bindErr := ctx.ShouldBindJSON(&req)
+		if bindErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, bindErr)
+			return
+		}
+		resp, wErr := s.accountService.Withdraw(ctx.Request.Context(), accID, req.Amount)
+		if wErr != nil {
+			abortWithReason(ctx, wErr)
+			return
+		}
+		ctx.IndentedJSON(http.StatusAccepted, resp)
+	}
+}
+
 func (s *Server) Transfer() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		var req dto.TransferenceRequest
@@ -59,15 +88,166 @@ func (s *Server) Transfer() gin.HandlerFunc {
 			ctx.IndentedJSON(http.StatusBadRequest, bindErr)
 			return
 		}
-		cErr := s.accountService.Transfer(req.From, req.To, req.Amount)
+		if req.ExecuteAt != nil {
+			s.schedule(ctx, req)
+			return
+		}
+		resp, cErr := s.accountService.Transfer(ctx.Request.Context(), req.From, req.To, req.Amount, req.Memo)
 		if cErr != nil {
-			ctx.AbortWithStatus(http.StatusInternalServerError)
+			abortWithReason(ctx, cErr)
+			return
 		}
-		ctx.IndentedJSON(http.StatusAccepted, gin.H{})
+		ctx.IndentedJSON(http.StatusAccepted, resp)
 		return
 	}
 }
 
+func (s *Server) BatchTransfer() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req dto.BatchTransferRequest
+		bindErr := ctx.ShouldBindJSON(&req)
+		if bindErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, bindErr)
+			return
+		}
+		resp, bErr := s.accountService.Batch(ctx.Request.Context(), req)
+		if errors.Is(bErr, service.ErrBatchRejected) {
+			// tell which transfer made the batch fail
+			ctx.IndentedJSON(http.StatusUnprocessableEntity, resp)
+			return
+		}
+		if bErr != nil {
+			ctx.AbortWithStatus(errorStatus(bErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusAccepted, resp)
+	}
+}
+
+func (s *Server) SplitTransfer() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req dto.SplitTransferRequest
+		bindErr := ctx.ShouldBindJSON(&req)
+		if bindErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, bindErr)
+			return
+		}
+		resp, sErr := s.accountService.Split(ctx.Request.Context(), req)
+		if sErr != nil {
+			abortWithReason(ctx, sErr)
+			return
+		}
+		ctx.IndentedJSON(http.StatusAccepted, resp)
+	}
+}
+
+func (s *Server) GetTransfer() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		transferID, pErr := uuid.Parse(ctx.Param("transferID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, gErr := s.accountService.GetTransfer(ctx.Request.Context(), transferID)
+		if gErr != nil {
+			ctx.AbortWithStatus(errorStatus(gErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) ListTransfers() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accID, pErr := uuid.Parse(ctx.Query("account"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, lErr := s.accountService.Transfers(ctx.Request.Context(), accID)
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) Reverse() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		transferID, pErr := uuid.Parse(ctx.Param("transferID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		// the body is optional, an empty one reverses the whole transfer
+		var req dto.ReversalRequest
+		if ctx.Request.ContentLength != 0 {
+			if bindErr := ctx.ShouldBindJSON(&req); bindErr != nil {
+				ctx.IndentedJSON(http.StatusBadRequest, bindErr)
+				return
+			}
+		}
+		resp, rErr := s.accountService.Reverse(ctx.Request.Context(), transferID, req)
+		if rErr != nil {
+			ctx.AbortWithStatus(errorStatus(rErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusCreated, resp)
+	}
+}
+
+func (s *Server) ReviewQueue() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resp, lErr := s.accountService.ReviewQueue(ctx.Request.Context())
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) ApproveTransfer() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		transferID, pErr := uuid.Parse(ctx.Param("transferID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, aErr := s.accountService.Approve(ctx.Request.Context(), transferID)
+		if aErr != nil {
+			abortWithReason(ctx, aErr)
+			return
+		}
+		ctx.IndentedJSON(http.StatusAccepted, resp)
+	}
+}
+
+func (s *Server) RejectTransfer() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		transferID, pErr := uuid.Parse(ctx.Param("transferID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		// the body is optional, it only tells why
+		var req dto.RejectTransferRequest
+		if ctx.Request.ContentLength != 0 {
+			if bindErr := ctx.ShouldBindJSON(&req); bindErr != nil {
+				ctx.IndentedJSON(http.StatusBadRequest, bindErr)
+				return
+			}
+		}
+		resp, rErr := s.accountService.Reject(ctx.Request.Context(), transferID, req)
+		if rErr != nil {
+			ctx.AbortWithStatus(errorStatus(rErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
 func (s *Server) Get() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		accountIDPar := ctx.Param("accountID")
@@ -76,22 +256,106 @@ func (s *Server) Get() gin.HandlerFunc {
 			ctx.IndentedJSON(http.StatusBadRequest, pErr)
 			return
 		}
-		resp, cErr := s.accountService.Get(accID)
+		resp, cErr := s.accountService.Get(ctx.Request.Context(), accID)
 		if cErr != nil {
-			ctx.AbortWithStatus(http.StatusInternalServerError)
+			ctx.AbortWithStatus(errorStatus(cErr))
+			return
 		}
 		ctx.IndentedJSON(http.StatusAccepted, resp)
 		return
 	}
 }
 
+func (s *Server) Transactions() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accountIDPar := ctx.Param("accountID")
+		accID, pErr := uuid.Parse(accountIDPar)
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, cErr := s.accountService.Transactions(ctx.Request.Context(), accID)
+		if cErr != nil {
+			ctx.AbortWithStatus(errorStatus(cErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
 func (s *Server) GetAll() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		resp, cErr := s.accountService.GetAll()
+		resp, cErr := s.accountService.GetAll(ctx.Request.Context())
 		if cErr != nil {
-			ctx.AbortWithStatus(http.StatusInternalServerError)
+			ctx.AbortWithStatus(errorStatus(cErr))
+			return
 		}
 		ctx.IndentedJSON(http.StatusAccepted, resp)
 		return
 	}
 }
+
+// LockStats tells how often account operations waited on each other
+func (s *Server) LockStats() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.IndentedJSON(http.StatusOK, s.accountService.LockStats(ctx.Request.Context()))
+	}
+}
+
+// statusClientClosedRequest answers requests the client gave up on before
+// they were served, net/http has no name for it
+const statusClientClosedRequest = 499
+
+// errorStatus maps a service error to the http status returned to the client
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	case errors.Is(err, repositories.ErrAccountNotFound),
+		errors.Is(err, repositories.ErrScheduledTransferNotFound),
+		errors.Is(err, repositories.ErrStandingOrderNotFound),
+		errors.Is(err, repositories.ErrHoldNotFound),
+		errors.Is(err, repositories.ErrSubscriptionNotFound),
+		errors.Is(err, repositories.ErrDeliveryNotFound),
+		errors.Is(err, repositories.ErrTransferNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repositories.ErrScheduledTransferNotPending),
+		errors.Is(err, repositories.ErrStandingOrderNotActive),
+		errors.Is(err, service.ErrHoldNotActive),
+		errors.Is(err, service.ErrNotReversible),
+		errors.Is(err, service.ErrTransferNotInReview),
+		errors.Is(err, service.ErrDeliveryNotDead),
+		errors.Is(err, repositories.ErrVersionConflict),
+		errors.Is(err, model.ErrOverReversal),
+		errors.Is(err, model.ErrTransferNotCompleted):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrInvalidSchedule),
+		errors.Is(err, service.ErrUnknownProduct),
+		errors.Is(err, service.ErrInvalidHold),
+		errors.Is(err, service.ErrUnbalancedTransfer),
+		errors.Is(err, service.ErrInvalidWebhook),
+		errors.Is(err, service.ErrInvalidStatement):
+		return http.StatusBadRequest
+	case errors.Is(err, model.ErrLimitExceeded),
+		errors.Is(err, service.ErrTransferDenied),
+		errors.Is(err, service.ErrHeldForReview),
+		errors.Is(err, service.ErrSanctioned):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, service.ErrAccountBusy):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// abortWithReason is like aborting with errorStatus, telling the client why
+// when it is a rule of the bank it broke
+func abortWithReason(ctx *gin.Context, err error) {
+	status := errorStatus(err)
+	if status == http.StatusUnprocessableEntity {
+		ctx.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.AbortWithStatus(status)
+}
@@ -0,0 +1,123 @@
+package app_test
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/repositories"
+	"bank/pkg/api/service"
+	"bank/pkg/app"
+	"bank/pkg/config"
+	"context"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubAccounts answers Get with err once the request context is done, or
+// right away when there is no wait
+type stubAccounts struct {
+	service.AccountService
+	err         error
+	wait        bool
+	hadDeadline bool
+}
+
+func (s *stubAccounts) Get(ctx context.Context, _ uuid.UUID) (dto.GetAccountResponse, error) {
+	_, s.hadDeadline = ctx.Deadline()
+	if s.wait {
+		<-ctx.Done()
+		return dto.GetAccountResponse{}, fmt.Errorf("get account: %w", ctx.Err())
+	}
+	return dto.GetAccountResponse{}, s.err
+}
+
+func get(ctx context.Context, router *gin.Engine) *httptest.ResponseRecorder {
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/account/"+uuid.NewString(), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("Given a route with a deadline", func(t *testing.T) {
+		accounts := &stubAccounts{wait: true}
+		router := app.NewServer(gin.New(), accounts).
+			WithTimeouts(config.Timeouts{Get: 10 * time.Millisecond}).
+			Routes()
+
+		t.Run("When the service outlives it", func(t *testing.T) {
+			w := get(context.Background(), router)
+
+			t.Run("Then the service saw the deadline", func(t *testing.T) {
+				assert.True(t, accounts.hadDeadline)
+			})
+
+			t.Run("Then it fails as gateway timeout", func(t *testing.T) {
+				assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+			})
+		})
+
+		t.Run("When the client gives up first", func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			w := get(ctx, router)
+
+			t.Run("Then it fails as client closed request", func(t *testing.T) {
+				assert.Equal(t, 499, w.Code)
+			})
+		})
+	})
+
+	t.Run("Given a route with its deadline disabled", func(t *testing.T) {
+		accounts := &stubAccounts{err: repositories.ErrAccountNotFound}
+		router := app.NewServer(gin.New(), accounts).
+			WithTimeouts(config.Timeouts{}).
+			Routes()
+
+		t.Run("When it is called", func(t *testing.T) {
+			get(context.Background(), router)
+
+			t.Run("Then the service has no deadline", func(t *testing.T) {
+				assert.False(t, accounts.hadDeadline)
+			})
+		})
+	})
+}
+
+func TestErrorStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cases := []struct {
+		err    error
+		status int
+	}{
+		{repositories.ErrAccountNotFound, http.StatusNotFound},
+		{repositories.ErrVersionConflict, http.StatusConflict},
+		{service.ErrInvalidHold, http.StatusBadRequest},
+		{service.ErrTransferDenied, http.StatusUnprocessableEntity},
+		{service.ErrAccountBusy, http.StatusServiceUnavailable},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+		{context.Canceled, 499},
+		{fmt.Errorf("database gone"), http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		t.Run(fmt.Sprintf("Given the service fails with %q", c.err), func(t *testing.T) {
+			accounts := &stubAccounts{err: fmt.Errorf("get account: %w", c.err)}
+			router := app.NewServer(gin.New(), accounts).Routes()
+
+			t.Run("When the account is read", func(t *testing.T) {
+				w := get(context.Background(), router)
+
+				t.Run(fmt.Sprintf("Then it answers %d", c.status), func(t *testing.T) {
+					assert.Equal(t, c.status, w.Code)
+				})
+			})
+		})
+	}
+}
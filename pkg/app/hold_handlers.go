@@ -0,0 +1,109 @@
+package app
+
+import (
+	"bank/pkg/api/dto"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (s *Server) PlaceHold() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accID, pErr := uuid.Parse(ctx.Param("accountID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		var req dto.PlaceHoldRequest
+		bindErr := ctx.ShouldBindJSON(&req)
+		if bindErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest,
+				gin.H{"error": "fields validation failed",
+					"desc": fmt.Sprintf("deails %v", bindErr),
+				})
+			return
+		}
+		resp, hErr := s.holdService.Place(ctx.Request.Context(), accID, req)
+		if hErr != nil {
+			ctx.AbortWithStatus(errorStatus(hErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusCreated, resp)
+	}
+}
+
+func (s *Server) ListHolds() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accID, pErr := uuid.Parse(ctx.Param("accountID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, lErr := s.holdService.List(ctx.Request.Context(), accID)
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) GetHold() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		holdID, pErr := uuid.Parse(ctx.Param("holdID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, gErr := s.holdService.Get(ctx.Request.Context(), holdID)
+		if gErr != nil {
+			ctx.AbortWithStatus(errorStatus(gErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) CaptureHold() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		holdID, pErr := uuid.Parse(ctx.Param("holdID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		// the body is optional, an empty one captures the whole hold
+		var req dto.CaptureHoldRequest
+		if ctx.Request.ContentLength != 0 {
+			if bindErr := ctx.ShouldBindJSON(&req); bindErr != nil {
+				ctx.IndentedJSON(http.StatusBadRequest,
+					gin.H{"error": "fields validation failed",
+						"desc": fmt.Sprintf("deails %v", bindErr),
+					})
+				return
+			}
+		}
+		resp, cErr := s.holdService.Capture(ctx.Request.Context(), holdID, req.Amount)
+		if cErr != nil {
+			abortWithReason(ctx, cErr)
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) ReleaseHold() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		holdID, pErr := uuid.Parse(ctx.Param("holdID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, rErr := s.holdService.Release(ctx.Request.Context(), holdID)
+		if rErr != nil {
+			ctx.AbortWithStatus(errorStatus(rErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
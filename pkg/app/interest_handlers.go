@@ -0,0 +1,23 @@
+package app
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (s *Server) GetInterest() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accID, pErr := uuid.Parse(ctx.Param("accountID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, gErr := s.interestService.Accruals(ctx.Request.Context(), accID)
+		if gErr != nil {
+			ctx.AbortWithStatus(errorStatus(gErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
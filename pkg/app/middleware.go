@@ -0,0 +1,58 @@
+package app
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/service"
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"time"
+)
+
+const (
+	// ActorHeader names who makes the request, set by the gateway in front of
+	// the service once the caller is authenticated
+	ActorHeader     = "X-Actor"
+	RequestIDHeader = "X-Request-ID"
+	// anonymousActor audits requests that did not tell who made them
+	anonymousActor = "anonymous"
+)
+
+// Timeout bounds the request context by d so every downstream call made with
+// it gives up once the deadline passes. A non positive d leaves it untouched.
+func Timeout(d time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if d <= 0 {
+			ctx.Next()
+			return
+		}
+		tCtx, cancel := context.WithTimeout(ctx.Request.Context(), d)
+		defer cancel()
+
+		ctx.Request = ctx.Request.WithContext(tCtx)
+		ctx.Next()
+	}
+}
+
+// Actor tells the services who made the request, for the audit trail. The
+// request id is taken from the request, or made up, and echoed back so
+// callers can find the records of their requests.
+func Actor() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		actor := model.Actor{
+			ID:        ctx.GetHeader(ActorHeader),
+			RequestID: ctx.GetHeader(RequestIDHeader),
+			SourceIP:  ctx.ClientIP(),
+		}
+		if actor.ID == "" {
+			actor.ID = anonymousActor
+		}
+		if actor.RequestID == "" {
+			actor.RequestID = uuid.NewString()
+		}
+		ctx.Header(RequestIDHeader, actor.RequestID)
+
+		ctx.Request = ctx.Request.WithContext(service.WithActor(ctx.Request.Context(), actor))
+		ctx.Next()
+	}
+}
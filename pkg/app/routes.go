@@ -4,18 +4,101 @@ import "github.com/gin-gonic/gin"
 
 func (s *Server) Routes() *gin.Engine {
 	router := s.router
+	router.Use(Actor())
 
 	accV1 := router.Group("/v1/account")
 	{
-		accV1.POST("/", s.Create())
-		accV1.PATCH("/:accountID/money", s.AddMoney())
-		accV1.GET("/", s.GetAll())
-		accV1.GET("/:accountID", s.Get())
+		accV1.POST("/", Timeout(s.timeouts.Create), s.Create())
+		accV1.PATCH("/:accountID/money", Timeout(s.timeouts.AddMoney), s.AddMoney())
+		accV1.PATCH("/:accountID/withdrawal", Timeout(s.timeouts.AddMoney), s.Withdraw())
+		accV1.GET("/", Timeout(s.timeouts.GetAll), s.GetAll())
+		accV1.GET("/:accountID", Timeout(s.timeouts.Get), s.Get())
+		accV1.GET("/:accountID/transactions", Timeout(s.timeouts.Get), s.Transactions())
+		if s.interestService != nil {
+			accV1.GET("/:accountID/interest", Timeout(s.timeouts.Get), s.GetInterest())
+		}
+		if s.statementService != nil {
+			accV1.GET("/:accountID/statements", Timeout(s.timeouts.Get), s.Statement())
+		}
+		if s.holdService != nil {
+			accV1.POST("/:accountID/holds", Timeout(s.timeouts.Transfer), s.PlaceHold())
+			accV1.GET("/:accountID/holds", Timeout(s.timeouts.Get), s.ListHolds())
+		}
+		if s.eventStream != nil {
+			// streams stay open, they end when the client goes away
+			accV1.GET("/:accountID/events", s.AccountEvents())
+		}
 	}
 
 	transferV1 := router.Group("/v1/transfer")
 	{
-		transferV1.POST("/", s.Transfer())
+		transferV1.POST("/", Timeout(s.timeouts.Transfer), s.Transfer())
+		transferV1.GET("/", Timeout(s.timeouts.GetAll), s.ListTransfers())
+		transferV1.GET("/:transferID", Timeout(s.timeouts.Get), s.GetTransfer())
+		transferV1.POST("/:transferID/reversal", Timeout(s.timeouts.Transfer), s.Reverse())
+		transferV1.GET("/review", Timeout(s.timeouts.GetAll), s.ReviewQueue())
+		transferV1.POST("/:transferID/approve", Timeout(s.timeouts.Transfer), s.ApproveTransfer())
+		transferV1.POST("/:transferID/reject", Timeout(s.timeouts.Transfer), s.RejectTransfer())
+		if s.scheduleService != nil {
+			transferV1.GET("/scheduled", Timeout(s.timeouts.Get), s.ListScheduled())
+			transferV1.DELETE("/scheduled/:transferID", Timeout(s.timeouts.Transfer), s.CancelScheduled())
+		}
+	}
+
+	transfersV1 := router.Group("/v1/transfers")
+	{
+		transfersV1.POST("/batch", Timeout(s.timeouts.Batch), s.BatchTransfer())
+		transfersV1.POST("/split", Timeout(s.timeouts.Transfer), s.SplitTransfer())
+	}
+
+	if s.standingOrderService != nil {
+		ordersV1 := router.Group("/v1/standing-orders")
+		{
+			ordersV1.POST("/", Timeout(s.timeouts.Create), s.CreateStandingOrder())
+			ordersV1.GET("/", Timeout(s.timeouts.GetAll), s.ListStandingOrders())
+			ordersV1.GET("/:orderID", Timeout(s.timeouts.Get), s.GetStandingOrder())
+			ordersV1.DELETE("/:orderID", Timeout(s.timeouts.Get), s.CancelStandingOrder())
+			ordersV1.GET("/:orderID/runs", Timeout(s.timeouts.Get), s.StandingOrderRuns())
+		}
+	}
+
+	if s.holdService != nil {
+		holdsV1 := router.Group("/v1/holds")
+		{
+			holdsV1.GET("/:holdID", Timeout(s.timeouts.Get), s.GetHold())
+			holdsV1.POST("/:holdID/capture", Timeout(s.timeouts.Transfer), s.CaptureHold())
+			holdsV1.POST("/:holdID/release", Timeout(s.timeouts.Transfer), s.ReleaseHold())
+		}
+	}
+
+	if s.sanctionsService != nil {
+		sanctionsV1 := router.Group("/v1/sanctions")
+		{
+			sanctionsV1.GET("/checks", Timeout(s.timeouts.GetAll), s.ListSanctionsChecks())
+		}
+	}
+
+	if s.webhookService != nil {
+		webhooksV1 := router.Group("/v1/webhooks")
+		{
+			webhooksV1.POST("/", Timeout(s.timeouts.Create), s.CreateWebhook())
+			webhooksV1.GET("/", Timeout(s.timeouts.GetAll), s.ListWebhooks())
+			webhooksV1.GET("/dead-letters", Timeout(s.timeouts.GetAll), s.WebhookDeadLetters())
+			webhooksV1.POST("/deliveries/:deliveryID/retry", Timeout(s.timeouts.Get), s.RetryWebhookDelivery())
+			webhooksV1.GET("/:webhookID", Timeout(s.timeouts.Get), s.GetWebhook())
+			webhooksV1.DELETE("/:webhookID", Timeout(s.timeouts.Get), s.DeleteWebhook())
+			webhooksV1.GET("/:webhookID/deliveries", Timeout(s.timeouts.GetAll), s.WebhookDeliveries())
+		}
+	}
+
+	router.GET("/v1/locks", Timeout(s.timeouts.Get), s.LockStats())
+
+	if s.auditService != nil {
+		auditV1 := router.Group("/v1/audit")
+		{
+			auditV1.GET("/", Timeout(s.timeouts.GetAll), s.ListAuditRecords())
+			auditV1.GET("/verify", Timeout(s.timeouts.GetAll), s.VerifyAudit())
+		}
 	}
 
 	return router
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"bank/pkg/api/model"
+	"bank/pkg/api/repositories"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (s *Server) ListSanctionsChecks() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		filter := repositories.SanctionsFilter{Decision: model.SanctionsDecision(ctx.Query("decision"))}
+		if subject := ctx.Query("subject"); subject != "" {
+			subjectID, pErr := uuid.Parse(subject)
+			if pErr != nil {
+				ctx.IndentedJSON(http.StatusBadRequest, pErr)
+				return
+			}
+			filter.SubjectID = subjectID
+		}
+		resp, lErr := s.sanctionsService.Checks(ctx.Request.Context(), filter)
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
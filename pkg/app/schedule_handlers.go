@@ -0,0 +1,52 @@
+package app
+
+import (
+	"bank/pkg/api/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (s *Server) schedule(ctx *gin.Context, req dto.TransferenceRequest) {
+	if s.scheduleService == nil {
+		ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": "scheduled transfers are not enabled"})
+		return
+	}
+	resp, sErr := s.scheduleService.Schedule(ctx.Request.Context(), req)
+	if sErr != nil {
+		ctx.AbortWithStatus(errorStatus(sErr))
+		return
+	}
+	ctx.IndentedJSON(http.StatusAccepted, resp)
+}
+
+func (s *Server) ListScheduled() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accID, pErr := uuid.Parse(ctx.Query("account"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, lErr := s.scheduleService.ListPending(ctx.Request.Context(), accID)
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) CancelScheduled() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		transferID, pErr := uuid.Parse(ctx.Param("transferID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		if cErr := s.scheduleService.Cancel(ctx.Request.Context(), transferID); cErr != nil {
+			ctx.AbortWithStatus(errorStatus(cErr))
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
@@ -2,22 +2,94 @@ package app
 
 import (
 	"bank/pkg/api/service"
+	"bank/pkg/config"
 	"github.com/gin-gonic/gin"
 	"log"
 )
 
 type Server struct {
-	accountService service.AccountService
-	router         *gin.Engine
+	accountService       service.AccountService
+	scheduleService      service.ScheduleService
+	standingOrderService service.StandingOrderService
+	interestService      service.InterestService
+	statementService     service.StatementService
+	holdService          service.HoldService
+	sanctionsService     service.SanctionsService
+	auditService         service.AuditService
+	webhookService       service.WebhookService
+	eventStream          service.EventStream
+	router               *gin.Engine
+	timeouts             config.Timeouts
 }
 
 func NewServer(router *gin.Engine, service service.AccountService) *Server {
 	return &Server{
 		router:         router,
 		accountService: service,
+		timeouts:       config.DefaultTimeouts(),
 	}
 }
 
+// WithTimeouts overrides the default per route deadlines
+func (s *Server) WithTimeouts(timeouts config.Timeouts) *Server {
+	s.timeouts = timeouts
+	return s
+}
+
+// WithScheduleService enables future dated transfers
+func (s *Server) WithScheduleService(scheduleService service.ScheduleService) *Server {
+	s.scheduleService = scheduleService
+	return s
+}
+
+// WithStandingOrderService enables recurring transfers
+func (s *Server) WithStandingOrderService(standingOrderService service.StandingOrderService) *Server {
+	s.standingOrderService = standingOrderService
+	return s
+}
+
+// WithInterestService exposes the interest accrued by accounts
+func (s *Server) WithInterestService(interestService service.InterestService) *Server {
+	s.interestService = interestService
+	return s
+}
+
+// WithStatementService enables account statements
+func (s *Server) WithStatementService(statementService service.StatementService) *Server {
+	s.statementService = statementService
+	return s
+}
+
+// WithHoldService enables holds and authorize/capture payments
+func (s *Server) WithHoldService(holdService service.HoldService) *Server {
+	s.holdService = holdService
+	return s
+}
+
+// WithSanctionsService exposes the sanctions checks recorded for audit
+func (s *Server) WithSanctionsService(sanctionsService service.SanctionsService) *Server {
+	s.sanctionsService = sanctionsService
+	return s
+}
+
+// WithAuditService exposes the audit trail
+func (s *Server) WithAuditService(auditService service.AuditService) *Server {
+	s.auditService = auditService
+	return s
+}
+
+// WithWebhookService enables webhooks
+func (s *Server) WithWebhookService(webhookService service.WebhookService) *Server {
+	s.webhookService = webhookService
+	return s
+}
+
+// WithEventStream enables the event stream of every account
+func (s *Server) WithEventStream(eventStream service.EventStream) *Server {
+	s.eventStream = eventStream
+	return s
+}
+
 func (s *Server) Run() error {
 	r := s.Routes()
 
@@ -0,0 +1,92 @@
+package app
+
+import (
+	"bank/pkg/api/dto"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (s *Server) CreateStandingOrder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req dto.CreateStandingOrderRequest
+		bindErr := ctx.ShouldBindJSON(&req)
+		if bindErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest,
+				gin.H{"error": "fields validation failed",
+					"desc": fmt.Sprintf("deails %v", bindErr),
+				})
+			return
+		}
+		resp, cErr := s.standingOrderService.Create(ctx.Request.Context(), req)
+		if cErr != nil {
+			ctx.AbortWithStatus(errorStatus(cErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusCreated, resp)
+	}
+}
+
+func (s *Server) ListStandingOrders() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accID, pErr := uuid.Parse(ctx.Query("account"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, lErr := s.standingOrderService.List(ctx.Request.Context(), accID)
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) GetStandingOrder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		orderID, pErr := uuid.Parse(ctx.Param("orderID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, gErr := s.standingOrderService.Get(ctx.Request.Context(), orderID)
+		if gErr != nil {
+			ctx.AbortWithStatus(errorStatus(gErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) CancelStandingOrder() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		orderID, pErr := uuid.Parse(ctx.Param("orderID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		if cErr := s.standingOrderService.Cancel(ctx.Request.Context(), orderID); cErr != nil {
+			ctx.AbortWithStatus(errorStatus(cErr))
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func (s *Server) StandingOrderRuns() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		orderID, pErr := uuid.Parse(ctx.Param("orderID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, rErr := s.standingOrderService.Runs(ctx.Request.Context(), orderID)
+		if rErr != nil {
+			ctx.AbortWithStatus(errorStatus(rErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/service"
+	"bytes"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"time"
+)
+
+// statementExport writes statements in a format other than json
+type statementExport struct {
+	write       func(w io.Writer, statement dto.StatementResponse) error
+	contentType string
+	extension   string
+}
+
+var statementExports = map[string]statementExport{
+	service.StatementCSV:     {service.WriteStatementCSV, "text/csv", "csv"},
+	service.StatementPDF:     {service.WriteStatementPDF, "application/pdf", "pdf"},
+	service.StatementCAMT053: {service.WriteStatementCAMT053, "application/xml", "xml"},
+	service.StatementOFX:     {service.WriteStatementOFX, "application/x-ofx", "ofx"},
+}
+
+// Statement returns the statement of an account over the days from and to
+// of the query, in the format of the query: json (default), csv, pdf, camt053
+// or ofx
+func (s *Server) Statement() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accID, pErr := uuid.Parse(ctx.Param("accountID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		var from, to time.Time
+		var err error
+		if query := ctx.Query("from"); query != "" {
+			if from, err = time.Parse("2006-01-02", query); err != nil {
+				ctx.IndentedJSON(http.StatusBadRequest, err)
+				return
+			}
+		}
+		if query := ctx.Query("to"); query != "" {
+			if to, err = time.Parse("2006-01-02", query); err != nil {
+				ctx.IndentedJSON(http.StatusBadRequest, err)
+				return
+			}
+		}
+		format := ctx.DefaultQuery("format", service.StatementJSON)
+		export, ok := statementExports[format]
+		if !ok && format != service.StatementJSON {
+			ctx.AbortWithStatus(errorStatus(service.ErrInvalidStatement))
+			return
+		}
+
+		statement, sErr := s.statementService.Statement(ctx.Request.Context(), accID, from, to)
+		if sErr != nil {
+			ctx.AbortWithStatus(errorStatus(sErr))
+			return
+		}
+		if format == service.StatementJSON {
+			ctx.IndentedJSON(http.StatusOK, statement)
+			return
+		}
+
+		var body bytes.Buffer
+		if wErr := export.write(&body, statement); wErr != nil {
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"statement-%s-%s-%s.%s\"",
+			statement.AccountID, statement.From.Format("2006-01-02"), statement.To.Format("2006-01-02"), export.extension))
+		ctx.Data(http.StatusOK, export.contentType, body.Bytes())
+	}
+}
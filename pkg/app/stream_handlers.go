@@ -0,0 +1,88 @@
+package app
+
+import (
+	"bank/pkg/api/dto"
+	"bank/pkg/api/service"
+	"encoding/json"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// LastEventIDHeader is sent back by browsers reconnecting to a stream
+	LastEventIDHeader = "Last-Event-ID"
+	// streamKeepAlive is how often an idle stream sends a comment, so proxies
+	// do not close it
+	streamKeepAlive = 15 * time.Second
+)
+
+// AccountEvents streams the events of an account as server-sent events. The
+// id of every message is the sequence of the last event sent, a client
+// reconnecting with it in Last-Event-ID, or last_event_id, gets what it
+// missed. Without it the stream starts at the events committed from now on.
+func (s *Server) AccountEvents() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		accountID, pErr := uuid.Parse(ctx.Param("accountID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		lastEventID := ctx.GetHeader(LastEventIDHeader)
+		if lastEventID == "" {
+			lastEventID = ctx.Query("last_event_id")
+		}
+		after := service.StreamFromHead
+		if lastEventID != "" {
+			var aErr error
+			if after, aErr = strconv.ParseInt(lastEventID, 10, 64); aErr != nil || after < 0 {
+				ctx.IndentedJSON(http.StatusBadRequest, gin.H{"error": "invalid last event id"})
+				return
+			}
+		}
+
+		messages, sErr := s.eventStream.Subscribe(ctx.Request.Context(), accountID, after)
+		if sErr != nil {
+			ctx.AbortWithStatus(errorStatus(sErr))
+			return
+		}
+
+		ctx.Header("Content-Type", "text/event-stream")
+		ctx.Header("Cache-Control", "no-cache")
+		ctx.Header("Connection", "keep-alive")
+		// proxies must not buffer the stream
+		ctx.Header("X-Accel-Buffering", "no")
+		ctx.Status(http.StatusOK)
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+		ctx.Stream(func(w io.Writer) bool {
+			select {
+			case message, ok := <-messages:
+				return ok && writeStreamMessage(w, message) == nil
+			case <-keepAlive.C:
+				_, err := io.WriteString(w, ": keep-alive\n\n")
+				return err == nil
+			}
+		})
+	}
+}
+
+// writeStreamMessage writes message as a server-sent event named after the
+// event type, or balance
+func writeStreamMessage(w io.Writer, message dto.AccountStreamMessage) error {
+	name, data := "balance", interface{}(message.Balance)
+	if message.Event != nil {
+		name, data = message.Event.Type, message.Event
+	}
+	content, mErr := json.Marshal(data)
+	if mErr != nil {
+		return mErr
+	}
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", message.Sequence, name, content)
+	return err
+}
@@ -0,0 +1,114 @@
+package app
+
+import (
+	"bank/pkg/api/dto"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (s *Server) CreateWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		var req dto.CreateWebhookRequest
+		bindErr := ctx.ShouldBindJSON(&req)
+		if bindErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest,
+				gin.H{"error": "fields validation failed",
+					"desc": fmt.Sprintf("deails %v", bindErr),
+				})
+			return
+		}
+		resp, wErr := s.webhookService.Subscribe(ctx.Request.Context(), req)
+		if wErr != nil {
+			ctx.AbortWithStatus(errorStatus(wErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusCreated, resp)
+	}
+}
+
+func (s *Server) ListWebhooks() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resp, lErr := s.webhookService.List(ctx.Request.Context())
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) GetWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		webhookID, pErr := uuid.Parse(ctx.Param("webhookID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, gErr := s.webhookService.Get(ctx.Request.Context(), webhookID)
+		if gErr != nil {
+			ctx.AbortWithStatus(errorStatus(gErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) DeleteWebhook() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		webhookID, pErr := uuid.Parse(ctx.Param("webhookID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		if dErr := s.webhookService.Unsubscribe(ctx.Request.Context(), webhookID); dErr != nil {
+			ctx.AbortWithStatus(errorStatus(dErr))
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	}
+}
+
+func (s *Server) WebhookDeliveries() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		webhookID, pErr := uuid.Parse(ctx.Param("webhookID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, lErr := s.webhookService.Deliveries(ctx.Request.Context(), webhookID)
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) WebhookDeadLetters() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		resp, lErr := s.webhookService.DeadLetters(ctx.Request.Context())
+		if lErr != nil {
+			ctx.AbortWithStatus(errorStatus(lErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
+
+func (s *Server) RetryWebhookDelivery() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		deliveryID, pErr := uuid.Parse(ctx.Param("deliveryID"))
+		if pErr != nil {
+			ctx.IndentedJSON(http.StatusBadRequest, pErr)
+			return
+		}
+		resp, rErr := s.webhookService.Retry(ctx.Request.Context(), deliveryID)
+		if rErr != nil {
+			ctx.AbortWithStatus(errorStatus(rErr))
+			return
+		}
+		ctx.IndentedJSON(http.StatusOK, resp)
+	}
+}
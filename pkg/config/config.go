@@ -0,0 +1,255 @@
+package config
+
+import (
+	"bank/pkg/api/model"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalid is returned by Load when a variable is set to something it can
+// not parse
+var ErrInvalid = errors.New("invalid config")
+
+// Timeouts holds the deadline applied to each route. A zero value means the
+// request only ends when the client goes away.
+type Timeouts struct {
+	Create   time.Duration
+	AddMoney time.Duration
+	Transfer time.Duration
+	Batch    time.Duration
+	Get      time.Duration
+	GetAll   time.Duration
+}
+
+const (
+	AccountStoreState  = "state"
+	AccountStoreEvents = "events"
+)
+
+// Database selects the sql driver (mysql, postgres or sqlite) and how to
+// connect to it
+type Database struct {
+	Driver string
+	DSN    string
+	// AccountStore is "state" to store accounts as they are, or "events" to
+	// store them as the events that changed them
+	AccountStore string
+	// SnapshotEvery is how many events of an account are stored between its
+	// snapshots when accounts are stored as events
+	SnapshotEvery int
+}
+
+type Config struct {
+	Database Database
+	Timeouts Timeouts
+	// SchedulerInterval is how often due scheduled transfers are looked for
+	SchedulerInterval time.Duration
+	// InterestInterval is how often interest accrual runs. Every run accrues
+	// the days missing up to yesterday, so it only needs to be under a day.
+	InterestInterval time.Duration
+	// HoldExpiryInterval is how often expired holds are released
+	HoldExpiryInterval time.Duration
+	// OutboxInterval is how often events waiting in the outbox are published
+	OutboxInterval time.Duration
+	// StreamPoll is how often account event streams look for events committed
+	// by other instances
+	StreamPoll time.Duration
+	// Locks configures the locks operations take on accounts
+	Locks Locks
+	// Bank identifies the bank in account statements
+	Bank Bank
+	// Fees configures the fees charged by the bank
+	Fees Fees
+	// LimitsFile holds the transfer and withdrawal limits in json, nothing is
+	// limited without it
+	LimitsFile string
+	// ScreeningFile holds the fraud rules transfers are screened with in
+	// json, nothing is screened without it
+	ScreeningFile string
+	// Sanctions configures the screening of names against sanctions lists
+	Sanctions Sanctions
+	// Webhooks configures the deliveries to webhooks
+	Webhooks Webhooks
+}
+
+type Locks struct {
+	// Stripes is how many stripes accounts are locked on, operations on
+	// accounts of different stripes run in parallel
+	Stripes int
+	// Queue is how many operations may wait on a stripe, more are refused.
+	// Zero lets them all wait.
+	Queue int
+}
+
+type Bank struct {
+	// ID is the routing number or code of the bank, at most 9 characters
+	ID string
+	// Currency of every account, as an ISO 4217 code
+	Currency string
+}
+
+type Fees struct {
+	// File holds the fee rules in json, no fees are charged without it
+	File string
+	// RevenueAccountID is the account collecting every fee
+	RevenueAccountID uuid.UUID
+	// MaintenanceInterval is how often pending maintenance fees are looked for
+	MaintenanceInterval time.Duration
+}
+
+type Sanctions struct {
+	// File holds the watchlist in csv or xml, no names are screened without it
+	File string
+	// Threshold is the similarity, from 0 to 1, from which a name is a hit
+	Threshold float64
+}
+
+type Webhooks struct {
+	// Interval is how often due deliveries are attempted
+	Interval time.Duration
+	// Timeout bounds every delivery attempt
+	Timeout time.Duration
+	// Retry tells how failed deliveries are tried again
+	Retry model.RetryPolicy
+}
+
+var defaultDSN = map[string]string{
+	"mysql":    "test:test@tcp(db:3306)/bank",
+	"postgres": "host=db user=test password=test dbname=bank port=5432 sslmode=disable",
+	"sqlite":   "bank.db",
+}
+
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Create:   5 * time.Second,
+		AddMoney: 5 * time.Second,
+		Transfer: 10 * time.Second,
+		Batch:    30 * time.Second,
+		Get:      2 * time.Second,
+		GetAll:   5 * time.Second,
+	}
+}
+
+// Load builds the config from environment variables, falling back to
+// defaults for anything not set. Every variable set to something it can not
+// parse is reported in the error.
+func Load() (Config, error) {
+	p := &parser{}
+	timeouts := DefaultTimeouts()
+	timeouts.Create = p.duration("TIMEOUT_CREATE", timeouts.Create)
+	timeouts.AddMoney = p.duration("TIMEOUT_ADD_MONEY", timeouts.AddMoney)
+	timeouts.Transfer = p.duration("TIMEOUT_TRANSFER", timeouts.Transfer)
+	timeouts.Batch = p.duration("TIMEOUT_BATCH", timeouts.Batch)
+	timeouts.Get = p.duration("TIMEOUT_GET", timeouts.Get)
+	timeouts.GetAll = p.duration("TIMEOUT_GET_ALL", timeouts.GetAll)
+
+	driver := env("DB_DRIVER", "mysql")
+
+	cfg := Config{
+		Database: Database{
+			Driver:        driver,
+			DSN:           env("DB_DSN", defaultDSN[driver]),
+			AccountStore:  env("ACCOUNT_STORE", AccountStoreState),
+			SnapshotEvery: int(p.number("SNAPSHOT_EVERY", 100)),
+		},
+		Timeouts:           timeouts,
+		SchedulerInterval:  p.duration("SCHEDULER_INTERVAL", 10*time.Second),
+		InterestInterval:   p.duration("INTEREST_INTERVAL", time.Hour),
+		HoldExpiryInterval: p.duration("HOLD_EXPIRY_INTERVAL", time.Minute),
+		OutboxInterval:     p.duration("OUTBOX_INTERVAL", time.Second),
+		StreamPoll:         p.duration("STREAM_POLL", 5*time.Second),
+		Locks: Locks{
+			Stripes: int(p.number("LOCK_STRIPES", 1024)),
+			Queue:   int(p.number("LOCK_QUEUE", 256)),
+		},
+		Bank: Bank{
+			ID:       env("BANK_ID", "000000000"),
+			Currency: env("BANK_CURRENCY", "EUR"),
+		},
+		LimitsFile:    env("LIMITS_FILE", ""),
+		ScreeningFile: env("SCREENING_FILE", ""),
+		Sanctions: Sanctions{
+			File:      env("SANCTIONS_FILE", ""),
+			Threshold: p.number("SANCTIONS_THRESHOLD", model.DefaultMatchThreshold),
+		},
+		Webhooks: Webhooks{
+			Interval: p.duration("WEBHOOK_INTERVAL", 5*time.Second),
+			Timeout:  p.duration("WEBHOOK_TIMEOUT", 10*time.Second),
+			Retry: model.RetryPolicy{
+				MaxAttempts: int(p.number("WEBHOOK_MAX_ATTEMPTS", float64(model.DefaultRetryPolicy.MaxAttempts))),
+				Backoff:     p.duration("WEBHOOK_BACKOFF", model.DefaultRetryPolicy.Backoff),
+				MaxBackoff:  p.duration("WEBHOOK_MAX_BACKOFF", model.DefaultRetryPolicy.MaxBackoff),
+			},
+		},
+		Fees: Fees{
+			File:                env("FEES_FILE", ""),
+			RevenueAccountID:    p.id("REVENUE_ACCOUNT_ID", uuid.MustParse("00000000-0000-0000-0000-000000000001")),
+			MaintenanceInterval: p.duration("MAINTENANCE_FEE_INTERVAL", time.Hour),
+		},
+	}
+	if len(p.invalid) > 0 {
+		return cfg, fmt.Errorf("%w: %s", ErrInvalid, strings.Join(p.invalid, ", "))
+	}
+	return cfg, nil
+}
+
+func env(key string, def string) string {
+	if val, ok := os.LookupEnv(key); ok {
+		return val
+	}
+	return def
+}
+
+// parser reads variables of the environment and keeps every one it could
+// not parse
+type parser struct {
+	invalid []string
+}
+
+func (p *parser) fail(key, val string, err error) {
+	p.invalid = append(p.invalid, fmt.Sprintf("%s=%q: %s", key, val, err))
+}
+
+func (p *parser) duration(key string, def time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		p.fail(key, val, err)
+		return def
+	}
+	return d
+}
+
+func (p *parser) number(key string, def float64) float64 {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		p.fail(key, val, err)
+		return def
+	}
+	return n
+}
+
+func (p *parser) id(key string, def uuid.UUID) uuid.UUID {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	id, err := uuid.Parse(val)
+	if err != nil {
+		p.fail(key, val, err)
+		return def
+	}
+	return id
+}
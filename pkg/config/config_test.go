@@ -0,0 +1,47 @@
+package config_test
+
+import (
+	"bank/pkg/config"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	t.Run("Given valid variables", func(t *testing.T) {
+		revenue := uuid.New()
+		t.Setenv("REVENUE_ACCOUNT_ID", revenue.String())
+		t.Setenv("TIMEOUT_GET", "3s")
+		t.Setenv("LOCK_STRIPES", "8")
+
+		t.Run("When the config is loaded", func(t *testing.T) {
+			cfg, err := config.Load()
+
+			t.Run("Then it holds their values", func(t *testing.T) {
+				require.NoError(t, err)
+				assert.Equal(t, revenue, cfg.Fees.RevenueAccountID)
+				assert.Equal(t, 3*time.Second, cfg.Timeouts.Get)
+				assert.Equal(t, 8, cfg.Locks.Stripes)
+			})
+		})
+	})
+
+	t.Run("Given variables that can not be parsed", func(t *testing.T) {
+		t.Setenv("REVENUE_ACCOUNT_ID", "revenue")
+		t.Setenv("TIMEOUT_GET", "3")
+		t.Setenv("LOCK_STRIPES", "eight")
+
+		t.Run("When the config is loaded", func(t *testing.T) {
+			_, err := config.Load()
+
+			t.Run("Then every one of them is reported", func(t *testing.T) {
+				assert.ErrorIs(t, err, config.ErrInvalid)
+				assert.Contains(t, err.Error(), "REVENUE_ACCOUNT_ID")
+				assert.Contains(t, err.Error(), "TIMEOUT_GET")
+				assert.Contains(t, err.Error(), "LOCK_STRIPES")
+			})
+		})
+	})
+}